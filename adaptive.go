@@ -0,0 +1,215 @@
+package trigger
+
+import (
+	"sync"
+	"time"
+)
+
+// 自适应并发控制配置, 按加性增、乘性减调整事件同时分发的上限
+type AdaptiveConcurrency struct {
+	// 初始上限, 默认为10
+	Initial int
+	// 最小上限, 默认为1
+	Min int
+	// 最大上限, 默认为1000
+	Max int
+	// 目标延迟, 窗口内平均分发耗时超过时视为过载, 0表示不检查
+	TargetLatency time.Duration
+	// 错误率上限, 窗口内失败的分发比例超过时视为过载, 0表示不检查
+	MaxErrorRate float64
+	// 过载时上限乘以的系数, 默认为0.5
+	Backoff float64
+	// 调整周期, 默认为1秒
+	Window time.Duration
+}
+
+// 自适应并发控制的当前状态
+type ConcurrencyStats struct {
+	// 当前上限
+	Limit int `json:"limit"`
+	// 正在分发的数量
+	InFlight int `json:"in_flight"`
+	// 累计因超出上限被拒绝的触发次数
+	Rejected uint64 `json:"rejected"`
+}
+
+// 事件类型 -> 自适应并发控制, 写入时复制, 触发时无需加锁即可读取
+type limiterTable map[interface{}]*adaptiveLimiter
+
+// 单个事件的自适应并发控制
+type adaptiveLimiter struct {
+	// 保护以下字段
+	mu sync.Mutex
+	// 配置
+	config AdaptiveConcurrency
+	// 当前上限
+	limit int
+	// 正在分发的数量
+	inFlight int
+	// 累计拒绝次数
+	rejected uint64
+	// 当前窗口的开始时间
+	windowStart time.Time
+	// 当前窗口的分发次数
+	samples int
+	// 当前窗口的失败次数
+	failures int
+	// 当前窗口的总耗时
+	latency time.Duration
+}
+
+//***************************************************
+//Description : 开启事件的自适应并发控制, 同时分发的数量超过上限时拒绝触发并报告包装ErrConcurrencyLimit的DispatchError
+//              每个窗口结束时按平均耗时与错误率调整上限: 过载时乘以Backoff, 否则加1, 用于自动保护下游依赖
+//              同步触发的耗时为全部监听之和, 异步触发为最慢的监听
+//param :       事件类型
+//param :       配置
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithAdaptiveConcurrency(event interface{}, config AdaptiveConcurrency) *Trigger {
+	if config.Min <= 0 {
+		config.Min = 1
+	}
+	if config.Max <= 0 {
+		config.Max = 1000
+	}
+	if config.Max < config.Min {
+		config.Max = config.Min
+	}
+	if config.Initial <= 0 {
+		config.Initial = 10
+	}
+	if config.Initial < config.Min {
+		config.Initial = config.Min
+	}
+	if config.Initial > config.Max {
+		config.Initial = config.Max
+	}
+	if config.Backoff <= 0 || config.Backoff >= 1 {
+		config.Backoff = 0.5
+	}
+	if config.Window <= 0 {
+		config.Window = time.Second
+	}
+
+	limiter := &adaptiveLimiter{config: config, limit: config.Initial, windowStart: time.Now()}
+	trigger.storeLimiter(event, limiter)
+	return trigger
+}
+
+//***************************************************
+//Description : 关闭事件的自适应并发控制
+//param :       事件类型
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveAdaptiveConcurrency(event interface{}) *Trigger {
+	trigger.storeLimiter(event, nil)
+	return trigger
+}
+
+//***************************************************
+//Description : 获取事件自适应并发控制的当前状态
+//param :       事件类型
+//return :      状态
+//return :      是否开启
+//***************************************************
+func (trigger *Trigger) ConcurrencyStats(event interface{}) (ConcurrencyStats, bool) {
+	limiter := trigger.limiterOf(event)
+	if nil == limiter {
+		return ConcurrencyStats{}, false
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	return ConcurrencyStats{Limit: limiter.limit, InFlight: limiter.inFlight, Rejected: limiter.rejected}, true
+}
+
+//***************************************************
+//Description : 复制并替换事件的自适应并发控制
+//param :       事件类型
+//param :       自适应并发控制, nil表示删除
+//***************************************************
+func (trigger *Trigger) storeLimiter(event interface{}, limiter *adaptiveLimiter) {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	next := make(limiterTable)
+	if current := trigger.limiters.Load(); nil != current {
+		for key, value := range *current {
+			next[key] = value
+		}
+	}
+	if nil == limiter {
+		delete(next, event)
+	} else {
+		next[event] = limiter
+	}
+	trigger.limiters.Store(&next)
+}
+
+//***************************************************
+//Description : 获取事件的自适应并发控制
+//param :       事件类型
+//return :      自适应并发控制, 未开启时为nil
+//***************************************************
+func (trigger *Trigger) limiterOf(event interface{}) *adaptiveLimiter {
+	table := trigger.limiters.Load()
+	if nil == table {
+		return nil
+	}
+	return (*table)[event]
+}
+
+//***************************************************
+//Description : 占用一个分发名额
+//return :      是否未超出上限
+//***************************************************
+func (limiter *adaptiveLimiter) acquire() bool {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	if limiter.inFlight >= limiter.limit {
+		limiter.rejected++
+		return false
+	}
+	limiter.inFlight++
+	return true
+}
+
+//***************************************************
+//Description : 释放分发名额并记录结果, 窗口结束时调整上限
+//param :       分发耗时
+//param :       是否有监听失败
+//***************************************************
+func (limiter *adaptiveLimiter) release(latency time.Duration, failed bool) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	limiter.inFlight--
+	limiter.samples++
+	limiter.latency += latency
+	if failed {
+		limiter.failures++
+	}
+
+	now := time.Now()
+	if now.Sub(limiter.windowStart) < limiter.config.Window {
+		return
+	}
+	config := limiter.config
+	average := limiter.latency / time.Duration(limiter.samples)
+	errorRate := float64(limiter.failures) / float64(limiter.samples)
+	if (config.TargetLatency > 0 && average > config.TargetLatency) || (config.MaxErrorRate > 0 && errorRate > config.MaxErrorRate) {
+		limiter.limit = int(float64(limiter.limit) * config.Backoff)
+	} else {
+		limiter.limit++
+	}
+	if limiter.limit < config.Min {
+		limiter.limit = config.Min
+	}
+	if limiter.limit > config.Max {
+		limiter.limit = config.Max
+	}
+	limiter.windowStart, limiter.samples, limiter.failures, limiter.latency = now, 0, 0, 0
+}
@@ -0,0 +1,113 @@
+package trigger
+
+import (
+	"sync"
+	"time"
+)
+
+// 一次触发通过分发前置检查后的状态, Emit、EmitSync与EmitGroup共用, 分发结束后调用finish释放
+type admission struct {
+	lineage  *Lineage
+	handlers []*handler    // 需要执行的监听, 包含影子监听
+	trace    *emitTrace    // 调试记录, 未开启时为nil
+	epoch    epochTicket   // 进入的读取周期
+	after    func()        // 分发后钩子
+	shed     *shedder      // 过载保护
+	shedAt   time.Time     // 开始分发的时间
+	budget   *memoryBudget // 内存预算
+	size     int64         // 占用的内存预算
+	limiter  *adaptiveLimiter
+	limitAt  time.Time   // 取得并发名额的时间
+	mutex    *sync.Mutex // 互斥组
+	failed   bool        // 是否有监听执行失败, 用于自适应并发上限
+	rejected error       // 没有分发的原因, 由EmitGroup交给协程组
+}
+
+//***************************************************
+//Description : 触发前的公共检查: 统计触发, 进入读取周期, 过期检查, 获取监听, 调试记录, 分发钩子, 过载保护, 内存预算, 自适应并发上限与互斥组
+//              无论能否分发, 都需在结束时调用返回值的finish
+//param :       事件类型
+//param :       回调函数中的参数, 需已展开延迟参数
+//param :       是否为同步触发
+//return :      检查后的状态
+//return :      是否有监听需要分发
+//***************************************************
+func (trigger *Trigger) admit(event interface{}, arguments []interface{}, sync bool) (admission, bool) {
+	var a admission
+	trigger.inFlight.Add(1)
+	a.epoch = trigger.enterEpoch(event)
+	a.lineage = trigger.currentLineage()
+	if expired(a.lineage.deadline()) {
+		trigger.reportExpired(Expiry{Event: event, Arguments: arguments, Deadline: a.lineage.Deadline})
+		a.rejected = &DispatchError{Event: event, Err: ErrExpired}
+		return a, false
+	}
+
+	// 获取此事件需要执行的监听者数组,如果为空则直接返回
+	a.handlers = trigger.dispatchable(event, arguments, a.lineage)
+	if tracer := trigger.tracer.Load(); nil != tracer {
+		a.trace = tracer.begin(trigger, event, arguments, a.handlers, sync)
+	}
+	if hooks := trigger.hooks.Load(); nil != hooks {
+		a.after = hooks.dispatch(event, trigger.redact(event, arguments), len(a.handlers), sync, trigger.caller())
+	}
+	if 0 == len(a.handlers) {
+		return a, false
+	}
+	// 过载时丢弃尽力而为的事件, 并记录分发耗时用于判断是否过载
+	if s := trigger.shedding.Load(); nil != s {
+		if trigger.shouldShed(s, event) {
+			a.rejected = &DispatchError{Event: event, Err: ErrShed}
+			return a, false
+		}
+		a.shed, a.shedAt = s, time.Now()
+	}
+	// 超出内存预算时丢弃或拒绝, 触发结束后归还
+	if budget := trigger.memory.Load(); nil != budget {
+		size, err := trigger.reserve(budget, event, arguments)
+		if nil != err {
+			a.rejected = err
+			return a, false
+		}
+		a.budget, a.size = budget, size
+	}
+	// 超出自适应并发上限时拒绝, 记录本次分发的耗时与结果
+	if limiter := trigger.limiterOf(event); nil != limiter {
+		if !limiter.acquire() {
+			a.rejected = &DispatchError{Event: event, Err: ErrConcurrencyLimit}
+			trigger.report(event, nil, a.rejected)
+			return a, false
+		}
+		a.limiter, a.limitAt = limiter, time.Now()
+	}
+	// 同一互斥组的触发依次分发
+	if mutex := trigger.mutexOf(event); nil != mutex {
+		mutex.Lock()
+		a.mutex = mutex
+	}
+	return a, true
+}
+
+//***************************************************
+//Description : 分发结束, 按获取的相反顺序释放admit占用的资源
+//param :       事件触发器
+//***************************************************
+func (a *admission) finish(trigger *Trigger) {
+	if nil != a.mutex {
+		a.mutex.Unlock()
+	}
+	if nil != a.limiter {
+		a.limiter.release(time.Since(a.limitAt), a.failed)
+	}
+	if nil != a.budget {
+		a.budget.used.Add(-a.size)
+	}
+	if nil != a.shed {
+		a.shed.observe(a.shedAt)
+	}
+	if nil != a.after {
+		a.after()
+	}
+	trigger.leaveEpoch(a.epoch)
+	trigger.inFlight.Add(-1)
+}
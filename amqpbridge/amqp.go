@@ -0,0 +1,229 @@
+// amqpbridge 通过RabbitMQ管理插件的HTTP接口把本地事件发布到交换机, 并把队列中的消息在本地触发, 只依赖net/http
+//
+// 消息体为JSON, 事件名称同时写入消息头event, 路由键默认为事件名称:
+//
+//	{"event":"order.paid","args":[1,"a"]}
+//
+// 发布确认: 管理接口返回消息是否被路由到队列, 没有路由时发布失败, 对应publisher confirms中的mandatory退回
+// 消费确认: 管理接口只能在取出消息时确认, 不支持手动确认, 因此消费为至多一次: 进程在取出与处理完成之间退出时消息丢失
+// 监听全部成功才算消费完成, 失败时把原消息重新发布到队列末尾并记录次数, 超出次数上限后发布到死信交换机
+// 格式错误的消息不重试, 直接发布到死信交换机或丢弃, 需要至少一次投递时应使用支持手动确认的AMQP客户端
+// 拓扑可以从JSON配置声明, 见Topology
+// 与wsbridge相同, 发布方开启WithInheritance时触发的截止时间与经过的节点写入消息的deadline与hops, 配置Config.Node后origin为本节点
+// 接收方携带截止时间触发, 已过期的触发由触发器丢弃并报告, 已经过本节点或超出Config.MaxHops的消息不触发, 两者都直接丢弃而不重试
+//
+//	{"event":"order.paid","args":[1],"deadline":1700000000000,"hops":["node-a"],"origin":"node-a"}
+package amqpbridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 单个响应体的大小上限
+const maxBodySize = 4 << 20
+
+// 消息体
+type Message struct {
+	// 事件名称
+	Event string `json:"event"`
+	// 参数
+	Args []json.RawMessage `json:"args"`
+	// 截止时间, Unix毫秒, 0表示没有
+	Deadline int64 `json:"deadline,omitempty"`
+	// 经过的节点, 用于环路检测
+	Hops []string `json:"hops,omitempty"`
+	// 发送方节点
+	Origin string `json:"origin,omitempty"`
+}
+
+// 管理接口返回的错误
+type Error struct {
+	// HTTP状态码
+	Status int `json:"-"`
+	// 错误类型
+	Type string `json:"error"`
+	// 错误原因
+	Reason string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("RabbitMQ错误[%d/%s]: %s", e.Status, e.Type, e.Reason)
+}
+
+// 访问配置
+type Config struct {
+	// 管理接口地址, 如http://localhost:15672
+	URL string
+	// 虚拟主机, 为空时为/
+	VHost string
+	// 用户名与密码
+	Username string
+	Password string
+	// HTTP客户端, 为nil时使用http.DefaultClient
+	HTTP *http.Client
+	// 本节点名称, 为空表示不做环路检测
+	Node string
+	// 触发最多经过的节点数, 超出时丢弃, 0表示不限
+	MaxHops int
+}
+
+//***************************************************
+//Description : 编码消息体, 参数按触发器的结构体标签与脱敏函数脱敏
+//              发布方协程的截止时间与经过的节点写入消息, 需开启WithInheritance
+//param :       触发器, nil时只按结构体标签脱敏且不携带截止时间
+//param :       事件名称
+//param :       参数
+//return :      JSON
+//return :      参数编码失败的错误
+//***************************************************
+func (config *Config) encodeMessage(t *trigger.Trigger, event string, arguments []interface{}) (string, error) {
+	arguments = t.RedactArguments(event, arguments)
+	message := Message{Event: event, Args: make([]json.RawMessage, len(arguments)), Origin: config.Node}
+	var lineage trigger.Lineage
+	if nil != t {
+		lineage, _ = t.Lineage()
+	}
+	if !lineage.Deadline.IsZero() {
+		message.Deadline = lineage.Deadline.UnixMilli()
+	}
+	message.Hops = config.via(lineage.Hops)
+	for i, argument := range arguments {
+		raw, err := json.Marshal(argument)
+		if nil != err {
+			return "", fmt.Errorf("第%d个参数编码失败: %w", i+1, err)
+		}
+		message.Args[i] = raw
+	}
+	encoded, err := json.Marshal(message)
+	return string(encoded), err
+}
+
+//***************************************************
+//Description : 在本地通过协程组触发收到的消息, 携带消息的截止时间与经过的节点, 已过期的触发由触发器丢弃并报告
+//param :       本地触发器
+//param :       协程组
+//param :       消息
+//param :       参数
+//return :      是否触发, 已经过本节点或超出最大跳数时丢弃
+//***************************************************
+func (config *Config) emit(t *trigger.Trigger, g *trigger.ListenerGroup, message Message, arguments []interface{}) bool {
+	if config.looped(message.Hops) {
+		return false
+	}
+	hops := config.via(message.Hops)
+	if 0 == message.Deadline && 0 == len(hops) {
+		t.EmitGroup(g, message.Event, arguments...)
+		return true
+	}
+	lineage := trigger.Lineage{Hops: hops}
+	if 0 != message.Deadline {
+		lineage.Deadline = time.UnixMilli(message.Deadline)
+	}
+	t.EmitGroupWith(g, lineage, message.Event, arguments...)
+	return true
+}
+
+//***************************************************
+//Description : 是否已经过本节点或超出最大跳数
+//param :       经过的节点
+//return :      是否形成环路
+//***************************************************
+func (config *Config) looped(hops []string) bool {
+	if config.MaxHops > 0 && len(hops) > config.MaxHops {
+		return true
+	}
+	if "" == config.Node {
+		return false
+	}
+	for _, hop := range hops {
+		if config.Node == hop {
+			return true
+		}
+	}
+	return false
+}
+
+//***************************************************
+//Description : 追加本节点
+//param :       经过的节点
+//return :      追加后的节点
+//***************************************************
+func (config *Config) via(hops []string) []string {
+	if "" == config.Node || config.looped(hops) {
+		return hops
+	}
+	return append(hops[:len(hops):len(hops)], config.Node)
+}
+
+//***************************************************
+//Description : 资源路径, 名称按路径段转义
+//param :       资源类型, 如exchanges
+//param :       名称, 依次拼接
+//return :      路径
+//***************************************************
+func (config *Config) path(kind string, names ...string) string {
+	vhost := config.VHost
+	if "" == vhost {
+		vhost = "/"
+	}
+	path := "/api/" + kind + "/" + url.PathEscape(vhost)
+	for _, name := range names {
+		path += "/" + url.PathEscape(name)
+	}
+	return path
+}
+
+//***************************************************
+//Description : 调用管理接口
+//param :       上下文
+//param :       HTTP方法
+//param :       路径
+//param :       请求体
+//param :       响应的解码目标, nil表示忽略
+//return :      请求失败或接口返回错误时的错误, 后者为*Error
+//***************************************************
+func (config *Config) call(ctx context.Context, method, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if nil != err {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, config.URL+path, bytes.NewReader(body))
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(config.Username, config.Password)
+
+	client := config.HTTP
+	if nil == client {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if nil != err {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		failure := &Error{Status: resp.StatusCode, Reason: resp.Status}
+		json.Unmarshal(data, failure)
+		return failure
+	}
+	if nil == out || 0 == len(data) {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
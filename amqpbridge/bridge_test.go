@@ -0,0 +1,235 @@
+package amqpbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+type order struct {
+	ID    int    `json:"id"`
+	Token string `json:"token" trigger:"redact"`
+}
+
+// 测试用的RabbitMQ管理接口, 绑定键以*结尾时按前缀匹配
+type fakeRabbit struct {
+	mu        sync.Mutex
+	exchanges map[string]string
+	queues    map[string][]delivery
+	bindings  map[string][][2]string
+}
+
+func (f *fakeRabbit) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if user, password, _ := req.BasicAuth(); "guest" != user || "guest" != password {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"not_authorised","reason":"Login failed"}`))
+		return
+	}
+	path := strings.Split(strings.TrimPrefix(req.URL.EscapedPath(), "/api/"), "/")
+	if "%2F" != path[1] {
+		http.NotFound(w, req)
+		return
+	}
+	var in map[string]json.RawMessage
+	json.NewDecoder(req.Body).Decode(&in)
+	switch {
+	case "exchanges" == path[0] && 3 == len(path):
+		var kind string
+		json.Unmarshal(in["type"], &kind)
+		f.exchanges[path[2]] = kind
+		w.WriteHeader(http.StatusCreated)
+	case "queues" == path[0] && 3 == len(path):
+		if _, ok := f.queues[path[2]]; !ok {
+			f.queues[path[2]] = nil
+		}
+		w.WriteHeader(http.StatusCreated)
+	case "bindings" == path[0]:
+		var key string
+		json.Unmarshal(in["routing_key"], &key)
+		f.bindings[path[3]] = append(f.bindings[path[3]], [2]string{path[5], key})
+		w.WriteHeader(http.StatusCreated)
+	case "exchanges" == path[0] && "publish" == path[3]:
+		var message publishing
+		encoded, _ := json.Marshal(in)
+		json.Unmarshal(encoded, &message)
+		var routed []string
+		if "amq.default" == path[2] {
+			if _, ok := f.queues[message.RoutingKey]; ok {
+				routed = append(routed, message.RoutingKey)
+			}
+		}
+		for _, binding := range f.bindings[path[2]] {
+			if binding[1] == message.RoutingKey || (strings.HasSuffix(binding[1], "*") && strings.HasPrefix(message.RoutingKey, strings.TrimSuffix(binding[1], "*"))) {
+				routed = append(routed, binding[0])
+			}
+		}
+		for _, queue := range routed {
+			f.queues[queue] = append(f.queues[queue], delivery{Payload: message.Payload, PayloadEncoding: "string",
+				RoutingKey: message.RoutingKey, Exchange: path[2], Properties: message.Properties})
+		}
+		json.NewEncoder(w).Encode(map[string]bool{"routed": 0 != len(routed)})
+	case "queues" == path[0] && "get" == path[3]:
+		var count int
+		json.Unmarshal(in["count"], &count)
+		queue := f.queues[path[2]]
+		if count > len(queue) {
+			count = len(queue)
+		}
+		json.NewEncoder(w).Encode(queue[:count])
+		f.queues[path[2]] = queue[count:]
+	}
+}
+
+func (f *fakeRabbit) depth(queue string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queues[queue])
+}
+
+func TestBridge(t *testing.T) {
+	fake := &fakeRabbit{exchanges: map[string]string{}, queues: map[string][]delivery{}, bindings: map[string][][2]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	config := Config{URL: server.URL, Username: "guest", Password: "guest"}
+
+	t.Log("测试从配置声明拓扑")
+	topology, err := LoadTopology([]byte(`{
+		"exchanges":[{"name":"orders","durable":true},{"name":"dead","type":"fanout"}],
+		"queues":[{"name":"billing","durable":true},{"name":"parking"}],
+		"bindings":[{"exchange":"orders","queue":"billing","routing_key":"order.*"},{"exchange":"dead","queue":"parking","routing_key":"order.*"}]}`))
+	if nil != err {
+		t.Fatalf("加载拓扑失败: %v", err)
+	}
+	if err := topology.Declare(context.Background(), config); nil != err || "topic" != fake.exchanges["orders"] || 1 != len(fake.bindings["orders"]) {
+		t.Fatalf("声明拓扑失败: %v %v", err, fake.exchanges)
+	}
+	if _, err := LoadTopology([]byte(`{"queues":[{}]}`)); nil == err {
+		t.Fatalf("缺少名称时应报错")
+	}
+
+	t.Log("测试发布确认")
+	local := trigger.NewTrigger()
+	publisher := &Publisher{Config: config, Exchange: "orders", Persistent: true}
+	publisher.Attach(local, "order.paid")
+	local.EmitSync("order.paid", order{ID: 1, Token: "secret"})
+	if 1 != fake.depth("billing") || strings.Contains(fake.queues["billing"][0].Payload, "secret") || 2 != fake.queues["billing"][0].Properties.DeliveryMode {
+		t.Fatalf("发布失败: %d", fake.depth("billing"))
+	}
+	if err := publisher.Publish(context.Background(), "invoice.sent"); !errors.Is(err, ErrUnroutable) {
+		t.Fatalf("没有路由时应发布失败: %v", err)
+	}
+
+	t.Log("测试失败的消息重新发布, 成功后不再投递")
+	var (
+		mu       sync.Mutex
+		received []int
+		attempts int32
+	)
+	remote := trigger.NewTrigger().WithCoercion(true).
+		On("order.paid", func(o order) error {
+			if 1 == o.ID && 1 == atomic.AddInt32(&attempts, 1) {
+				return errors.New("暂时失败")
+			}
+			if 2 == o.ID {
+				return errors.New("永久失败")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, o.ID)
+			return nil
+		})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var malformed atomic.Value
+	consumer := &Consumer{Config: config, Queue: "billing", PollInterval: 5 * time.Millisecond, MaxAttempts: 3, DeadLetterExchange: "dead",
+		OnError: func(err error) { malformed.Store(err) }}
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- consumer.Run(ctx, remote)
+	}()
+
+	t.Log("测试达到次数上限后转入死信交换机")
+	local.EmitSync("order.paid", order{ID: 2})
+	deadline := time.Now().Add(5 * time.Second)
+	for (0 != fake.depth("billing") || 0 == fake.depth("parking")) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	if 1 != len(received) || 1 != received[0] || 2 != atomic.LoadInt32(&attempts) {
+		t.Fatalf("消费错误: %v %d", received, attempts)
+	}
+	mu.Unlock()
+	fake.mu.Lock()
+	if 1 != len(fake.queues["parking"]) || 3.0 != fake.queues["parking"][0].Properties.Headers[attemptsHeader] || "order.paid" != fake.queues["parking"][0].RoutingKey {
+		t.Fatalf("死信转移错误: %+v", fake.queues["parking"])
+	}
+	fake.mu.Unlock()
+
+	t.Log("测试格式错误的消息不重试, 直接转入死信交换机")
+	fake.mu.Lock()
+	fake.queues["billing"] = append(fake.queues["billing"], delivery{Payload: `{"args":[3]}`, PayloadEncoding: "string", RoutingKey: "order.paid"})
+	fake.mu.Unlock()
+	for 2 != fake.depth("parking") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	fake.mu.Lock()
+	if 2 != len(fake.queues["parking"]) || 1.0 != fake.queues["parking"][1].Properties.Headers[attemptsHeader] || !errors.Is(malformed.Load().(error), ErrMalformed) {
+		t.Fatalf("格式错误的消息转移错误: %+v %v", fake.queues["parking"], malformed.Load())
+	}
+	fake.mu.Unlock()
+
+	cancel()
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Fatalf("退出错误: %v", err)
+	}
+}
+
+func TestLineage(t *testing.T) {
+	t.Log("测试发布时写入截止时间与经过的节点")
+	deadline := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	config := &Config{Node: "node-b"}
+	var message Message
+	local := trigger.NewTrigger().WithInheritance(true)
+	local.On("order.paid", func(id int) {
+		encoded, _ := config.encodeMessage(local, "order.paid", []interface{}{id})
+		json.Unmarshal([]byte(encoded), &message)
+	})
+	local.EmitSyncWith(trigger.Lineage{Deadline: deadline, Hops: []string{"node-a"}}, "order.paid", 1)
+	if deadline.UnixMilli() != message.Deadline || "[node-a node-b]" != fmt.Sprint(message.Hops) || "node-b" != message.Origin {
+		t.Fatalf("消息的继承属性错误: %+v", message)
+	}
+
+	t.Log("测试接收时丢弃环路与过期的触发")
+	var received []time.Time
+	remote := trigger.NewTrigger().WithInheritance(true)
+	remote.On("order.paid", func() {
+		lineage, _ := remote.Lineage()
+		received = append(received, lineage.Deadline)
+	})
+	g := new(trigger.ListenerGroup)
+	if config.emit(remote, g, message, nil) {
+		t.Fatalf("已经过本节点的消息不应触发")
+	}
+	message.Hops = []string{"node-a"}
+	if !config.emit(remote, g, message, nil) || nil != g.Wait() || 1 != len(received) || !deadline.Equal(received[0]) {
+		t.Fatalf("消息未触发: %v", received)
+	}
+	message.Deadline = time.Now().Add(-time.Second).UnixMilli()
+	g = new(trigger.ListenerGroup)
+	if !config.emit(remote.RecoverWith(func(event, listener interface{}, err error) {}), g, message, nil) || !errors.Is(g.Wait(), trigger.ErrExpired) || 1 != len(received) {
+		t.Fatalf("过期的消息应丢弃: %v", received)
+	}
+}
@@ -0,0 +1,210 @@
+package amqpbridge
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 默认配置
+const (
+	defaultBatchSize    = 10
+	defaultPollInterval = time.Second
+)
+
+// 消息不是有效的消息体, 不重试
+var ErrMalformed = errors.New("消息格式错误")
+
+// 重新发布时记录的消息头
+const (
+	// 执行失败次数
+	attemptsHeader = "x-trigger-attempts"
+	// 第一次发布时的路由键, 转入死信交换机时使用
+	routingKeyHeader = "x-trigger-routing-key"
+)
+
+// 取出的消息
+type delivery struct {
+	Payload         string     `json:"payload"`
+	PayloadEncoding string     `json:"payload_encoding"`
+	RoutingKey      string     `json:"routing_key"`
+	Exchange        string     `json:"exchange"`
+	Properties      properties `json:"properties"`
+}
+
+// 消费队列并在本地触发
+type Consumer struct {
+	Config
+	// 队列名称
+	Queue string
+	// 每次取出的消息数量上限, 为0时为10
+	BatchSize int
+	// 队列为空时的等待时间, 为0时为1秒
+	PollInterval time.Duration
+	// 失败次数上限, 达到后发布到死信交换机, 为0时不限制
+	MaxAttempts int
+	// 死信交换机, 为空时达到上限与格式错误的消息丢弃并报告
+	DeadLetterExchange string
+	// 取出、重新发布或消息格式错误时的回调, 为nil时忽略
+	OnError func(err error)
+}
+
+//***************************************************
+//Description : 持续取出消息并在本地触发, 同一批消息并发处理, 处理完一批再取下一批
+//              监听通过EmitGroup执行, 失败时把原消息重新发布到此队列, 达到次数上限后发布到死信交换机
+//              消息在取出时即被确认, 为至多一次消费, 格式错误的消息不重试
+//param :       上下文, 取消时退出
+//param :       本地触发器
+//return :      上下文的错误
+//***************************************************
+func (c *Consumer) Run(ctx context.Context, t *trigger.Trigger) error {
+	interval := c.PollInterval
+	if 0 == interval {
+		interval = defaultPollInterval
+	}
+	for {
+		deliveries, err := c.get(ctx)
+		if nil != ctx.Err() {
+			return ctx.Err()
+		}
+		if nil != err {
+			c.fail(err)
+		}
+		if 0 == len(deliveries) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(interval):
+			}
+			continue
+		}
+
+		var wg sync.WaitGroup
+		for _, d := range deliveries {
+			wg.Add(1)
+			go func(d delivery) {
+				defer wg.Done()
+				c.handle(ctx, t, d)
+			}(d)
+		}
+		wg.Wait()
+	}
+}
+
+//***************************************************
+//Description : 处理一条消息
+//param :       上下文
+//param :       本地触发器
+//param :       消息
+//***************************************************
+func (c *Consumer) handle(ctx context.Context, t *trigger.Trigger, d delivery) {
+	payload := []byte(d.Payload)
+	if "base64" == d.PayloadEncoding {
+		decoded, err := base64.StdEncoding.DecodeString(d.Payload)
+		if nil != err {
+			c.fail(fmt.Errorf("%w: 解码失败: %v", ErrMalformed, err))
+			c.retry(ctx, d, payload, true)
+			return
+		}
+		payload = decoded
+	}
+	var message Message
+	if err := json.Unmarshal(payload, &message); nil != err {
+		c.fail(fmt.Errorf("%w: %v", ErrMalformed, err))
+		c.retry(ctx, d, payload, true)
+		return
+	}
+	if "" == message.Event {
+		c.fail(fmt.Errorf("%w: 缺少事件名称", ErrMalformed))
+		c.retry(ctx, d, payload, true)
+		return
+	}
+	arguments := make([]interface{}, len(message.Args))
+	for i, arg := range message.Args {
+		arguments[i] = arg
+	}
+
+	// 已经过本节点的消息与已过期的触发不再重试
+	g := new(trigger.ListenerGroup)
+	if !c.emit(t, g, message, arguments) {
+		return
+	}
+	if err := g.Wait(); nil != err && !errors.Is(err, trigger.ErrExpired) {
+		c.retry(ctx, d, payload, false)
+	}
+}
+
+//***************************************************
+//Description : 重新发布失败的消息, 达到次数上限后发布到死信交换机
+//param :       上下文
+//param :       消息
+//param :       解码后的消息体
+//param :       是否不再重试, 格式错误的消息直接发布到死信交换机
+//***************************************************
+func (c *Consumer) retry(ctx context.Context, d delivery, payload []byte, final bool) {
+	// 重新发布不因退出而中断, 避免已取出的消息丢失
+	ctx = context.WithoutCancel(ctx)
+	headers := make(map[string]interface{}, len(d.Properties.Headers)+1)
+	for name, value := range d.Properties.Headers {
+		headers[name] = value
+	}
+	attempts := 1
+	if previous, ok := headers[attemptsHeader].(float64); ok {
+		attempts = int(previous) + 1
+	}
+	headers[attemptsHeader] = attempts
+	if _, ok := headers[routingKeyHeader]; !ok {
+		headers[routingKeyHeader] = d.RoutingKey
+	}
+	message := publishing{Properties: d.Properties, Payload: string(payload), PayloadEncoding: "string"}
+	message.Properties.Headers = headers
+
+	exchange := ""
+	message.RoutingKey = c.Queue
+	if final || (0 != c.MaxAttempts && attempts >= c.MaxAttempts) {
+		if "" == c.DeadLetterExchange {
+			c.fail(fmt.Errorf("消息失败%d次, 已丢弃: %s", attempts, payload))
+			return
+		}
+		exchange, message.RoutingKey = c.DeadLetterExchange, fmt.Sprint(headers[routingKeyHeader])
+	}
+	if err := publish(ctx, c.Config, exchange, message); nil != err {
+		c.fail(fmt.Errorf("重新发布消息失败: %w", err))
+	}
+}
+
+//***************************************************
+//Description : 取出一批消息, 取出时即由服务端确认, 之后进程退出会丢失这批消息
+//param :       上下文
+//return :      消息
+//return :      取出失败的错误
+//***************************************************
+func (c *Consumer) get(ctx context.Context) ([]delivery, error) {
+	count := c.BatchSize
+	if 0 == count {
+		count = defaultBatchSize
+	}
+	in := map[string]interface{}{"count": count, "ackmode": "ack_requeue_false", "encoding": "auto"}
+	var out []delivery
+	if err := c.call(ctx, http.MethodPost, c.path("queues", c.Queue, "get"), in, &out); nil != err {
+		return nil, err
+	}
+	return out, nil
+}
+
+//***************************************************
+//Description : 报告错误
+//param :       错误
+//***************************************************
+func (c *Consumer) fail(err error) {
+	if nil != c.OnError {
+		c.OnError(err)
+	}
+}
@@ -0,0 +1,125 @@
+package amqpbridge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/yann1989/trigger"
+)
+
+// 消息没有路由到任何队列
+var ErrUnroutable = errors.New("消息没有路由到任何队列")
+
+// 消息属性
+type properties struct {
+	DeliveryMode int                    `json:"delivery_mode,omitempty"`
+	ContentType  string                 `json:"content_type,omitempty"`
+	Headers      map[string]interface{} `json:"headers,omitempty"`
+}
+
+// 发布请求
+type publishing struct {
+	Properties      properties `json:"properties"`
+	RoutingKey      string     `json:"routing_key"`
+	Payload         string     `json:"payload"`
+	PayloadEncoding string     `json:"payload_encoding"`
+}
+
+// 将本地事件发布到交换机
+type Publisher struct {
+	Config
+	// 交换机名称
+	Exchange string
+	// 事件的路由键, 为nil时为事件名称
+	RoutingKey func(event string, arguments []interface{}) string
+	// 是否发布为持久消息
+	Persistent bool
+	// Attach发布失败时的回调, 为nil时忽略
+	OnError func(event string, err error)
+}
+
+//***************************************************
+//Description : 发布事件并等待确认
+//param :       上下文
+//param :       事件名称
+//param :       参数
+//return :      编码或发布失败的错误, 没有路由到队列时为ErrUnroutable
+//***************************************************
+func (p *Publisher) Publish(ctx context.Context, event string, arguments ...interface{}) error {
+	return p.publish(ctx, nil, event, arguments)
+}
+
+//***************************************************
+//Description : 发布事件并等待确认, 参数按触发器的脱敏配置脱敏
+//param :       上下文
+//param :       触发器, nil时只按结构体标签脱敏
+//param :       事件名称
+//param :       参数
+//return :      编码或发布失败的错误, 没有路由到队列时为ErrUnroutable
+//***************************************************
+func (p *Publisher) publish(ctx context.Context, t *trigger.Trigger, event string, arguments []interface{}) error {
+	payload, err := p.encodeMessage(t, event, arguments)
+	if nil != err {
+		return err
+	}
+	key := event
+	if nil != p.RoutingKey {
+		key = p.RoutingKey(event, arguments)
+	}
+	message := publishing{
+		Properties:      properties{ContentType: "application/json", Headers: map[string]interface{}{"event": event}},
+		RoutingKey:      key,
+		Payload:         payload,
+		PayloadEncoding: "string",
+	}
+	if p.Persistent {
+		message.Properties.DeliveryMode = 2
+	}
+	return publish(ctx, p.Config, p.Exchange, message)
+}
+
+//***************************************************
+//Description : 在触发器上监听指定事件并发布, 发布失败时调用OnError, 同时作为监听的返回值
+//param :       触发器
+//param :       字符串类型的事件名称
+//return :      Publisher
+//***************************************************
+func (p *Publisher) Attach(t *trigger.Trigger, events ...string) *Publisher {
+	for _, event := range events {
+		event := event
+		t.On(event, func(arguments ...interface{}) error {
+			err := p.publish(context.Background(), t, event, arguments)
+			if nil != err && nil != p.OnError {
+				p.OnError(event, err)
+			}
+			return err
+		})
+	}
+	return p
+}
+
+//***************************************************
+//Description : 发布消息到交换机
+//param :       上下文
+//param :       访问配置
+//param :       交换机名称, 空字符串为默认交换机
+//param :       消息
+//return :      发布失败的错误, 没有路由到队列时为ErrUnroutable
+//***************************************************
+func publish(ctx context.Context, config Config, exchange string, message publishing) error {
+	// 默认交换机在管理接口中名为amq.default
+	if "" == exchange {
+		exchange = "amq.default"
+	}
+	var out struct {
+		Routed bool `json:"routed"`
+	}
+	if err := config.call(ctx, http.MethodPost, config.path("exchanges", exchange, "publish"), message, &out); nil != err {
+		return err
+	}
+	if !out.Routed {
+		return ErrUnroutable
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package amqpbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// 交换机声明
+type Exchange struct {
+	// 名称
+	Name string `json:"name"`
+	// 类型, direct、topic、fanout或headers, 为空时为topic
+	Type string `json:"type"`
+	// 是否持久化
+	Durable bool `json:"durable"`
+}
+
+// 队列声明
+type Queue struct {
+	// 名称
+	Name string `json:"name"`
+	// 是否持久化
+	Durable bool `json:"durable"`
+	// 队列参数, 如x-message-ttl、x-dead-letter-exchange
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+}
+
+// 绑定声明
+type Binding struct {
+	// 交换机名称
+	Exchange string `json:"exchange"`
+	// 队列名称
+	Queue string `json:"queue"`
+	// 路由键, topic交换机可以使用通配符
+	RoutingKey string `json:"routing_key"`
+}
+
+// 拓扑声明, 可以从JSON配置加载
+//
+//	{"exchanges":[{"name":"orders","type":"topic","durable":true}],
+//	 "queues":[{"name":"billing","durable":true}],
+//	 "bindings":[{"exchange":"orders","queue":"billing","routing_key":"order.*"}]}
+type Topology struct {
+	Exchanges []Exchange `json:"exchanges"`
+	Queues    []Queue    `json:"queues"`
+	Bindings  []Binding  `json:"bindings"`
+}
+
+//***************************************************
+//Description : 从JSON配置加载拓扑
+//param :       JSON
+//return :      拓扑
+//return :      解析失败或缺少名称的错误
+//***************************************************
+func LoadTopology(data []byte) (Topology, error) {
+	var topology Topology
+	if err := json.Unmarshal(data, &topology); nil != err {
+		return Topology{}, fmt.Errorf("拓扑配置格式错误: %w", err)
+	}
+	for _, exchange := range topology.Exchanges {
+		if "" == exchange.Name {
+			return Topology{}, fmt.Errorf("交换机缺少名称")
+		}
+	}
+	for _, queue := range topology.Queues {
+		if "" == queue.Name {
+			return Topology{}, fmt.Errorf("队列缺少名称")
+		}
+	}
+	for _, binding := range topology.Bindings {
+		if "" == binding.Exchange || "" == binding.Queue {
+			return Topology{}, fmt.Errorf("绑定缺少交换机或队列")
+		}
+	}
+	return topology, nil
+}
+
+//***************************************************
+//Description : 依次声明交换机、队列与绑定, 已存在且属性相同时不变, 重复执行是安全的
+//param :       上下文
+//param :       访问配置
+//return :      声明失败的错误, 已声明的部分保留
+//***************************************************
+func (topology Topology) Declare(ctx context.Context, config Config) error {
+	for _, exchange := range topology.Exchanges {
+		kind := exchange.Type
+		if "" == kind {
+			kind = "topic"
+		}
+		in := map[string]interface{}{"type": kind, "durable": exchange.Durable}
+		if err := config.call(ctx, http.MethodPut, config.path("exchanges", exchange.Name), in, nil); nil != err {
+			return fmt.Errorf("声明交换机[%s]失败: %w", exchange.Name, err)
+		}
+	}
+	for _, queue := range topology.Queues {
+		in := map[string]interface{}{"durable": queue.Durable, "arguments": queue.Arguments}
+		if nil == queue.Arguments {
+			in["arguments"] = map[string]interface{}{}
+		}
+		if err := config.call(ctx, http.MethodPut, config.path("queues", queue.Name), in, nil); nil != err {
+			return fmt.Errorf("声明队列[%s]失败: %w", queue.Name, err)
+		}
+	}
+	for _, binding := range topology.Bindings {
+		in := map[string]string{"routing_key": binding.RoutingKey}
+		path := config.path("bindings", "e", binding.Exchange, "q", binding.Queue)
+		if err := config.call(ctx, http.MethodPost, path, in, nil); nil != err {
+			return fmt.Errorf("绑定[%s -> %s]失败: %w", binding.Exchange, binding.Queue, err)
+		}
+	}
+	return nil
+}
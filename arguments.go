@@ -0,0 +1,112 @@
+package trigger
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+//***************************************************
+//Description : 按回调函数的参数列表绑定参数
+//              - 参数少于回调函数的固定参数时, 缺少的参数使用零值
+//              - 参数为nil时使用对应参数类型的零值, 接口类型参数得到nil接口
+//              - 可变参数回调函数中多余的参数逐个放入可变参数, 按元素类型校验
+//              - 开启类型转换时, 类型不匹配的参数尝试转换为参数类型
+//param :       用于存放结果的数组, 可以为nil
+//param :       回调函数类型
+//param :       回调函数中的参数
+//param :       类型转换配置, nil表示不转换
+//return :      参数反射数组, 可直接用于Call
+//return :      参数不匹配的错误
+//***************************************************
+func bindArguments(values []reflect.Value, fnType reflect.Type, arguments []interface{}, coercion *coercion) ([]reflect.Value, error) {
+	numIn := fnType.NumIn()
+	// 固定参数数量
+	fixed := numIn
+	if fnType.IsVariadic() {
+		fixed--
+	}
+
+	if len(arguments) > fixed && !fnType.IsVariadic() {
+		return nil, fmt.Errorf("%w: 传入%d个参数, 回调函数只接收%d个", ErrArgumentMismatch, len(arguments), numIn)
+	}
+
+	size := fixed
+	if len(arguments) > size {
+		size = len(arguments)
+	}
+	if cap(values) < size {
+		values = make([]reflect.Value, 0, size)
+	}
+	for i := 0; i < size; i++ {
+		// 参数类型, 可变参数部分为元素类型
+		var in reflect.Type
+		if i < fixed {
+			in = fnType.In(i)
+		} else {
+			in = fnType.In(fixed).Elem()
+		}
+
+		// 缺少的参数使用零值
+		if i >= len(arguments) {
+			values = append(values, reflect.Zero(in))
+			continue
+		}
+
+		value, err := bindArgument(in, arguments[i], coercion)
+		if nil != err {
+			return nil, fmt.Errorf("%w: 第%d个参数%v", ErrArgumentMismatch, i+1, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+//***************************************************
+//Description : 绑定单个参数
+//param :       参数类型
+//param :       参数
+//param :       类型转换配置, nil表示不转换
+//return :      参数反射
+//return :      参数不匹配的错误
+//***************************************************
+func bindArgument(in reflect.Type, argument interface{}, coercion *coercion) (reflect.Value, error) {
+	// 延迟求值的参数在第一次使用时求值
+	if lazy, ok := argument.(*lazyValue); ok {
+		var err error
+		if argument, err = lazy.get(); nil != err {
+			return reflect.Value{}, err
+		}
+	}
+
+	// nil使用零值, 接口类型得到nil接口, 指针/切片/map等得到对应类型的nil
+	if nil == argument {
+		return reflect.Zero(in), nil
+	}
+
+	value := reflect.ValueOf(argument)
+	// 开启类型转换时, interface{}参数收到的原始JSON解析为通用类型
+	if nil != coercion && rawMessageType == value.Type() && reflect.Interface == in.Kind() && 0 == in.NumMethod() {
+		var decoded interface{}
+		if err := json.Unmarshal(value.Bytes(), &decoded); nil != err {
+			return reflect.Value{}, fmt.Errorf("从%v转换为%v失败: %v", value.Type(), in, err)
+		}
+		if nil == decoded {
+			return reflect.Zero(in), nil
+		}
+		return reflect.ValueOf(decoded), nil
+	}
+	if value.Type().AssignableTo(in) {
+		return value, nil
+	}
+
+	// 尝试类型转换
+	if nil != coercion {
+		if coerced, ok, err := coercion.coerce(value, in); nil != err {
+			return reflect.Value{}, fmt.Errorf("从%v转换为%v失败: %v", value.Type(), in, err)
+		} else if ok {
+			return coerced, nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("为%v, 回调函数需要%v", value.Type(), in)
+}
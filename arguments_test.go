@@ -0,0 +1,107 @@
+package trigger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBindArguments(t *testing.T) {
+	var stringer fmt.Stringer
+	cases := []struct {
+		name      string
+		listener  interface{}
+		arguments []interface{}
+		expect    string
+	}{
+		{"零值补齐", func(a string, b int) {}, []interface{}{"a"}, `[a 0]`},
+		{"nil参数", func(a *int, b []string) {}, []interface{}{nil, nil}, `[<nil> []]`},
+		{"nil接口", func(s fmt.Stringer) {}, []interface{}{nil}, `[<nil>]`},
+		{"可变参数nil", func(prefix string, rest ...error) {}, []interface{}{"p", nil, errors.New("e")}, `[p <nil> e]`},
+		{"可变参数为空", func(prefix int, rest ...int) {}, nil, `[0]`},
+		{"typed nil", func(s fmt.Stringer) {}, []interface{}{stringer}, `[<nil>]`},
+	}
+
+	for _, c := range cases {
+		values, err := bindArguments(nil, reflect.TypeOf(c.listener), c.arguments, nil)
+		if nil != err {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		var actual []interface{}
+		for _, value := range values {
+			actual = append(actual, value.Interface())
+		}
+		if c.expect != fmt.Sprint(actual) {
+			t.Fatalf("%s: 期望%s, 实际%v", c.name, c.expect, actual)
+		}
+	}
+
+	if _, err := bindArguments(nil, reflect.TypeOf(func(string) {}), []interface{}{"a", "b"}, nil); !errors.Is(err, ErrArgumentMismatch) {
+		t.Fatalf("参数过多应返回错误: %v", err)
+	}
+	if _, err := bindArguments(nil, reflect.TypeOf(func(string, ...int) {}), []interface{}{"a", "b"}, nil); !errors.Is(err, ErrArgumentMismatch) {
+		t.Fatalf("可变参数类型不匹配应返回错误: %v", err)
+	}
+}
+
+func TestVariadicListener(t *testing.T) {
+	var calls []string
+	trigger := NewTrigger().
+		On("log", func(args ...interface{}) { calls = append(calls, fmt.Sprint(args...)) }).
+		On("log", func(prefix string, rest ...int) { calls = append(calls, fmt.Sprint(prefix, rest)) }).
+		Once("log", func(prefix string, rest ...int) { calls = append(calls, fmt.Sprint("once", rest)) })
+
+	trigger.EmitSync("log", "p", 1, 2).EmitSync("log", "q")
+	expect := []string{"p1 2", "p[1 2]", "once[1 2]", "q", "q[]"}
+	if fmt.Sprint(expect) != fmt.Sprint(calls) {
+		t.Fatalf("可变参数展开错误: %q", calls)
+	}
+
+	t.Log("测试Once的nil参数")
+	var received error = errors.New("未调用")
+	trigger.Once("error", func(err error) { received = err }).EmitSync("error", nil)
+	if nil != received {
+		t.Fatalf("nil参数应绑定为nil接口: %v", received)
+	}
+}
+
+// 测试文本解析的等级类型
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	if "high" != string(text) {
+		return errors.New("未知等级")
+	}
+	*l = 2
+	return nil
+}
+
+func TestCoercion(t *testing.T) {
+	type order struct {
+		ID int `json:"id"`
+	}
+
+	var calls []string
+	trigger := NewTrigger().
+		RecoverWith(func(event interface{}, listener interface{}, err error) { calls = append(calls, "error") }).
+		WithCoercion(true).
+		AddCoercer(func(s string) (fmt.Stringer, error) { return time.ParseDuration(s) }).
+		On("number", func(n int64) { calls = append(calls, fmt.Sprint(n)) }).
+		On("json", func(o order) { calls = append(calls, fmt.Sprint(o.ID)) }).
+		On("text", func(l level) { calls = append(calls, fmt.Sprint(int(l))) }).
+		On("hook", func(s fmt.Stringer) { calls = append(calls, s.String()) })
+
+	trigger.
+		EmitSync("number", 42).
+		EmitSync("number", 1.5).
+		EmitSync("json", json.RawMessage(`{"id":7}`)).
+		EmitSync("text", "high").
+		EmitSync("hook", "1s")
+	if "42,error,7,2,1s" != strings.Join(calls, ",") {
+		t.Fatalf("类型转换结果错误: %v", calls)
+	}
+}
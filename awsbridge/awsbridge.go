@@ -0,0 +1,293 @@
+// awsbridge 通过AWS SNS发布本地事件, 并把SQS队列中的消息在本地触发, 只依赖net/http, 请求按Signature Version 4签名
+//
+// 消息体为JSON, SNS发布时同时写入名为event的消息属性, 可用于订阅的过滤策略:
+//
+//	{"event":"order.paid","args":[1,"a"]}
+//
+// SQS收到的消息可以是上述消息体, 也可以是SNS投递的通知(未开启原始消息投递时), 此时取其Message字段
+// 本地监听收到的参数为json.RawMessage, 使用具体类型时需开启WithCoercion
+// 监听全部成功后才删除消息, 执行期间按可见性超时的一半定期延长, 失败时立即恢复可见以便重新投递
+// 接收次数超出上限时原样转入死信队列, 也可以不配置而由队列的重新驱动策略处理
+// 与wsbridge相同, 发布方开启WithInheritance时触发的截止时间与经过的节点写入消息的deadline与hops, 配置Config.Node后origin为本节点
+// 接收方携带截止时间触发, 已过期的触发由触发器丢弃并报告, 已经过本节点或超出Config.MaxHops的消息不触发, 两者都直接删除而不重试
+//
+//	{"event":"order.paid","args":[1],"deadline":1700000000000,"hops":["node-a"],"origin":"node-a"}
+package awsbridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 单个响应体的大小上限
+const maxBodySize = 1 << 20
+
+// 消息体
+type Message struct {
+	// 事件名称
+	Event string `json:"event"`
+	// 参数
+	Args []json.RawMessage `json:"args"`
+	// 截止时间, Unix毫秒, 0表示没有
+	Deadline int64 `json:"deadline,omitempty"`
+	// 经过的节点, 用于环路检测
+	Hops []string `json:"hops,omitempty"`
+	// 发送方节点
+	Origin string `json:"origin,omitempty"`
+}
+
+// AWS服务返回的错误
+type Error struct {
+	// HTTP状态码
+	Status int
+	// 错误码
+	Code string
+	// 错误信息
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("AWS错误[%d/%s]: %s", e.Status, e.Code, e.Message)
+}
+
+// 访问配置
+type Config struct {
+	// 区域, 如us-east-1
+	Region string
+	// 访问凭证
+	Credentials Credentials
+	// 服务地址, 为空时为https://<服务>.<区域>.amazonaws.com/, 可指向LocalStack等兼容实现
+	Endpoint string
+	// HTTP客户端, 为nil时使用http.DefaultClient
+	HTTP *http.Client
+	// 本节点名称, 为空表示不做环路检测
+	Node string
+	// 触发最多经过的节点数, 超出时丢弃, 0表示不限
+	MaxHops int
+}
+
+//***************************************************
+//Description : 编码消息体, 参数按触发器的结构体标签与脱敏函数脱敏
+//              发布方协程的截止时间与经过的节点写入消息, 需开启WithInheritance
+//param :       触发器, nil时只按结构体标签脱敏且不携带截止时间
+//param :       事件名称
+//param :       参数
+//return :      消息体JSON
+//return :      参数编码失败的错误
+//***************************************************
+func (config *Config) encodeMessage(t *trigger.Trigger, event string, arguments []interface{}) (string, error) {
+	arguments = t.RedactArguments(event, arguments)
+	message := Message{Event: event, Args: make([]json.RawMessage, len(arguments)), Origin: config.Node}
+	var lineage trigger.Lineage
+	if nil != t {
+		lineage, _ = t.Lineage()
+	}
+	if !lineage.Deadline.IsZero() {
+		message.Deadline = lineage.Deadline.UnixMilli()
+	}
+	message.Hops = config.via(lineage.Hops)
+	for i, argument := range arguments {
+		raw, err := json.Marshal(argument)
+		if nil != err {
+			return "", fmt.Errorf("第%d个参数编码失败: %w", i+1, err)
+		}
+		message.Args[i] = raw
+	}
+	encoded, err := json.Marshal(message)
+	return string(encoded), err
+}
+
+//***************************************************
+//Description : 在本地通过协程组触发收到的消息, 携带消息的截止时间与经过的节点, 已过期的触发由触发器丢弃并报告
+//param :       本地触发器
+//param :       协程组
+//param :       消息
+//param :       参数
+//return :      是否触发, 已经过本节点或超出最大跳数时丢弃
+//***************************************************
+func (config *Config) emit(t *trigger.Trigger, g *trigger.ListenerGroup, message Message, arguments []interface{}) bool {
+	if config.looped(message.Hops) {
+		return false
+	}
+	hops := config.via(message.Hops)
+	if 0 == message.Deadline && 0 == len(hops) {
+		t.EmitGroup(g, message.Event, arguments...)
+		return true
+	}
+	lineage := trigger.Lineage{Hops: hops}
+	if 0 != message.Deadline {
+		lineage.Deadline = time.UnixMilli(message.Deadline)
+	}
+	t.EmitGroupWith(g, lineage, message.Event, arguments...)
+	return true
+}
+
+//***************************************************
+//Description : 是否已经过本节点或超出最大跳数
+//param :       经过的节点
+//return :      是否形成环路
+//***************************************************
+func (config *Config) looped(hops []string) bool {
+	if config.MaxHops > 0 && len(hops) > config.MaxHops {
+		return true
+	}
+	if "" == config.Node {
+		return false
+	}
+	for _, hop := range hops {
+		if config.Node == hop {
+			return true
+		}
+	}
+	return false
+}
+
+//***************************************************
+//Description : 追加本节点
+//param :       经过的节点
+//return :      追加后的节点
+//***************************************************
+func (config *Config) via(hops []string) []string {
+	if "" == config.Node || config.looped(hops) {
+		return hops
+	}
+	return append(hops[:len(hops):len(hops)], config.Node)
+}
+
+//***************************************************
+//Description : 解码消息体, 支持SNS投递的通知
+//param :       SQS消息体
+//return :      消息
+//return :      格式错误
+//***************************************************
+func decodeMessage(body string) (Message, error) {
+	var notification struct {
+		Type    string `json:"Type"`
+		Message string `json:"Message"`
+	}
+	if err := json.Unmarshal([]byte(body), &notification); nil == err && "Notification" == notification.Type {
+		body = notification.Message
+	}
+	var message Message
+	if err := json.Unmarshal([]byte(body), &message); nil != err {
+		return Message{}, fmt.Errorf("消息格式错误: %w", err)
+	}
+	if "" == message.Event {
+		return Message{}, fmt.Errorf("消息缺少事件名称")
+	}
+	return message, nil
+}
+
+//***************************************************
+//Description : 服务地址
+//param :       服务名称
+//return :      地址
+//***************************************************
+func (config *Config) endpoint(service string) string {
+	if "" != config.Endpoint {
+		return config.Endpoint
+	}
+	return "https://" + service + "." + config.Region + ".amazonaws.com/"
+}
+
+//***************************************************
+//Description : 发送签名的请求
+//param :       上下文
+//param :       服务名称
+//param :       请求体
+//param :       附加的请求头
+//return :      HTTP状态码
+//return :      响应体
+//return :      请求失败的错误
+//***************************************************
+func (config *Config) send(ctx context.Context, service string, body []byte, header http.Header) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.endpoint(service), bytes.NewReader(body))
+	if nil != err {
+		return 0, nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	sign(req, body, config.Credentials, config.Region, service, time.Now())
+
+	client := config.HTTP
+	if nil == client {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	return resp.StatusCode, data, err
+}
+
+//***************************************************
+//Description : 以query协议调用, 用于SNS
+//param :       上下文
+//param :       服务名称
+//param :       请求参数, 包括Action与Version
+//param :       XML响应的解码目标
+//return :      请求失败或服务返回错误时的错误, 后者为*Error
+//***************************************************
+func (config *Config) callQuery(ctx context.Context, service string, form url.Values, out interface{}) error {
+	header := http.Header{"Content-Type": {"application/x-www-form-urlencoded; charset=utf-8"}}
+	status, data, err := config.send(ctx, service, []byte(form.Encode()), header)
+	if nil != err {
+		return err
+	}
+	if status < 200 || status > 299 {
+		var failure struct {
+			Error struct {
+				Code    string `xml:"Code"`
+				Message string `xml:"Message"`
+			} `xml:"Error"`
+		}
+		xml.Unmarshal(data, &failure)
+		return &Error{Status: status, Code: failure.Error.Code, Message: failure.Error.Message}
+	}
+	return xml.Unmarshal(data, out)
+}
+
+//***************************************************
+//Description : 以JSON协议调用, 用于SQS
+//param :       上下文
+//param :       服务名称
+//param :       操作名称, 如AmazonSQS.ReceiveMessage
+//param :       请求参数
+//param :       响应的解码目标, nil表示忽略
+//return :      请求失败或服务返回错误时的错误, 后者为*Error
+//***************************************************
+func (config *Config) callJSON(ctx context.Context, service, target string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if nil != err {
+		return err
+	}
+	header := http.Header{"Content-Type": {"application/x-amz-json-1.0"}, "X-Amz-Target": {target}}
+	status, data, err := config.send(ctx, service, body, header)
+	if nil != err {
+		return err
+	}
+	if status < 200 || status > 299 {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &failure)
+		return &Error{Status: status, Code: failure.Type, Message: failure.Message}
+	}
+	if nil == out {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
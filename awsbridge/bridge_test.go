@@ -0,0 +1,239 @@
+package awsbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+type order struct {
+	ID    int    `json:"id"`
+	Token string `json:"token" trigger:"redact"`
+}
+
+// 测试用的队列消息
+type queued struct {
+	body      string
+	handle    string
+	receives  int
+	visibleAt time.Time
+}
+
+// 测试用的SNS与SQS, 发布的消息以通知格式进入队列
+type fakeAWS struct {
+	mu         sync.Mutex
+	queue      []*queued
+	dead       []string
+	handles    int
+	extensions int32
+	services   map[string]bool
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	authorization := req.Header.Get("Authorization")
+	for _, service := range []string{"sns", "sqs"} {
+		if strings.Contains(authorization, "/us-east-1/"+service+"/aws4_request") {
+			f.services[service] = true
+		}
+	}
+	if !strings.HasPrefix(authorization, algorithm+" Credential=AKID/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	target := req.Header.Get("X-Amz-Target")
+	if "" == target {
+		req.ParseForm()
+		notification, _ := json.Marshal(map[string]string{"Type": "Notification", "Message": req.PostForm.Get("Message")})
+		f.queue = append(f.queue, &queued{body: string(notification)})
+		w.Write([]byte(`<PublishResponse><PublishResult><MessageId>m-1</MessageId></PublishResult></PublishResponse>`))
+		return
+	}
+
+	var input struct {
+		QueueURL          string `json:"QueueUrl"`
+		ReceiptHandle     string `json:"ReceiptHandle"`
+		VisibilityTimeout int    `json:"VisibilityTimeout"`
+		MessageBody       string `json:"MessageBody"`
+	}
+	json.NewDecoder(req.Body).Decode(&input)
+	switch target {
+	case "AmazonSQS.ReceiveMessage":
+		var messages []sqsMessage
+		for _, m := range f.queue {
+			if time.Now().Before(m.visibleAt) {
+				continue
+			}
+			f.handles++
+			m.handle, m.receives = strconv.Itoa(f.handles), m.receives+1
+			m.visibleAt = time.Now().Add(time.Duration(input.VisibilityTimeout) * time.Second)
+			messages = append(messages, sqsMessage{MessageID: "m", ReceiptHandle: m.handle, Body: m.body,
+				Attributes: map[string]string{"ApproximateReceiveCount": strconv.Itoa(m.receives)}})
+		}
+		if 0 == len(messages) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"Messages": messages})
+	case "AmazonSQS.DeleteMessage", "AmazonSQS.ChangeMessageVisibility":
+		for i, m := range f.queue {
+			if m.handle != input.ReceiptHandle {
+				continue
+			}
+			if "AmazonSQS.DeleteMessage" == target {
+				f.queue = append(f.queue[:i], f.queue[i+1:]...)
+			} else {
+				if 0 != input.VisibilityTimeout {
+					atomic.AddInt32(&f.extensions, 1)
+				}
+				m.visibleAt = time.Now().Add(time.Duration(input.VisibilityTimeout) * time.Second)
+			}
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ReceiptHandleIsInvalid","message":"invalid"}`))
+	case "AmazonSQS.SendMessage":
+		f.dead = append(f.dead, input.MessageBody)
+		w.Write([]byte(`{"MessageId":"d-1"}`))
+	}
+}
+
+func (f *fakeAWS) pending() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queue), len(f.dead)
+}
+
+func TestSign(t *testing.T) {
+	t.Log("测试Signature Version 4签名")
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	credentials := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	sign(req, nil, credentials, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if expected != req.Header.Get("Authorization") {
+		t.Fatalf("签名错误: %s", req.Header.Get("Authorization"))
+	}
+}
+
+func TestBridge(t *testing.T) {
+	fake := &fakeAWS{services: map[string]bool{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	config := Config{Region: "us-east-1", Credentials: Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, Endpoint: server.URL + "/"}
+
+	t.Log("测试发布到SNS")
+	local := trigger.NewTrigger()
+	(&Publisher{Config: config, TopicARN: "arn:aws:sns:us-east-1:1:orders"}).Attach(local, "order.paid")
+	local.EmitSync("order.paid", order{ID: 1, Token: "secret"})
+	if queued, _ := fake.pending(); 1 != queued || strings.Contains(fake.queue[0].body, "secret") {
+		t.Fatalf("发布失败或未脱敏: %d", queued)
+	}
+
+	t.Log("测试失败的消息恢复可见后重新投递, 成功后删除")
+	var (
+		mu       sync.Mutex
+		received []order
+		attempts int32
+	)
+	remote := trigger.NewTrigger().WithCoercion(true).
+		On("order.paid", func(o order) error {
+			if 1 == atomic.AddInt32(&attempts, 1) {
+				return errors.New("暂时失败")
+			}
+			// 执行超过可见性超时的一半, 需延长
+			time.Sleep(700 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, o)
+			return nil
+		})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	consumer := &Consumer{Config: config, QueueURL: "https://sqs/q", Visibility: time.Second, MaxReceives: 3, DeadLetterURL: "https://sqs/dlq"}
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- consumer.Run(ctx, remote)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for queued, _ := fake.pending(); 0 != queued && time.Now().Before(deadline); queued, _ = fake.pending() {
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	if 1 != len(received) || 1 != received[0].ID || 2 != atomic.LoadInt32(&attempts) {
+		t.Fatalf("消费错误: %+v %d", received, attempts)
+	}
+	mu.Unlock()
+	if 0 == atomic.LoadInt32(&fake.extensions) || !fake.services["sns"] || !fake.services["sqs"] {
+		t.Fatalf("未延长可见性超时或签名的服务错误: %d %v", fake.extensions, fake.services)
+	}
+
+	t.Log("测试超出接收次数转入死信队列")
+	fake.mu.Lock()
+	fake.queue = append(fake.queue, &queued{body: `{"event":"order.paid","args":[{"id":2}]}`, receives: 3})
+	fake.mu.Unlock()
+	deadline = time.Now().Add(5 * time.Second)
+	for _, dead := fake.pending(); 0 == dead && time.Now().Before(deadline); _, dead = fake.pending() {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if queued, dead := fake.pending(); 0 != queued || 1 != dead || !strings.Contains(fake.dead[0], `"id":2`) {
+		t.Fatalf("死信转移错误: %d %d", queued, dead)
+	}
+
+	cancel()
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Fatalf("退出错误: %v", err)
+	}
+}
+
+func TestLineage(t *testing.T) {
+	t.Log("测试发布时写入截止时间与经过的节点")
+	deadline := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	config := &Config{Node: "node-b"}
+	var message Message
+	local := trigger.NewTrigger().WithInheritance(true)
+	local.On("order.paid", func(id int) {
+		encoded, _ := config.encodeMessage(local, "order.paid", []interface{}{id})
+		json.Unmarshal([]byte(encoded), &message)
+	})
+	local.EmitSyncWith(trigger.Lineage{Deadline: deadline, Hops: []string{"node-a"}}, "order.paid", 1)
+	if deadline.UnixMilli() != message.Deadline || "[node-a node-b]" != fmt.Sprint(message.Hops) || "node-b" != message.Origin {
+		t.Fatalf("消息的继承属性错误: %+v", message)
+	}
+
+	t.Log("测试接收时丢弃环路与过期的触发")
+	var received []time.Time
+	remote := trigger.NewTrigger().WithInheritance(true)
+	remote.On("order.paid", func() {
+		lineage, _ := remote.Lineage()
+		received = append(received, lineage.Deadline)
+	})
+	g := new(trigger.ListenerGroup)
+	if config.emit(remote, g, message, nil) {
+		t.Fatalf("已经过本节点的消息不应触发")
+	}
+	message.Hops = []string{"node-a"}
+	if !config.emit(remote, g, message, nil) || nil != g.Wait() || 1 != len(received) || !deadline.Equal(received[0]) {
+		t.Fatalf("消息未触发: %v", received)
+	}
+	message.Deadline = time.Now().Add(-time.Second).UnixMilli()
+	g = new(trigger.ListenerGroup)
+	if !config.emit(remote.RecoverWith(func(event, listener interface{}, err error) {}), g, message, nil) || !errors.Is(g.Wait(), trigger.ErrExpired) || 1 != len(received) {
+		t.Fatalf("过期的消息应丢弃: %v", received)
+	}
+}
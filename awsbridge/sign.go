@@ -0,0 +1,140 @@
+package awsbridge
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 签名算法
+const algorithm = "AWS4-HMAC-SHA256"
+
+// 请求时间的格式
+const amzDateFormat = "20060102T150405Z"
+
+// 访问凭证
+type Credentials struct {
+	// 访问密钥ID
+	AccessKeyID string
+	// 秘密访问密钥
+	SecretAccessKey string
+	// 临时凭证的会话令牌, 长期凭证为空
+	SessionToken string
+}
+
+//***************************************************
+//Description : 按Signature Version 4签名请求, 签名host、x-amz-*与content-type请求头
+//param :       HTTP请求
+//param :       请求体
+//param :       访问凭证
+//param :       区域
+//param :       服务名称, 如sns、sqs
+//param :       签名时间
+//***************************************************
+func sign(req *http.Request, body []byte, credentials Credentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format(amzDateFormat)
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if "" != credentials.SessionToken {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	// 规范请求
+	host := req.Host
+	if "" == host {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if "content-type" == lower || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if "" == path {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hashHex(body),
+	}, "\n")
+
+	// 待签字符串与签名密钥
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := algorithm + "\n" + amzDate + "\n" + scope + "\n" + hashHex([]byte(canonical))
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", algorithm+" Credential="+credentials.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+//***************************************************
+//Description : 规范查询字符串, 按名称与值排序, 空格编码为%20
+//param :       查询参数
+//return :      规范查询字符串
+//***************************************************
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name)+"="+uriEncode(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+//***************************************************
+//Description : 按RFC 3986编码, 只保留非保留字符
+//param :       原始字符串
+//return :      编码结果
+//***************************************************
+func uriEncode(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(url.QueryEscape(s), "+", "%20"), "%7E", "~")
+}
+
+//***************************************************
+//Description : 计算SHA256的十六进制摘要
+//param :       数据
+//return :      摘要
+//***************************************************
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+//***************************************************
+//Description : 计算HMAC-SHA256
+//param :       密钥
+//param :       数据
+//return :      摘要
+//***************************************************
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
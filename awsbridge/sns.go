@@ -0,0 +1,90 @@
+package awsbridge
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/yann1989/trigger"
+)
+
+// SNS的API版本
+const snsVersion = "2010-03-31"
+
+// 将本地事件发布到SNS主题
+type Publisher struct {
+	Config
+	// 主题ARN
+	TopicARN string
+	// FIFO主题的消息组ID, 同一组内按发布顺序投递, 为nil时不设置
+	GroupID func(event string, arguments []interface{}) string
+	// Attach发布失败时的回调, 为nil时忽略
+	OnError func(event string, err error)
+}
+
+//***************************************************
+//Description : 发布事件, 事件名称同时写入event消息属性
+//param :       上下文
+//param :       事件名称
+//param :       参数
+//return :      消息ID
+//return :      编码或发布失败的错误
+//***************************************************
+func (p *Publisher) Publish(ctx context.Context, event string, arguments ...interface{}) (string, error) {
+	return p.publish(ctx, nil, event, arguments)
+}
+
+//***************************************************
+//Description : 发布事件, 参数按触发器的脱敏配置脱敏
+//param :       上下文
+//param :       触发器, nil时只按结构体标签脱敏
+//param :       事件名称
+//param :       参数
+//return :      消息ID
+//return :      编码或发布失败的错误
+//***************************************************
+func (p *Publisher) publish(ctx context.Context, t *trigger.Trigger, event string, arguments []interface{}) (string, error) {
+	message, err := p.encodeMessage(t, event, arguments)
+	if nil != err {
+		return "", err
+	}
+	form := url.Values{
+		"Action":                         {"Publish"},
+		"Version":                        {snsVersion},
+		"TopicArn":                       {p.TopicARN},
+		"Message":                        {message},
+		"MessageAttributes.entry.1.Name": {"event"},
+		"MessageAttributes.entry.1.Value.DataType":    {"String"},
+		"MessageAttributes.entry.1.Value.StringValue": {event},
+	}
+	if nil != p.GroupID {
+		form.Set("MessageGroupId", p.GroupID(event, arguments))
+	}
+
+	var out struct {
+		MessageID string `xml:"PublishResult>MessageId"`
+	}
+	if err := p.callQuery(ctx, "sns", form, &out); nil != err {
+		return "", err
+	}
+	return out.MessageID, nil
+}
+
+//***************************************************
+//Description : 在触发器上监听指定事件并发布, 发布失败时调用OnError, 同时作为监听的返回值
+//param :       触发器
+//param :       字符串类型的事件名称
+//return :      Publisher
+//***************************************************
+func (p *Publisher) Attach(t *trigger.Trigger, events ...string) *Publisher {
+	for _, event := range events {
+		event := event
+		t.On(event, func(arguments ...interface{}) error {
+			_, err := p.publish(context.Background(), t, event, arguments)
+			if nil != err && nil != p.OnError {
+				p.OnError(event, err)
+			}
+			return err
+		})
+	}
+	return p
+}
@@ -0,0 +1,260 @@
+package awsbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 默认配置
+const (
+	defaultVisibility = 30 * time.Second
+	defaultWaitTime   = 20 * time.Second
+	defaultBatchSize  = 10
+	// 接收失败后的等待时间
+	retryInterval = time.Second
+)
+
+// SQS消息
+type sqsMessage struct {
+	MessageID         string                     `json:"MessageId"`
+	ReceiptHandle     string                     `json:"ReceiptHandle"`
+	Body              string                     `json:"Body"`
+	Attributes        map[string]string          `json:"Attributes"`
+	MessageAttributes map[string]json.RawMessage `json:"MessageAttributes"`
+}
+
+// 消费SQS队列并在本地触发
+type Consumer struct {
+	Config
+	// 队列地址
+	QueueURL string
+	// 可见性超时, 执行期间按其一半定期延长, 为0时为30秒, 按秒取整
+	Visibility time.Duration
+	// 长轮询的等待时间, 为0时为20秒
+	WaitTime time.Duration
+	// 每次接收的消息数量上限, 为0时为10
+	BatchSize int
+	// 接收次数上限, 超出后原样转入DeadLetterURL并删除, 为0时不限制
+	MaxReceives int
+	// 死信队列地址
+	DeadLetterURL string
+	// 接收、删除或消息格式错误时的回调, 为nil时忽略
+	OnError func(err error)
+}
+
+//***************************************************
+//Description : 持续接收消息并在本地触发, 同一批消息并发处理, 处理完一批再接收下一批
+//              监听通过EmitGroup执行, 全部成功后删除消息, 失败时恢复可见等待重新投递
+//param :       上下文, 取消时退出
+//param :       本地触发器
+//return :      上下文的错误
+//***************************************************
+func (c *Consumer) Run(ctx context.Context, t *trigger.Trigger) error {
+	for {
+		messages, err := c.receive(ctx)
+		if nil != ctx.Err() {
+			return ctx.Err()
+		}
+		if nil != err {
+			c.fail(err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryInterval):
+			}
+			continue
+		}
+
+		var wg sync.WaitGroup
+		for _, message := range messages {
+			wg.Add(1)
+			go func(message sqsMessage) {
+				defer wg.Done()
+				c.handle(ctx, t, message)
+			}(message)
+		}
+		wg.Wait()
+	}
+}
+
+//***************************************************
+//Description : 处理一条消息
+//param :       上下文
+//param :       本地触发器
+//param :       消息
+//***************************************************
+func (c *Consumer) handle(ctx context.Context, t *trigger.Trigger, message sqsMessage) {
+	// 确认与转移不因退出而中断, 避免执行完的消息重复投递
+	ack := context.WithoutCancel(ctx)
+
+	// 超出接收次数的消息原样转入死信队列
+	received, _ := strconv.Atoi(message.Attributes["ApproximateReceiveCount"])
+	if 0 != c.MaxReceives && received > c.MaxReceives && "" != c.DeadLetterURL {
+		if err := c.deadLetter(ack, message); nil != err {
+			c.fail(err)
+		}
+		return
+	}
+
+	decoded, err := decodeMessage(message.Body)
+	if nil != err {
+		c.fail(fmt.Errorf("消息[%s]: %w", message.MessageID, err))
+		c.release(ack, message)
+		return
+	}
+	arguments := make([]interface{}, len(decoded.Args))
+	for i, arg := range decoded.Args {
+		arguments[i] = arg
+	}
+
+	// 执行期间定期延长可见性超时
+	done := make(chan struct{})
+	extended := make(chan struct{})
+	go func() {
+		defer close(extended)
+		c.extend(ctx, message, done)
+	}()
+	// 已经过本节点的消息与已过期的触发直接删除
+	g := new(trigger.ListenerGroup)
+	if c.emit(t, g, decoded, arguments) {
+		err = g.Wait()
+	}
+	close(done)
+	<-extended
+
+	if nil != err && !errors.Is(err, trigger.ErrExpired) {
+		c.release(ack, message)
+		return
+	}
+	input := map[string]string{"QueueUrl": c.QueueURL, "ReceiptHandle": message.ReceiptHandle}
+	if err := c.callJSON(ack, "sqs", "AmazonSQS.DeleteMessage", input, nil); nil != err {
+		c.fail(err)
+	}
+}
+
+//***************************************************
+//Description : 接收一批消息
+//param :       上下文
+//return :      消息
+//return :      接收失败的错误
+//***************************************************
+func (c *Consumer) receive(ctx context.Context) ([]sqsMessage, error) {
+	batch := c.BatchSize
+	if 0 == batch {
+		batch = defaultBatchSize
+	}
+	wait := c.WaitTime
+	if 0 == wait {
+		wait = defaultWaitTime
+	}
+	input := map[string]interface{}{
+		"QueueUrl":              c.QueueURL,
+		"MaxNumberOfMessages":   batch,
+		"WaitTimeSeconds":       int(wait / time.Second),
+		"VisibilityTimeout":     c.visibilitySeconds(),
+		"AttributeNames":        []string{"All"},
+		"MessageAttributeNames": []string{"All"},
+	}
+	var out struct {
+		Messages []sqsMessage `json:"Messages"`
+	}
+	if err := c.callJSON(ctx, "sqs", "AmazonSQS.ReceiveMessage", input, &out); nil != err {
+		return nil, err
+	}
+	return out.Messages, nil
+}
+
+//***************************************************
+//Description : 监听执行期间按可见性超时的一半定期延长
+//param :       上下文
+//param :       消息
+//param :       监听执行完毕时关闭
+//***************************************************
+func (c *Consumer) extend(ctx context.Context, message sqsMessage, done <-chan struct{}) {
+	seconds := c.visibilitySeconds()
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.changeVisibility(ctx, message, seconds); nil != err {
+				c.fail(err)
+			}
+		}
+	}
+}
+
+//***************************************************
+//Description : 立即恢复可见, 以便重新投递或由重新驱动策略转入死信队列
+//param :       上下文
+//param :       消息
+//***************************************************
+func (c *Consumer) release(ctx context.Context, message sqsMessage) {
+	if err := c.changeVisibility(ctx, message, 0); nil != err {
+		c.fail(err)
+	}
+}
+
+//***************************************************
+//Description : 修改消息的可见性超时
+//param :       上下文
+//param :       消息
+//param :       超时秒数
+//return :      请求失败的错误
+//***************************************************
+func (c *Consumer) changeVisibility(ctx context.Context, message sqsMessage, seconds int) error {
+	input := map[string]interface{}{"QueueUrl": c.QueueURL, "ReceiptHandle": message.ReceiptHandle, "VisibilityTimeout": seconds}
+	return c.callJSON(ctx, "sqs", "AmazonSQS.ChangeMessageVisibility", input, nil)
+}
+
+//***************************************************
+//Description : 原样转入死信队列后删除, 保留消息体与消息属性
+//param :       上下文
+//param :       消息
+//return :      请求失败的错误
+//***************************************************
+func (c *Consumer) deadLetter(ctx context.Context, message sqsMessage) error {
+	input := map[string]interface{}{"QueueUrl": c.DeadLetterURL, "MessageBody": message.Body}
+	if 0 != len(message.MessageAttributes) {
+		input["MessageAttributes"] = message.MessageAttributes
+	}
+	if err := c.callJSON(ctx, "sqs", "AmazonSQS.SendMessage", input, nil); nil != err {
+		return fmt.Errorf("消息[%s]转入死信队列失败: %w", message.MessageID, err)
+	}
+	remove := map[string]string{"QueueUrl": c.QueueURL, "ReceiptHandle": message.ReceiptHandle}
+	return c.callJSON(ctx, "sqs", "AmazonSQS.DeleteMessage", remove, nil)
+}
+
+//***************************************************
+//Description : 可见性超时的秒数, 至少为1
+//return :      秒数
+//***************************************************
+func (c *Consumer) visibilitySeconds() int {
+	visibility := c.Visibility
+	if 0 == visibility {
+		visibility = defaultVisibility
+	}
+	if visibility < time.Second {
+		return 1
+	}
+	return int(visibility / time.Second)
+}
+
+//***************************************************
+//Description : 报告错误
+//param :       错误
+//***************************************************
+func (c *Consumer) fail(err error) {
+	if nil != c.OnError {
+		c.OnError(err)
+	}
+}
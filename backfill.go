@@ -0,0 +1,173 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// 补发的起点, 两者都为零值时从日志中最早的记录开始
+type Backfill struct {
+	// 从此序号开始补发
+	FromSeq uint64
+	// 只补发不早于此时间的记录
+	FromTime time.Time
+}
+
+// 补发监听在注册表中使用的事件类型, 不会被触发直接调用
+type backfillEvent struct {
+	key string
+}
+
+//***************************************************
+//Description : 从指定序号开始补发
+//param :       第一条补发的序号
+//return :      补发的起点
+//***************************************************
+func BackfillFromSeq(seq uint64) Backfill {
+	return Backfill{FromSeq: seq}
+}
+
+//***************************************************
+//Description : 从指定时间开始补发
+//param :       最早补发的触发时间
+//return :      补发的起点
+//***************************************************
+func BackfillFromTime(from time.Time) Backfill {
+	return Backfill{FromTime: from}
+}
+
+//***************************************************
+//Description : 添加补发监听: 先按顺序执行日志中起点之后此事件的历史记录, 再继续执行之后的新记录
+//              历史与新触发都从日志中按序号读取, 因此切换时不会遗漏也不会重复, 新触发在追加日志后异步执行
+//              需先开启WithJournal, 内存日志中超出容量被丢弃的记录无法补发, 从文件恢复的参数需配合WithCoercion
+//param :       事件类型
+//param :       监听名称, 用于删除
+//param :       补发的起点
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddBackfillListener(event interface{}, key string, from Backfill, listener interface{}) *Trigger {
+	if reflect.Func != reflect.ValueOf(listener).Kind() {
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrNotFunction})
+		return trigger
+	}
+	journal := trigger.Journal()
+	if nil == journal {
+		err := fmt.Errorf("%w: 补发监听[%s]需要触发日志", ErrNoJournal, key)
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: err})
+		return trigger
+	}
+
+	trigger.RemoveBackfillListener(key)
+	target := backfillEvent{key: key}
+	trigger.ReplaceListener(target, key, listener)
+	handlers := trigger.handlersOf(target)
+	if 0 == len(handlers) {
+		return trigger
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	worker := &durableWorker{cancel: cancel, done: make(chan struct{})}
+	trigger.Lock()
+	if nil == trigger.backfills {
+		trigger.backfills = make(map[string]*durableWorker)
+	}
+	trigger.backfills[key] = worker
+	trigger.Unlock()
+
+	var after uint64
+	if from.FromSeq > 0 {
+		after = from.FromSeq - 1
+	}
+	go trigger.consumeBackfill(ctx, worker, journal, after, from.FromTime, event, handlers[0])
+	return trigger
+}
+
+//***************************************************
+//Description : 调用的AddBackfillListener
+//param :       事件类型
+//param :       监听名称
+//param :       补发的起点
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnBackfill(event interface{}, key string, from Backfill, listener interface{}) *Trigger {
+	return trigger.AddBackfillListener(event, key, from, listener)
+}
+
+//***************************************************
+//Description : 删除补发监听, 等待正在处理的记录结束
+//param :       监听名称
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveBackfillListener(key string) *Trigger {
+	trigger.Lock()
+	worker := trigger.backfills[key]
+	delete(trigger.backfills, key)
+	trigger.Unlock()
+
+	if nil != worker {
+		worker.stop()
+		trigger.RemoveNamedListener(backfillEvent{key: key}, key)
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 停止所有补发监听, 用于关闭触发器
+//***************************************************
+func (trigger *Trigger) stopBackfills() {
+	trigger.Lock()
+	backfills := trigger.backfills
+	trigger.backfills = nil
+	trigger.Unlock()
+
+	for _, worker := range backfills {
+		worker.stop()
+	}
+}
+
+//***************************************************
+//Description : 补发监听的读取循环, 读完已有记录后等待新记录
+//param :       上下文, 取消时退出
+//param :       补发监听
+//param :       日志
+//param :       已读取的最后序号
+//param :       最早补发的触发时间
+//param :       事件类型
+//param :       监听者
+//***************************************************
+func (trigger *Trigger) consumeBackfill(ctx context.Context, worker *durableWorker, journal Journal, after uint64, from time.Time, event interface{}, h *handler) {
+	defer close(worker.done)
+
+	for {
+		// 先获取通知通道再读取, 避免读取后追加的记录没有通知
+		changed := trigger.journalChanged()
+		records, err := journal.Read(after, durableBatch)
+		if nil != err {
+			trigger.report(event, h.source, &DispatchError{Event: event, Listener: h.source, Err: fmt.Errorf("读取触发日志失败: %w", err)})
+			return
+		}
+
+		for _, record := range records {
+			after = record.Seq
+			if record.Event == event && !record.Time.Before(from) {
+				trigger.invoke(event, h, record.Arguments)
+			}
+			if nil != ctx.Err() {
+				return
+			}
+		}
+		if 0 != len(records) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package trigger
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Bag类型反射
+var bagType = reflect.TypeOf((*Bag)(nil))
+
+// 有序键值存储, 用于EmitSync中前后监听之间传递数据
+type Bag struct {
+	// 读写锁
+	sync.RWMutex
+	// 按写入顺序排列的键
+	keys []string
+	// 键值
+	values map[string]interface{}
+}
+
+//***************************************************
+//Description : Bag构造函数
+//return :      Bag
+//***************************************************
+func NewBag() *Bag {
+	return &Bag{values: make(map[string]interface{})}
+}
+
+//***************************************************
+//Description : 写入值, 已存在的键保持原有顺序
+//param :       键
+//param :       值
+//return :      Bag
+//***************************************************
+func (bag *Bag) Set(key string, value interface{}) *Bag {
+	bag.Lock()
+	defer bag.Unlock()
+
+	if _, ok := bag.values[key]; !ok {
+		bag.keys = append(bag.keys, key)
+	}
+	bag.values[key] = value
+	return bag
+}
+
+//***************************************************
+//Description : 读取值
+//param :       键
+//return :      值
+//return :      是否存在
+//***************************************************
+func (bag *Bag) Get(key string) (interface{}, bool) {
+	bag.RLock()
+	defer bag.RUnlock()
+
+	value, ok := bag.values[key]
+	return value, ok
+}
+
+//***************************************************
+//Description : 删除值
+//param :       键
+//return :      Bag
+//***************************************************
+func (bag *Bag) Delete(key string) *Bag {
+	bag.Lock()
+	defer bag.Unlock()
+
+	if _, ok := bag.values[key]; !ok {
+		return bag
+	}
+	delete(bag.values, key)
+	for i, k := range bag.keys {
+		if k == key {
+			bag.keys = append(bag.keys[:i:i], bag.keys[i+1:]...)
+			break
+		}
+	}
+	return bag
+}
+
+//***************************************************
+//Description : 按写入顺序获取所有键
+//return :      键数组
+//***************************************************
+func (bag *Bag) Keys() []string {
+	bag.RLock()
+	defer bag.RUnlock()
+
+	return append([]string(nil), bag.keys...)
+}
+
+//***************************************************
+//Description : 键值数量
+//return :      数量
+//***************************************************
+func (bag *Bag) Len() int {
+	bag.RLock()
+	defer bag.RUnlock()
+
+	return len(bag.keys)
+}
+
+//***************************************************
+//Description : 拆分出第一个参数中的Bag
+//param :       回调函数中的参数
+//return :      Bag, 没有则为nil
+//return :      其余参数
+//***************************************************
+func splitBag(arguments []interface{}) (*Bag, []interface{}) {
+	if 0 != len(arguments) {
+		if bag, ok := arguments[0].(*Bag); ok {
+			return bag, arguments[1:]
+		}
+	}
+	return nil, arguments
+}
+
+//***************************************************
+//Description : 回调函数的第一个参数是否为*Bag
+//param :       回调函数类型
+//return :      是否接收Bag
+//***************************************************
+func acceptsBag(fnType reflect.Type) bool {
+	return 0 != fnType.NumIn() && bagType == fnType.In(0)
+}
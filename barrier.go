@@ -0,0 +1,200 @@
+package trigger
+
+import (
+	"context"
+	"sync"
+)
+
+// 触发屏障, 之后通过EmitAfterBarrier的触发在屏障之前开始的触发全部结束后才分发
+type Barrier struct {
+	trigger *Trigger
+	// 之前的触发全部结束后关闭
+	done chan struct{}
+	// 保护以下字段
+	mu sync.Mutex
+	// 等待屏障的触发, 按调用顺序
+	pending []barrierEmit
+	// 等待的触发是否已全部分发, 之后的触发直接分发
+	released bool
+}
+
+// 等待屏障的触发
+type barrierEmit struct {
+	// 事件类型
+	event interface{}
+	// 回调函数中的参数
+	arguments []interface{}
+}
+
+// 事件类型 -> 事件的读取周期, 写入时复制
+type epochTable map[interface{}]*readEpoch
+
+// 进入的读取周期, 开启事件屏障后同时进入事件自身的周期
+type epochTicket struct {
+	// 全局周期
+	global uint32
+	// 事件的读取周期, 事件未用于屏障时为nil
+	local *readEpoch
+	// 事件的周期
+	i uint32
+}
+
+//***************************************************
+//Description : 创建触发屏障, 用于分阶段的启动与关闭
+//              屏障在之前已开始的指定事件的触发全部执行完毕后通过, 不指定事件时等待之前开始的所有触发
+//              事件第一次用于屏障时才开始按事件记录, 此时还需等待之前开始的所有触发, 可在启动时预先创建
+//              执行完毕指Emit/EmitSync返回或EmitGroup的最后一个监听结束, 不包括影子监听等后台任务
+//              在这些触发的监听中调用Wait会死锁, EmitAfterBarrier不会
+//param :       需等待的事件, 为空表示所有事件
+//return :      触发屏障
+//***************************************************
+func (trigger *Trigger) Barrier(events ...interface{}) *Barrier {
+	epochs, created := trigger.barrierEpochsOf(events)
+
+	barrier := &Barrier{trigger: trigger, done: make(chan struct{})}
+	trigger.background.Add(1)
+	go func() {
+		defer trigger.background.Add(-1)
+
+		if 0 == len(events) || created {
+			trigger.epoch.synchronize()
+		}
+		for _, epoch := range epochs {
+			epoch.synchronize()
+		}
+		close(barrier.done)
+		barrier.release()
+	}()
+	return barrier
+}
+
+//***************************************************
+//Description : 在屏障通过后触发事件, 同Emit
+//              屏障未通过时不阻塞调用方, 屏障通过后按调用顺序在后台依次触发
+//param :       触发屏障
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitAfterBarrier(barrier *Barrier, event interface{}, arguments ...interface{}) *Trigger {
+	barrier.mu.Lock()
+	if !barrier.released {
+		barrier.pending = append(barrier.pending, barrierEmit{event: event, arguments: arguments})
+		barrier.mu.Unlock()
+		return trigger
+	}
+	barrier.mu.Unlock()
+	return trigger.Emit(event, arguments...)
+}
+
+//***************************************************
+//Description : 屏障通过时关闭的通道
+//return :      通道
+//***************************************************
+func (barrier *Barrier) Done() <-chan struct{} {
+	return barrier.done
+}
+
+//***************************************************
+//Description : 阻塞等待屏障通过, 不等待屏障后的触发
+//param :       上下文
+//return :      上下文结束时返回包装ctx.Err()的TimeoutError
+//***************************************************
+func (barrier *Barrier) Wait(ctx context.Context) error {
+	select {
+	case <-barrier.done:
+		return nil
+	case <-ctx.Done():
+		return &TimeoutError{Err: ctx.Err()}
+	}
+}
+
+//***************************************************
+//Description : 按调用顺序分发等待屏障的触发, 分发期间新加入的触发排在后面
+//***************************************************
+func (barrier *Barrier) release() {
+	for {
+		barrier.mu.Lock()
+		pending := barrier.pending
+		barrier.pending = nil
+		if 0 == len(pending) {
+			barrier.released = true
+			barrier.mu.Unlock()
+			return
+		}
+		barrier.mu.Unlock()
+
+		for _, emit := range pending {
+			barrier.trigger.Emit(emit.event, emit.arguments...)
+		}
+	}
+}
+
+//***************************************************
+//Description : 获取事件的读取周期, 不存在时创建
+//param :       需等待的事件
+//return :      各事件的读取周期
+//return :      是否有新创建的周期, 创建之前开始的触发没有进入事件的周期, 需等待全局周期
+//***************************************************
+func (trigger *Trigger) barrierEpochsOf(events []interface{}) ([]*readEpoch, bool) {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	var current epochTable
+	if table := trigger.barrierEpochs.Load(); nil != table {
+		current = *table
+	}
+	epochs := make([]*readEpoch, len(events))
+	var next epochTable
+	for i, event := range events {
+		if epoch, ok := current[event]; ok {
+			epochs[i] = epoch
+			continue
+		}
+		if nil == next {
+			next = make(epochTable, len(current)+len(events))
+			for key, value := range current {
+				next[key] = value
+			}
+		}
+		if epoch, ok := next[event]; ok {
+			epochs[i] = epoch
+			continue
+		}
+		epochs[i] = &readEpoch{}
+		next[event] = epochs[i]
+	}
+	if nil == next {
+		return epochs, false
+	}
+	trigger.barrierEpochs.Store(&next)
+	return epochs, true
+}
+
+//***************************************************
+//Description : 触发开始时进入读取周期, 需在读取注册表之前调用
+//param :       事件类型
+//return :      进入的周期, 结束时传给leaveEpoch
+//***************************************************
+func (trigger *Trigger) enterEpoch(event interface{}) epochTicket {
+	// 先进入全局周期再查找事件的周期, 创建事件周期后的全局等待一定覆盖未进入事件周期的触发
+	ticket := epochTicket{global: trigger.epoch.enter()}
+	if table := trigger.barrierEpochs.Load(); nil != table {
+		if local, ok := (*table)[event]; ok {
+			ticket.local = local
+			ticket.i = local.enter()
+		}
+	}
+	return ticket
+}
+
+//***************************************************
+//Description : 触发结束时离开读取周期
+//param :       enterEpoch返回的周期
+//***************************************************
+func (trigger *Trigger) leaveEpoch(ticket epochTicket) {
+	if nil != ticket.local {
+		ticket.local.leave(ticket.i)
+	}
+	trigger.epoch.leave(ticket.global)
+}
@@ -0,0 +1,149 @@
+// bench 提供标准的负载场景, 用于在自己的负载下测量触发器并比较不同的触发方式
+package bench
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 触发方式
+type Mode int
+
+const (
+	// 使用EmitSync同步触发
+	ModeSync Mode = iota
+	// 使用Emit异步触发
+	ModeAsync
+)
+
+// 触发方式名称
+func (mode Mode) String() string {
+	if ModeAsync == mode {
+		return "async"
+	}
+	return "sync"
+}
+
+// 触发函数, 由触发方式决定为Emit或EmitSync
+type EmitFunc func(event interface{}, arguments ...interface{}) *trigger.Trigger
+
+// 负载场景
+type Scenario struct {
+	// 场景名称
+	Name string
+	// 准备工作, 如注册监听, 不计入耗时
+	Setup func(t *trigger.Trigger, emit EmitFunc)
+	// 单次操作
+	Run func(t *trigger.Trigger, emit EmitFunc, i int)
+}
+
+// 测量配置
+type Profile struct {
+	// 负载场景
+	Scenario Scenario
+	// 触发方式
+	Mode Mode
+	// 操作次数
+	Iterations int
+	// 并发执行的协程数量, 小于1视为1
+	Concurrency int
+}
+
+// 测量结果
+type Result struct {
+	// 场景名称
+	Scenario string `json:"scenario"`
+	// 触发方式
+	Mode string `json:"mode"`
+	// 操作次数
+	Iterations int `json:"iterations"`
+	// 并发协程数量
+	Concurrency int `json:"concurrency"`
+	// 总耗时
+	Duration time.Duration `json:"duration"`
+	// 平均每次操作耗时
+	PerOp time.Duration `json:"per_op"`
+	// 每秒操作次数
+	OpsPerSec float64 `json:"ops_per_sec"`
+	// 平均每次操作的内存分配次数
+	AllocsPerOp float64 `json:"allocs_per_op"`
+	// 平均每次操作的内存分配字节数
+	BytesPerOp float64 `json:"bytes_per_op"`
+}
+
+//***************************************************
+//Description : 在指定触发器上运行一次测量
+//param :       触发器, 会在其上注册场景需要的监听
+//param :       测量配置
+//return :      测量结果
+//***************************************************
+func RunProfile(t *trigger.Trigger, profile Profile) Result {
+	emit := EmitFunc(t.EmitSync)
+	if ModeAsync == profile.Mode {
+		emit = t.Emit
+	}
+	concurrency := profile.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	if nil != profile.Scenario.Setup {
+		profile.Scenario.Setup(t, emit)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	// 按协程平均分配操作
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for worker := 0; worker < concurrency; worker++ {
+		go func(worker int) {
+			defer wg.Done()
+			for i := worker; i < profile.Iterations; i += concurrency {
+				profile.Scenario.Run(t, emit, i)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	duration := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	result := Result{
+		Scenario:    profile.Scenario.Name,
+		Mode:        profile.Mode.String(),
+		Iterations:  profile.Iterations,
+		Concurrency: concurrency,
+		Duration:    duration,
+	}
+	if 0 != profile.Iterations {
+		result.PerOp = duration / time.Duration(profile.Iterations)
+		result.AllocsPerOp = float64(after.Mallocs-before.Mallocs) / float64(profile.Iterations)
+		result.BytesPerOp = float64(after.TotalAlloc-before.TotalAlloc) / float64(profile.Iterations)
+	}
+	if 0 != duration {
+		result.OpsPerSec = float64(profile.Iterations) / duration.Seconds()
+	}
+	return result
+}
+
+//***************************************************
+//Description : 用两种触发方式分别测量同一场景, 每次使用新的触发器
+//param :       触发器构造函数, 用于带上自己的配置
+//param :       负载场景
+//param :       操作次数
+//return :      同步与异步的测量结果
+//***************************************************
+func Compare(newTrigger func() *trigger.Trigger, scenario Scenario, iterations int) []Result {
+	var results []Result
+	for _, mode := range []Mode{ModeSync, ModeAsync} {
+		results = append(results, RunProfile(newTrigger(), Profile{Scenario: scenario, Mode: mode, Iterations: iterations}))
+	}
+	return results
+}
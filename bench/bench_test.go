@@ -0,0 +1,59 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/yann1989/trigger"
+)
+
+func TestRunProfile(t *testing.T) {
+	for _, scenario := range Scenarios() {
+		for _, result := range Compare(trigger.NewTrigger, scenario, 100) {
+			if 100 != result.Iterations || 0 == result.OpsPerSec {
+				t.Fatalf("测量结果错误: %+v", result)
+			}
+			t.Logf("%s/%s: %v/op, %.1f allocs/op", result.Scenario, result.Mode, result.PerOp, result.AllocsPerOp)
+		}
+	}
+}
+
+func BenchmarkScenarios(b *testing.B) {
+	for _, scenario := range Scenarios() {
+		for _, mode := range []Mode{ModeSync, ModeAsync} {
+			scenario, mode := scenario, mode
+			b.Run(scenario.Name+"/"+mode.String(), func(b *testing.B) {
+				b.ReportAllocs()
+				RunProfile(trigger.NewTrigger(), Profile{Scenario: scenario, Mode: mode, Iterations: b.N})
+			})
+		}
+	}
+}
+
+// 对比监听不变、并发触发时完整的EmitSync路径: rwmutex在每次触发前取一次触发器的读锁,
+// 模拟改为写入时复制之前触发路径上的读锁, snapshot为当前无锁读取监听映射的实现
+func BenchmarkRegistryParallel(b *testing.B) {
+	for _, locked := range []bool{true, false} {
+		name := "snapshot"
+		if locked {
+			name = "rwmutex"
+		}
+		locked := locked
+		b.Run(name, func(b *testing.B) {
+			t := trigger.NewTrigger()
+			for i := 0; i < 4; i++ {
+				t.On("bench", func(id int, name string) {})
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if locked {
+						t.RLock()
+						t.RUnlock()
+					}
+					t.EmitSync("bench", 1, "name")
+				}
+			})
+		})
+	}
+}
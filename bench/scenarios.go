@@ -0,0 +1,101 @@
+package bench
+
+import (
+	"fmt"
+
+	"github.com/yann1989/trigger"
+)
+
+//***************************************************
+//Description : 扇出场景, 一个事件有多个监听
+//param :       监听数量
+//return :      负载场景
+//***************************************************
+func FanOut(listeners int) Scenario {
+	return Scenario{
+		Name: fmt.Sprintf("fan-out/%d", listeners),
+		Setup: func(t *trigger.Trigger, emit EmitFunc) {
+			t.SetMaxListeners(-1)
+			for i := 0; i < listeners; i++ {
+				t.On("bench.fanout", func(id int) {})
+			}
+		},
+		Run: func(t *trigger.Trigger, emit EmitFunc, i int) {
+			emit("bench.fanout", i)
+		},
+	}
+}
+
+//***************************************************
+//Description : 高频注册场景, 每次操作注册一个监听, 触发后再移除
+//return :      负载场景
+//***************************************************
+func Churn() Scenario {
+	return Scenario{
+		Name: "churn",
+		Setup: func(t *trigger.Trigger, emit EmitFunc) {
+			t.SetMaxListeners(-1)
+		},
+		Run: func(t *trigger.Trigger, emit EmitFunc, i int) {
+			key := fmt.Sprint("churn-", i)
+			t.OnNamed("bench.churn", key, func(id int) {})
+			emit("bench.churn", i)
+			t.OffNamed("bench.churn", key)
+		},
+	}
+}
+
+//***************************************************
+//Description : 深层嵌套场景, 监听中逐层触发下一层事件
+//param :       嵌套层数
+//return :      负载场景
+//***************************************************
+func DeepNesting(depth int) Scenario {
+	return Scenario{
+		Name: fmt.Sprintf("deep-nesting/%d", depth),
+		Setup: func(t *trigger.Trigger, emit EmitFunc) {
+			for level := 0; level < depth-1; level++ {
+				next := level + 1
+				t.On(nestedEvent(level), func(id int) { emit(nestedEvent(next), id) })
+			}
+			t.On(nestedEvent(depth-1), func(id int) {})
+		},
+		Run: func(t *trigger.Trigger, emit EmitFunc, i int) {
+			emit(nestedEvent(0), i)
+		},
+	}
+}
+
+// 嵌套场景的事件类型
+type nestedEvent int
+
+//***************************************************
+//Description : 大参数场景, 每次触发携带指定大小的数据
+//param :       数据字节数
+//return :      负载场景
+//***************************************************
+func LargeArgs(size int) Scenario {
+	payload := make([]byte, size)
+	attributes := make(map[string]string, 64)
+	for i := 0; i < 64; i++ {
+		attributes[fmt.Sprint("key-", i)] = fmt.Sprint("value-", i)
+	}
+
+	return Scenario{
+		Name: fmt.Sprintf("large-args/%d", size),
+		Setup: func(t *trigger.Trigger, emit EmitFunc) {
+			t.On("bench.large", func(data []byte, attributes map[string]string) {})
+		},
+		Run: func(t *trigger.Trigger, emit EmitFunc, i int) {
+			emit("bench.large", payload, attributes)
+		},
+	}
+}
+
+//***************************************************
+//Description : 标准场景集合
+//return :      负载场景数组
+//***************************************************
+func Scenarios() []Scenario {
+	return []Scenario{FanOut(16), Churn(), DeepNesting(8), LargeArgs(64 << 10)}
+}
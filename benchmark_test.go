@@ -0,0 +1,56 @@
+package trigger
+
+import (
+	"testing"
+)
+
+func BenchmarkEmitSync(b *testing.B) {
+	trigger := NewTrigger()
+	for i := 0; i < 4; i++ {
+		trigger.On("bench", func(id int, name string) {})
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trigger.EmitSync("bench", i, "name")
+	}
+}
+
+func BenchmarkEmit(b *testing.B) {
+	trigger := NewTrigger()
+	for i := 0; i < 4; i++ {
+		trigger.On("bench", func(id int, name string) {})
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trigger.Emit("bench", i, "name")
+	}
+}
+
+func BenchmarkEmitNoListeners(b *testing.B) {
+	trigger := NewTrigger()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trigger.Emit("nobody", i)
+	}
+}
+
+func BenchmarkEmitSyncParallel(b *testing.B) {
+	trigger := NewTrigger()
+	for i := 0; i < 4; i++ {
+		trigger.On("bench", func(id int, name string) {})
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			trigger.EmitSync("bench", 1, "name")
+		}
+	})
+}
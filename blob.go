@@ -0,0 +1,135 @@
+package trigger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// 大参数存储, 用于认领检查模式: 超过阈值的参数存入存储, 信封或日志中只保留引用, 执行监听前再按引用取回
+type BlobStore interface {
+	// 保存数据, 返回引用
+	Put(ctx context.Context, data []byte) (string, error)
+	// 按引用读取数据
+	Get(ctx context.Context, ref string) ([]byte, error)
+}
+
+// 以目录保存的大参数存储, 文件名为内容的SHA-256, 相同内容只保存一份
+type FileBlobStore struct {
+	// 目录
+	dir string
+}
+
+//***************************************************
+//Description : 创建以目录保存的大参数存储, 目录不存在时创建
+//param :       目录
+//return :      大参数存储
+//return :      创建目录失败的错误
+//***************************************************
+func NewFileBlobStore(dir string) (*FileBlobStore, error) {
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		return nil, err
+	}
+	return &FileBlobStore{dir: dir}, nil
+}
+
+// 保存数据, 引用为内容的SHA-256
+func (store *FileBlobStore) Put(ctx context.Context, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	ref := hex.EncodeToString(sum[:])
+	path := filepath.Join(store.dir, ref)
+	if _, err := os.Stat(path); nil == err {
+		return ref, nil
+	}
+
+	// 先写临时文件再改名, 避免读取到写了一半的内容
+	temp, err := os.CreateTemp(store.dir, ref+".*.tmp")
+	if nil != err {
+		return "", err
+	}
+	if _, err := temp.Write(data); nil != err {
+		temp.Close()
+		os.Remove(temp.Name())
+		return "", err
+	}
+	if err := temp.Close(); nil != err {
+		os.Remove(temp.Name())
+		return "", err
+	}
+	if err := os.Rename(temp.Name(), path); nil != err {
+		os.Remove(temp.Name())
+		return "", err
+	}
+	return ref, nil
+}
+
+// 按引用读取数据, 引用必须是SHA-256的十六进制形式
+func (store *FileBlobStore) Get(ctx context.Context, ref string) ([]byte, error) {
+	if decoded, err := hex.DecodeString(ref); nil != err || sha256.Size != len(decoded) {
+		return nil, fmt.Errorf("无效的大参数引用: %q", ref)
+	}
+	return os.ReadFile(filepath.Join(store.dir, ref))
+}
+
+//***************************************************
+//Description : 把超过阈值的已编码参数存入大参数存储, 并在原位置替换为null
+//param :       上下文
+//param :       大参数存储
+//param :       阈值字节数
+//param :       已编码的参数
+//return :      参数下标 -> 引用, 没有超过阈值的参数时为nil
+//return :      保存失败的错误
+//***************************************************
+func CheckIn(ctx context.Context, store BlobStore, threshold int, raws []json.RawMessage) (map[int]string, error) {
+	var claims map[int]string
+	for i, raw := range raws {
+		if len(raw) <= threshold {
+			continue
+		}
+		ref, err := store.Put(ctx, raw)
+		if nil != err {
+			return nil, fmt.Errorf("第%d个参数保存失败: %w", i+1, err)
+		}
+		if nil == claims {
+			claims = make(map[int]string)
+		}
+		claims[i] = ref
+		raws[i] = json.RawMessage("null")
+	}
+	return claims, nil
+}
+
+//***************************************************
+//Description : 按引用取回CheckIn替换的参数, 在原位置还原
+//param :       上下文
+//param :       大参数存储, nil时有引用则报告ErrNoBlobStore
+//param :       已编码的参数
+//param :       参数下标 -> 引用
+//return :      读取失败的错误
+//***************************************************
+func CheckOut(ctx context.Context, store BlobStore, raws []json.RawMessage, claims map[int]string) error {
+	if 0 == len(claims) {
+		return nil
+	}
+	if nil == store {
+		return ErrNoBlobStore
+	}
+	for i, ref := range claims {
+		if i < 0 || i >= len(raws) {
+			return fmt.Errorf("大参数引用的下标越界: %d", i)
+		}
+		data, err := store.Get(ctx, ref)
+		if nil != err {
+			return fmt.Errorf("第%d个参数读取失败: %w", i+1, err)
+		}
+		if !json.Valid(data) {
+			return fmt.Errorf("第%d个参数不是有效的JSON", i+1)
+		}
+		raws[i] = data
+	}
+	return nil
+}
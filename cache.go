@@ -0,0 +1,140 @@
+package trigger
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// 缓存的最小清理阈值
+const minCacheSweep = 64
+
+// 缓存键函数, 参数为按回调函数参数列表绑定后的值, 可变参数展开
+type CacheKey func(arguments []interface{}) string
+
+// 监听结果缓存
+type listenerCache struct {
+	// 保护以下字段
+	mu sync.Mutex
+	// 缓存键 -> 结果
+	entries map[string]cachedResult
+	// 数量达到此值时清理过期结果
+	sweepAt int
+}
+
+// 缓存的监听结果
+type cachedResult struct {
+	// 回调函数的返回值
+	results []reflect.Value
+	// 过期时间
+	expires time.Time
+}
+
+//***************************************************
+//Description : 添加带结果缓存的监听, 有效期内相同缓存键的触发不再执行回调函数, 直接返回缓存的返回值
+//              最后一个返回值为非nil的error时不缓存, panic时不缓存
+//param :       事件名称
+//param :       缓存键函数
+//param :       回调函数
+//param :       缓存有效期
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddCachedListener(event interface{}, key CacheKey, listener interface{}, ttl time.Duration) *Trigger {
+	fn := reflect.ValueOf(listener)
+	if reflect.Func != fn.Kind() {
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrNotFunction})
+		return trigger
+	}
+	if nil == key || ttl <= 0 {
+		return trigger.AddListener(event, listener)
+	}
+
+	// 包装方式同Once, 移除时按原回调函数匹配
+	fnType := fn.Type()
+	cache := &listenerCache{entries: make(map[string]cachedResult), sweepAt: minCacheSweep}
+	run := reflect.MakeFunc(fnType, func(values []reflect.Value) []reflect.Value {
+		k := key(boundArguments(fnType, values))
+		if results, ok := cache.load(k); ok {
+			return results
+		}
+
+		results := callValues(fnType, fn, values)
+		if !failedResults(fnType, results) {
+			cache.store(k, results, ttl)
+		}
+		return results
+	}).Interface()
+
+	return trigger.register(event, run, &handler{source: listener})
+}
+
+//***************************************************
+//Description : 调用的AddCachedListener
+//param :       事件名称
+//param :       缓存键函数
+//param :       回调函数
+//param :       缓存有效期
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnCached(event interface{}, key CacheKey, listener interface{}, ttl time.Duration) *Trigger {
+	return trigger.AddCachedListener(event, key, listener, ttl)
+}
+
+//***************************************************
+//Description : 读取未过期的结果
+//param :       缓存键
+//return :      回调函数的返回值
+//return :      是否命中
+//***************************************************
+func (cache *listenerCache) load(key string) ([]reflect.Value, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry, ok := cache.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.results, true
+}
+
+//***************************************************
+//Description : 保存结果, 数量过多时先清理过期结果
+//param :       缓存键
+//param :       回调函数的返回值
+//param :       有效期
+//***************************************************
+func (cache *listenerCache) store(key string, results []reflect.Value, ttl time.Duration) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	now := time.Now()
+	if len(cache.entries) >= cache.sweepAt {
+		for k, entry := range cache.entries {
+			if now.After(entry.expires) {
+				delete(cache.entries, k)
+			}
+		}
+		cache.sweepAt = 2 * len(cache.entries)
+		if cache.sweepAt < minCacheSweep {
+			cache.sweepAt = minCacheSweep
+		}
+	}
+	cache.entries[key] = cachedResult{results: results, expires: now.Add(ttl)}
+}
+
+//***************************************************
+//Description : 返回值是否表示失败, 即最后一个返回值为非nil的error
+//param :       回调函数类型
+//param :       回调函数的返回值
+//return :      是否失败
+//***************************************************
+func failedResults(fnType reflect.Type, results []reflect.Value) bool {
+	if 0 == len(results) || !fnType.Out(len(results)-1).Implements(errorType) {
+		return false
+	}
+	switch last := results[len(results)-1]; last.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return !last.IsNil()
+	}
+	return true
+}
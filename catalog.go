@@ -0,0 +1,193 @@
+package trigger
+
+import (
+	"reflect"
+	"sort"
+)
+
+// 事件目录中的事件声明
+type EventSpec struct {
+	// 事件名称
+	Name string
+	// 载荷类型, 即第一个参数的类型, nil表示不限制
+	Payload reflect.Type
+	// 事件说明
+	Doc string
+	// 是否已弃用
+	Deprecated bool
+	// 弃用后替代的事件名称
+	Replacement string
+	// 弃用后的使用计数
+	usage *deprecationUsage
+}
+
+// 事件名称 -> 事件声明, 写入时复制
+type eventCatalog map[string]EventSpec
+
+//***************************************************
+//Description : 在事件目录中声明事件, 重复声明时覆盖说明与载荷类型, 保留弃用标记
+//              声明了载荷类型的事件, 触发时第一个参数需可赋值给载荷类型, 否则以ValidationError拒绝
+//param :       事件名称
+//param :       载荷样例, 仅使用其类型, 可以传入reflect.Type, nil表示不限制
+//param :       事件说明
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RegisterEvent(name string, payload interface{}, doc string) *Trigger {
+	spec := EventSpec{Name: name, Doc: doc}
+	if t, ok := payload.(reflect.Type); ok {
+		spec.Payload = t
+	} else if nil != payload {
+		spec.Payload = reflect.TypeOf(payload)
+	}
+
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	next := make(eventCatalog)
+	if current := trigger.catalog.Load(); nil != current {
+		for key, value := range *current {
+			next[key] = value
+		}
+	}
+	if current, ok := next[name]; ok {
+		spec.Deprecated, spec.Replacement, spec.usage = current.Deprecated, current.Replacement, current.usage
+	}
+	next[name] = spec
+	trigger.catalog.Store(&next)
+	return trigger
+}
+
+//***************************************************
+//Description : 开启或关闭严格模式
+//              开启后触发或监听未在目录中声明的字符串事件时以ErrUnregisteredEvent拒绝
+//              监听的第一个参数无法接收声明的载荷类型时以ErrPayloadMismatch拒绝注册
+//              触发器自身的元事件与非字符串事件不受限制
+//param :       是否开启
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithStrictEvents(strict bool) *Trigger {
+	trigger.strictEvents.Store(strict)
+	return trigger
+}
+
+//***************************************************
+//Description : 获取事件的声明
+//param :       事件名称
+//return :      事件声明
+//return :      是否已声明
+//***************************************************
+func (trigger *Trigger) EventSpecOf(name string) (EventSpec, bool) {
+	if current := trigger.catalog.Load(); nil != current {
+		spec, ok := (*current)[name]
+		return spec, ok
+	}
+	return EventSpec{}, false
+}
+
+//***************************************************
+//Description : 获取事件目录, 按名称排序
+//return :      全部事件声明
+//***************************************************
+func (trigger *Trigger) RegisteredEvents() []EventSpec {
+	current := trigger.catalog.Load()
+	if nil == current {
+		return nil
+	}
+	specs := make([]EventSpec, 0, len(*current))
+	for _, spec := range *current {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+//***************************************************
+//Description : 查找受目录约束的事件的声明
+//param :       事件类型
+//return :      事件声明, 未声明或不受约束时为零值
+//return :      严格模式下是否拒绝此未声明事件
+//***************************************************
+func (trigger *Trigger) specOf(event interface{}) (EventSpec, bool) {
+	name, ok := event.(string)
+	if !ok || isMetaEvent(event) {
+		return EventSpec{}, false
+	}
+	if spec, ok := trigger.EventSpecOf(name); ok {
+		return spec, false
+	}
+	return EventSpec{}, trigger.strictEvents.Load()
+}
+
+//***************************************************
+//Description : 按事件目录校验触发
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      不符合声明时的错误
+//***************************************************
+func (trigger *Trigger) checkEmit(event interface{}, arguments []interface{}) error {
+	// 没有事件目录时不做校验
+	if nil == trigger.catalog.Load() && !trigger.strictEvents.Load() {
+		return nil
+	}
+	spec, reject := trigger.specOf(event)
+	if reject {
+		return &DispatchError{Event: event, Err: ErrUnregisteredEvent}
+	}
+	trigger.warnDeprecated(spec, DeprecatedEmit)
+	if nil == spec.Payload {
+		return nil
+	}
+	if 0 == len(arguments) {
+		return &ValidationError{Event: event, Err: ErrPayloadMismatch}
+	}
+	// 惰性参数在执行监听时才求值, 无法提前校验
+	if _, lazy := arguments[0].(*LazyArg); lazy {
+		return nil
+	}
+	if !payloadAccepts(spec.Payload, arguments[0]) {
+		return &ValidationError{Event: event, Err: ErrPayloadMismatch}
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 严格模式下按事件目录校验监听
+//param :       事件类型
+//param :       回调函数类型
+//return :      不符合声明时的原因
+//***************************************************
+func (trigger *Trigger) checkListen(event interface{}, fnType reflect.Type) error {
+	spec, reject := trigger.specOf(event)
+	if reject {
+		return ErrUnregisteredEvent
+	}
+	trigger.warnDeprecated(spec, DeprecatedListen)
+	if nil == spec.Payload || !trigger.strictEvents.Load() || 0 == fnType.NumIn() {
+		return nil
+	}
+	in := fnType.In(0)
+	if fnType.IsVariadic() && 1 == fnType.NumIn() {
+		in = in.Elem()
+	}
+	if !spec.Payload.AssignableTo(in) {
+		return ErrPayloadMismatch
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 参数是否可赋值给载荷类型
+//param :       载荷类型
+//param :       参数
+//return :      是否可赋值
+//***************************************************
+func payloadAccepts(payload reflect.Type, argument interface{}) bool {
+	if nil == argument {
+		switch payload.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return true
+		}
+		return false
+	}
+	return reflect.TypeOf(argument).AssignableTo(payload)
+}
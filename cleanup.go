@@ -0,0 +1,82 @@
+package trigger
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// 对象被回收时的触发
+type cleanupEmit struct {
+	// 事件触发器
+	trigger *Trigger
+	// 事件类型
+	event interface{}
+	// 回调函数中的参数
+	arguments []interface{}
+}
+
+var (
+	// 保护cleanups
+	cleanupMu sync.Mutex
+	// 对象地址 -> 对象被回收时的触发, 每个对象只设置一次终结器, 同一对象的多次注册在此合并
+	cleanups = make(map[uintptr][]cleanupEmit)
+)
+
+//***************************************************
+//Description : 对象被垃圾回收时触发事件, 用于观察缓存淘汰, 连接回收等资源释放
+//              基于runtime.SetFinalizer, 同一对象可以注册多个, 触发时间由垃圾回收决定, 程序退出前可能不会触发
+//              对象需为new或取地址的复合字面量返回的指针, 且不能另外设置终结器, 否则runtime会panic
+//              参数中不能引用该对象, 否则对象永远不会被回收; 处于引用环中的对象与不含指针的小对象可能不触发
+//              触发在新协程中执行, 不阻塞运行时的终结器协程, 执行期间计入WaitIdle
+//param :       对象指针
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitOnCleanup(obj interface{}, event interface{}, arguments ...interface{}) *Trigger {
+	v := reflect.ValueOf(obj)
+	if reflect.Ptr != v.Kind() || v.IsNil() {
+		trigger.report(event, nil, &RegistrationError{Event: event, Err: ErrNotPointer})
+		return trigger
+	}
+
+	key := v.Pointer()
+	cleanupMu.Lock()
+	pending, registered := cleanups[key]
+	cleanups[key] = append(pending, cleanupEmit{trigger: trigger, event: event, arguments: arguments})
+	cleanupMu.Unlock()
+
+	if !registered {
+		runtime.SetFinalizer(obj, runCleanups)
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 终结器, 执行对象上注册的所有触发
+//param :       对象指针
+//***************************************************
+func runCleanups(obj interface{}) {
+	key := reflect.ValueOf(obj).Pointer()
+	cleanupMu.Lock()
+	pending := cleanups[key]
+	delete(cleanups, key)
+	cleanupMu.Unlock()
+
+	for _, c := range pending {
+		c.trigger.emitCleanup(c)
+	}
+}
+
+//***************************************************
+//Description : 对象被回收后触发事件
+//param :       对象被回收时的触发
+//***************************************************
+func (trigger *Trigger) emitCleanup(c cleanupEmit) {
+	trigger.background.Add(1)
+	go func() {
+		defer trigger.background.Add(-1)
+		trigger.Emit(c.event, c.arguments...)
+	}()
+}
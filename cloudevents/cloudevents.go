@@ -0,0 +1,241 @@
+// cloudevents 按CloudEvents 1.0规范编码与解码事件信封, 包括JSON格式与HTTP的binary/structured绑定
+// 用于与Knative, EventBridge等支持CloudEvents的系统互通
+package cloudevents
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+const (
+	// 规范版本
+	SpecVersion = "1.0"
+	// structured模式的内容类型
+	ContentTypeStructured = "application/cloudevents+json"
+	// JSON数据的内容类型
+	ContentTypeJSON = "application/json"
+)
+
+// 规范定义的属性名称, 用于区分扩展属性
+var contextAttributes = map[string]bool{
+	"specversion": true, "id": true, "source": true, "type": true, "subject": true,
+	"time": true, "datacontenttype": true, "dataschema": true, "data": true, "data_base64": true,
+}
+
+// 校验失败
+var ErrInvalidEvent = errors.New("不是有效的CloudEvents事件")
+
+// CloudEvents事件
+type Event struct {
+	// 规范版本, 固定为1.0
+	SpecVersion string
+	// 事件ID, 同一来源内唯一
+	ID string
+	// 事件来源, URI引用
+	Source string
+	// 事件类型, 对应触发器的事件名称
+	Type string
+	// 事件主题
+	Subject string
+	// 发生时间
+	Time time.Time
+	// 数据的内容类型, 为空视为application/json
+	DataContentType string
+	// 数据的schema地址
+	DataSchema string
+	// 数据, 内容类型为JSON时为JSON文本
+	Data []byte
+	// 扩展属性
+	Extensions map[string]string
+}
+
+//***************************************************
+//Description : 创建事件, 数据编码为JSON
+//param :       事件来源
+//param :       事件类型
+//param :       数据, nil表示没有数据, 编码前按trigger.Redact脱敏
+//return :      事件
+//return :      数据编码失败的错误
+//***************************************************
+func New(source, eventType string, data interface{}) (Event, error) {
+	e := Event{
+		SpecVersion: SpecVersion,
+		ID:          newID(),
+		Source:      source,
+		Type:        eventType,
+		Time:        time.Now().UTC(),
+	}
+	if nil != data {
+		raw, err := json.Marshal(trigger.Redact(data))
+		if nil != err {
+			return Event{}, err
+		}
+		e.DataContentType = ContentTypeJSON
+		e.Data = raw
+	}
+	return e, nil
+}
+
+//***************************************************
+//Description : 校验必需属性
+//return :      校验失败时返回包装ErrInvalidEvent的错误
+//***************************************************
+func (e Event) Validate() error {
+	switch {
+	case SpecVersion != e.SpecVersion:
+		return fmt.Errorf("%w: 不支持的版本%q", ErrInvalidEvent, e.SpecVersion)
+	case "" == e.ID:
+		return fmt.Errorf("%w: 缺少id", ErrInvalidEvent)
+	case "" == e.Source:
+		return fmt.Errorf("%w: 缺少source", ErrInvalidEvent)
+	case "" == e.Type:
+		return fmt.Errorf("%w: 缺少type", ErrInvalidEvent)
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 按JSON解码数据
+//param :       解码目标
+//return :      解码失败的错误
+//***************************************************
+func (e Event) DataAs(v interface{}) error {
+	if !e.isJSON() {
+		return fmt.Errorf("数据内容类型%q不是JSON", e.DataContentType)
+	}
+	return json.Unmarshal(e.Data, v)
+}
+
+//***************************************************
+//Description : 编码为JSON格式, 非JSON数据使用data_base64
+//return :      JSON文本
+//return :      编码失败的错误
+//***************************************************
+func (e Event) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, 8+len(e.Extensions))
+	for name, value := range e.Extensions {
+		out[name] = value
+	}
+	out["specversion"] = e.SpecVersion
+	out["id"] = e.ID
+	out["source"] = e.Source
+	out["type"] = e.Type
+	if "" != e.Subject {
+		out["subject"] = e.Subject
+	}
+	if !e.Time.IsZero() {
+		out["time"] = e.Time.Format(time.RFC3339Nano)
+	}
+	if "" != e.DataContentType {
+		out["datacontenttype"] = e.DataContentType
+	}
+	if "" != e.DataSchema {
+		out["dataschema"] = e.DataSchema
+	}
+	if nil != e.Data {
+		if e.isJSON() {
+			out["data"] = json.RawMessage(e.Data)
+		} else {
+			out["data_base64"] = base64.StdEncoding.EncodeToString(e.Data)
+		}
+	}
+	return json.Marshal(out)
+}
+
+//***************************************************
+//Description : 从JSON格式解码
+//param :       JSON文本
+//return :      解码失败的错误
+//***************************************************
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var in map[string]json.RawMessage
+	if err := json.Unmarshal(data, &in); nil != err {
+		return err
+	}
+
+	decoded := Event{}
+	var err error
+	str := func(name string) string {
+		var s string
+		if raw, ok := in[name]; ok && nil == err {
+			err = json.Unmarshal(raw, &s)
+		}
+		return s
+	}
+	decoded.SpecVersion = str("specversion")
+	decoded.ID = str("id")
+	decoded.Source = str("source")
+	decoded.Type = str("type")
+	decoded.Subject = str("subject")
+	decoded.DataContentType = str("datacontenttype")
+	decoded.DataSchema = str("dataschema")
+	if t := str("time"); "" != t && nil == err {
+		decoded.Time, err = time.Parse(time.RFC3339Nano, t)
+	}
+	if raw, ok := in["data"]; ok {
+		decoded.Data = []byte(raw)
+		// 非JSON内容类型的data为JSON字符串
+		if !decoded.isJSON() && nil == err {
+			var s string
+			if nil == json.Unmarshal(raw, &s) {
+				decoded.Data = []byte(s)
+			}
+		}
+	}
+	if b64 := str("data_base64"); "" != b64 && nil == err {
+		decoded.Data, err = base64.StdEncoding.DecodeString(b64)
+	}
+	if nil != err {
+		return err
+	}
+
+	for name, raw := range in {
+		if contextAttributes[name] {
+			continue
+		}
+		if nil == decoded.Extensions {
+			decoded.Extensions = make(map[string]string)
+		}
+		var s string
+		if nil != json.Unmarshal(raw, &s) {
+			s = strings.Trim(string(raw), `"`)
+		}
+		decoded.Extensions[name] = s
+	}
+	*e = decoded
+	return nil
+}
+
+//***************************************************
+//Description : 数据是否为JSON
+//return :      内容类型为空, application/json或+json后缀时为true
+//***************************************************
+func (e Event) isJSON() bool {
+	if "" == e.DataContentType {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(e.DataContentType)
+	if nil != err {
+		return false
+	}
+	return ContentTypeJSON == mediaType || "text/json" == mediaType || strings.HasSuffix(mediaType, "+json")
+}
+
+//***************************************************
+//Description : 生成随机事件ID
+//return :      32位十六进制字符串
+//***************************************************
+func newID() string {
+	var buf [16]byte
+	rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
@@ -0,0 +1,96 @@
+package cloudevents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+type order struct {
+	ID    string `json:"id"`
+	Total int    `json:"total"`
+}
+
+func TestJSON(t *testing.T) {
+	t.Log("测试JSON格式编解码")
+	e, _ := New("/shop", "order.created", order{ID: "1", Total: 100})
+	e.Extensions = map[string]string{"tenant": "acme"}
+	encoded, _ := json.Marshal(e)
+
+	var decoded Event
+	if err := json.Unmarshal(encoded, &decoded); nil != err {
+		t.Fatalf("解码失败: %v", err)
+	}
+	var o order
+	if err := decoded.DataAs(&o); nil != err || 100 != o.Total || "acme" != decoded.Extensions["tenant"] || !decoded.Time.Equal(e.Time) {
+		t.Fatalf("编解码结果错误: %s", encoded)
+	}
+
+	t.Log("测试非JSON数据")
+	e.DataContentType, e.Data = "application/octet-stream", []byte{0, 1, 2}
+	encoded, _ = json.Marshal(e)
+	json.Unmarshal(encoded, &decoded)
+	if !strings.Contains(string(encoded), "data_base64") || 3 != len(decoded.Data) {
+		t.Fatalf("二进制数据编解码错误: %s", encoded)
+	}
+
+	if err := (Event{SpecVersion: SpecVersion}).Validate(); !errors.Is(err, ErrInvalidEvent) {
+		t.Fatalf("缺少属性未校验失败")
+	}
+
+	t.Log("测试数据脱敏")
+	type secret struct {
+		Token string `trigger:"redact"`
+	}
+	e, _ = New("/shop", "order.created", []secret{{Token: "abc"}})
+	if strings.Contains(string(e.Data), "abc") {
+		t.Fatalf("数据未脱敏: %s", e.Data)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	received := make(chan order, 2)
+	tr := trigger.NewTrigger().On("order.created", func(e Event) {
+		var o order
+		e.DataAs(&o)
+		received <- o
+	})
+	server := httptest.NewServer(Handler(tr))
+	defer server.Close()
+
+	for _, structured := range []bool{true, false} {
+		t.Logf("测试HTTP绑定, structured=%v", structured)
+		publisher := &Publisher{URL: server.URL, Source: "/shop", Structured: structured}
+		if err := publisher.Publish(context.Background(), "order.created", order{ID: "1", Total: 100}); nil != err {
+			t.Fatalf("发送失败: %v", err)
+		}
+		select {
+		case o := <-received:
+			if 100 != o.Total {
+				t.Fatalf("收到的数据错误: %+v", o)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("未收到事件")
+		}
+	}
+
+	t.Log("测试转发本地事件")
+	local := trigger.NewTrigger()
+	(&Publisher{URL: server.URL, Source: "/local"}).Attach(local, "order.created")
+	local.EmitSync("order.created", order{Total: 7})
+	if o := <-received; 7 != o.Total {
+		t.Fatalf("转发的数据错误: %+v", o)
+	}
+
+	resp, _ := http.Post(server.URL, "application/json", strings.NewReader("{}"))
+	if http.StatusBadRequest != resp.StatusCode {
+		t.Fatalf("无效事件应返回400: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,252 @@
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// binary模式属性头前缀
+const headerPrefix = "Ce-"
+
+// 单个请求体的大小上限
+const maxBodySize = 1 << 20
+
+//***************************************************
+//Description : 创建携带事件的HTTP请求
+//param :       上下文
+//param :       目标地址
+//param :       事件
+//param :       true使用structured模式, false使用binary模式
+//return :      HTTP请求
+//return :      事件校验或编码失败的错误
+//***************************************************
+func NewRequest(ctx context.Context, url string, e Event, structured bool) (*http.Request, error) {
+	if err := e.Validate(); nil != err {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if nil != err {
+		return nil, err
+	}
+	return req, WriteRequest(req, e, structured)
+}
+
+//***************************************************
+//Description : 将事件写入HTTP请求
+//param :       HTTP请求
+//param :       事件
+//param :       true使用structured模式, false使用binary模式
+//return :      编码失败的错误
+//***************************************************
+func WriteRequest(req *http.Request, e Event, structured bool) error {
+	var body []byte
+	if structured {
+		encoded, err := json.Marshal(e)
+		if nil != err {
+			return err
+		}
+		body = encoded
+		req.Header.Set("Content-Type", ContentTypeStructured)
+	} else {
+		body = e.Data
+		setHeader(req.Header, "specversion", e.SpecVersion)
+		setHeader(req.Header, "id", e.ID)
+		setHeader(req.Header, "source", e.Source)
+		setHeader(req.Header, "type", e.Type)
+		setHeader(req.Header, "subject", e.Subject)
+		setHeader(req.Header, "dataschema", e.DataSchema)
+		if !e.Time.IsZero() {
+			setHeader(req.Header, "time", e.Time.Format(time.RFC3339Nano))
+		}
+		for name, value := range e.Extensions {
+			setHeader(req.Header, name, value)
+		}
+		if "" != e.DataContentType {
+			req.Header.Set("Content-Type", e.DataContentType)
+		}
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 从HTTP请求读取事件, 按Content-Type自动识别structured或binary模式
+//param :       HTTP请求
+//return :      事件
+//return :      读取或校验失败的错误
+//***************************************************
+func ReadRequest(req *http.Request) (Event, error) {
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize))
+	if nil != err {
+		return Event{}, err
+	}
+
+	var e Event
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if ContentTypeStructured == mediaType {
+		if err := json.Unmarshal(body, &e); nil != err {
+			return Event{}, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+		}
+		return e, e.Validate()
+	}
+
+	for name, values := range req.Header {
+		if !strings.HasPrefix(name, headerPrefix) || 0 == len(values) {
+			continue
+		}
+		attribute := strings.ToLower(strings.TrimPrefix(name, headerPrefix))
+		value := values[0]
+		switch attribute {
+		case "specversion":
+			e.SpecVersion = value
+		case "id":
+			e.ID = value
+		case "source":
+			e.Source = value
+		case "type":
+			e.Type = value
+		case "subject":
+			e.Subject = value
+		case "dataschema":
+			e.DataSchema = value
+		case "time":
+			if e.Time, err = time.Parse(time.RFC3339Nano, value); nil != err {
+				return Event{}, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+			}
+		default:
+			if nil == e.Extensions {
+				e.Extensions = make(map[string]string)
+			}
+			e.Extensions[attribute] = value
+		}
+	}
+	e.DataContentType = req.Header.Get("Content-Type")
+	if 0 != len(body) {
+		e.Data = body
+	}
+	return e, e.Validate()
+}
+
+//***************************************************
+//Description : 接收CloudEvents请求并在触发器上同步触发, 事件名称为事件类型, 参数为Event
+//              校验失败返回400, 所有监听执行完后返回202
+//param :       触发器
+//return :      HTTP处理器
+//***************************************************
+func Handler(t *trigger.Trigger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if http.MethodPost != req.Method {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		e, err := ReadRequest(req)
+		if nil != err {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		t.EmitSync(e.Type, e)
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// 将本地事件以CloudEvents格式发送到HTTP地址
+type Publisher struct {
+	// HTTP客户端, 为nil时使用http.DefaultClient
+	Client *http.Client
+	// 目标地址
+	URL string
+	// 事件来源
+	Source string
+	// 是否使用structured模式
+	Structured bool
+	// Attach转发失败时的回调, 为nil时忽略
+	OnError func(eventType string, err error)
+}
+
+//***************************************************
+//Description : 发送事件
+//param :       上下文
+//param :       事件类型
+//param :       数据
+//return :      发送失败或对方返回非2xx状态时的错误
+//***************************************************
+func (p *Publisher) Publish(ctx context.Context, eventType string, data interface{}) error {
+	e, err := New(p.Source, eventType, data)
+	if nil != err {
+		return err
+	}
+	req, err := NewRequest(ctx, p.URL, e, p.Structured)
+	if nil != err {
+		return err
+	}
+
+	client := p.Client
+	if nil == client {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("发送事件[%s]失败: %s", eventType, resp.Status)
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 在触发器上监听指定事件并转发, 只有一个参数时数据为该参数, 否则为参数数组
+//              参数先按触发器的结构体标签与脱敏函数脱敏
+//              发送失败时调用OnError, 同时作为监听的返回值记录在调试记录中
+//param :       触发器
+//param :       字符串类型的事件名称, 同时作为事件类型
+//return :      Publisher
+//***************************************************
+func (p *Publisher) Attach(t *trigger.Trigger, events ...string) *Publisher {
+	for _, event := range events {
+		event := event
+		t.On(event, func(arguments ...interface{}) error {
+			arguments = t.RedactArguments(event, arguments)
+			var data interface{} = arguments
+			if 1 == len(arguments) {
+				data = arguments[0]
+			}
+			err := p.Publish(context.Background(), event, data)
+			if nil != err && nil != p.OnError {
+				p.OnError(event, err)
+			}
+			return err
+		})
+	}
+	return p
+}
+
+//***************************************************
+//Description : 设置binary模式的属性头, 空值不设置
+//param :       请求头
+//param :       属性名称
+//param :       属性值
+//***************************************************
+func setHeader(header http.Header, name, value string) {
+	if "" != value {
+		header.Set(headerPrefix+name, value)
+	}
+}
+
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"text/template"
+)
+
+// 生成文件模板
+var fileTemplate = template.Must(template.New("invokers").Parse(`// Code generated by triggergen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"github.com/yann1989/trigger"
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+)
+
+func init() {
+{{- range .Signatures}}
+	trigger.RegisterInvoker(({{.Type}})(nil), func(listener interface{}, arguments []interface{}) bool {
+		if {{len .Params}} != len(arguments) {
+			return false
+		}
+{{- range $i, $param := .Params}}
+		a{{$i}}, ok := arguments[{{$i}}].({{$param}})
+		if !ok {
+			return false
+		}
+{{- end}}
+		listener.({{.Type}})({{range $i, $param := .Params}}{{if $i}}, {{end}}a{{$i}}{{end}})
+		return true
+	})
+{{- end}}
+}
+`))
+
+// 监听签名
+type signature struct {
+	// 函数类型, 如func(string, int)
+	Type string
+	// 参数类型
+	Params []string
+}
+
+//***************************************************
+//Description : 生成直接调用函数的源码
+//param :       包名
+//param :       导入路径
+//param :       监听签名
+//return :      格式化后的源码
+//return :      签名解析错误
+//***************************************************
+func generate(pkg string, imports []string, types []string) ([]byte, error) {
+	if 0 == len(types) {
+		return nil, errors.New("至少需要一个监听签名")
+	}
+
+	var signatures []signature
+	for _, typ := range types {
+		sig, err := parseSignature(typ)
+		if nil != err {
+			return nil, err
+		}
+		signatures = append(signatures, sig)
+	}
+
+	var buffer bytes.Buffer
+	err := fileTemplate.Execute(&buffer, map[string]interface{}{
+		"Package":    pkg,
+		"Imports":    imports,
+		"Signatures": signatures,
+	})
+	if nil != err {
+		return nil, err
+	}
+	return format.Source(buffer.Bytes())
+}
+
+//***************************************************
+//Description : 解析监听签名
+//param :       函数类型, 如func(string, int)
+//return :      监听签名
+//return :      不是函数类型或为可变参数时的错误
+//***************************************************
+func parseSignature(typ string) (signature, error) {
+	expr, err := parser.ParseExpr(typ)
+	if nil != err {
+		return signature{}, fmt.Errorf("解析签名%q失败: %v", typ, err)
+	}
+	fnType, ok := expr.(*ast.FuncType)
+	if !ok {
+		return signature{}, fmt.Errorf("签名%q不是函数类型", typ)
+	}
+
+	sig := signature{Type: typ}
+	for _, field := range fnType.Params.List {
+		if _, ok := field.Type.(*ast.Ellipsis); ok {
+			return signature{}, fmt.Errorf("签名%q为可变参数, 请使用反射调用", typ)
+		}
+
+		var buffer bytes.Buffer
+		if err := printer.Fprint(&buffer, token.NewFileSet(), field.Type); nil != err {
+			return signature{}, err
+		}
+		// 形如func(a, b int)的写法一个字段对应多个参数
+		count := len(field.Names)
+		if 0 == count {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			sig.Params = append(sig.Params, buffer.String())
+		}
+	}
+	return sig, nil
+}
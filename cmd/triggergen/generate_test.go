@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	source, err := generate("orders", []string{"time"}, []string{"func(string, int)", "func(a, b time.Duration)"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{
+		"trigger.RegisterInvoker((func(string, int))(nil)",
+		"a1, ok := arguments[1].(time.Duration)",
+		"listener.(func(a, b time.Duration))(a0, a1)",
+	} {
+		if !strings.Contains(string(source), expect) {
+			t.Fatalf("生成代码缺少%q:\n%s", expect, source)
+		}
+	}
+
+	if _, err := generate("orders", nil, []string{"func(...int)"}); nil == err {
+		t.Fatal("可变参数签名应返回错误")
+	}
+}
@@ -0,0 +1,50 @@
+// triggergen 为声明的监听签名生成直接调用函数, 使触发时绕过反射
+//
+// 用法:
+//
+//	triggergen -package orders -o trigger_invokers.go -import example.com/orders/model "func(string, int)" "func(model.Order)"
+//
+// 生成的文件在init中调用trigger.RegisterInvoker, 未声明的签名以及可变参数签名仍使用反射调用
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 可重复的字符串参数
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func main() {
+	var imports stringsFlag
+	pkg := flag.String("package", "main", "生成文件的包名")
+	output := flag.String("o", "trigger_invokers.go", "输出文件, -表示标准输出")
+	flag.Var(&imports, "import", "签名中用到的包的导入路径, 可重复")
+	flag.Parse()
+
+	source, err := generate(*pkg, imports, flag.Args())
+	if nil != err {
+		fmt.Fprintln(os.Stderr, "triggergen:", err)
+		os.Exit(1)
+	}
+
+	if "-" == *output {
+		os.Stdout.Write(source)
+		return
+	}
+	if err := os.WriteFile(*output, source, 0644); nil != err {
+		fmt.Fprintln(os.Stderr, "triggergen:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,138 @@
+package trigger
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// 类型反射
+var (
+	errorType           = reflect.TypeOf((*error)(nil)).Elem()
+	rawMessageType      = reflect.TypeOf(json.RawMessage(nil))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// 参数类型转换配置, 创建后不再修改
+type coercion struct {
+	// 自定义转换函数, 键为源类型与目标类型
+	coercers map[[2]reflect.Type]reflect.Value
+}
+
+//***************************************************
+//Description : 开启或关闭参数类型转换
+//              开启后, 类型不匹配的参数按以下顺序尝试转换为回调函数的参数类型:
+//              1. AddCoercer注册的转换函数
+//              2. 数值类型之间的转换, 只在不丢失精度时转换
+//              3. json.RawMessage解析为结构体/map/切片等
+//              4. string解析为实现了encoding.TextUnmarshaler的类型
+//param :       是否开启
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithCoercion(enabled bool) *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	if !enabled {
+		trigger.coercion.Store(nil)
+		return trigger
+	}
+	if nil == trigger.coercion.Load() {
+		trigger.coercion.Store(&coercion{})
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 注册自定义转换函数, 同时开启参数类型转换
+//param :       转换函数, 类型为func(From) (To, error)
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddCoercer(coercer interface{}) *Trigger {
+	fn := reflect.ValueOf(coercer)
+	fnType := fn.Type()
+	if reflect.Func != fn.Kind() || 1 != fnType.NumIn() || 2 != fnType.NumOut() || errorType != fnType.Out(1) {
+		trigger.report(nil, coercer, &RegistrationError{Listener: coercer, Err: errors.New("转换函数类型需为func(From) (To, error)")})
+		return trigger
+	}
+
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	// 复制后替换, 正在执行的调用仍使用旧配置
+	coercers := make(map[[2]reflect.Type]reflect.Value)
+	if current := trigger.coercion.Load(); nil != current {
+		for key, value := range current.coercers {
+			coercers[key] = value
+		}
+	}
+	coercers[[2]reflect.Type{fnType.In(0), fnType.Out(0)}] = fn
+	trigger.coercion.Store(&coercion{coercers: coercers})
+	return trigger
+}
+
+//***************************************************
+//Description : 转换参数类型
+//param :       参数反射
+//param :       目标类型
+//return :      转换后的参数
+//return :      是否可以转换
+//return :      转换失败的错误
+//***************************************************
+func (c *coercion) coerce(value reflect.Value, target reflect.Type) (reflect.Value, bool, error) {
+	// 自定义转换函数
+	if fn, ok := c.coercers[[2]reflect.Type{value.Type(), target}]; ok {
+		results := fn.Call([]reflect.Value{value})
+		if err, _ := results[1].Interface().(error); nil != err {
+			return reflect.Value{}, false, err
+		}
+		return results[0], true, nil
+	}
+
+	// 数值转换
+	if isNumber(value.Kind()) && isNumber(target.Kind()) {
+		converted := value.Convert(target)
+		// 转换回原类型不相等说明丢失精度
+		if converted.Convert(value.Type()).Interface() != value.Interface() {
+			return reflect.Value{}, false, fmt.Errorf("%v超出范围或丢失精度", value.Interface())
+		}
+		return converted, true, nil
+	}
+
+	// json解析
+	if rawMessageType == value.Type() {
+		pointer := reflect.New(target)
+		if err := json.Unmarshal(value.Bytes(), pointer.Interface()); nil != err {
+			return reflect.Value{}, false, err
+		}
+		return pointer.Elem(), true, nil
+	}
+
+	// 文本解析
+	if reflect.String == value.Kind() && reflect.PointerTo(target).Implements(textUnmarshalerType) {
+		pointer := reflect.New(target)
+		if err := pointer.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value.String())); nil != err {
+			return reflect.Value{}, false, err
+		}
+		return pointer.Elem(), true, nil
+	}
+
+	return reflect.Value{}, false, nil
+}
+
+//***************************************************
+//Description : 是否为数值类型
+//param :       类型种类
+//return :      是否为数值
+//***************************************************
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
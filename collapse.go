@@ -0,0 +1,124 @@
+package trigger
+
+import (
+	"context"
+	"sync"
+)
+
+// 合并参数相同的并发请求
+type flightGroup struct {
+	mu sync.Mutex
+	// 请求参数的摘要 -> 进行中的请求
+	flights map[string]*flight
+}
+
+// 进行中的请求
+type flight struct {
+	// 请求结束时关闭
+	done chan struct{}
+	// 回复的参数
+	reply []interface{}
+	// 错误
+	err error
+	// 等待的请求数
+	waiters int
+	// 所有等待方都放弃时取消请求
+	cancel context.CancelFunc
+}
+
+//***************************************************
+//Description : 开启事件的并发请求合并, 参数相同的并发Request只触发一次事件, 共享同一个回复或错误
+//              请求的等待时间由各自的上下文决定, 全部放弃等待时取消此次请求
+//              与WithRequestCache同时开启时先查缓存, 未命中的并发请求再合并
+//param :       请求事件
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithRequestCollapsing(event interface{}) *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	if nil == trigger.requestFlights {
+		trigger.requestFlights = make(map[interface{}]*flightGroup)
+	}
+	if nil == trigger.requestFlights[event] {
+		trigger.requestFlights[event] = &flightGroup{flights: make(map[string]*flight)}
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 关闭事件的并发请求合并, 进行中的请求不受影响
+//param :       请求事件
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveRequestCollapsing(event interface{}) *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	delete(trigger.requestFlights, event)
+	return trigger
+}
+
+//***************************************************
+//Description : 获取事件的并发请求合并
+//param :       请求事件
+//return :      未开启时为nil
+//***************************************************
+func (trigger *Trigger) flightsOf(event interface{}) *flightGroup {
+	trigger.RLock()
+	defer trigger.RUnlock()
+
+	return trigger.requestFlights[event]
+}
+
+//***************************************************
+//Description : 执行请求, 已有参数相同的请求进行中时等待其结果
+//param :       上下文, 结束时返回TimeoutError
+//param :       请求事件
+//param :       请求参数的摘要
+//param :       执行请求
+//return :      回复的参数
+//return :      错误
+//***************************************************
+func (group *flightGroup) do(ctx context.Context, event interface{}, digest string, fetch func(context.Context) ([]interface{}, error)) ([]interface{}, error) {
+	group.mu.Lock()
+	f, ok := group.flights[digest]
+	if ok {
+		f.waiters++
+	} else {
+		var flightCtx context.Context
+		flightCtx, cancel := context.WithCancel(context.Background())
+		f = &flight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		group.flights[digest] = f
+		go func() {
+			reply, err := fetch(flightCtx)
+
+			group.mu.Lock()
+			if group.flights[digest] == f {
+				delete(group.flights, digest)
+			}
+			f.reply, f.err = reply, err
+			group.mu.Unlock()
+			close(f.done)
+			cancel()
+		}()
+	}
+	group.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return append([]interface{}(nil), f.reply...), f.err
+	case <-ctx.Done():
+		group.mu.Lock()
+		f.waiters--
+		if 0 == f.waiters {
+			// 不再有等待方, 之后的请求重新发起
+			if group.flights[digest] == f {
+				delete(group.flights, digest)
+			}
+			f.cancel()
+		}
+		group.mu.Unlock()
+		return nil, &TimeoutError{Event: event, Err: ctx.Err()}
+	}
+}
@@ -0,0 +1,96 @@
+package trigger
+
+import (
+	"fmt"
+)
+
+// 压缩键函数, 按记录参数返回压缩键, 同一事件相同压缩键的记录只保留最新的一条
+// 从文件恢复的记录参数为JSON解码的通用类型
+type CompactionKey func(arguments []interface{}) string
+
+// 支持压缩的触发日志
+type CompactingJournal interface {
+	Journal
+	// 删除remove返回true的记录, 返回删除的数量, 序号保持不变
+	Compact(remove func(Record) bool) (int, error)
+}
+
+//***************************************************
+//Description : 设置事件的日志压缩键, CompactJournal时此事件相同压缩键的记录只保留最新的一条
+//              适用于携带完整状态的事件, 如device.status按设备ID压缩
+//param :       事件名称
+//param :       压缩键函数, nil表示取消压缩
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithCompaction(event string, key CompactionKey) *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	if nil == key {
+		delete(trigger.compactions, event)
+		return trigger
+	}
+	if nil == trigger.compactions {
+		trigger.compactions = make(map[string]CompactionKey)
+	}
+	trigger.compactions[event] = key
+	return trigger
+}
+
+//***************************************************
+//Description : 压缩触发日志, 删除已被相同压缩键的新记录取代的记录
+//              压缩期间追加的记录不受影响, 消费组的提交序号不变, 可由定时任务定期调用
+//return :      删除的记录数量
+//return :      未开启日志或日志不支持压缩时返回包装ErrNoJournal的错误
+//***************************************************
+func (trigger *Trigger) CompactJournal() (int, error) {
+	journal, ok := trigger.Journal().(CompactingJournal)
+	if !ok {
+		return 0, fmt.Errorf("%w: 触发日志不支持压缩", ErrNoJournal)
+	}
+
+	trigger.RLock()
+	compactions := make(map[string]CompactionKey, len(trigger.compactions))
+	for event, key := range trigger.compactions {
+		compactions[event] = key
+	}
+	trigger.RUnlock()
+	if 0 == len(compactions) {
+		return 0, nil
+	}
+
+	records, err := journal.Read(0, 0)
+	if nil != err {
+		return 0, err
+	}
+	// 事件名称与压缩键 -> 最新记录的序号
+	type compactionSlot struct {
+		event string
+		key   string
+	}
+	latest := make(map[compactionSlot]uint64)
+	superseded := make(map[uint64]struct{})
+	for _, record := range records {
+		event, ok := record.Event.(string)
+		if !ok {
+			continue
+		}
+		key := compactions[event]
+		if nil == key {
+			continue
+		}
+		slot := compactionSlot{event: event, key: key(record.Arguments)}
+		if seq, ok := latest[slot]; ok {
+			superseded[seq] = struct{}{}
+		}
+		latest[slot] = record.Seq
+	}
+	if 0 == len(superseded) {
+		return 0, nil
+	}
+
+	return journal.Compact(func(record Record) bool {
+		_, ok := superseded[record.Seq]
+		return ok
+	})
+}
@@ -0,0 +1,110 @@
+package trigger
+
+import (
+	"reflect"
+	"sync"
+)
+
+// 合并模式下单个监听的执行状态
+type conflation struct {
+	// 保护以下字段
+	mu sync.Mutex
+	// 是否正在执行
+	busy bool
+	// 执行期间到达的最新参数, 没有时为nil
+	pending []interface{}
+	// 是否有等待执行的参数
+	waiting bool
+}
+
+//***************************************************
+//Description : 开启或关闭事件的合并模式
+//              开启后监听正在执行时到达的触发不再排队, 只保留最新一次的参数, 在本次执行结束后立即执行
+//              适用于界面刷新, 缓存失效等只关心最新值的事件, 被合并的触发不会执行也不会返回结果
+//param :       事件类型
+//param :       是否开启
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) SetConflation(event interface{}, enabled bool) *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	if enabled {
+		if nil == trigger.conflated {
+			trigger.conflated = make(map[interface{}]bool)
+		}
+		trigger.conflated[event] = true
+	} else {
+		delete(trigger.conflated, event)
+	}
+	for _, h := range trigger.handlersOf(event) {
+		if !enabled {
+			h.conflation.Store(nil)
+		} else if nil == h.conflation.Load() {
+			h.conflation.Store(new(conflation))
+		}
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 为合并模式事件的新监听初始化执行状态, 调用方需持有写锁
+//param :       事件类型
+//param :       监听者数组
+//***************************************************
+func (trigger *Trigger) prepareConflation(event interface{}, handlers []*handler) {
+	if !trigger.conflated[event] {
+		return
+	}
+	for _, h := range handlers {
+		if nil == h.conflation.Load() {
+			h.conflation.Store(new(conflation))
+		}
+	}
+}
+
+//***************************************************
+//Description : 以合并模式调用监听, 正在执行时只记录最新参数, 否则执行并依次执行期间到达的最新参数
+//param :       事件类型
+//param :       监听者
+//param :       执行状态
+//param :       回调函数中的参数
+//return :      第一次执行的返回值, 被合并时为nil
+//return :      第一次执行失败的错误
+//***************************************************
+func (trigger *Trigger) invokeConflated(event interface{}, h *handler, c *conflation, arguments []interface{}) (results []reflect.Value, failure error) {
+	c.mu.Lock()
+	if c.busy {
+		c.pending, c.waiting = arguments, true
+		c.mu.Unlock()
+		return nil, nil
+	}
+	c.busy = true
+	c.mu.Unlock()
+
+	// 监听panic继续抛出时也要释放执行状态, 否则之后的触发都会被合并
+	released := false
+	defer func() {
+		if !released {
+			c.mu.Lock()
+			c.busy, c.pending, c.waiting = false, nil, false
+			c.mu.Unlock()
+		}
+	}()
+
+	results, failure = trigger.call(event, h, arguments)
+	for {
+		c.mu.Lock()
+		if !c.waiting {
+			c.busy = false
+			c.mu.Unlock()
+			released = true
+			return results, failure
+		}
+		next := c.pending
+		c.pending, c.waiting = nil, false
+		c.mu.Unlock()
+
+		trigger.call(event, h, next)
+	}
+}
@@ -0,0 +1,133 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// 支持消费组提交序号的触发日志
+type OffsetJournal interface {
+	Journal
+	// 提交消费组已处理的最后序号
+	Commit(group string, seq uint64) error
+	// 消费组已提交的最后序号, 没有提交时为0
+	Committed(group string) (uint64, error)
+}
+
+// 按游标消费触发日志的消费者, 不同消费组互不影响
+type Consumer struct {
+	// 日志
+	journal OffsetJournal
+	// 触发器, 用于等待新记录
+	trigger *Trigger
+	// 消费组名称
+	group string
+	// 保护position
+	mu sync.Mutex
+	// 已读取的最后序号
+	position uint64
+}
+
+//***************************************************
+//Description : 创建消费者, 消费组有已提交序号时从其后继续, 否则从fromSeq开始
+//param :       消费组名称
+//param :       没有提交记录时第一条读取的序号, 0与1都表示从头开始
+//return :      消费者
+//return :      未开启日志或日志不支持提交时返回包装ErrNoJournal的错误
+//***************************************************
+func (trigger *Trigger) NewConsumer(group string, fromSeq uint64) (*Consumer, error) {
+	journal, ok := trigger.Journal().(OffsetJournal)
+	if !ok {
+		return nil, fmt.Errorf("%w: 消费组[%s]需要支持提交序号的触发日志", ErrNoJournal, group)
+	}
+	committed, err := journal.Committed(group)
+	if nil != err {
+		return nil, err
+	}
+
+	consumer := &Consumer{journal: journal, trigger: trigger, group: group, position: committed}
+	if 0 == committed && fromSeq > 0 {
+		consumer.position = fromSeq - 1
+	}
+	return consumer, nil
+}
+
+//***************************************************
+//Description : 读取之后的最多n条记录并前移游标, 游标不会自动提交
+//param :       最多读取数量, 小于等于0表示不限
+//return :      记录
+//return :      读取失败的错误
+//***************************************************
+func (consumer *Consumer) Fetch(n int) ([]Record, error) {
+	consumer.mu.Lock()
+	defer consumer.mu.Unlock()
+
+	records, err := consumer.journal.Read(consumer.position, n)
+	if nil != err {
+		return nil, err
+	}
+	if 0 != len(records) {
+		consumer.position = records[len(records)-1].Seq
+	}
+	return records, nil
+}
+
+//***************************************************
+//Description : 同Fetch, 没有新记录时等待直到有新记录或上下文结束
+//param :       上下文
+//param :       最多读取数量
+//return :      记录
+//return :      读取失败的错误, 上下文结束时返回TimeoutError
+//***************************************************
+func (consumer *Consumer) FetchWait(ctx context.Context, n int) ([]Record, error) {
+	for {
+		changed := consumer.trigger.journalChanged()
+		records, err := consumer.Fetch(n)
+		if nil != err || 0 != len(records) {
+			return records, err
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, &TimeoutError{Err: ctx.Err()}
+		}
+	}
+}
+
+//***************************************************
+//Description : 提交已处理的最后序号, 重新创建同组的消费者时从其后继续
+//param :       序号
+//return :      提交失败的错误
+//***************************************************
+func (consumer *Consumer) Commit(seq uint64) error {
+	return consumer.journal.Commit(consumer.group, seq)
+}
+
+//***************************************************
+//Description : 已提交的最后序号
+//return :      序号
+//return :      读取失败的错误
+//***************************************************
+func (consumer *Consumer) Committed() (uint64, error) {
+	return consumer.journal.Committed(consumer.group)
+}
+
+//***************************************************
+//Description : 已读取的最后序号
+//return :      序号
+//***************************************************
+func (consumer *Consumer) Position() uint64 {
+	consumer.mu.Lock()
+	defer consumer.mu.Unlock()
+
+	return consumer.position
+}
+
+//***************************************************
+//Description : 消费组名称
+//return :      名称
+//***************************************************
+func (consumer *Consumer) Group() string {
+	return consumer.group
+}
@@ -0,0 +1,190 @@
+// contracts 用于在测试中校验事件的生产者与消费者是否匹配
+// 生产者声明触发的事件及参数类型, 消费者声明监听的事件及回调函数, Verify检查所有消费的事件都有生产者且参数类型一致
+package contracts
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/yann1989/trigger"
+)
+
+var bagType = reflect.TypeOf((*trigger.Bag)(nil))
+
+// 生产者声明
+type production struct {
+	// 生产者名称
+	producer string
+	// 参数类型, nil表示参数为nil
+	payload []reflect.Type
+}
+
+// 消费者声明
+type consumption struct {
+	// 消费者名称
+	consumer string
+	// 回调函数类型
+	fnType reflect.Type
+}
+
+// 事件契约集合
+type Contracts struct {
+	mu sync.Mutex
+	// 事件类型 -> 生产者声明数组
+	produced map[interface{}][]production
+	// 事件类型 -> 消费者声明数组
+	consumed map[interface{}][]consumption
+	// 注册阶段的错误
+	errs []error
+}
+
+//***************************************************
+//Description : 创建事件契约集合
+//return :      事件契约集合
+//***************************************************
+func New() *Contracts {
+	return &Contracts{
+		produced: make(map[interface{}][]production),
+		consumed: make(map[interface{}][]consumption),
+	}
+}
+
+//***************************************************
+//Description : 声明生产者触发的事件
+//param :       生产者名称
+//param :       事件类型
+//param :       参数样例, 仅使用其类型, 可以传入reflect.Type
+//return :      事件契约集合
+//***************************************************
+func (c *Contracts) Produces(producer string, event interface{}, payload ...interface{}) *Contracts {
+	types := make([]reflect.Type, len(payload))
+	for i, sample := range payload {
+		if t, ok := sample.(reflect.Type); ok {
+			types[i] = t
+		} else {
+			types[i] = reflect.TypeOf(sample)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.produced[event] = append(c.produced[event], production{producer: producer, payload: types})
+	return c
+}
+
+//***************************************************
+//Description : 声明消费者监听的事件
+//param :       消费者名称
+//param :       事件类型
+//param :       回调函数, 与注册到触发器上的相同
+//return :      事件契约集合
+//***************************************************
+func (c *Contracts) Consumes(consumer string, event, listener interface{}) *Contracts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fnType := reflect.TypeOf(listener)
+	if nil == fnType || reflect.Func != fnType.Kind() {
+		c.errs = append(c.errs, fmt.Errorf("消费者[%s]监听事件[%v]: %w", consumer, event, trigger.ErrNotFunction))
+		return c
+	}
+	c.consumed[event] = append(c.consumed[event], consumption{consumer: consumer, fnType: fnType})
+	return c
+}
+
+//***************************************************
+//Description : 检查所有契约
+//return :      不匹配的错误数组, 全部匹配时为空
+//***************************************************
+func (c *Contracts) Check() []error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	errs := append([]error(nil), c.errs...)
+	for _, event := range sortedEvents(c.consumed) {
+		productions := c.produced[event]
+		for _, consumption := range c.consumed[event] {
+			if 0 == len(productions) {
+				errs = append(errs, fmt.Errorf("消费者[%s]监听的事件[%v]没有生产者", consumption.consumer, event))
+				continue
+			}
+			for _, production := range productions {
+				if err := compatible(production.payload, consumption.fnType); nil != err {
+					errs = append(errs, fmt.Errorf("事件[%v]生产者[%s]与消费者[%s]不匹配: %w",
+						event, production.producer, consumption.consumer, err))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+//***************************************************
+//Description : 在测试中检查所有契约, 每个不匹配报告一个错误
+//param :       测试对象
+//***************************************************
+func (c *Contracts) Verify(t testing.TB) {
+	t.Helper()
+	for _, err := range c.Check() {
+		t.Error(err)
+	}
+}
+
+//***************************************************
+//Description : 检查参数类型能否传给回调函数, 与触发时的参数绑定规则一致
+//param :       参数类型数组
+//param :       回调函数类型
+//return :      不匹配时的错误
+//***************************************************
+func compatible(payload []reflect.Type, fnType reflect.Type) error {
+	numIn := fnType.NumIn()
+	offset := 0
+	// 接收*trigger.Bag的回调函数, 第一个参数由触发器提供
+	if numIn > 0 && bagType == fnType.In(0) && (0 == len(payload) || bagType != payload[0]) {
+		offset = 1
+	}
+
+	for i, t := range payload {
+		index := i + offset
+		var want reflect.Type
+		switch {
+		case fnType.IsVariadic() && index >= numIn-1:
+			want = fnType.In(numIn - 1).Elem()
+		case index < numIn:
+			want = fnType.In(index)
+		default:
+			return fmt.Errorf("%w: 参数数量%d, 回调函数需要%d", trigger.ErrArgumentMismatch, len(payload), numIn-offset)
+		}
+
+		if nil == t {
+			switch want.Kind() {
+			case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+				continue
+			}
+			return fmt.Errorf("%w: 第%d个参数为nil, 回调函数需要%v", trigger.ErrArgumentMismatch, i+1, want)
+		}
+		if !t.AssignableTo(want) {
+			return fmt.Errorf("%w: 第%d个参数为%v, 回调函数需要%v", trigger.ErrArgumentMismatch, i+1, t, want)
+		}
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 获取排序后的事件类型, 保证错误顺序稳定
+//param :       事件类型 -> 消费者声明数组
+//return :      事件类型数组
+//***************************************************
+func sortedEvents(consumed map[interface{}][]consumption) []interface{} {
+	events := make([]interface{}, 0, len(consumed))
+	for event := range consumed {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return fmt.Sprint(events[i]) < fmt.Sprint(events[j])
+	})
+	return events
+}
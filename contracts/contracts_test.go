@@ -0,0 +1,34 @@
+package contracts
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yann1989/trigger"
+)
+
+func TestContracts(t *testing.T) {
+	t.Log("测试契约匹配")
+	New().
+		Produces("order", "order.created", "id", 100).
+		Produces("refund", "order.created", "id").
+		Consumes("mail", "order.created", func(id string, amount int) {}).
+		Consumes("audit", "order.created", func(bag *trigger.Bag, id string, rest ...int) {}).
+		Verify(t)
+
+	t.Log("测试契约不匹配")
+	errs := New().
+		Produces("order", "order.created", 100).
+		Produces("order", "order.paid", nil).
+		Consumes("mail", "order.created", func(id string) {}).
+		Consumes("stock", "order.shipped", func() {}).
+		Consumes("ledger", "order.paid", func(amount int) {}).
+		Consumes("broken", "order.paid", "不是函数").
+		Check()
+	if 4 != len(errs) {
+		t.Fatalf("不匹配数量错误: %v", errs)
+	}
+	if !errors.Is(errs[0], trigger.ErrNotFunction) || !errors.Is(errs[1], trigger.ErrArgumentMismatch) {
+		t.Fatalf("错误类型错误: %v", errs)
+	}
+}
@@ -0,0 +1,128 @@
+package trigger
+
+import (
+	"reflect"
+)
+
+// 参数复制函数, 返回传入参数的副本, 不能修改传入的参数
+type Copier func(argument interface{}) interface{}
+
+//***************************************************
+//Description : 设置分发时复制参数, 每个监听收到各自的参数副本, 避免监听之间通过共享参数互相影响
+//              Bag, 惰性参数, context.Context与sync包中的类型仍然共享
+//              每个监听每次调用都复制一次, 大参数的事件需权衡开销
+//param :       复制函数, 可使用DeepCopy, nil表示不复制
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithCopyOnDispatch(copier Copier) *Trigger {
+	if nil == copier {
+		trigger.copier.Store(nil)
+		return trigger
+	}
+	trigger.copier.Store(&copier)
+	return trigger
+}
+
+//***************************************************
+//Description : 按复制函数复制本次调用的参数
+//param :       回调函数中的参数
+//return :      参数副本, 未设置复制函数时为原参数
+//***************************************************
+func (trigger *Trigger) copyArguments(arguments []interface{}) []interface{} {
+	copier := trigger.copier.Load()
+	if nil == copier || 0 == len(arguments) {
+		return arguments
+	}
+
+	copied := make([]interface{}, len(arguments))
+	for i, argument := range arguments {
+		if nil == argument || sharedArgument(reflect.TypeOf(argument)) {
+			copied[i] = argument
+			continue
+		}
+		copied[i] = (*copier)(argument)
+	}
+	return copied
+}
+
+//***************************************************
+//Description : 基于反射的深度复制, 沿指针, 切片, 数组, 映射与结构体的导出字段递归复制
+//              未导出字段只做浅复制, 函数与通道共享, 循环引用保持同样的结构
+//param :       参数
+//return :      参数副本
+//***************************************************
+func DeepCopy(argument interface{}) interface{} {
+	if nil == argument {
+		return nil
+	}
+	return deepCopy(reflect.ValueOf(argument), make(map[uintptr]reflect.Value)).Interface()
+}
+
+//***************************************************
+//Description : 深度复制值
+//param :       值
+//param :       已复制的指针 -> 副本, 保持循环引用与共享引用
+//return :      副本
+//***************************************************
+func deepCopy(v reflect.Value, copied map[uintptr]reflect.Value) reflect.Value {
+	if sharedArgument(v.Type()) {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		if c, ok := copied[v.Pointer()]; ok {
+			return c
+		}
+		c := reflect.New(v.Type().Elem())
+		copied[v.Pointer()] = c
+		c.Elem().Set(deepCopy(v.Elem(), copied))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem(), copied))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i), copied))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i), copied))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(deepCopy(iter.Key(), copied), deepCopy(iter.Value(), copied))
+		}
+		return c
+	case reflect.Struct:
+		// 先整体复制, 再替换可设置的导出字段
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := c.Field(i); field.CanSet() {
+				field.Set(deepCopy(v.Field(i), copied))
+			}
+		}
+		return c
+	}
+	return v
+}
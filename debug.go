@@ -0,0 +1,353 @@
+package trigger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 触发器方法的函数名前缀
+var triggerMethodPrefix = reflect.TypeOf(Trigger{}).PkgPath() + ".(*Trigger)."
+
+const (
+	// 记录的触发调用栈最大深度
+	maxTraceStack = 16
+	// 记录的触发原因链最大长度
+	maxTraceCause = 8
+)
+
+// 监听执行状态
+const (
+	traceOK       = "ok"
+	traceError    = "error"
+	tracePanic    = "panic"
+	traceInvalid  = "invalid"
+	traceRemoved  = "removed"
+	traceFiltered = "filtered"
+)
+
+// 调试记录中的监听执行结果
+type traceOutcome struct {
+	// 监听描述
+	listener string
+	// 执行状态
+	status string
+	// 失败的错误
+	err error
+	// 耗时
+	latency time.Duration
+}
+
+// 触发原因, 即执行哪次触发的监听时发起了本次触发
+type traceCause struct {
+	id    uint64
+	event interface{}
+}
+
+// 单次触发的调试记录
+type emitTrace struct {
+	// 所属的调试记录器
+	tracer *debugTracer
+	// 序号
+	id uint64
+	// 事件类型
+	event interface{}
+	// 回调函数中的参数
+	arguments []interface{}
+	// 是否为同步触发
+	sync bool
+	// 触发时间
+	time time.Time
+	// 触发处的调用栈
+	stack []runtime.Frame
+	// 发起触发的协程ID
+	goroutine uint64
+	// 原因链, 由近及远
+	causes []traceCause
+	// 保护listeners
+	mu sync.Mutex
+	// 监听执行结果
+	listeners []traceOutcome
+}
+
+// 触发调试记录器, 保留最近的触发记录
+type debugTracer struct {
+	mu sync.Mutex
+	// 环形缓冲区
+	ring []*emitTrace
+	// 下一条记录写入的位置
+	next int
+	// 记录序号
+	seq uint64
+	// 协程ID -> 正在执行的触发记录
+	active sync.Map
+}
+
+//***************************************************
+//Description : 开启触发调试记录, 保留最近n次触发的调用处, 原因链与每个监听的执行结果
+//              开启后每次触发需要获取调用栈与协程ID, 仅用于调试
+//param :       保留的触发次数, 小于1时关闭
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithDebugTrace(n int) *Trigger {
+	if n < 1 {
+		trigger.tracer.Store(nil)
+		return trigger
+	}
+	trigger.tracer.Store(&debugTracer{ring: make([]*emitTrace, n)})
+	return trigger
+}
+
+//***************************************************
+//Description : 按时间顺序输出保留的触发调试记录
+//param :       输出目标
+//return :      写入错误, 未开启调试记录时返回错误
+//***************************************************
+func (trigger *Trigger) DebugTrace(w io.Writer) error {
+	tracer := trigger.tracer.Load()
+	if nil == tracer {
+		return errors.New("未开启触发调试记录, 见WithDebugTrace")
+	}
+
+	var buf bytes.Buffer
+	for _, trace := range tracer.traces() {
+		trace.dump(&buf)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+//***************************************************
+//Description : 记录一次触发的开始
+//param :       事件触发器
+//param :       事件类型
+//param :       回调函数中的参数
+//param :       本次需要执行的监听者
+//param :       是否为同步触发
+//return :      触发记录
+//***************************************************
+func (tracer *debugTracer) begin(trigger *Trigger, event interface{}, arguments []interface{}, handlers []*handler, sync bool) *emitTrace {
+	trace := &emitTrace{
+		tracer:    tracer,
+		event:     event,
+		arguments: trigger.redact(event, arguments),
+		sync:      sync,
+		time:      time.Now(),
+	}
+	trace.stack = callSite()
+	trace.goroutine = goroutineID()
+
+	// 在监听中发起的触发, 记录原因链
+	if parent, ok := tracer.active.Load(goroutineID()); ok {
+		parent := parent.(*emitTrace)
+		trace.causes = append([]traceCause{{id: parent.id, event: parent.event}}, parent.causes...)
+		if len(trace.causes) > maxTraceCause {
+			trace.causes = trace.causes[:maxTraceCause]
+		}
+	}
+
+	// 被降级或灰度过滤掉的监听
+	for _, h := range trigger.handlersOf(event) {
+		if !h.shadow && !containsHandler(handlers, h) {
+			trace.listeners = append(trace.listeners, traceOutcome{listener: h.describe(), status: traceFiltered})
+		}
+	}
+
+	tracer.mu.Lock()
+	tracer.seq++
+	trace.id = tracer.seq
+	tracer.ring[tracer.next] = trace
+	tracer.next = (tracer.next + 1) % len(tracer.ring)
+	tracer.mu.Unlock()
+	return trace
+}
+
+//***************************************************
+//Description : 标记当前协程正在执行此触发的监听
+//param :       触发记录
+//return :      恢复函数, 监听执行完后调用
+//***************************************************
+func (tracer *debugTracer) enter(trace *emitTrace) func() {
+	id := goroutineID()
+	previous, ok := tracer.active.Load(id)
+	tracer.active.Store(id, trace)
+	return func() {
+		if ok {
+			tracer.active.Store(id, previous)
+		} else {
+			tracer.active.Delete(id)
+		}
+	}
+}
+
+//***************************************************
+//Description : 记录当前协程正在执行的触发中单个监听的结果
+//param :       监听者
+//param :       回调函数的返回值
+//param :       调用失败的错误
+//param :       是否因监听已被移除而跳过
+//param :       耗时
+//***************************************************
+func (tracer *debugTracer) record(h *handler, results []reflect.Value, failure error, skipped bool, latency time.Duration) {
+	value, ok := tracer.active.Load(goroutineID())
+	if !ok {
+		return
+	}
+	trace := value.(*emitTrace)
+
+	outcome := traceOutcome{listener: h.describe(), status: traceOK, err: failure, latency: latency}
+	var validation *ValidationError
+	switch {
+	case skipped:
+		outcome.status = traceRemoved
+	case errors.As(failure, &validation):
+		outcome.status = traceInvalid
+	case nil != failure:
+		outcome.status = tracePanic
+	case nil != resultError(results):
+		outcome.status = traceError
+		outcome.err = resultError(results)
+	}
+
+	trace.mu.Lock()
+	trace.listeners = append(trace.listeners, outcome)
+	trace.mu.Unlock()
+}
+
+//***************************************************
+//Description : 按时间顺序获取保留的触发记录
+//return :      触发记录数组
+//***************************************************
+func (tracer *debugTracer) traces() []*emitTrace {
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+
+	traces := make([]*emitTrace, 0, len(tracer.ring))
+	for i := range tracer.ring {
+		if trace := tracer.ring[(tracer.next+i)%len(tracer.ring)]; nil != trace {
+			traces = append(traces, trace)
+		}
+	}
+	return traces
+}
+
+//***************************************************
+//Description : 输出单次触发记录
+//param :       输出缓冲区
+//***************************************************
+func (trace *emitTrace) dump(buf *bytes.Buffer) {
+	mode := "async"
+	if trace.sync {
+		mode = "sync"
+	}
+	fmt.Fprintf(buf, "#%d %v %s %s goroutine=%d arguments=%v\n", trace.id, trace.event, mode, trace.time.Format("15:04:05.000000"), trace.goroutine, trace.arguments)
+
+	if 0 != len(trace.causes) {
+		buf.WriteString("  cause:")
+		for i, cause := range trace.causes {
+			if 0 != i {
+				buf.WriteString(" <-")
+			}
+			fmt.Fprintf(buf, " #%d %v", cause.id, cause.event)
+		}
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("  listeners:\n")
+	trace.mu.Lock()
+	if 0 == len(trace.listeners) {
+		buf.WriteString("    (none)\n")
+	}
+	for _, outcome := range trace.listeners {
+		fmt.Fprintf(buf, "    %s: %s %v", outcome.listener, outcome.status, outcome.latency)
+		if nil != outcome.err {
+			fmt.Fprintf(buf, " %v", outcome.err)
+		}
+		buf.WriteString("\n")
+	}
+	trace.mu.Unlock()
+
+	buf.WriteString("  stack:\n")
+	for _, frame := range trace.stack {
+		fmt.Fprintf(buf, "    %s\n        %s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+}
+
+//***************************************************
+//Description : 监听的描述, 用于调试输出
+//return :      函数名或接收者类型与方法名, 命名监听附带名称
+//***************************************************
+func (h *handler) describe() string {
+	var name string
+	if nil != h.receiver {
+		name = fmt.Sprintf("%T.%s", h.receiver, h.method)
+	} else if source := reflect.ValueOf(h.source); reflect.Func == source.Kind() {
+		name = runtime.FuncForPC(source.Pointer()).Name()
+	} else {
+		name = fmt.Sprintf("%T", h.source)
+	}
+	if "" != h.key {
+		name += "[" + h.key + "]"
+	}
+	return name
+}
+
+//***************************************************
+//Description : 监听者数组中是否包含某监听者
+//param :       监听者数组
+//param :       监听者
+//return :      是否包含
+//***************************************************
+func containsHandler(handlers []*handler, h *handler) bool {
+	for _, candidate := range handlers {
+		if candidate == h {
+			return true
+		}
+	}
+	return false
+}
+
+//***************************************************
+//Description : 获取触发处的调用栈, 跳过触发器自身的方法
+//return :      调用栈
+//***************************************************
+func callSite() []runtime.Frame {
+	pcs := make([]uintptr, maxTraceStack+8)
+	// 跳过runtime.Callers, callSite与begin
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+
+	var stack []runtime.Frame
+	for len(stack) < maxTraceStack {
+		frame, more := frames.Next()
+		if 0 != len(stack) || !strings.HasPrefix(frame.Function, triggerMethodPrefix) {
+			stack = append(stack, frame)
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+//***************************************************
+//Description : 获取当前协程ID, 仅用于调试记录与继承属性
+//return :      协程ID
+//***************************************************
+func goroutineID() uint64 {
+	var buf [64]byte
+	stack := buf[:runtime.Stack(buf[:], false)]
+	// 格式为"goroutine 123 [running]:"
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i > 0 {
+		stack = stack[:i]
+	}
+	id, _ := strconv.ParseUint(string(stack), 10, 64)
+	return id
+}
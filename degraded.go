@@ -0,0 +1,223 @@
+package trigger
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// 降级模式下最多缓存的调用数量, 超出后直接跳过
+const maxDegradeBuffer = 1024
+
+// 降级模式下非核心监听的处理方式
+type DegradeMode int
+
+const (
+	// 跳过非核心监听
+	DegradeSkip DegradeMode = iota
+	// 缓存非核心监听的调用, 退出降级模式后按顺序补发
+	DegradeBuffer
+)
+
+// 降级模式名称
+func (mode DegradeMode) String() string {
+	if DegradeBuffer == mode {
+		return "buffer"
+	}
+	return "skip"
+}
+
+// 降级模式下缓存的调用
+type bufferedCall struct {
+	// 事件类型
+	event interface{}
+	// 监听者
+	handler *handler
+	// 回调函数中的参数
+	arguments []interface{}
+	// 截止时间, 零值表示不限
+	deadline time.Time
+}
+
+//***************************************************
+//Description : 添加核心监听, 降级模式下仍会执行
+//param :       事件名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddEssentialListener(event, listener interface{}) *Trigger {
+	return trigger.register(event, listener, &handler{essential: true})
+}
+
+//***************************************************
+//Description : 调用的AddEssentialListener
+//param :       事件名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnEssential(event, listener interface{}) *Trigger {
+	return trigger.AddEssentialListener(event, listener)
+}
+
+//***************************************************
+//Description : 进入降级模式, 只有核心监听会被执行
+//param :       非核心监听的处理方式
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EnterDegraded(mode DegradeMode) *Trigger {
+	trigger.degradeMu.Lock()
+	defer trigger.degradeMu.Unlock()
+
+	trigger.degraded.Store(true)
+	trigger.degradeMode = mode
+	return trigger
+}
+
+//***************************************************
+//Description : 退出降级模式, 并在后台协程中按顺序补发缓存的调用, 不等待补发完成
+//              补发期间计入WaitIdle, 补发中的panic即使按PanicPropagate也不会抛出, 而是交给recoverer
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) ExitDegraded() *Trigger {
+	trigger.degradeMu.Lock()
+	buffered := trigger.degradeBuffer
+	trigger.degraded.Store(false)
+	trigger.degradeBuffer = nil
+	trigger.degradeMu.Unlock()
+
+	if 0 == len(buffered) {
+		return trigger
+	}
+	// 不在调用方(如管理接口)中补发, 避免阻塞调用方或把监听的panic抛给调用方
+	trigger.background.Add(1)
+	go func() {
+		defer trigger.background.Add(-1)
+		for _, call := range buffered {
+			trigger.replay(call)
+		}
+	}()
+	return trigger
+}
+
+//***************************************************
+//Description : 补发一次缓存的调用, 已过期则丢弃并报告
+//              后台协程没有可以接收panic的调用方, 按PanicPropagate抛出的panic在此恢复并交给recoverer
+//param :       缓存的调用
+//***************************************************
+func (trigger *Trigger) replay(call bufferedCall) {
+	defer func() {
+		if r := recover(); nil != r {
+			trigger.RLock()
+			recoverer := trigger.recoverer
+			trigger.RUnlock()
+			if nil == recoverer {
+				recoverer = defaultRecoveryFunc
+			}
+			err := &DispatchError{Event: call.event, Listener: call.handler.source, Err: newPanicError(call.event, call.handler, r)}
+			recoverer(call.event, call.handler.source, err)
+		}
+	}()
+
+	if expired(call.deadline) {
+		trigger.reportExpired(Expiry{Event: call.event, Listener: call.handler.source, Arguments: call.arguments, Deadline: call.deadline})
+		return
+	}
+	trigger.invoke(call.event, call.handler, call.arguments)
+}
+
+//***************************************************
+//Description : 降级模式下缓存的调用数量
+//return :      数量
+//***************************************************
+func (trigger *Trigger) degradeDepth() int {
+	trigger.degradeMu.Lock()
+	defer trigger.degradeMu.Unlock()
+
+	return len(trigger.degradeBuffer)
+}
+
+//***************************************************
+//Description : 是否处于降级模式
+//return :      是否降级
+//***************************************************
+func (trigger *Trigger) IsDegraded() bool {
+	return trigger.degraded.Load()
+}
+
+//***************************************************
+//Description : 过滤出降级模式下可以执行的监听者, 其余的跳过或缓存
+//param :       事件类型
+//param :       此事件的监听者数组
+//param :       回调函数中的参数
+//param :       截止时间, 补发时已过期则丢弃
+//return :      可以执行的监听者数组
+//***************************************************
+func (trigger *Trigger) degradeFilter(event interface{}, handlers []*handler, arguments []interface{}, deadline time.Time) []*handler {
+	var admitted, held []*handler
+	for _, h := range handlers {
+		if h.essential {
+			admitted = append(admitted, h)
+		} else {
+			held = append(held, h)
+		}
+	}
+	if 0 == len(held) {
+		return admitted
+	}
+
+	// 只有需要跳过或缓存的监听时才加锁, 加锁前可能已经退出降级模式
+	trigger.degradeMu.Lock()
+	defer trigger.degradeMu.Unlock()
+
+	if !trigger.degraded.Load() {
+		return handlers
+	}
+	for _, h := range held {
+		if DegradeBuffer == trigger.degradeMode && len(trigger.degradeBuffer) < maxDegradeBuffer {
+			trigger.degradeBuffer = append(trigger.degradeBuffer, bufferedCall{event: event, handler: h, arguments: arguments, deadline: deadline})
+		}
+	}
+	return admitted
+}
+
+// 降级状态
+type degradeStatus struct {
+	Degraded bool   `json:"degraded"`
+	Mode     string `json:"mode"`
+	Buffered int    `json:"buffered"`
+}
+
+//***************************************************
+//Description : 降级模式管理接口
+//              GET 查询状态, POST 进入降级模式(参数mode=skip|buffer), DELETE 退出降级模式, 缓存的调用在后台补发
+//return :      http处理器
+//***************************************************
+func (trigger *Trigger) DegradedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost, http.MethodPut:
+			mode := DegradeSkip
+			if "buffer" == r.URL.Query().Get("mode") {
+				mode = DegradeBuffer
+			}
+			trigger.EnterDegraded(mode)
+		case http.MethodDelete:
+			trigger.ExitDegraded()
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		trigger.degradeMu.Lock()
+		status := degradeStatus{
+			Degraded: trigger.degraded.Load(),
+			Mode:     trigger.degradeMode.String(),
+			Buffered: len(trigger.degradeBuffer),
+		}
+		trigger.degradeMu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	})
+}
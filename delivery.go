@@ -0,0 +1,123 @@
+package trigger
+
+import (
+	"reflect"
+	"time"
+)
+
+const (
+	// 至少一次时默认的最大尝试次数
+	defaultDeliveryAttempts = 3
+	// 至少一次时默认的重试间隔
+	defaultDeliveryBackoff = 100 * time.Millisecond
+)
+
+// 事件的送达保证
+type Delivery int
+
+const (
+	// 未声明: 每个监听执行一次, 持久监听执行完再提交序号
+	DeliveryDefault Delivery = iota
+	// 至多一次: 失败不重试, 持久监听先提交序号再执行, 中途退出的记录不会重新执行
+	AtMostOnce
+	// 至少一次: 失败时重试, 持久监听执行成功才提交序号, 桥接等待对方确认并重发, 接收方去重
+	AtLeastOnce
+)
+
+// 事件的送达策略
+type DeliveryPolicy struct {
+	// 送达保证
+	Guarantee Delivery
+	// 至少一次时每次分发的最大尝试次数, 默认3
+	MaxAttempts int
+	// 至少一次时的首次重试间隔, 之后每次翻倍, 默认100毫秒, 桥接以此作为等待确认的超时时间
+	Backoff time.Duration
+}
+
+// 事件 -> 送达策略, 写入时复制
+type deliveryTable map[interface{}]DeliveryPolicy
+
+//***************************************************
+//Description : 声明事件的送达保证, 分发, 持久监听与桥接按此协作
+//              至少一次: 监听panic, 参数不匹配或返回非nil的error时按间隔重试, 每次失败照常报告
+//              持久监听重试用尽后不提交序号, 等待后继续重试此记录; 监听需能承受重复执行
+//              至多一次: 不重试, 持久监听在执行前提交序号
+//param :       事件类型
+//param :       送达策略, Guarantee为DeliveryDefault表示取消声明
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithDelivery(event interface{}, policy DeliveryPolicy) *Trigger {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaultDeliveryAttempts
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = defaultDeliveryBackoff
+	}
+
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	next := make(deliveryTable)
+	if current := trigger.deliveries.Load(); nil != current {
+		for key, value := range *current {
+			next[key] = value
+		}
+	}
+	if DeliveryDefault == policy.Guarantee {
+		delete(next, event)
+	} else {
+		next[event] = policy
+	}
+	trigger.deliveries.Store(&next)
+	return trigger
+}
+
+//***************************************************
+//Description : 获取事件的送达策略, 供桥接等组件按声明协作
+//param :       事件类型
+//return :      送达策略, 未声明时Guarantee为DeliveryDefault
+//***************************************************
+func (trigger *Trigger) DeliveryOf(event interface{}) DeliveryPolicy {
+	if current := trigger.deliveries.Load(); nil != current {
+		return (*current)[event]
+	}
+	return DeliveryPolicy{}
+}
+
+//***************************************************
+//Description : 按送达保证调用监听, 至少一次的事件失败时重试
+//param :       事件类型
+//param :       监听者
+//param :       回调函数中的参数
+//param :       单次调用
+//return :      最后一次调用的返回值
+//return :      最后一次调用的错误
+//***************************************************
+func (trigger *Trigger) deliver(event interface{}, h *handler, arguments []interface{}, call func(interface{}, *handler, []interface{}) ([]reflect.Value, error)) ([]reflect.Value, error) {
+	results, err := call(event, h, arguments)
+	if delivered(results, err) {
+		return results, err
+	}
+	policy := trigger.DeliveryOf(event)
+	if AtLeastOnce != policy.Guarantee {
+		return results, err
+	}
+
+	backoff := policy.Backoff
+	for attempt := 1; attempt < policy.MaxAttempts && !delivered(results, err); attempt++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		results, err = call(event, h, arguments)
+	}
+	return results, err
+}
+
+//***************************************************
+//Description : 调用是否成功
+//param :       回调函数的返回值
+//param :       调用失败的错误
+//return :      没有错误且最后一个返回值不是非nil的error
+//***************************************************
+func delivered(results []reflect.Value, err error) bool {
+	return nil == err && nil == resultError(results)
+}
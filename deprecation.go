@@ -0,0 +1,108 @@
+package trigger
+
+import (
+	"sync/atomic"
+)
+
+// 使用已弃用事件时触发的元事件, 参数为Deprecation, 每个事件的触发与监听各报告一次
+const DeprecatedEvent = "trigger.deprecated_event"
+
+// 已弃用事件的使用方式
+const (
+	// 触发
+	DeprecatedEmit = "emit"
+	// 监听
+	DeprecatedListen = "listen"
+)
+
+// 一次已弃用事件的使用
+type Deprecation struct {
+	// 事件名称
+	Event string
+	// 替代的事件名称, 可为空
+	Replacement string
+	// 使用方式, DeprecatedEmit或DeprecatedListen
+	Usage string
+	// 使用处, 只在开启WithProvenance时有值
+	Caller *Provenance
+}
+
+// 已弃用事件的使用计数
+type deprecationUsage struct {
+	// 触发次数
+	emits atomic.Uint64
+	// 监听次数
+	listens atomic.Uint64
+	// 是否已报告触发
+	emitWarned atomic.Bool
+	// 是否已报告监听
+	listenWarned atomic.Bool
+}
+
+//***************************************************
+//Description : 把事件目录中的事件标记为已弃用, 未声明的事件同时加入目录
+//              之后每次触发与监听都计入DeprecatedUsage, 首次触发与首次监听各触发一次DeprecatedEvent
+//              已弃用的事件仍照常执行
+//param :       事件名称
+//param :       替代的事件名称, 可为空
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) DeprecateEvent(name, replacement string) *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	next := make(eventCatalog)
+	if current := trigger.catalog.Load(); nil != current {
+		for key, value := range *current {
+			next[key] = value
+		}
+	}
+	spec, ok := next[name]
+	if !ok {
+		spec.Name = name
+	}
+	if nil == spec.usage {
+		spec.usage = &deprecationUsage{}
+	}
+	spec.Deprecated = true
+	spec.Replacement = replacement
+	next[name] = spec
+	trigger.catalog.Store(&next)
+	return trigger
+}
+
+//***************************************************
+//Description : 获取已弃用事件的使用次数, 用于确认迁移进度
+//return :      事件名称 -> 触发与监听的累计次数
+//***************************************************
+func (trigger *Trigger) DeprecatedUsage() map[string]uint64 {
+	usage := make(map[string]uint64)
+	if current := trigger.catalog.Load(); nil != current {
+		for name, spec := range *current {
+			if spec.Deprecated {
+				usage[name] = spec.usage.emits.Load() + spec.usage.listens.Load()
+			}
+		}
+	}
+	return usage
+}
+
+//***************************************************
+//Description : 记录已弃用事件的使用, 首次使用时报告
+//param :       事件声明
+//param :       使用方式
+//***************************************************
+func (trigger *Trigger) warnDeprecated(spec EventSpec, usage string) {
+	if !spec.Deprecated {
+		return
+	}
+
+	count, warned := &spec.usage.emits, &spec.usage.emitWarned
+	if DeprecatedListen == usage {
+		count, warned = &spec.usage.listens, &spec.usage.listenWarned
+	}
+	count.Add(1)
+	if warned.CompareAndSwap(false, true) {
+		trigger.Emit(DeprecatedEvent, Deprecation{Event: spec.Name, Replacement: spec.Replacement, Usage: usage, Caller: trigger.caller()})
+	}
+}
@@ -0,0 +1,310 @@
+package trigger
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// 派生事件规则的命名监听前缀
+const ruleKeyPrefix = "rule:"
+
+// 派生事件的条件, 作用于第一个参数
+type Condition struct {
+	// 字段路径, 以.分隔, 支持结构体字段名, json标签名与字符串键的map, 为空表示参数本身
+	Field string `json:"field"`
+	// 比较运算符: ==, !=, >, >=, <, <=
+	Op string `json:"op"`
+	// 比较的值, 数值统一按float64比较
+	Value interface{} `json:"value"`
+}
+
+// 派生事件规则: 源事件触发且满足条件时, 以相同参数触发派生事件
+type Rule struct {
+	// 规则名称, 同一源事件内唯一
+	Name string `json:"name"`
+	// 源事件
+	Event interface{} `json:"event"`
+	// 条件, 全部满足才派生
+	When []Condition `json:"when"`
+	// 条件表达式, 语法见Expression, 与When同时满足才派生
+	Where string `json:"where"`
+	// 自定义条件, 与When同时满足才派生, 不能从配置加载
+	Match func(arguments []interface{}) bool `json:"-"`
+	// 派生事件
+	Emit interface{} `json:"emit"`
+	// 投影: 名称 -> 表达式, 非空时以求值结果组成的map[string]interface{}作为派生事件的唯一参数
+	Select map[string]string `json:"select"`
+}
+
+//***************************************************
+//Description : 从JSON配置加载派生事件规则
+//param :       JSON数组
+//return :      规则数组
+//return :      解析失败的错误
+//***************************************************
+func LoadRules(data []byte) ([]Rule, error) {
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); nil != err {
+		return nil, err
+	}
+	return rules, nil
+}
+
+//***************************************************
+//Description : 添加派生事件规则, 源事件的监听执行时同步触发派生事件
+//              同名规则会替换之前的规则
+//param :       规则
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddRule(rule Rule) *Trigger {
+	where, projection, err := rule.compile()
+	if nil != err {
+		trigger.report(rule.Event, nil, &RegistrationError{Event: rule.Event, Err: err})
+		return trigger
+	}
+	return trigger.ReplaceListener(rule.Event, ruleKeyPrefix+rule.Name, func(arguments ...interface{}) {
+		if !rule.matches(arguments) || (nil != where && !where.Match(arguments)) {
+			return
+		}
+		if 0 == len(projection) {
+			trigger.EmitSync(rule.Emit, arguments...)
+			return
+		}
+		projected := make(map[string]interface{}, len(projection))
+		for name, expression := range projection {
+			projected[name] = expression.Eval(arguments)
+		}
+		trigger.EmitSync(rule.Emit, projected)
+	})
+}
+
+//***************************************************
+//Description : 添加多条派生事件规则
+//param :       规则数组
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddRules(rules []Rule) *Trigger {
+	for _, rule := range rules {
+		trigger.AddRule(rule)
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 删除派生事件规则
+//param :       源事件
+//param :       规则名称
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveRule(event interface{}, name string) *Trigger {
+	return trigger.RemoveNamedListener(event, ruleKeyPrefix+name)
+}
+
+//***************************************************
+//Description : 添加派生事件, 以派生事件作为规则名称
+//param :       源事件
+//param :       派生事件
+//param :       条件, nil表示总是派生
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) Derive(event, derived interface{}, match func(arguments []interface{}) bool) *Trigger {
+	return trigger.AddRule(Rule{Name: fmt.Sprint(derived), Event: event, Match: match, Emit: derived})
+}
+
+//***************************************************
+//Description : 校验规则
+//return :      包装ErrInvalidRule的错误
+//***************************************************
+func (rule Rule) validate() error {
+	switch {
+	case "" == rule.Name:
+		return fmt.Errorf("%w: 缺少名称", ErrInvalidRule)
+	case nil == rule.Event || nil == rule.Emit:
+		return fmt.Errorf("%w: 规则[%s]缺少源事件或派生事件", ErrInvalidRule, rule.Name)
+	case rule.Event == rule.Emit:
+		return fmt.Errorf("%w: 规则[%s]的派生事件不能是源事件", ErrInvalidRule, rule.Name)
+	}
+	for _, condition := range rule.When {
+		switch condition.Op {
+		case "==", "!=", ">", ">=", "<", "<=":
+		default:
+			return fmt.Errorf("%w: 规则[%s]不支持的运算符%q", ErrInvalidRule, rule.Name, condition.Op)
+		}
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 校验规则并编译其中的表达式
+//return :      条件表达式, 没有时为nil
+//return :      投影表达式
+//return :      包装ErrInvalidRule的错误
+//***************************************************
+func (rule Rule) compile() (*Expression, map[string]*Expression, error) {
+	if err := rule.validate(); nil != err {
+		return nil, nil, err
+	}
+
+	var where *Expression
+	if "" != rule.Where {
+		expression, err := CompileExpression(rule.Where)
+		if nil != err {
+			return nil, nil, fmt.Errorf("%w: 规则[%s]: %w", ErrInvalidRule, rule.Name, err)
+		}
+		where = expression
+	}
+	projection := make(map[string]*Expression, len(rule.Select))
+	for name, source := range rule.Select {
+		expression, err := CompileExpression(source)
+		if nil != err {
+			return nil, nil, fmt.Errorf("%w: 规则[%s]的投影%s: %w", ErrInvalidRule, rule.Name, name, err)
+		}
+		projection[name] = expression
+	}
+	return where, projection, nil
+}
+
+//***************************************************
+//Description : 参数是否满足规则的所有条件
+//param :       回调函数中的参数
+//return :      是否满足
+//***************************************************
+func (rule Rule) matches(arguments []interface{}) bool {
+	for _, condition := range rule.When {
+		if 0 == len(arguments) || !condition.matches(arguments[0]) {
+			return false
+		}
+	}
+	return nil == rule.Match || rule.Match(arguments)
+}
+
+//***************************************************
+//Description : 值是否满足条件, 字段不存在时不满足
+//param :       参数
+//return :      是否满足
+//***************************************************
+func (condition Condition) matches(argument interface{}) bool {
+	value, ok := fieldValue(argument, condition.Field)
+	if !ok {
+		return false
+	}
+
+	left, leftNumber := toFloat(value)
+	right, rightNumber := toFloat(condition.Value)
+	if leftNumber && rightNumber {
+		return compare(condition.Op, left < right, left == right)
+	}
+	l, r := fmt.Sprint(value), fmt.Sprint(condition.Value)
+	return compare(condition.Op, l < r, l == r)
+}
+
+//***************************************************
+//Description : 按运算符得出比较结果
+//param :       运算符
+//param :       左值是否小于右值
+//param :       左值是否等于右值
+//return :      比较结果
+//***************************************************
+func compare(op string, less, equal bool) bool {
+	switch op {
+	case "==":
+		return equal
+	case "!=":
+		return !equal
+	case ">":
+		return !less && !equal
+	case ">=":
+		return !less
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	}
+	return false
+}
+
+//***************************************************
+//Description : 按路径获取字段值
+//param :       参数
+//param :       字段路径, 以.分隔
+//return :      字段值
+//return :      字段是否存在
+//***************************************************
+func fieldValue(argument interface{}, path string) (interface{}, bool) {
+	v := reflect.ValueOf(argument)
+	if "" == path {
+		return argument, v.IsValid()
+	}
+
+	for _, name := range strings.Split(path, ".") {
+		for reflect.Ptr == v.Kind() || reflect.Interface == v.Kind() {
+			if v.IsNil() {
+				return nil, false
+			}
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Map:
+			if reflect.String != v.Type().Key().Kind() {
+				return nil, false
+			}
+			v = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		case reflect.Struct:
+			v = structField(v, name)
+		default:
+			return nil, false
+		}
+		if !v.IsValid() {
+			return nil, false
+		}
+	}
+	if !v.CanInterface() {
+		return nil, false
+	}
+	return v.Interface(), true
+}
+
+//***************************************************
+//Description : 按字段名或json标签名获取结构体字段
+//param :       结构体反射
+//param :       名称
+//return :      字段反射, 不存在时无效
+//***************************************************
+func structField(v reflect.Value, name string) reflect.Value {
+	if field := v.FieldByName(name); field.IsValid() {
+		return field
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; tag == name {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+//***************************************************
+//Description : 转换为float64
+//param :       值
+//return :      转换结果
+//return :      是否为数值
+//***************************************************
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	if number, ok := value.(json.Number); ok {
+		f, err := number.Float64()
+		return f, nil == err
+	}
+	return 0, false
+}
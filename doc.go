@@ -0,0 +1,16 @@
+// trigger 事件触发器
+//
+// 内存可见性
+//
+// 以下保证与Go内存模型一致, 可以在-race下依赖, 无需在调用方与监听之间额外加锁:
+//
+//   - 调用Emit/EmitSync之前的写入, 对本次触发的所有监听可见, 包括在新协程与执行器中执行的监听
+//   - Emit/EmitSync返回时, 本次触发中普通监听, 串行监听与执行器监听的写入都对调用方可见
+//   - 同一串行监听的前一次调用的写入, 对后一次调用可见
+//   - WaitIdle返回时, 影子监听, 平滑升级与已到期的计划触发等后台任务的写入都对调用方可见
+//
+// 例外: 降级模式下缓存的调用, 合并模式中交由正在执行的触发代为执行的参数, 以及尚未到期的计划触发,
+// 都在触发返回之后执行, 需要WaitIdle或由监听自行同步.
+// 执行器需以通道或锁把调用交给执行协程, 否则以上保证不成立.
+// 监听之间并发执行, 彼此之间没有保证, 共享参数需自行同步或使用WithCopyOnDispatch.
+package trigger
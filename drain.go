@@ -0,0 +1,86 @@
+package trigger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 等待旧触发结束时的最大检查间隔
+const maxDrainPoll = 10 * time.Millisecond
+
+// 触发的读取周期, 用于确认替换注册表之前开始的触发都已结束
+// 触发在读取注册表之前进入当前周期, 切换周期后等待旧周期的触发数量归零
+type readEpoch struct {
+	// 串行切换周期
+	mu sync.Mutex
+	// 当前周期
+	current atomic.Uint32
+	// 两个周期各自正在执行的触发数量
+	readers [2]atomic.Int64
+}
+
+//***************************************************
+//Description : 进入当前周期, 需在读取注册表之前调用
+//return :      进入的周期, 结束时传给leave
+//***************************************************
+func (epoch *readEpoch) enter() uint32 {
+	i := epoch.current.Load() & 1
+	epoch.readers[i].Add(1)
+	return i
+}
+
+//***************************************************
+//Description : 离开周期
+//param :       enter返回的周期
+//***************************************************
+func (epoch *readEpoch) leave(i uint32) {
+	epoch.readers[i].Add(-1)
+}
+
+//***************************************************
+//Description : 切换周期并等待之前开始的触发全部结束
+//***************************************************
+func (epoch *readEpoch) synchronize() {
+	epoch.mu.Lock()
+	defer epoch.mu.Unlock()
+
+	old := (epoch.current.Add(1) - 1) & 1
+	poll := time.Millisecond
+	for 0 != epoch.readers[old].Load() {
+		time.Sleep(poll)
+		if poll *= 2; poll > maxDrainPoll {
+			poll = maxDrainPoll
+		}
+	}
+}
+
+//***************************************************
+//Description : 平滑升级同名监听, 不存在时直接添加
+//              新监听立即接收之后的触发, 已经分发给旧监听的触发仍由旧监听执行完毕
+//              旧监听空闲后在后台移除并释放资源, 不阻塞调用方, 可用WaitIdle等待移除完成
+//              降级模式下缓存的调用不计入, 旧监听移除后跳过
+//param :       事件名称
+//param :       监听名称
+//param :       新版本的回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) UpgradeListener(event interface{}, key string, listener interface{}) *Trigger {
+	replaced, ok := trigger.install(event, listener, &handler{key: key}, replaceHandler)
+	if !ok || 0 == len(replaced) {
+		return trigger
+	}
+
+	trigger.background.Add(1)
+	go func() {
+		defer trigger.background.Add(-1)
+
+		// 读取到旧注册表的触发结束后, 旧监听不会再收到新的调用
+		trigger.epoch.synchronize()
+		for _, old := range replaced {
+			<-old.gate.shut()
+			trigger.shutdownHandler(event, old)
+		}
+	}()
+	return trigger
+}
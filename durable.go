@@ -0,0 +1,230 @@
+package trigger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const (
+	// 持久监听的消费组名称前缀
+	durableGroupPrefix = "durable:"
+	// 持久监听每次读取的记录数量
+	durableBatch = 64
+)
+
+// 持久监听在注册表中使用的事件类型, 不会被触发直接调用
+type durableEvent struct {
+	key string
+}
+
+// 运行中的持久监听
+type durableWorker struct {
+	// 停止消费
+	cancel context.CancelFunc
+	// 消费协程退出后关闭
+	done chan struct{}
+	// 消费者, 用于健康报告中的消费延迟
+	consumer *Consumer
+}
+
+//***************************************************
+//Description : 停止消费并等待正在处理的记录结束
+//***************************************************
+func (worker *durableWorker) stop() {
+	worker.cancel()
+	<-worker.done
+}
+
+//***************************************************
+//Description : 添加持久监听: 不由触发直接调用, 而是按顺序消费触发日志中此事件的记录, 每处理一条提交一次序号
+//              重启后以相同名称注册时从上次提交的序号继续, 已处理的记录不会重复执行, 只有处理中途退出的那一条会重新执行
+//              第一次注册时从之后的新记录开始, 需先开启WithJournal且日志支持提交序号
+//              从文件恢复的参数为json.RawMessage, 需配合WithCoercion解析为回调函数的参数类型
+//              监听panic时按常规方式报告, 序号仍然提交, 不会阻塞后续记录
+//              参数不匹配时报告后不提交并停止消费, 修正后以相同名称重新注册从该记录继续
+//              以WithDelivery声明送达保证后: 至多一次在执行前提交, 至少一次在执行成功后才提交
+//param :       事件类型
+//param :       监听名称, 作为消费组名称的一部分, 需要保持稳定
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddDurableListener(event interface{}, key string, listener interface{}) *Trigger {
+	if reflect.Func != reflect.ValueOf(listener).Kind() {
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrNotFunction})
+		return trigger
+	}
+	journal := trigger.Journal()
+	if nil == journal {
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrNoJournal})
+		return trigger
+	}
+	consumer, err := trigger.NewConsumer(durableGroupPrefix+key, journal.Last()+1)
+	if nil != err {
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: err})
+		return trigger
+	}
+
+	trigger.RemoveDurableListener(key)
+	target := durableEvent{key: key}
+	trigger.ReplaceListener(target, key, listener)
+	handlers := trigger.handlersOf(target)
+	if 0 == len(handlers) {
+		return trigger
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	worker := &durableWorker{cancel: cancel, done: make(chan struct{}), consumer: consumer}
+	trigger.Lock()
+	if nil == trigger.durables {
+		trigger.durables = make(map[string]*durableWorker)
+	}
+	trigger.durables[key] = worker
+	trigger.Unlock()
+
+	go trigger.consumeDurable(ctx, worker, consumer, event, handlers[0])
+	return trigger
+}
+
+//***************************************************
+//Description : 调用的AddDurableListener
+//param :       事件类型
+//param :       监听名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnDurable(event interface{}, key string, listener interface{}) *Trigger {
+	return trigger.AddDurableListener(event, key, listener)
+}
+
+//***************************************************
+//Description : 删除持久监听, 等待正在处理的记录结束, 已提交的序号保留
+//param :       监听名称
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveDurableListener(key string) *Trigger {
+	trigger.Lock()
+	worker := trigger.durables[key]
+	delete(trigger.durables, key)
+	trigger.Unlock()
+
+	if nil != worker {
+		worker.stop()
+		trigger.RemoveNamedListener(durableEvent{key: key}, key)
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 停止所有持久监听, 用于关闭触发器
+//***************************************************
+func (trigger *Trigger) stopDurables() {
+	trigger.Lock()
+	durables := trigger.durables
+	trigger.durables = nil
+	trigger.Unlock()
+
+	for _, worker := range durables {
+		worker.stop()
+	}
+}
+
+//***************************************************
+//Description : 持久监听的消费循环
+//param :       上下文, 取消时退出
+//param :       持久监听
+//param :       消费者
+//param :       事件类型
+//param :       监听者
+//***************************************************
+func (trigger *Trigger) consumeDurable(ctx context.Context, worker *durableWorker, consumer *Consumer, event interface{}, h *handler) {
+	defer close(worker.done)
+
+	for {
+		records, err := consumer.FetchWait(ctx, durableBatch)
+		if nil != ctx.Err() {
+			return
+		}
+		if nil != err {
+			trigger.report(event, h.source, &DispatchError{Event: event, Listener: h.source, Err: fmt.Errorf("读取触发日志失败: %w", err)})
+			return
+		}
+
+		// 其他事件的记录不需处理, 每批只提交一次
+		var skipped uint64
+		for _, record := range records {
+			if record.Event != event {
+				skipped = record.Seq
+				continue
+			}
+			skipped = 0
+
+			// 至多一次先提交, 中途退出时不会重新执行
+			policy := trigger.DeliveryOf(event)
+			if AtMostOnce == policy.Guarantee && !trigger.commitDurable(consumer, event, h, record.Seq) {
+				return
+			}
+			if !trigger.consumeRecord(ctx, event, h, record, policy) {
+				return
+			}
+			if AtMostOnce != policy.Guarantee && !trigger.commitDurable(consumer, event, h, record.Seq) {
+				return
+			}
+			if nil != ctx.Err() {
+				return
+			}
+		}
+		if 0 != skipped && !trigger.commitDurable(consumer, event, h, skipped) {
+			return
+		}
+	}
+}
+
+//***************************************************
+//Description : 持久监听执行一条记录, 至少一次的事件重试用尽后等待并继续重试, 直到成功或退出
+//param :       上下文, 取消时退出
+//param :       事件类型
+//param :       监听者
+//param :       记录
+//param :       送达策略
+//return :      是否可以继续, 退出或参数不匹配时为false
+//***************************************************
+func (trigger *Trigger) consumeRecord(ctx context.Context, event interface{}, h *handler, record Record, policy DeliveryPolicy) bool {
+	for {
+		results, err := trigger.deliver(event, h, record.Arguments, trigger.invoke)
+		// 参数不匹配重试也不会成功, 不提交以免丢失记录
+		var invalid *ValidationError
+		if errors.As(err, &invalid) {
+			return false
+		}
+		if AtLeastOnce != policy.Guarantee || delivered(results, err) {
+			return true
+		}
+
+		timer := time.NewTimer(policy.Backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+	}
+}
+
+//***************************************************
+//Description : 提交持久监听的序号, 失败时报告
+//param :       消费者
+//param :       事件类型
+//param :       监听者
+//param :       序号
+//return :      是否提交成功
+//***************************************************
+func (trigger *Trigger) commitDurable(consumer *Consumer, event interface{}, h *handler, seq uint64) bool {
+	if err := consumer.Commit(seq); nil != err {
+		trigger.report(event, h.source, &DispatchError{Event: event, Listener: h.source, Err: fmt.Errorf("提交序号失败: %w", err)})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package trigger
+
+// 事件总线的通用接口, 便于组合多个总线或替换实现
+type Emitter interface {
+	// 添加监听, 同Trigger.On
+	On(event, listener interface{}) Emitter
+	// 添加只执行一次的监听, 同Trigger.Once
+	Once(event, listener interface{}) Emitter
+	// 删除监听, 同Trigger.Off
+	Off(event, listener interface{}) Emitter
+	// 触发事件, 同Trigger.Emit
+	Emit(event interface{}, arguments ...interface{}) Emitter
+	// 同步触发事件, 同Trigger.EmitSync
+	EmitSync(event interface{}, arguments ...interface{}) Emitter
+}
+
+// 将触发器适配为Emitter
+type triggerEmitter struct {
+	trigger *Trigger
+}
+
+//***************************************************
+//Description : 获取触发器的Emitter接口
+//return :      Emitter
+//***************************************************
+func (trigger *Trigger) AsEmitter() Emitter {
+	return triggerEmitter{trigger: trigger}
+}
+
+// 调用的Trigger.On
+func (e triggerEmitter) On(event, listener interface{}) Emitter {
+	e.trigger.On(event, listener)
+	return e
+}
+
+// 调用的Trigger.Once
+func (e triggerEmitter) Once(event, listener interface{}) Emitter {
+	e.trigger.Once(event, listener)
+	return e
+}
+
+// 调用的Trigger.Off
+func (e triggerEmitter) Off(event, listener interface{}) Emitter {
+	e.trigger.Off(event, listener)
+	return e
+}
+
+// 调用的Trigger.Emit
+func (e triggerEmitter) Emit(event interface{}, arguments ...interface{}) Emitter {
+	e.trigger.Emit(event, arguments...)
+	return e
+}
+
+// 调用的Trigger.EmitSync
+func (e triggerEmitter) EmitSync(event interface{}, arguments ...interface{}) Emitter {
+	e.trigger.EmitSync(event, arguments...)
+	return e
+}
@@ -0,0 +1,197 @@
+package trigger
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// 错误原因
+var (
+	ErrNotFunction        = errors.New("传入参数不是函数类型")
+	ErrExceedMaxListeners = errors.New("此事件超过最大监听数量")
+	ErrArgumentMismatch   = errors.New("参数与回调函数不匹配")
+	ErrListenerNotFound   = errors.New("此事件没有找到该监听")
+	ErrMethodNotFound     = errors.New("接收者没有该导出方法")
+	ErrInvalidWeight      = errors.New("监听权重需大于0")
+	ErrInvalidPattern     = errors.New("事件名称正则不能为空")
+	ErrEmitDenied         = errors.New("没有触发此事件的权限")
+	ErrRateLimited        = errors.New("超出触发频率配额")
+	ErrQueueFull          = errors.New("超出同时执行的触发数量配额")
+	ErrInvalidRule        = errors.New("派生事件规则无效")
+	ErrInvalidWindow      = errors.New("聚合窗口配置无效")
+	ErrInvalidJoin        = errors.New("事件关联配置无效")
+	ErrInvalidExpression  = errors.New("表达式无效")
+	ErrDependencyCycle    = errors.New("监听的执行顺序形成循环依赖")
+	ErrNoReplyTarget      = errors.New("没有可回复的地址")
+	ErrNoJournal          = errors.New("没有可用的触发日志")
+	ErrConcurrencyLimit   = errors.New("超出自适应并发上限")
+	ErrMemoryBudget       = errors.New("超出触发参数的内存预算")
+	ErrNoBlobStore        = errors.New("没有配置大参数存储")
+	ErrFailoverMismatch   = errors.New("主备监听的回调函数类型不一致")
+	ErrInvalidExperiment  = errors.New("实验配置无效")
+	ErrUnregisteredEvent  = errors.New("事件未在事件目录中声明")
+	ErrPayloadMismatch    = errors.New("参数与事件声明的载荷类型不一致")
+	ErrArgumentMutated    = errors.New("监听修改了共享的触发参数")
+	ErrNotPointer         = errors.New("清理对象需为非nil指针")
+	ErrNilReceiver        = errors.New("方法监听的接收者不能为nil")
+	ErrClosed             = errors.New("触发器已关闭")
+	ErrExpired            = errors.New("触发已超过截止时间")
+	ErrShed               = errors.New("过载时丢弃了尽力而为的事件")
+)
+
+// 注册/移除监听时的错误
+type RegistrationError struct {
+	// 事件类型
+	Event interface{}
+	// 监听回调函数
+	Listener interface{}
+	// 原因
+	Err error
+}
+
+func (e *RegistrationError) Error() string {
+	return fmt.Sprintf("事件[%v]注册监听失败: %v", e.Event, e.Err)
+}
+
+func (e *RegistrationError) Unwrap() error {
+	return e.Err
+}
+
+// 执行监听时的错误
+type DispatchError struct {
+	// 事件类型
+	Event interface{}
+	// 监听回调函数
+	Listener interface{}
+	// 原因
+	Err error
+}
+
+func (e *DispatchError) Error() string {
+	return fmt.Sprintf("事件[%v]执行监听失败: %v", e.Event, e.Err)
+}
+
+func (e *DispatchError) Unwrap() error {
+	return e.Err
+}
+
+// 超时错误
+type TimeoutError struct {
+	// 事件类型
+	Event interface{}
+	// 监听回调函数
+	Listener interface{}
+	// 原因, 通常为context.DeadlineExceeded或context.Canceled
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("事件[%v]超时: %v", e.Event, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// 参数校验错误
+type ValidationError struct {
+	// 事件类型
+	Event interface{}
+	// 监听回调函数
+	Listener interface{}
+	// 原因
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("事件[%v]参数校验失败: %v", e.Event, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// 触发被权限策略拒绝
+type AuthorizationError struct {
+	// 事件类型
+	Event interface{}
+	// 触发方
+	Source interface{}
+	// 策略返回的原因
+	Err error
+}
+
+func (e *AuthorizationError) Error() string {
+	return fmt.Sprintf("触发方[%v]触发事件[%v]被拒绝: %v", e.Source, e.Event, e.Err)
+}
+
+func (e *AuthorizationError) Unwrap() error {
+	return e.Err
+}
+
+// 监听panic转换的错误, 作为DispatchError的Err, 可通过errors.As获取
+type ListenerPanicError struct {
+	// 事件类型
+	Event interface{}
+	// 监听回调函数
+	Listener interface{}
+	// 监听名称, 格式同调试记录
+	Name string
+	// panic的原始值
+	Value interface{}
+	// panic时的堆栈
+	Stack []byte
+}
+
+func (e *ListenerPanicError) Error() string {
+	return fmt.Sprintf("%v", e.Value)
+}
+
+// panic的值为error时返回该值, 以便errors.Is与errors.As匹配业务错误
+func (e *ListenerPanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// 启动或关闭阶段中组件失败或超时
+type PhaseError struct {
+	// 阶段事件, AppStartEvent或AppStopEvent
+	Phase string
+	// 组件名称, 未命名的监听为监听描述
+	Component string
+	// 原因, 超时时为*TimeoutError
+	Err error
+}
+
+func (e *PhaseError) Error() string {
+	return fmt.Sprintf("阶段[%s]组件[%s]失败: %v", e.Phase, e.Component, e.Err)
+}
+
+func (e *PhaseError) Unwrap() error {
+	return e.Err
+}
+
+//***************************************************
+//Description : 报告错误, 如果未对recoverer赋值, 则直接panic, 否则调用recoverer
+//param :       事件类型
+//param :       监听回调函数
+//param :       错误
+//***************************************************
+func (trigger *Trigger) report(event, listener interface{}, err error) {
+	if nil == trigger.recoverer {
+		panic(err)
+	}
+	trigger.recoverer(event, listener, err)
+}
+
+//***************************************************
+//Description : 把监听中recover到的值转换为错误, 在recover所在的defer中调用以保留堆栈
+//param :       事件类型
+//param :       监听者
+//param :       panic的值
+//return :      ListenerPanicError
+//***************************************************
+func newPanicError(event interface{}, h *handler, r interface{}) *ListenerPanicError {
+	return &ListenerPanicError{Event: event, Listener: h.source, Name: h.describe(), Value: r, Stack: debug.Stack()}
+}
@@ -0,0 +1,123 @@
+package trigger
+
+import (
+	"reflect"
+	"time"
+)
+
+// 执行器, 接收监听的调用函数并负责在合适的协程中执行, 如界面主循环或专用的工作协程
+// 每个交给Execute的函数都必须被执行一次, 否则触发方会一直等待
+type Executor interface {
+	// 执行监听的调用, 可以在其他协程中异步执行
+	Execute(task func())
+}
+
+// 函数形式的执行器
+type ExecutorFunc func(task func())
+
+// 调用函数本身
+func (f ExecutorFunc) Execute(task func()) {
+	f(task)
+}
+
+// 交给执行器的调用信息
+type TaskInfo struct {
+	// 事件类型
+	Event interface{}
+	// 回调函数
+	Listener interface{}
+	// 监听名称, 匿名监听为空
+	Name string
+	// 触发的优先级, 来自继承属性, 默认为0
+	Priority int
+	// 触发的截止时间, 来自继承属性, 零值表示没有
+	Deadline time.Time
+}
+
+// 接收调用信息的执行器, 可按优先级与截止时间调度而不是先进先出, 如最早截止时间优先
+// 执行器实现此接口时触发器调用ExecuteTask而不是Execute
+type TaskExecutor interface {
+	Executor
+	// 执行监听的调用
+	ExecuteTask(info TaskInfo, task func())
+}
+
+//***************************************************
+//Description : 添加绑定执行器的监听, 监听的调用交给执行器执行, 而不是由触发器启动的协程或触发方协程执行
+//              Emit与EmitSync仍等待执行器执行完毕才返回, 监听中的panic在触发方协程中继续抛出
+//param :       事件名称
+//param :       执行器
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddExecutorListener(event interface{}, executor Executor, listener interface{}) *Trigger {
+	if nil == executor {
+		return trigger.register(event, listener, &handler{})
+	}
+	return trigger.register(event, listener, &handler{executor: executor})
+}
+
+//***************************************************
+//Description : 调用的AddExecutorListener
+//param :       事件名称
+//param :       执行器
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnExecutor(event interface{}, executor Executor, listener interface{}) *Trigger {
+	return trigger.AddExecutorListener(event, executor, listener)
+}
+
+//***************************************************
+//Description : 交给执行器调用单个监听并等待结束, 继承属性随调用传递到执行器的协程
+//param :       执行器
+//param :       事件类型
+//param :       监听者
+//param :       回调函数中的参数
+//return :      回调函数的返回值
+//return :      调用失败的错误
+//***************************************************
+func (trigger *Trigger) execute(executor Executor, event interface{}, h *handler, arguments []interface{}) (results []reflect.Value, failure error) {
+	lineage := trigger.currentLineage()
+	var panicValue interface{}
+	done := make(chan struct{})
+	submit(executor, event, h, lineage, func() {
+		defer close(done)
+		defer func() {
+			panicValue = recover()
+		}()
+		if nil != lineage {
+			defer trigger.enterLineage(lineage)()
+		}
+		results, failure = trigger.invokeHere(event, h, arguments)
+	})
+	<-done
+
+	// 在触发方协程中继续抛出
+	if nil != panicValue {
+		panic(panicValue)
+	}
+	return results, failure
+}
+
+//***************************************************
+//Description : 把调用交给执行器, 支持调用信息的执行器同时收到优先级与截止时间
+//param :       执行器
+//param :       事件类型
+//param :       监听者
+//param :       触发的继承属性, 没有时为nil
+//param :       调用
+//***************************************************
+func submit(executor Executor, event interface{}, h *handler, lineage *Lineage, task func()) {
+	scheduler, ok := executor.(TaskExecutor)
+	if !ok {
+		executor.Execute(task)
+		return
+	}
+	info := TaskInfo{Event: event, Listener: h.source, Name: h.key}
+	if nil != lineage {
+		info.Priority = lineage.Priority
+		info.Deadline = lineage.Deadline
+	}
+	scheduler.ExecuteTask(info, task)
+}
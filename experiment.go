@@ -0,0 +1,147 @@
+package trigger
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+)
+
+// 每次分流后触发的元事件, 参数为ExperimentRecord, 用于记录与分析
+const ExperimentEvent = "trigger.experiment"
+
+// 实验的两组
+const (
+	// 对照组
+	VariantControl = "control"
+	// 实验组
+	VariantTreatment = "treatment"
+)
+
+// 实验分流的命名监听前缀
+const experimentKeyPrefix = "experiment:"
+
+// 实验组监听在注册表中使用的事件类型, 不会被触发直接调用
+type experimentEvent struct {
+	name    string
+	variant string
+}
+
+// A/B实验配置
+type Experiment struct {
+	// 实验名称, 同一事件内唯一, 也参与分流哈希, 不同实验的分流互不相关
+	Name string
+	// 进入实验组的百分比, 0到100
+	Percent int
+	// 分流键, 如用户ID, 相同的键总是进入同一组; nil表示第一个参数
+	Key func(arguments []interface{}) string
+}
+
+// 一次分流的记录
+type ExperimentRecord struct {
+	// 实验名称
+	Experiment string
+	// 事件类型
+	Event interface{}
+	// 分流键
+	Key string
+	// 处理此次触发的组, VariantControl或VariantTreatment
+	Variant string
+}
+
+//***************************************************
+//Description : 添加A/B实验: 按分流键的哈希把确定比例的触发交给实验组监听, 其余交给对照组监听
+//              每次分流后触发ExperimentEvent记录处理的组, 同名实验会被替换
+//param :       事件名称
+//param :       实验配置
+//param :       对照组回调函数
+//param :       实验组回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddExperiment(event interface{}, experiment Experiment, control, treatment []interface{}) *Trigger {
+	if "" == experiment.Name || experiment.Percent < 0 || experiment.Percent > 100 {
+		trigger.report(event, nil, &RegistrationError{Event: event, Err: ErrInvalidExperiment})
+		return trigger
+	}
+	for _, listener := range append(control[:len(control):len(control)], treatment...) {
+		if reflect.Func != reflect.ValueOf(listener).Kind() {
+			trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrNotFunction})
+			return trigger
+		}
+	}
+
+	trigger.clearExperiment(experiment.Name)
+	arms := map[string][]interface{}{VariantControl: control, VariantTreatment: treatment}
+	for variant, listeners := range arms {
+		for _, listener := range listeners {
+			trigger.AddListener(experimentEvent{name: experiment.Name, variant: variant}, listener)
+		}
+	}
+
+	return trigger.ReplaceListener(event, experimentKeyPrefix+experiment.Name, func(arguments ...interface{}) {
+		key := experiment.key(arguments)
+		variant := experiment.Assign(key)
+		for _, h := range trigger.handlersOf(experimentEvent{name: experiment.Name, variant: variant}) {
+			trigger.invoke(event, h, arguments)
+		}
+		trigger.Emit(ExperimentEvent, ExperimentRecord{Experiment: experiment.Name, Event: event, Key: key, Variant: variant})
+	})
+}
+
+//***************************************************
+//Description : 删除A/B实验及其两组监听
+//param :       事件名称
+//param :       实验名称
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveExperiment(event interface{}, name string) *Trigger {
+	trigger.RemoveNamedListener(event, experimentKeyPrefix+name)
+	trigger.clearExperiment(name)
+	return trigger
+}
+
+//***************************************************
+//Description : 分流键所属的组, 同一实验名称与分流键总是得到相同结果
+//param :       分流键
+//return :      VariantControl或VariantTreatment
+//***************************************************
+func (experiment Experiment) Assign(key string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(experiment.Name))
+	hash.Write([]byte{0})
+	hash.Write([]byte(key))
+	if int(hash.Sum32()%100) < experiment.Percent {
+		return VariantTreatment
+	}
+	return VariantControl
+}
+
+//***************************************************
+//Description : 计算分流键
+//param :       回调函数中的参数
+//return :      分流键
+//***************************************************
+func (experiment Experiment) key(arguments []interface{}) string {
+	if nil != experiment.Key {
+		return experiment.Key(arguments)
+	}
+	if 0 == len(arguments) {
+		return ""
+	}
+	argument := arguments[0]
+	if lazy, ok := argument.(*lazyValue); ok {
+		argument, _ = lazy.get()
+	}
+	return fmt.Sprint(argument)
+}
+
+//***************************************************
+//Description : 移除实验两组的监听
+//param :       实验名称
+//***************************************************
+func (trigger *Trigger) clearExperiment(name string) {
+	for _, variant := range []string{VariantControl, VariantTreatment} {
+		trigger.removeMatching(experimentEvent{name: name, variant: variant}, true, func(*handler) bool {
+			return true
+		})
+	}
+}
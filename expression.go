@@ -0,0 +1,390 @@
+package trigger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 编译后的求值函数, 返回值与值是否存在
+type evaluator func(arguments []interface{}) (interface{}, bool)
+
+// 运行时表达式, 用于配置中的过滤条件, 投影与派生事件
+//
+// 语法:
+//   - 字段路径: total, user.name 从第一个参数取值; $1.name 从第二个参数取值; $0 为第一个参数本身
+//   - 字面量: 数值, 单引号或双引号字符串, true, false, null
+//   - 比较: == != > >= < <=, 数值按float64比较, 其它按字符串比较, 字段不存在时为false, 与null比较时不存在视为null
+//   - 逻辑: && || ! 或 and or not, 以及括号
+type Expression struct {
+	// 源码
+	source string
+	// 编译结果
+	eval evaluator
+}
+
+//***************************************************
+//Description : 编译表达式
+//param :       表达式源码
+//return :      表达式
+//return :      包装ErrInvalidExpression的错误
+//***************************************************
+func CompileExpression(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if nil != err {
+		return nil, fmt.Errorf("%w: %q: %v", ErrInvalidExpression, source, err)
+	}
+	p := &parser{tokens: tokens}
+	eval, err := p.or()
+	if nil == err && p.pos < len(p.tokens) {
+		err = fmt.Errorf("多余的%q", p.tokens[p.pos].text)
+	}
+	if nil != err {
+		return nil, fmt.Errorf("%w: %q: %v", ErrInvalidExpression, source, err)
+	}
+	return &Expression{source: source, eval: eval}, nil
+}
+
+//***************************************************
+//Description : 编译表达式, 失败时panic, 用于常量表达式
+//param :       表达式源码
+//return :      表达式
+//***************************************************
+func MustCompileExpression(source string) *Expression {
+	expression, err := CompileExpression(source)
+	if nil != err {
+		panic(err)
+	}
+	return expression
+}
+
+//***************************************************
+//Description : 求值
+//param :       回调函数中的参数
+//return :      结果, 不存在时为nil
+//***************************************************
+func (expression *Expression) Eval(arguments []interface{}) interface{} {
+	value, _ := expression.eval(arguments)
+	return value
+}
+
+//***************************************************
+//Description : 参数是否满足表达式, 可直接作为Derive与Rule.Match的条件
+//param :       回调函数中的参数
+//return :      是否满足
+//***************************************************
+func (expression *Expression) Match(arguments []interface{}) bool {
+	return truthy(expression.eval(arguments))
+}
+
+//***************************************************
+//Description : 表达式源码
+//return :      源码
+//***************************************************
+func (expression *Expression) String() string {
+	return expression.source
+}
+
+// 词法单元类型
+const (
+	tokenOperator = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+)
+
+// 词法单元
+type token struct {
+	// 类型
+	kind int
+	// 文本, 字符串为去掉引号后的内容
+	text string
+}
+
+//***************************************************
+//Description : 词法分析
+//param :       表达式源码
+//return :      词法单元数组
+//return :      错误
+//***************************************************
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case '\'' == r || '"' == r:
+			j := i + 1
+			for j < len(runes) && runes[j] != r {
+				j++
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("字符串未结束")
+			}
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[i+1 : j])})
+			i = j + 1
+		case unicode.IsDigit(r) || ('-' == r && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || '.' == runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || '_' == r || '$' == r:
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || strings.ContainsRune("_.$", runes[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[i:j])})
+			i = j
+		default:
+			operator := ""
+			for _, candidate := range []string{"==", "!=", ">=", "<=", "&&", "||", ">", "<", "!", "(", ")"} {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if "" == operator {
+				return nil, fmt.Errorf("无法识别的字符%q", r)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: operator})
+			i += len(operator)
+		}
+	}
+	return tokens, nil
+}
+
+// 递归下降语法分析器
+type parser struct {
+	// 词法单元数组
+	tokens []token
+	// 当前位置
+	pos int
+}
+
+//***************************************************
+//Description : 当前位置是否为指定的运算符或关键字, 是则前进
+//param :       运算符或关键字
+//return :      是否匹配
+//***************************************************
+func (p *parser) accept(texts ...string) bool {
+	if p.pos >= len(p.tokens) || tokenNumber == p.tokens[p.pos].kind || tokenString == p.tokens[p.pos].kind {
+		return false
+	}
+	for _, text := range texts {
+		if p.tokens[p.pos].text == text {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+//***************************************************
+//Description : 逻辑或
+//return :      求值函数
+//return :      错误
+//***************************************************
+func (p *parser) or() (evaluator, error) {
+	left, err := p.and()
+	for nil == err && p.accept("||", "or") {
+		var right evaluator
+		if right, err = p.and(); nil == err {
+			l, r := left, right
+			left = func(arguments []interface{}) (interface{}, bool) {
+				return truthy(l(arguments)) || truthy(r(arguments)), true
+			}
+		}
+	}
+	return left, err
+}
+
+//***************************************************
+//Description : 逻辑与
+//return :      求值函数
+//return :      错误
+//***************************************************
+func (p *parser) and() (evaluator, error) {
+	left, err := p.not()
+	for nil == err && p.accept("&&", "and") {
+		var right evaluator
+		if right, err = p.not(); nil == err {
+			l, r := left, right
+			left = func(arguments []interface{}) (interface{}, bool) {
+				return truthy(l(arguments)) && truthy(r(arguments)), true
+			}
+		}
+	}
+	return left, err
+}
+
+//***************************************************
+//Description : 逻辑非
+//return :      求值函数
+//return :      错误
+//***************************************************
+func (p *parser) not() (evaluator, error) {
+	if !p.accept("!", "not") {
+		return p.comparison()
+	}
+	operand, err := p.not()
+	return func(arguments []interface{}) (interface{}, bool) {
+		return !truthy(operand(arguments)), true
+	}, err
+}
+
+//***************************************************
+//Description : 比较
+//return :      求值函数
+//return :      错误
+//***************************************************
+func (p *parser) comparison() (evaluator, error) {
+	left, err := p.operand()
+	if nil != err || p.pos >= len(p.tokens) {
+		return left, err
+	}
+	op := p.tokens[p.pos].text
+	if !p.accept("==", "!=", ">", ">=", "<", "<=") {
+		return left, nil
+	}
+	null := p.pos < len(p.tokens) && tokenIdent == p.tokens[p.pos].kind && "null" == p.tokens[p.pos].text
+	right, err := p.operand()
+	if nil != err {
+		return nil, err
+	}
+
+	if null {
+		if "==" != op && "!=" != op {
+			return nil, fmt.Errorf("null只能用==或!=比较")
+		}
+		return func(arguments []interface{}) (interface{}, bool) {
+			value, ok := left(arguments)
+			return (!ok || nil == value) == ("==" == op), true
+		}, nil
+	}
+	return func(arguments []interface{}) (interface{}, bool) {
+		l, ok := left(arguments)
+		if !ok {
+			return false, true
+		}
+		r, ok := right(arguments)
+		if !ok {
+			return false, true
+		}
+		lf, leftNumber := toFloat(l)
+		rf, rightNumber := toFloat(r)
+		if leftNumber && rightNumber {
+			return compare(op, lf < rf, lf == rf), true
+		}
+		ls, rs := fmt.Sprint(l), fmt.Sprint(r)
+		return compare(op, ls < rs, ls == rs), true
+	}, nil
+}
+
+//***************************************************
+//Description : 操作数: 字面量, 字段路径或括号
+//return :      求值函数
+//return :      错误
+//***************************************************
+func (p *parser) operand() (evaluator, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("表达式不完整")
+	}
+	if p.accept("(") {
+		inner, err := p.or()
+		if nil == err && !p.accept(")") {
+			err = fmt.Errorf("缺少)")
+		}
+		return inner, err
+	}
+
+	t := p.tokens[p.pos]
+	p.pos++
+	switch t.kind {
+	case tokenNumber:
+		number, err := strconv.ParseFloat(t.text, 64)
+		if nil != err {
+			return nil, fmt.Errorf("无效的数值%q", t.text)
+		}
+		return constant(number), nil
+	case tokenString:
+		return constant(t.text), nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return constant(true), nil
+		case "false":
+			return constant(false), nil
+		case "null":
+			return constant(nil), nil
+		case "and", "or", "not":
+			return nil, fmt.Errorf("%q缺少操作数", t.text)
+		}
+		return path(t.text)
+	}
+	return nil, fmt.Errorf("意外的%q", t.text)
+}
+
+//***************************************************
+//Description : 常量求值函数
+//param :       常量
+//return :      求值函数
+//***************************************************
+func constant(value interface{}) evaluator {
+	return func([]interface{}) (interface{}, bool) {
+		return value, true
+	}
+}
+
+//***************************************************
+//Description : 字段路径求值函数
+//param :       字段路径, 可以$N开头指定参数下标
+//return :      求值函数
+//return :      错误
+//***************************************************
+func path(text string) (evaluator, error) {
+	index, field := 0, text
+	if strings.HasPrefix(text, "$") {
+		head := strings.SplitN(text[1:], ".", 2)
+		n, err := strconv.Atoi(head[0])
+		if nil != err || n < 0 {
+			return nil, fmt.Errorf("无效的参数下标%q", text)
+		}
+		index, field = n, ""
+		if 2 == len(head) {
+			field = head[1]
+		}
+	}
+	return func(arguments []interface{}) (interface{}, bool) {
+		if index >= len(arguments) {
+			return nil, false
+		}
+		return fieldValue(arguments[index], field)
+	}, nil
+}
+
+//***************************************************
+//Description : 值是否为真: 存在且不是false, nil, 0或空字符串
+//param :       值
+//param :       值是否存在
+//return :      是否为真
+//***************************************************
+func truthy(value interface{}, ok bool) bool {
+	if !ok || nil == value {
+		return false
+	}
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return "" != v
+	}
+	if number, isNumber := toFloat(value); isNumber {
+		return 0 != number
+	}
+	return true
+}
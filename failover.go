@@ -0,0 +1,239 @@
+package trigger
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// 默认连续失败多少次后切换到备用监听
+	defaultFailoverThreshold = 5
+	// 默认切换后多久再试探主监听
+	defaultFailoverOpenFor = 30 * time.Second
+)
+
+// 主备监听的熔断配置
+type Failover struct {
+	// 主监听连续失败(panic或返回非nil的error)多少次后熔断, 默认5
+	Threshold int
+	// 熔断持续时间, 之后放行一次调用试探主监听, 成功则恢复, 默认30秒
+	OpenFor time.Duration
+}
+
+// 主备监听
+type failover struct {
+	// 熔断配置
+	policy Failover
+	// 主监听, 注销后为nil
+	primary atomic.Pointer[reflect.Value]
+	// 备用监听
+	standby reflect.Value
+	// 保护以下字段
+	mu sync.Mutex
+	// 主监听连续失败次数
+	failures int
+	// 熔断结束时间, 零值表示未熔断
+	openUntil time.Time
+	// 是否有试探主监听的调用正在执行
+	probing bool
+}
+
+//***************************************************
+//Description : 添加主备监听, 以名称注册为一个监听
+//              平时只调用主监听, 主监听熔断或被RemoveFailoverPrimary注销后调用备用监听
+//              熔断结束后放行一次调用试探主监听, 成功则切回主监听, 失败则继续熔断
+//              触发熔断的调用不会再交给备用监听, 其panic与错误照常报告
+//param :       事件名称
+//param :       监听名称
+//param :       主监听回调函数
+//param :       备用监听回调函数, 类型需与主监听相同
+//param :       熔断配置
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddFailoverListener(event interface{}, key string, primary, standby interface{}, policy Failover) *Trigger {
+	primaryFn, standbyFn := reflect.ValueOf(primary), reflect.ValueOf(standby)
+	if reflect.Func != primaryFn.Kind() || reflect.Func != standbyFn.Kind() {
+		trigger.report(event, primary, &RegistrationError{Event: event, Listener: primary, Err: ErrNotFunction})
+		return trigger
+	}
+	if primaryFn.Type() != standbyFn.Type() {
+		trigger.report(event, primary, &RegistrationError{Event: event, Listener: standby, Err: ErrFailoverMismatch})
+		return trigger
+	}
+	if policy.Threshold <= 0 {
+		policy.Threshold = defaultFailoverThreshold
+	}
+	if policy.OpenFor <= 0 {
+		policy.OpenFor = defaultFailoverOpenFor
+	}
+
+	f := &failover{policy: policy, standby: standbyFn}
+	f.primary.Store(&primaryFn)
+	fnType := primaryFn.Type()
+	run := reflect.MakeFunc(fnType, func(values []reflect.Value) []reflect.Value {
+		fn, probe := f.route()
+		if !probe {
+			return callValues(fnType, fn, values)
+		}
+
+		done := false
+		defer func() {
+			if !done {
+				f.record(true)
+			}
+		}()
+		results := callValues(fnType, fn, values)
+		done = true
+		f.record(failedResults(fnType, results))
+		return results
+	}).Interface()
+
+	return trigger.registerAt(event, run, &handler{key: key, source: f}, replaceHandler)
+}
+
+//***************************************************
+//Description : 调用的AddFailoverListener
+//param :       事件名称
+//param :       监听名称
+//param :       主监听回调函数
+//param :       备用监听回调函数
+//param :       熔断配置
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnFailover(event interface{}, key string, primary, standby interface{}, policy Failover) *Trigger {
+	return trigger.AddFailoverListener(event, key, primary, standby, policy)
+}
+
+//***************************************************
+//Description : 注销主备监听中的主监听, 之后的触发都由备用监听处理
+//              删除整个主备监听使用RemoveNamedListener
+//param :       事件名称
+//param :       监听名称
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveFailoverPrimary(event interface{}, key string) *Trigger {
+	if f := trigger.failoverOf(event, key); nil != f {
+		f.primary.Store(nil)
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 主备监听当前是否由备用监听处理
+//param :       事件名称
+//param :       监听名称
+//return :      主监听熔断或已注销时为true, 没有此主备监听时为false
+//***************************************************
+func (trigger *Trigger) FailedOver(event interface{}, key string) bool {
+	f := trigger.failoverOf(event, key)
+	if nil == f {
+		return false
+	}
+	if nil == f.primary.Load() {
+		return true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return !f.openUntil.IsZero()
+}
+
+//***************************************************
+//Description : 熔断状态, 用于健康报告
+//param :       事件类型
+//param :       监听名称
+//return :      熔断状态
+//***************************************************
+func (f *failover) state(event interface{}, key string) CircuitState {
+	state := CircuitState{Event: fmt.Sprint(event), Key: key}
+	if nil == f.primary.Load() {
+		state.Open = true
+		return state
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state.Open, state.Until = !f.openUntil.IsZero(), f.openUntil
+	return state
+}
+
+//***************************************************
+//Description : 查找主备监听
+//param :       事件名称
+//param :       监听名称
+//return :      没有时为nil
+//***************************************************
+func (trigger *Trigger) failoverOf(event interface{}, key string) *failover {
+	for _, h := range trigger.handlersOf(event) {
+		if f, ok := h.source.(*failover); ok && key == h.key {
+			return f
+		}
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 选择本次调用的监听
+//return :      回调函数反射
+//return :      是否调用主监听, 调用后需记录结果
+//***************************************************
+func (f *failover) route() (reflect.Value, bool) {
+	primary := f.primary.Load()
+	if nil == primary {
+		return f.standby, false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.openUntil.IsZero() {
+		return *primary, true
+	}
+	// 熔断结束后同时只放行一次试探
+	if f.probing || time.Now().Before(f.openUntil) {
+		return f.standby, false
+	}
+	f.probing = true
+	return *primary, true
+}
+
+//***************************************************
+//Description : 记录主监听的调用结果
+//param :       是否失败
+//***************************************************
+func (f *failover) record(failed bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	probe := f.probing
+	f.probing = false
+	if !failed {
+		f.failures = 0
+		f.openUntil = time.Time{}
+		return
+	}
+	f.failures++
+	if probe || f.failures >= f.policy.Threshold {
+		f.failures = 0
+		f.openUntil = time.Now().Add(f.policy.OpenFor)
+	}
+}
+
+//***************************************************
+//Description : 以reflect.MakeFunc收到的参数调用回调函数
+//param :       回调函数类型
+//param :       回调函数反射
+//param :       参数, 可变参数已打包为切片
+//return :      返回值
+//***************************************************
+func callValues(fnType reflect.Type, fn reflect.Value, values []reflect.Value) []reflect.Value {
+	if fnType.IsVariadic() {
+		return fn.CallSlice(values)
+	}
+	return fn.Call(values)
+}
@@ -0,0 +1,398 @@
+package trigger
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 以文件保存的触发日志, 记录按行追加为JSON, 消费组的提交序号保存在同名的.offsets文件中
+// 打开时加载全部记录, 参数保留为json.RawMessage, 开启WithCoercion后按回调函数的参数类型解析
+// 写入文件与转存的参数先按trigger:"redact"标签脱敏, 重新打开后读到的是脱敏后的值
+type FileJournal struct {
+	// 保护以下字段
+	mu sync.RWMutex
+	// 日志文件路径
+	path string
+	// 追加写入的文件
+	file *os.File
+	// 按序号排列的记录
+	records []Record
+	// 最后一条记录的序号
+	last uint64
+	// 消费组 -> 已提交的最后序号
+	offsets map[string]uint64
+	// 大参数存储, nil表示不转存
+	blobs BlobStore
+	// 转存的阈值字节数
+	blobThreshold int
+	// 序号 -> 转存的参数下标与引用, 这些参数在内存与文件中都为nil, 读取时取回
+	claims map[uint64]map[int]string
+	// 写入文件前的脱敏函数, 由WithJournal设置为触发器的脱敏, nil表示只按结构体标签脱敏
+	redactor Redactor
+}
+
+// 文件中的一行记录
+type fileRecord struct {
+	Record
+	// 转存的参数下标 -> 引用
+	Claims map[int]string `json:"claims,omitempty"`
+}
+
+// 从文件读取的一行记录, 参数保留原始JSON
+type fileLine struct {
+	// 序号
+	Seq uint64 `json:"seq"`
+	// 事件类型
+	Event interface{} `json:"event"`
+	// 回调函数中的参数
+	Arguments []json.RawMessage `json:"arguments"`
+	// 触发时间
+	Time time.Time `json:"time"`
+	// 转存的参数下标 -> 引用
+	Claims map[int]string `json:"claims,omitempty"`
+}
+
+//***************************************************
+//Description : 转换为记录, 参数为json.RawMessage, null为nil
+//return :      记录
+//***************************************************
+func (line fileLine) record() Record {
+	record := Record{Seq: line.Seq, Event: line.Event, Time: line.Time}
+	if nil != line.Arguments {
+		record.Arguments = make([]interface{}, len(line.Arguments))
+		for i, raw := range line.Arguments {
+			if "null" != string(raw) {
+				record.Arguments[i] = raw
+			}
+		}
+	}
+	return record
+}
+
+//***************************************************
+//Description : 打开以文件保存的触发日志, 不存在时创建
+//              写入中途退出留下的不完整的最后一行会被截掉
+//param :       文件路径
+//return :      日志
+//return :      打开或解析失败的错误
+//***************************************************
+func OpenFileJournal(path string) (*FileJournal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if nil != err {
+		return nil, err
+	}
+	journal := &FileJournal{path: path, file: file, offsets: make(map[string]uint64), claims: make(map[uint64]map[int]string)}
+
+	// 完整记录的总长度, 其后为写入中途退出留下的不完整内容
+	var valid int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if nil != err {
+			file.Close()
+			return nil, err
+		}
+		var decoded fileLine
+		if err := json.Unmarshal(line, &decoded); nil != err {
+			file.Close()
+			return nil, err
+		}
+		if 0 != len(decoded.Claims) {
+			journal.claims[decoded.Seq] = decoded.Claims
+		}
+		journal.records = append(journal.records, decoded.record())
+		journal.last = decoded.Seq
+		valid += int64(len(line))
+	}
+	if err := file.Truncate(valid); nil != err {
+		file.Close()
+		return nil, err
+	}
+
+	if data, err := os.ReadFile(journal.offsetsPath()); nil == err {
+		if err := json.Unmarshal(data, &journal.offsets); nil != err {
+			file.Close()
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		file.Close()
+		return nil, err
+	}
+	return journal, nil
+}
+
+// 追加记录
+func (journal *FileJournal) Append(record Record) (uint64, error) {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	record.Seq = journal.last + 1
+	if nil != journal.blobs {
+		claimed, err := journal.checkIn(record)
+		if nil != err {
+			return 0, err
+		}
+		record = claimed
+	}
+	data, err := journal.encode(record)
+	if nil == err {
+		// 不完整的最后一行在打开时已被截掉, 从文件末尾追加
+		if _, err = journal.file.Seek(0, io.SeekEnd); nil == err {
+			_, err = journal.file.Write(append(data, '\n'))
+		}
+	}
+	if nil != err {
+		// 序号未使用, 丢弃转存引用
+		delete(journal.claims, record.Seq)
+		return 0, err
+	}
+	journal.last = record.Seq
+	journal.records = append(journal.records, record)
+	return record.Seq, nil
+}
+
+// 读取序号大于after的记录
+func (journal *FileJournal) Read(after uint64, limit int) ([]Record, error) {
+	journal.mu.RLock()
+	defer journal.mu.RUnlock()
+
+	start := sort.Search(len(journal.records), func(i int) bool {
+		return journal.records[i].Seq > after
+	})
+	end := len(journal.records)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	records := append([]Record(nil), journal.records[start:end]...)
+	for i, record := range records {
+		if claims := journal.claims[record.Seq]; 0 != len(claims) {
+			resolved, err := journal.checkOut(record, claims)
+			if nil != err {
+				return nil, err
+			}
+			records[i] = resolved
+		}
+	}
+	return records, nil
+}
+
+// 最后一条记录的序号
+func (journal *FileJournal) Last() uint64 {
+	journal.mu.RLock()
+	defer journal.mu.RUnlock()
+
+	return journal.last
+}
+
+// 提交消费组已处理的最后序号
+func (journal *FileJournal) Commit(group string, seq uint64) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	journal.offsets[group] = seq
+	data, err := json.Marshal(journal.offsets)
+	if nil != err {
+		return err
+	}
+	// 先写临时文件再改名, 避免写入中途退出损坏文件
+	temp := journal.offsetsPath() + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); nil != err {
+		return err
+	}
+	return os.Rename(temp, journal.offsetsPath())
+}
+
+// 消费组已提交的最后序号
+func (journal *FileJournal) Committed(group string) (uint64, error) {
+	journal.mu.RLock()
+	defer journal.mu.RUnlock()
+
+	return journal.offsets[group], nil
+}
+
+//***************************************************
+//Description : 删除remove返回true的记录并重写日志文件, 其余记录的序号不变
+//param :       是否删除记录
+//return :      删除的数量
+//return :      重写失败的错误, 失败时日志保持原样
+//***************************************************
+func (journal *FileJournal) Compact(remove func(Record) bool) (int, error) {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	kept := make([]Record, 0, len(journal.records))
+	var data []byte
+	var removedSeqs []uint64
+	for _, record := range journal.records {
+		if remove(record) {
+			removedSeqs = append(removedSeqs, record.Seq)
+			continue
+		}
+		line, err := journal.encode(record)
+		if nil != err {
+			return 0, err
+		}
+		data = append(append(data, line...), '\n')
+		kept = append(kept, record)
+	}
+	removed := len(journal.records) - len(kept)
+	if 0 == removed {
+		return 0, nil
+	}
+
+	// 先写临时文件再改名, 避免写入中途退出损坏日志, 改名后继续使用临时文件的句柄追加
+	temp := journal.path + ".tmp"
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if nil != err {
+		return 0, err
+	}
+	if _, err := file.Write(data); nil != err {
+		file.Close()
+		os.Remove(temp)
+		return 0, err
+	}
+	if err := os.Rename(temp, journal.path); nil != err {
+		file.Close()
+		os.Remove(temp)
+		return 0, err
+	}
+	journal.file.Close()
+	journal.file = file
+	journal.records = kept
+	for _, seq := range removedSeqs {
+		delete(journal.claims, seq)
+	}
+	return removed, nil
+}
+
+//***************************************************
+//Description : 开启大参数转存, 之后追加的记录中编码后超过阈值的参数存入大参数存储, 文件中只保留引用
+//              读取时按引用取回, 取回的参数为json.RawMessage, 重新打开日志后需再次设置才能读取转存的参数
+//param :       大参数存储
+//param :       阈值字节数
+//return :      日志
+//***************************************************
+func (journal *FileJournal) WithBlobStore(store BlobStore, threshold int) *FileJournal {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	journal.blobs = store
+	journal.blobThreshold = threshold
+	return journal
+}
+
+//***************************************************
+//Description : 转存超过阈值的参数, 调用方需持有写锁
+//param :       记录
+//return :      转存的参数替换为nil的记录
+//return :      编码或保存失败的错误
+//***************************************************
+func (journal *FileJournal) checkIn(record Record) (Record, error) {
+	raws := make([]json.RawMessage, len(record.Arguments))
+	for i, argument := range journal.redact(record) {
+		raw, err := json.Marshal(argument)
+		if nil != err {
+			return record, err
+		}
+		raws[i] = raw
+	}
+	claims, err := CheckIn(context.Background(), journal.blobs, journal.blobThreshold, raws)
+	if nil != err || 0 == len(claims) {
+		return record, err
+	}
+
+	arguments := append([]interface{}(nil), record.Arguments...)
+	for i := range claims {
+		arguments[i] = nil
+	}
+	record.Arguments = arguments
+	journal.claims[record.Seq] = claims
+	return record, nil
+}
+
+//***************************************************
+//Description : 按引用取回转存的参数, 调用方需持有读锁
+//param :       记录
+//param :       转存的参数下标与引用
+//return :      取回参数的记录
+//return :      读取或解码失败的错误
+//***************************************************
+func (journal *FileJournal) checkOut(record Record, claims map[int]string) (Record, error) {
+	raws := make([]json.RawMessage, len(record.Arguments))
+	if err := CheckOut(context.Background(), journal.blobs, raws, claims); nil != err {
+		return record, err
+	}
+
+	arguments := append([]interface{}(nil), record.Arguments...)
+	for i := range claims {
+		arguments[i] = raws[i]
+	}
+	record.Arguments = arguments
+	return record, nil
+}
+
+//***************************************************
+//Description : 获取写入文件的脱敏参数, 调用方需持有锁
+//param :       记录
+//return :      脱敏后的参数
+//***************************************************
+func (journal *FileJournal) redact(record Record) []interface{} {
+	if nil == journal.redactor {
+		return redactArguments(record.Arguments)
+	}
+	return journal.redactor(record.Event, record.Arguments)
+}
+
+//***************************************************
+//Description : 设置写入文件前的脱敏函数
+//param :       脱敏函数
+//***************************************************
+func (journal *FileJournal) redactWith(redactor Redactor) {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	journal.redactor = redactor
+}
+
+//***************************************************
+//Description : 编码一行记录, 参数先脱敏, 转存的参数附带引用
+//param :       记录
+//return :      JSON
+//return :      编码失败的错误
+//***************************************************
+func (journal *FileJournal) encode(record Record) ([]byte, error) {
+	record.Arguments = journal.redact(record)
+	if claims := journal.claims[record.Seq]; 0 != len(claims) {
+		return json.Marshal(fileRecord{Record: record, Claims: claims})
+	}
+	return json.Marshal(record)
+}
+
+//***************************************************
+//Description : 关闭日志文件
+//return :      关闭失败的错误
+//***************************************************
+func (journal *FileJournal) Close() error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	return journal.file.Close()
+}
+
+//***************************************************
+//Description : 提交序号文件路径
+//return :      路径
+//***************************************************
+func (journal *FileJournal) offsetsPath() string {
+	return journal.path + ".offsets"
+}
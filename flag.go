@@ -0,0 +1,72 @@
+package trigger
+
+import (
+	"reflect"
+)
+
+// 功能开关提供方, 每次触发时判断开关是否对本次触发开启, 可按参数中的用户, 租户等字段定向
+type FlagProvider interface {
+	// 开关是否开启, 参数为按回调函数参数列表绑定后的值, 可变参数展开
+	Enabled(flag string, event interface{}, arguments []interface{}) bool
+}
+
+// 函数形式的功能开关提供方
+type FlagProviderFunc func(flag string, event interface{}, arguments []interface{}) bool
+
+// 调用函数本身
+func (f FlagProviderFunc) Enabled(flag string, event interface{}, arguments []interface{}) bool {
+	return f(flag, event, arguments)
+}
+
+//***************************************************
+//Description : 设置功能开关提供方, 替换后之后的触发立即按新的提供方判断
+//param :       提供方, nil表示所有开关关闭
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithFlagProvider(provider FlagProvider) *Trigger {
+	if nil == provider {
+		trigger.flags.Store(nil)
+		return trigger
+	}
+	trigger.flags.Store(&provider)
+	return trigger
+}
+
+//***************************************************
+//Description : 添加受功能开关控制的监听, 每次触发时询问提供方, 开关关闭时跳过并返回零值
+//              没有设置提供方时开关视为关闭, 可用原回调函数移除
+//param :       开关名称
+//param :       事件名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddFlaggedListener(flag string, event, listener interface{}) *Trigger {
+	fn := reflect.ValueOf(listener)
+	if reflect.Func != fn.Kind() {
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrNotFunction})
+		return trigger
+	}
+
+	// 包装方式同Once, 移除时按原回调函数匹配
+	fnType := fn.Type()
+	run := reflect.MakeFunc(fnType, func(values []reflect.Value) []reflect.Value {
+		provider := trigger.flags.Load()
+		if nil == provider || !(*provider).Enabled(flag, event, boundArguments(fnType, values)) {
+			return zeroResults(fnType)
+		}
+		return callValues(fnType, fn, values)
+	}).Interface()
+
+	return trigger.register(event, run, &handler{source: listener})
+}
+
+//***************************************************
+//Description : 调用的AddFlaggedListener
+//param :       开关名称
+//param :       事件名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnWhenFlag(flag string, event, listener interface{}) *Trigger {
+	return trigger.AddFlaggedListener(flag, event, listener)
+}
@@ -0,0 +1,98 @@
+package trigger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 闸门关闭标记位, 低位为正在执行的调用数量
+const gateClosed = int64(1) << 62
+
+// 监听调用闸门, 用于移除监听时等待正在执行的调用结束
+//
+// 快照语义:
+//   - 触发开始时确定监听数组, 之后新增的监听不会被本次触发调用
+//   - 监听被移除后, 尚未开始的调用直接跳过, 正在执行的调用不受影响
+//   - Off返回时此监听正在执行的调用已全部结束, 之后不会再被调用
+type gate struct {
+	// 关闭标记与正在执行的调用数量
+	state atomic.Int64
+	// 保护idle的创建
+	mu sync.Mutex
+	// 关闭且调用全部结束时关闭此通道
+	idle chan struct{}
+	// 保证idle只关闭一次
+	once sync.Once
+}
+
+//***************************************************
+//Description : 开始一次调用
+//return :      闸门已关闭时返回false, 此时不应调用
+//***************************************************
+func (g *gate) acquire() bool {
+	for {
+		state := g.state.Load()
+		if 0 != state&gateClosed {
+			return false
+		}
+		if g.state.CompareAndSwap(state, state+1) {
+			return true
+		}
+	}
+}
+
+//***************************************************
+//Description : 结束一次调用
+//***************************************************
+func (g *gate) release() {
+	if gateClosed == g.state.Add(-1) {
+		g.once.Do(func() { close(g.idle) })
+	}
+}
+
+//***************************************************
+//Description : 关闭闸门, 之后的调用全部跳过
+//return :      正在执行的调用全部结束时关闭的通道
+//***************************************************
+func (g *gate) shut() <-chan struct{} {
+	// 先创建通道再设置关闭标记, 看到关闭标记的release一定能看到通道
+	g.mu.Lock()
+	if nil == g.idle {
+		g.idle = make(chan struct{})
+	}
+	idle := g.idle
+	g.mu.Unlock()
+
+	for {
+		state := g.state.Load()
+		if g.state.CompareAndSwap(state, state|gateClosed) {
+			if 0 == state&^gateClosed {
+				g.once.Do(func() { close(idle) })
+			}
+			return idle
+		}
+	}
+}
+
+//***************************************************
+//Description : 正在执行的调用数量
+//return :      数量
+//***************************************************
+func (g *gate) running() int64 {
+	return g.state.Load() &^ gateClosed
+}
+
+//***************************************************
+//Description : 等待正在执行的调用减少到指定数量, 用于不等待当前协程自身的调用
+//param :       数量
+//***************************************************
+func (g *gate) waitRunning(n int64) {
+	poll := 50 * time.Microsecond
+	for g.running() > n {
+		time.Sleep(poll)
+		if poll *= 2; poll > maxIdlePoll {
+			poll = maxIdlePoll
+		}
+	}
+}
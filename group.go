@@ -0,0 +1,137 @@
+package trigger
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// 结构化并发的协程组, 与errgroup.Group的Go方法签名一致, 可直接传入*errgroup.Group
+type Group interface {
+	Go(f func() error)
+}
+
+// 等待所有监听执行完毕并保留第一个错误的协程组, 零值可用
+// 桥接以EmitGroup触发后调用Wait, 在监听全部成功后才确认消息
+type ListenerGroup struct {
+	wg   sync.WaitGroup
+	once sync.Once
+	err  error
+}
+
+//***************************************************
+//Description : 在新协程中执行函数
+//param :       函数, 返回的第一个非nil错误由Wait返回
+//***************************************************
+func (g *ListenerGroup) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := f(); nil != err {
+			g.once.Do(func() { g.err = err })
+		}
+	}()
+}
+
+//***************************************************
+//Description : 等待所有函数执行完毕
+//return :      第一个错误, 全部成功时为nil
+//***************************************************
+func (g *ListenerGroup) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
+
+//***************************************************
+//Description : 触发事件, 每个监听通过协程组的Go方法执行, 由调用方的协程组管理其生命周期与错误
+//              监听panic, 参数不匹配或最后一个返回值为非nil的error时, 以*DispatchError或*ValidationError作为该协程的错误
+//              不等待监听执行完毕, 影子监听不会执行, 没有权限时以*AuthorizationError作为错误, 不符合事件目录时以目录的错误作为错误
+//              与Emit一样经过过期检查、钩子、过载保护、内存预算与并发上限, 互斥组在最后一个监听结束后才释放
+//              因过期、过载丢弃、超出内存预算或并发上限而没有分发时, 以对应哨兵错误的*DispatchError作为错误, 避免调用方误以为已处理
+//param :       协程组
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitGroup(g Group, event interface{}, arguments ...interface{}) *Trigger {
+	if err := trigger.permit(nil, event, arguments); nil != err {
+		g.Go(func() error {
+			return err
+		})
+		return trigger
+	}
+	if err := trigger.checkEmit(event, arguments); nil != err {
+		g.Go(func() error {
+			return err
+		})
+		return trigger
+	}
+
+	trigger.emitted.Add(1)
+	arguments = wrapLazy(arguments)
+	a, ok := trigger.admit(event, arguments, false)
+	if !ok {
+		a.finish(trigger)
+		if nil != a.rejected {
+			g.Go(func() error { return a.rejected })
+		}
+		return trigger
+	}
+	handlers, _ := splitShadows(a.handlers)
+	if 0 == len(handlers) {
+		a.finish(trigger)
+		return trigger
+	}
+
+	// 最后一个监听执行完毕时才算本次触发结束, 此时释放互斥组与并发名额等
+	var failed atomic.Bool
+	remaining := int32(len(handlers))
+	trace, lineage := a.trace, trigger.inheritable(a.lineage)
+	for _, h := range handlers {
+		h := h
+		g.Go(func() (err error) {
+			defer func() {
+				if nil != err {
+					failed.Store(true)
+				}
+				if 0 == atomic.AddInt32(&remaining, -1) {
+					a.failed = failed.Load()
+					a.finish(trigger)
+				}
+			}()
+			if nil != trace {
+				defer trace.tracer.enter(trace)()
+			}
+			if nil != lineage {
+				defer trigger.enterLineage(lineage)()
+			}
+
+			results, failure := trigger.deliver(event, h, arguments, trigger.invoke)
+			if nil != failure {
+				return failure
+			}
+			if err := resultError(results); nil != err {
+				return &DispatchError{Event: event, Listener: h.source, Err: err}
+			}
+			return nil
+		})
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 获取回调函数返回的错误
+//param :       回调函数的返回值
+//return :      最后一个返回值为非nil的error时返回, 否则为nil
+//***************************************************
+func resultError(results []reflect.Value) error {
+	if 0 == len(results) {
+		return nil
+	}
+	last := results[len(results)-1]
+	if !last.Type().Implements(errorType) || !last.CanInterface() {
+		return nil
+	}
+	err, _ := last.Interface().(error)
+	return err
+}
@@ -0,0 +1,179 @@
+package trigger
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// 健康状态
+type Status string
+
+const (
+	// 正常
+	StatusUp Status = "up"
+	// 降级运行
+	StatusDegraded Status = "degraded"
+	// 不可用
+	StatusDown Status = "down"
+)
+
+// 外部组件的健康检查函数, 返回nil表示正常
+type HealthCheck func() error
+
+// 单项检查结果
+type CheckResult struct {
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// 主备监听的熔断状态
+type CircuitState struct {
+	// 事件类型
+	Event string `json:"event"`
+	// 监听名称
+	Key string `json:"key"`
+	// 是否由备用监听处理
+	Open bool `json:"open"`
+	// 熔断结束时间, 主监听已注销时为零值
+	Until time.Time `json:"until,omitempty"`
+}
+
+// 健康报告
+type Report struct {
+	// 整体状态
+	Status Status `json:"status"`
+	// 是否处于降级模式
+	Degraded bool `json:"degraded"`
+	// 是否已关闭
+	Closed bool `json:"closed"`
+	// 有监听的事件数量
+	Events int `json:"events"`
+	// 监听总数
+	Listeners int `json:"listeners"`
+	// 等待执行的调用数量
+	QueueDepth int `json:"queue_depth"`
+	// 主备监听的熔断状态, 任一熔断时整体为降级
+	Circuits []CircuitState `json:"circuits,omitempty"`
+	// 持久监听名称 -> 日志中尚未提交的记录数量
+	Lag map[string]uint64 `json:"lag,omitempty"`
+	// 外部组件检查结果, 如wsbridge的Server.Check与Client.Check
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+//***************************************************
+//Description : 注册外部组件的健康检查, 如桥接连接状态
+//param :       检查名称
+//param :       检查函数, 传nil表示删除
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RegisterHealthCheck(name string, check HealthCheck) *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	if nil == check {
+		delete(trigger.healthChecks, name)
+		return trigger
+	}
+	if nil == trigger.healthChecks {
+		trigger.healthChecks = make(map[string]HealthCheck)
+	}
+	trigger.healthChecks[name] = check
+	return trigger
+}
+
+//***************************************************
+//Description : 汇总触发器的健康状态, 包括主备监听的熔断、持久监听的消费延迟与外部组件检查
+//return :      健康报告
+//***************************************************
+func (trigger *Trigger) Health() Report {
+	stats := trigger.Stats()
+	report := Report{
+		Degraded:   stats.Degraded,
+		Closed:     stats.Closed,
+		Events:     stats.Events,
+		Listeners:  stats.Listeners,
+		QueueDepth: stats.QueueDepth,
+	}
+
+	trigger.RLock()
+	checks := make(map[string]HealthCheck, len(trigger.healthChecks))
+	for name, check := range trigger.healthChecks {
+		checks[name] = check
+	}
+	consumers := make(map[string]*Consumer, len(trigger.durables))
+	for key, worker := range trigger.durables {
+		consumers[key] = worker.consumer
+	}
+	for event, handlers := range trigger.loadRegistry() {
+		for _, h := range handlers {
+			if f, ok := h.source.(*failover); ok {
+				report.Circuits = append(report.Circuits, f.state(event, h.key))
+			}
+		}
+	}
+	trigger.RUnlock()
+	sort.Slice(report.Circuits, func(i, j int) bool {
+		if report.Circuits[i].Event != report.Circuits[j].Event {
+			return report.Circuits[i].Event < report.Circuits[j].Event
+		}
+		return report.Circuits[i].Key < report.Circuits[j].Key
+	})
+
+	if 0 != len(consumers) {
+		last := trigger.Journal().Last()
+		report.Lag = make(map[string]uint64, len(consumers))
+		for key, consumer := range consumers {
+			// 读取失败时按没有提交计算
+			committed, _ := consumer.Committed()
+			report.Lag[key] = 0
+			if last > committed {
+				report.Lag[key] = last - committed
+			}
+		}
+	}
+
+	report.Status = StatusUp
+	if report.Degraded {
+		report.Status = StatusDegraded
+	}
+	for _, circuit := range report.Circuits {
+		if circuit.Open {
+			report.Status = StatusDegraded
+		}
+	}
+	if report.Closed {
+		report.Status = StatusDown
+	}
+
+	// 在锁外执行检查, 避免检查函数阻塞触发器
+	if 0 != len(checks) {
+		report.Checks = make(map[string]CheckResult, len(checks))
+		for name, check := range checks {
+			if err := check(); nil != err {
+				report.Checks[name] = CheckResult{Status: StatusDown, Error: err.Error()}
+				report.Status = StatusDown
+			} else {
+				report.Checks[name] = CheckResult{Status: StatusUp}
+			}
+		}
+	}
+	return report
+}
+
+//***************************************************
+//Description : 健康检查接口, 不可用时返回503, 用于就绪探针
+//return :      http处理器
+//***************************************************
+func (trigger *Trigger) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := trigger.Health()
+
+		w.Header().Set("Content-Type", "application/json")
+		if StatusDown == report.Status {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,56 @@
+package trigger
+
+import (
+	"time"
+)
+
+// 心跳事件, 参数为Stats
+const HeartbeatEvent = "trigger.heartbeat"
+
+//***************************************************
+//Description : 开启心跳, 按周期触发HeartbeatEvent事件并携带当前统计
+//              重复调用会替换之前的心跳
+//param :       心跳周期, 小于等于0表示停止心跳
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) StartHeartbeat(interval time.Duration) *Trigger {
+	trigger.StopHeartbeat()
+	if interval <= 0 {
+		return trigger
+	}
+
+	stop := make(chan struct{})
+	trigger.Lock()
+	trigger.heartbeatStop = stop
+	trigger.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				trigger.Emit(HeartbeatEvent, trigger.Stats())
+			}
+		}
+	}()
+	return trigger
+}
+
+//***************************************************
+//Description : 停止心跳
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) StopHeartbeat() *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	if nil != trigger.heartbeatStop {
+		close(trigger.heartbeatStop)
+		trigger.heartbeatStop = nil
+	}
+	return trigger
+}
@@ -0,0 +1,89 @@
+package trigger
+
+import (
+	"time"
+)
+
+// 单次触发的阶段信息
+type DispatchInfo struct {
+	// 事件类型
+	Event interface{}
+	// 回调函数中的参数, 已按结构体标签与WithRedactor脱敏
+	Arguments []interface{}
+	// 本次触发需要执行的监听数量, 包括影子监听
+	Listeners int
+	// 是否为同步触发
+	Sync bool
+	// 开始分发的时间
+	Start time.Time
+	// 分发耗时, 只在AfterDispatch中有值, 不包括后台执行的影子监听
+	Duration time.Duration
+	// 发起触发的调用处与协程, 只在开启WithProvenance时有值
+	Caller *Provenance
+}
+
+// 单个监听的执行信息
+type ListenerInfo struct {
+	// 事件类型
+	Event interface{}
+	// 回调函数
+	Listener interface{}
+	// 监听名称, 匿名监听为空
+	Name string
+	// 回调函数中的参数, 已按结构体标签与WithRedactor脱敏
+	Arguments []interface{}
+	// 开始执行的时间
+	Start time.Time
+	// 执行耗时
+	Duration time.Duration
+	// 执行失败的错误, 如参数不匹配或panic
+	Err error
+}
+
+// 触发阶段钩子, 未设置的钩子不调用
+// 钩子在触发路径上同步执行, 应尽量轻量, 其中的panic不会被拦截
+type Hooks struct {
+	// 分发前调用, 于触发方协程中执行
+	BeforeDispatch func(DispatchInfo)
+	// 每个监听执行后调用, 于执行监听的协程中执行, 异步触发时会并发调用
+	AfterListener func(ListenerInfo)
+	// 所有监听执行后调用, 于触发方协程中执行
+	AfterDispatch func(DispatchInfo)
+}
+
+//***************************************************
+//Description : 设置触发阶段钩子, 用于自定义监控而不必包装触发方法
+//              没有监听的触发也会调用分发钩子, 此时Listeners为0
+//param :       钩子, 全部为nil时表示取消
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithHooks(hooks Hooks) *Trigger {
+	if nil == hooks.BeforeDispatch && nil == hooks.AfterListener && nil == hooks.AfterDispatch {
+		trigger.hooks.Store(nil)
+		return trigger
+	}
+	trigger.hooks.Store(&hooks)
+	return trigger
+}
+
+//***************************************************
+//Description : 调用分发前钩子
+//param :       事件类型
+//param :       回调函数中的参数
+//param :       需要执行的监听数量
+//param :       是否为同步触发
+//param :       触发来源, 未开启时为nil
+//return :      调用分发后钩子的函数
+//***************************************************
+func (hooks *Hooks) dispatch(event interface{}, arguments []interface{}, listeners int, sync bool, caller *Provenance) func() {
+	info := DispatchInfo{Event: event, Arguments: arguments, Listeners: listeners, Sync: sync, Start: time.Now(), Caller: caller}
+	if nil != hooks.BeforeDispatch {
+		hooks.BeforeDispatch(info)
+	}
+	return func() {
+		if nil != hooks.AfterDispatch {
+			info.Duration = time.Since(info.Start)
+			hooks.AfterDispatch(info)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package trigger
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// 初始化监听的监听名称
+const onceInitKey = "trigger.once_init"
+
+// 事件的一次性初始化
+type onceInit struct {
+	// 保证初始化函数只执行一次
+	once sync.Once
+	// 初始化结束后关闭
+	done chan struct{}
+	// 初始化函数的返回值
+	values []reflect.Value
+	// 初始化函数的返回值, 供WaitFor与InitResult读取
+	results []interface{}
+}
+
+//***************************************************
+//Description : 添加事件的一次性初始化, 类似sync.OnceValues与事件通知的结合
+//              第一次触发时以触发参数执行初始化函数, 之后的触发不再执行, 直接返回缓存的返回值
+//              初始化函数panic时缓存返回值的零值, 与sync.OnceValues一样不会重试
+//              初始化结束后WaitFor立即返回缓存的返回值, 而不是等待下一次触发
+//              同一事件重复添加时替换之前的初始化
+//param :       事件名称
+//param :       初始化函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnceInit(event, initFn interface{}) *Trigger {
+	fn := reflect.ValueOf(initFn)
+	if reflect.Func != fn.Kind() {
+		trigger.report(event, initFn, &RegistrationError{Event: event, Listener: initFn, Err: ErrNotFunction})
+		return trigger
+	}
+
+	// 包装方式同Once, 移除时按原回调函数匹配
+	fnType := fn.Type()
+	state := &onceInit{done: make(chan struct{})}
+	run := reflect.MakeFunc(fnType, func(values []reflect.Value) []reflect.Value {
+		state.once.Do(func() {
+			// panic时保留零值并继续抛出, 由触发器按监听panic处理
+			state.values = zeroResults(fnType)
+			defer func() {
+				state.results = make([]interface{}, len(state.values))
+				for i, value := range state.values {
+					state.results[i] = value.Interface()
+				}
+				close(state.done)
+			}()
+			state.values = callValues(fnType, fn, values)
+		})
+		return state.values
+	}).Interface()
+
+	trigger.Lock()
+	if nil == trigger.inits {
+		trigger.inits = make(map[interface{}]*onceInit)
+	}
+	trigger.inits[event] = state
+	trigger.Unlock()
+
+	return trigger.registerAt(event, run, &handler{key: onceInitKey, source: initFn}, replaceHandler)
+}
+
+//***************************************************
+//Description : 获取一次性初始化的结果
+//param :       事件名称
+//return :      初始化函数的返回值
+//return :      是否已初始化
+//***************************************************
+func (trigger *Trigger) InitResult(event interface{}) ([]interface{}, bool) {
+	state := trigger.initOf(event)
+	if nil == state {
+		return nil, false
+	}
+	select {
+	case <-state.done:
+		return state.results, true
+	default:
+		return nil, false
+	}
+}
+
+//***************************************************
+//Description : 获取事件的一次性初始化
+//param :       事件类型
+//return :      一次性初始化, 没有时为nil
+//***************************************************
+func (trigger *Trigger) initOf(event interface{}) *onceInit {
+	trigger.RLock()
+	defer trigger.RUnlock()
+
+	return trigger.inits[event]
+}
+
+//***************************************************
+//Description : 等待一次性初始化结束
+//param :       上下文
+//param :       事件类型
+//param :       一次性初始化
+//return :      初始化函数的返回值
+//return :      上下文结束时返回包装ctx.Err()的TimeoutError
+//***************************************************
+func (state *onceInit) wait(ctx context.Context, event interface{}) ([]interface{}, error) {
+	select {
+	case <-state.done:
+		return state.results, nil
+	case <-ctx.Done():
+		return nil, &TimeoutError{Event: event, Err: ctx.Err()}
+	}
+}
@@ -0,0 +1,225 @@
+package trigger
+
+import (
+	"reflect"
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+// 每个正则监听缓存的事件名称数量上限, 超出后不再缓存
+const maxMatchCache = 1024
+
+// 拦截监听回调函数, 接收事件类型与本次触发的全部参数
+type AnyListener func(event interface{}, arguments []interface{})
+
+// 拦截监听在注册表中使用的事件类型, 不可导出因此不会与用户事件冲突
+type (
+	anyEvent       struct{}
+	unhandledEvent struct{}
+	matchEvent     struct{}
+)
+
+// 正则监听的事件名称匹配器
+type matcher struct {
+	// 事件名称正则
+	pattern *regexp.Regexp
+	// 事件名称 -> 是否匹配
+	cache sync.Map
+	// 已缓存的数量
+	size atomic.Int32
+}
+
+//***************************************************
+//Description : 事件名称是否匹配, 结果按名称缓存
+//param :       事件名称
+//return :      是否匹配
+//***************************************************
+func (m *matcher) match(name string) bool {
+	if matched, ok := m.cache.Load(name); ok {
+		return matched.(bool)
+	}
+	matched := m.pattern.MatchString(name)
+	if m.size.Load() < maxMatchCache {
+		m.size.Add(1)
+		m.cache.Store(name, matched)
+	}
+	return matched
+}
+
+//***************************************************
+//Description : 添加拦截所有触发的监听, 在普通监听之前于触发方协程中执行
+//              可用于日志与指标, 不受降级模式与灰度发布影响
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddAnyListener(listener AnyListener) *Trigger {
+	return trigger.AddListener(anyEvent{}, listener)
+}
+
+//***************************************************
+//Description : 调用的AddAnyListener
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnAny(listener AnyListener) *Trigger {
+	return trigger.AddAnyListener(listener)
+}
+
+//***************************************************
+//Description : 删除拦截所有触发的监听
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveAnyListener(listener AnyListener) *Trigger {
+	return trigger.RemoveListener(anyEvent{}, listener)
+}
+
+//***************************************************
+//Description : 调用的RemoveAnyListener
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OffAny(listener AnyListener) *Trigger {
+	return trigger.RemoveAnyListener(listener)
+}
+
+//***************************************************
+//Description : 添加没有普通监听的触发的监听, 用于发现无人处理的触发
+//              元事件没有监听时不会执行, EmitIfListeners在没有监听时不触发因此也不会执行
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddUnhandledListener(listener AnyListener) *Trigger {
+	return trigger.AddListener(unhandledEvent{}, listener)
+}
+
+//***************************************************
+//Description : 调用的AddUnhandledListener
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnUnhandled(listener AnyListener) *Trigger {
+	return trigger.AddUnhandledListener(listener)
+}
+
+//***************************************************
+//Description : 删除没有普通监听的触发的监听
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveUnhandledListener(listener AnyListener) *Trigger {
+	return trigger.RemoveListener(unhandledEvent{}, listener)
+}
+
+//***************************************************
+//Description : 调用的RemoveUnhandledListener
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OffUnhandled(listener AnyListener) *Trigger {
+	return trigger.RemoveUnhandledListener(listener)
+}
+
+//***************************************************
+//Description : 添加按事件名称正则匹配的拦截监听, 只匹配字符串类型的事件
+//              执行时机同AddAnyListener, 匹配结果按事件名称缓存
+//param :       事件名称正则
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddMatchListener(pattern *regexp.Regexp, listener AnyListener) *Trigger {
+	if nil == pattern {
+		trigger.report(matchEvent{}, listener, &RegistrationError{Event: matchEvent{}, Listener: listener, Err: ErrInvalidPattern})
+		return trigger
+	}
+	return trigger.register(matchEvent{}, listener, &handler{matcher: &matcher{pattern: pattern}})
+}
+
+//***************************************************
+//Description : 调用的AddMatchListener
+//param :       事件名称正则
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnMatch(pattern *regexp.Regexp, listener AnyListener) *Trigger {
+	return trigger.AddMatchListener(pattern, listener)
+}
+
+//***************************************************
+//Description : 删除按事件名称正则匹配的拦截监听, 正则与回调函数都相同才删除
+//param :       事件名称正则
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveMatchListener(pattern *regexp.Regexp, listener AnyListener) *Trigger {
+	fn := reflect.ValueOf(listener)
+	trigger.removeMatching(matchEvent{}, true, func(h *handler) bool {
+		return nil != pattern && h.matcher.pattern.String() == pattern.String() && h.matchFunc(fn)
+	})
+	return trigger
+}
+
+//***************************************************
+//Description : 调用的RemoveMatchListener
+//param :       事件名称正则
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OffMatch(pattern *regexp.Regexp, listener AnyListener) *Trigger {
+	return trigger.RemoveMatchListener(pattern, listener)
+}
+
+//***************************************************
+//Description : 执行拦截监听
+//param :       事件类型
+//param :       回调函数中的参数
+//param :       此事件的普通监听者数组, 为空时执行无人处理监听
+//***************************************************
+func (trigger *Trigger) intercept(event interface{}, arguments []interface{}, handlers []*handler) {
+	interceptors := trigger.handlersOf(anyEvent{})
+	if name, ok := event.(string); ok {
+		var matched []*handler
+		for _, h := range trigger.handlersOf(matchEvent{}) {
+			if h.matcher.match(name) {
+				matched = append(matched, h)
+			}
+		}
+		if 0 != len(matched) {
+			interceptors = append(interceptors[:len(interceptors):len(interceptors)], matched...)
+		}
+	}
+	if 0 == len(handlers) && !isMetaEvent(event) {
+		interceptors = append(interceptors[:len(interceptors):len(interceptors)], trigger.handlersOf(unhandledEvent{})...)
+	}
+	if 0 == len(interceptors) {
+		return
+	}
+
+	// 拦截监听收到求值后的参数, 触发日志自行决定是否求值
+	var values []interface{}
+	for _, h := range interceptors {
+		if _, ok := h.source.(journalListener); ok {
+			trigger.invoke(event, h, []interface{}{event, arguments})
+			continue
+		}
+		if nil == values {
+			values = []interface{}{event, resolveLazy(arguments)}
+		}
+		trigger.invoke(event, h, values)
+	}
+}
+
+//***************************************************
+//Description : 是否为拦截监听的注册表事件类型
+//param :       事件类型
+//return :      是否为拦截监听
+//***************************************************
+func isInterceptEvent(event interface{}) bool {
+	switch event.(type) {
+	case anyEvent, unhandledEvent, matchEvent:
+		return true
+	}
+	return false
+}
+
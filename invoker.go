@@ -0,0 +1,39 @@
+package trigger
+
+import (
+	"reflect"
+	"sync"
+)
+
+// 预先生成的直接调用函数, 绕过反射调用监听
+// 参数可以直接类型断言为监听的参数类型时调用监听并返回true, 否则返回false由反射处理
+// 通常由cmd/triggergen生成, 在init中通过RegisterInvoker注册
+type Invoker func(listener interface{}, arguments []interface{}) bool
+
+// 按监听签名注册的直接调用函数
+var invokers sync.Map
+
+//***************************************************
+//Description : 为某个监听签名注册直接调用函数, 只影响之后注册的监听
+//param :       此签名的函数样例, 如(func(string, int))(nil)
+//param :       直接调用函数
+//***************************************************
+func RegisterInvoker(sample interface{}, invoker Invoker) {
+	fnType := reflect.TypeOf(sample)
+	if nil == fnType || reflect.Func != fnType.Kind() {
+		panic(ErrNotFunction)
+	}
+	invokers.Store(fnType, invoker)
+}
+
+//***************************************************
+//Description : 查找某个监听签名的直接调用函数
+//param :       监听签名
+//return :      直接调用函数, 没有则为nil
+//***************************************************
+func lookupInvoker(fnType reflect.Type) Invoker {
+	if invoker, ok := invokers.Load(fnType); ok {
+		return invoker.(Invoker)
+	}
+	return nil
+}
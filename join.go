@@ -0,0 +1,227 @@
+package trigger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 关联事件的命名监听前缀
+const joinKeyPrefix = "join:"
+
+// 两个事件流按键关联的配置
+type Join struct {
+	// 关联成功时触发的事件名称, 同时作为关联名称
+	Name string
+	// 左侧事件
+	Left interface{}
+	// 右侧事件
+	Right interface{}
+	// 关联键的字段路径, 作用于第一个参数, 规则同Condition.Field
+	Key string
+	// 自定义关联键, 优先于Key, 返回false表示忽略此次触发
+	KeyFunc func(arguments []interface{}) (interface{}, bool)
+	// 等待另一侧的时间窗口
+	Window time.Duration
+	// 超时未关联时触发的事件, nil表示不触发
+	Timeout interface{}
+}
+
+// 关联结果, 作为关联事件与超时事件的参数
+type Joined struct {
+	// 关联名称
+	Name string
+	// 关联键
+	Key interface{}
+	// 左侧事件的参数, 超时且左侧未到达时为nil
+	Left []interface{}
+	// 右侧事件的参数, 超时且右侧未到达时为nil
+	Right []interface{}
+	// 左侧到达时间
+	LeftAt time.Time
+	// 右侧到达时间
+	RightAt time.Time
+}
+
+// 等待关联的一侧
+type pendingJoin struct {
+	// 已到达的一侧
+	joined Joined
+	// 超时定时器
+	timer *time.Timer
+}
+
+// 运行中的关联
+type joiner struct {
+	// 配置
+	join Join
+	// 保护pending
+	mu sync.Mutex
+	// 关联键 -> 等待中的关联
+	pending map[interface{}]*pendingJoin
+	// 是否已停止
+	stopped bool
+}
+
+//***************************************************
+//Description : 添加两个事件流的关联, 同一键的左右两侧在时间窗口内都到达时触发关联事件
+//              同一侧重复到达时保留最新的参数, 同名关联会替换之前的关联
+//param :       关联配置
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddJoin(join Join) *Trigger {
+	if "" == join.Name || nil == join.Left || nil == join.Right || join.Left == join.Right ||
+		join.Window <= 0 || ("" == join.Key && nil == join.KeyFunc) {
+		err := fmt.Errorf("%w: 关联[%s]需要名称, 两个不同的事件, 关联键与大于0的时间窗口", ErrInvalidJoin, join.Name)
+		trigger.report(join.Left, nil, &RegistrationError{Event: join.Left, Err: err})
+		return trigger
+	}
+
+	j := &joiner{join: join, pending: make(map[interface{}]*pendingJoin)}
+	trigger.Lock()
+	previous := trigger.joins[join.Name]
+	if nil == trigger.joins {
+		trigger.joins = make(map[string]*joiner)
+	}
+	trigger.joins[join.Name] = j
+	trigger.Unlock()
+	if nil != previous {
+		previous.stop()
+	}
+
+	trigger.ReplaceListener(join.Left, joinKeyPrefix+join.Name, func(arguments ...interface{}) {
+		trigger.arrive(j, true, arguments)
+	})
+	trigger.ReplaceListener(join.Right, joinKeyPrefix+join.Name, func(arguments ...interface{}) {
+		trigger.arrive(j, false, arguments)
+	})
+	return trigger
+}
+
+//***************************************************
+//Description : 删除关联, 等待中的关联直接丢弃, 不触发超时事件
+//param :       关联名称
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveJoin(name string) *Trigger {
+	trigger.Lock()
+	j := trigger.joins[name]
+	delete(trigger.joins, name)
+	trigger.Unlock()
+
+	if nil != j {
+		j.stop()
+		trigger.RemoveNamedListener(j.join.Left, joinKeyPrefix+name)
+		trigger.RemoveNamedListener(j.join.Right, joinKeyPrefix+name)
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 停止所有关联, 用于关闭触发器
+//***************************************************
+func (trigger *Trigger) stopJoins() {
+	trigger.Lock()
+	joins := trigger.joins
+	trigger.joins = nil
+	trigger.Unlock()
+
+	for _, j := range joins {
+		j.stop()
+	}
+}
+
+//***************************************************
+//Description : 一侧事件到达
+//param :       关联
+//param :       是否为左侧
+//param :       回调函数中的参数
+//***************************************************
+func (trigger *Trigger) arrive(j *joiner, left bool, arguments []interface{}) {
+	key, ok := j.key(arguments)
+	if !ok {
+		return
+	}
+	now := time.Now()
+
+	j.mu.Lock()
+	if j.stopped {
+		j.mu.Unlock()
+		return
+	}
+	p := j.pending[key]
+	if nil == p {
+		p = &pendingJoin{joined: Joined{Name: j.join.Name, Key: key}}
+		j.pending[key] = p
+		p.timer = time.AfterFunc(j.join.Window, func() {
+			trigger.expire(j, key, p)
+		})
+	}
+	if left {
+		p.joined.Left, p.joined.LeftAt = arguments, now
+	} else {
+		p.joined.Right, p.joined.RightAt = arguments, now
+	}
+
+	complete := nil != p.joined.Left && nil != p.joined.Right
+	if complete {
+		p.timer.Stop()
+		delete(j.pending, key)
+	}
+	j.mu.Unlock()
+
+	if complete {
+		trigger.EmitSync(j.join.Name, p.joined)
+	}
+}
+
+//***************************************************
+//Description : 时间窗口结束仍未关联
+//param :       关联
+//param :       关联键
+//param :       等待中的关联
+//***************************************************
+func (trigger *Trigger) expire(j *joiner, key interface{}, p *pendingJoin) {
+	j.mu.Lock()
+	// 已关联或已被替换
+	if j.stopped || j.pending[key] != p {
+		j.mu.Unlock()
+		return
+	}
+	delete(j.pending, key)
+	j.mu.Unlock()
+
+	if nil != j.join.Timeout {
+		trigger.Emit(j.join.Timeout, p.joined)
+	}
+}
+
+//***************************************************
+//Description : 获取关联键
+//param :       回调函数中的参数
+//return :      关联键
+//return :      是否取到
+//***************************************************
+func (j *joiner) key(arguments []interface{}) (interface{}, bool) {
+	if nil != j.join.KeyFunc {
+		return j.join.KeyFunc(arguments)
+	}
+	if 0 == len(arguments) {
+		return nil, false
+	}
+	return fieldValue(arguments[0], j.join.Key)
+}
+
+//***************************************************
+//Description : 停止关联并丢弃等待中的关联
+//***************************************************
+func (j *joiner) stop() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.stopped = true
+	for key, p := range j.pending {
+		p.timer.Stop()
+		delete(j.pending, key)
+	}
+}
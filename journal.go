@@ -0,0 +1,196 @@
+package trigger
+
+import (
+	"sync"
+	"time"
+)
+
+// 记录触发的监听名称
+const journalKey = "journal"
+
+// 记录触发的拦截监听, 收到未求值的延迟参数, 由record决定是否求值
+type journalListener func(event interface{}, arguments []interface{})
+
+// 日志中的一次触发
+type Record struct {
+	// 序号, 从1开始递增
+	Seq uint64 `json:"seq"`
+	// 事件类型
+	Event interface{} `json:"event"`
+	// 回调函数中的参数
+	Arguments []interface{} `json:"arguments"`
+	// 触发时间
+	Time time.Time `json:"time"`
+}
+
+// 触发日志, 按顺序保存触发记录
+type Journal interface {
+	// 追加记录并分配序号
+	Append(record Record) (uint64, error)
+	// 读取序号大于after的记录, 最多limit条, 早于保留范围的记录已被丢弃
+	Read(after uint64, limit int) ([]Record, error)
+	// 最后一条记录的序号, 没有记录时为0
+	Last() uint64
+}
+
+//***************************************************
+//Description : 开启触发日志, 记录指定事件的每次触发, 包括没有监听的触发
+//param :       日志
+//param :       需要记录的事件, 为空表示记录所有非元事件
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithJournal(journal Journal, events ...interface{}) *Trigger {
+	filter := make(map[interface{}]bool, len(events))
+	for _, event := range events {
+		filter[event] = true
+	}
+
+	// 文件日志写入前按此触发器的结构体标签与脱敏函数脱敏
+	if file, ok := journal.(*FileJournal); ok {
+		file.redactWith(trigger.redact)
+	}
+
+	trigger.Lock()
+	trigger.journal = journal
+	if nil == trigger.journalNotify {
+		trigger.journalNotify = make(chan struct{})
+	}
+	trigger.Unlock()
+
+	return trigger.ReplaceListener(anyEvent{}, journalKey, journalListener(func(event interface{}, arguments []interface{}) {
+		if isMetaEvent(event) || (0 != len(filter) && !filter[event]) {
+			return
+		}
+		trigger.record(journal, event, arguments)
+	}))
+}
+
+//***************************************************
+//Description : 获取触发日志
+//return :      日志, 未开启时为nil
+//***************************************************
+func (trigger *Trigger) Journal() Journal {
+	trigger.RLock()
+	defer trigger.RUnlock()
+
+	return trigger.journal
+}
+
+//***************************************************
+//Description : 追加触发记录并通知等待新记录的读取方
+//              延迟参数只在事件有监听时求值, 没有监听时记录为nil
+//param :       日志
+//param :       事件类型
+//param :       回调函数中的参数
+//***************************************************
+func (trigger *Trigger) record(journal Journal, event interface{}, arguments []interface{}) {
+	resolved := arguments
+	if hasLazy(arguments) {
+		if 0 != len(trigger.handlersOf(event)) {
+			resolved = resolveLazy(arguments)
+		} else {
+			resolved = make([]interface{}, len(arguments))
+			for i, argument := range arguments {
+				if _, ok := argument.(*lazyValue); !ok {
+					resolved[i] = argument
+				}
+			}
+		}
+	}
+	if _, err := journal.Append(Record{Event: event, Arguments: resolved, Time: time.Now()}); nil != err {
+		trigger.report(event, nil, &DispatchError{Event: event, Err: err})
+		return
+	}
+
+	trigger.Lock()
+	close(trigger.journalNotify)
+	trigger.journalNotify = make(chan struct{})
+	trigger.Unlock()
+}
+
+//***************************************************
+//Description : 获取新记录的通知通道, 追加记录后关闭
+//return :      通道, 未开启日志时为nil
+//***************************************************
+func (trigger *Trigger) journalChanged() <-chan struct{} {
+	trigger.RLock()
+	defer trigger.RUnlock()
+
+	return trigger.journalNotify
+}
+
+// 内存中的触发日志, 超出容量后丢弃最早的记录
+type memoryJournal struct {
+	// 保护以下字段
+	mu sync.RWMutex
+	// 环形缓冲
+	records []Record
+	// 最后一条记录的序号
+	last uint64
+	// 消费组 -> 已提交的最后序号
+	offsets map[string]uint64
+}
+
+//***************************************************
+//Description : 创建内存中的触发日志
+//param :       最多保留的记录数量
+//return :      日志
+//***************************************************
+func NewMemoryJournal(capacity int) Journal {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &memoryJournal{records: make([]Record, capacity), offsets: make(map[string]uint64)}
+}
+
+// 追加记录
+func (journal *memoryJournal) Append(record Record) (uint64, error) {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	journal.last++
+	record.Seq = journal.last
+	journal.records[(journal.last-1)%uint64(len(journal.records))] = record
+	return record.Seq, nil
+}
+
+// 读取序号大于after的记录
+func (journal *memoryJournal) Read(after uint64, limit int) ([]Record, error) {
+	journal.mu.RLock()
+	defer journal.mu.RUnlock()
+
+	capacity := uint64(len(journal.records))
+	if journal.last > capacity && after < journal.last-capacity {
+		after = journal.last - capacity
+	}
+	var records []Record
+	for seq := after + 1; seq <= journal.last && (limit <= 0 || len(records) < limit); seq++ {
+		records = append(records, journal.records[(seq-1)%capacity])
+	}
+	return records, nil
+}
+
+// 最后一条记录的序号
+func (journal *memoryJournal) Last() uint64 {
+	journal.mu.RLock()
+	defer journal.mu.RUnlock()
+
+	return journal.last
+}
+
+// 提交消费组已处理的最后序号
+func (journal *memoryJournal) Commit(group string, seq uint64) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	journal.offsets[group] = seq
+	return nil
+}
+
+// 消费组已提交的最后序号
+func (journal *memoryJournal) Committed(group string) (uint64, error) {
+	journal.mu.RLock()
+	defer journal.mu.RUnlock()
+
+	return journal.offsets[group], nil
+}
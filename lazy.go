@@ -0,0 +1,115 @@
+package trigger
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// 延迟求值的参数, 由Lazy创建
+type LazyArg struct {
+	// 求值函数
+	fn reflect.Value
+}
+
+//***************************************************
+//Description : 创建延迟求值的参数, 每次触发只在第一个监听调用前求值一次
+//              没有监听执行时不会求值, 拦截监听收到求值后的参数
+//param :       求值函数, 类型为func() T
+//return :      延迟求值的参数, 作为Emit/EmitSync的参数传入
+//***************************************************
+func Lazy(fn interface{}) *LazyArg {
+	return &LazyArg{fn: reflect.ValueOf(fn)}
+}
+
+// 单次触发中的延迟参数, 保证只求值一次
+type lazyValue struct {
+	// 延迟求值的参数
+	arg *LazyArg
+	// 保证只求值一次
+	once sync.Once
+	// 求值结果
+	value interface{}
+	// 求值错误
+	err error
+}
+
+//***************************************************
+//Description : 获取求值结果
+//return :      求值结果
+//return :      求值函数类型错误
+//***************************************************
+func (lazy *lazyValue) get() (interface{}, error) {
+	lazy.once.Do(func() {
+		fn := lazy.arg.fn
+		if reflect.Func != fn.Kind() || 0 != fn.Type().NumIn() || 1 != fn.Type().NumOut() {
+			lazy.err = fmt.Errorf("%w: Lazy需要func() T类型的求值函数", ErrArgumentMismatch)
+			return
+		}
+
+		result := fn.Call(nil)[0]
+		// 返回nil接口时按nil参数处理
+		if reflect.Interface == result.Kind() && result.IsNil() {
+			return
+		}
+		lazy.value = result.Interface()
+	})
+	return lazy.value, lazy.err
+}
+
+//***************************************************
+//Description : 为本次触发包装延迟求值的参数, 没有延迟参数时返回原数组
+//param :       回调函数中的参数
+//return :      包装后的参数
+//***************************************************
+func wrapLazy(arguments []interface{}) []interface{} {
+	var wrapped []interface{}
+	for i, argument := range arguments {
+		arg, ok := argument.(*LazyArg)
+		if !ok {
+			continue
+		}
+		if nil == wrapped {
+			wrapped = append([]interface{}(nil), arguments...)
+		}
+		wrapped[i] = &lazyValue{arg: arg}
+	}
+
+	if nil == wrapped {
+		return arguments
+	}
+	return wrapped
+}
+
+//***************************************************
+//Description : 是否有本次触发的延迟参数
+//param :       回调函数中的参数
+//return :      是否有延迟参数
+//***************************************************
+func hasLazy(arguments []interface{}) bool {
+	for _, argument := range arguments {
+		if _, ok := argument.(*lazyValue); ok {
+			return true
+		}
+	}
+	return false
+}
+
+//***************************************************
+//Description : 求值本次触发的延迟参数, 求值失败的参数为nil, 没有延迟参数时返回原数组
+//param :       回调函数中的参数
+//return :      求值后的参数
+//***************************************************
+func resolveLazy(arguments []interface{}) []interface{} {
+	if !hasLazy(arguments) {
+		return arguments
+	}
+	resolved := make([]interface{}, len(arguments))
+	for i, argument := range arguments {
+		if lazy, ok := argument.(*lazyValue); ok {
+			argument, _ = lazy.get()
+		}
+		resolved[i] = argument
+	}
+	return resolved
+}
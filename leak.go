@@ -0,0 +1,135 @@
+package trigger
+
+import (
+	"time"
+)
+
+const (
+	// 长期未被调用的监听, 参数为ListenerStat
+	UnusedListenerEvent = "trigger.unused_listener"
+	// 检测窗口内被触发但没有监听的事件, 参数为事件类型与触发次数
+	UnhandledEvent = "trigger.unhandled_event"
+)
+
+//***************************************************
+//Description : 开启泄漏检测, 每个窗口结束时检查一次
+//              注册超过一个窗口且从未被调用的监听触发UnusedListenerEvent, 每个监听只报告一次
+//              窗口内被触发但没有监听的事件触发UnhandledEvent
+//param :       检测窗口, 小于等于0表示关闭泄漏检测
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EnableLeakDetection(window time.Duration) *Trigger {
+	trigger.DisableLeakDetection()
+	if window <= 0 {
+		return trigger
+	}
+
+	stop := make(chan struct{})
+	trigger.Lock()
+	trigger.leakStop = stop
+	trigger.unhandled = make(map[interface{}]int)
+	trigger.Unlock()
+	trigger.leakDetect.Store(true)
+
+	go func() {
+		ticker := time.NewTicker(window)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				trigger.detectLeaks(now.Add(-window))
+			}
+		}
+	}()
+	return trigger
+}
+
+//***************************************************
+//Description : 关闭泄漏检测
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) DisableLeakDetection() *Trigger {
+	trigger.leakDetect.Store(false)
+
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	if nil != trigger.leakStop {
+		close(trigger.leakStop)
+		trigger.leakStop = nil
+	}
+	trigger.unhandled = nil
+	return trigger
+}
+
+//***************************************************
+//Description : 记录没有监听的事件
+//param :       事件类型
+//***************************************************
+func (trigger *Trigger) recordUnhandled(event interface{}) {
+	// 忽略元事件, 避免互相触发
+	if isMetaEvent(event) {
+		return
+	}
+
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	if nil != trigger.unhandled {
+		trigger.unhandled[event]++
+	}
+}
+
+//***************************************************
+//Description : 检查并报告泄漏
+//param :       早于此时间注册的监听视为长期监听
+//***************************************************
+func (trigger *Trigger) detectLeaks(before time.Time) {
+	trigger.Lock()
+	unhandled := trigger.unhandled
+	if nil != unhandled {
+		trigger.unhandled = make(map[interface{}]int)
+	}
+
+	var unused []ListenerStat
+	for event, handlers := range trigger.loadRegistry() {
+		// 元事件与拦截监听通常很少被调用, 不做检查
+		if isMetaEvent(event) || isInterceptEvent(event) {
+			continue
+		}
+		for _, h := range handlers {
+			if h.flagged || h.registered.After(before) {
+				continue
+			}
+			if stat := h.snapshot(event); 0 == stat.Calls {
+				h.flagged = true
+				unused = append(unused, stat)
+			}
+		}
+	}
+	trigger.Unlock()
+
+	// 在锁外触发元事件
+	for _, stat := range unused {
+		trigger.Emit(UnusedListenerEvent, stat)
+	}
+	for event, count := range unhandled {
+		trigger.Emit(UnhandledEvent, event, count)
+	}
+}
+
+//***************************************************
+//Description : 是否为触发器自身产生的元事件
+//param :       事件类型
+//return :      是否为元事件
+//***************************************************
+func isMetaEvent(event interface{}) bool {
+	switch event {
+	case UnusedListenerEvent, UnhandledEvent, HeartbeatEvent, ExpiredEvent, ExperimentEvent, DeprecatedEvent:
+		return true
+	}
+	return false
+}
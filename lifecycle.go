@@ -0,0 +1,124 @@
+package trigger
+
+import (
+	"context"
+	"time"
+)
+
+// WaitIdle检查的最大间隔
+const maxIdlePoll = 10 * time.Millisecond
+
+// 需要初始化的监听, 注册时调用Init
+type Initializer interface {
+	Init(ctx context.Context) error
+}
+
+// 需要释放资源的监听, 移除时以及触发器关闭时调用Shutdown
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+//***************************************************
+//Description : 初始化监听
+//param :       监听对象
+//return :      错误
+//***************************************************
+func initListener(listener interface{}) error {
+	if initializer, ok := listener.(Initializer); ok {
+		return initializer.Init(context.Background())
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 释放监听资源
+//param :       上下文
+//param :       监听对象
+//return :      错误
+//***************************************************
+func shutdownListener(ctx context.Context, listener interface{}) error {
+	if shutdowner, ok := listener.(Shutdowner); ok {
+		return shutdowner.Shutdown(ctx)
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 等待触发器空闲: 没有正在执行的触发, 影子监听与补发等后台任务也都已结束
+//              定时器尚未到期的计划触发与降级模式下缓存的调用不计入, 用于测试与关闭前等待总线稳定
+//param :       上下文
+//return :      上下文结束时返回TimeoutError
+//***************************************************
+func (trigger *Trigger) WaitIdle(ctx context.Context) error {
+	poll := 50 * time.Microsecond
+	for !trigger.idle() {
+		timer := time.NewTimer(poll)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return &TimeoutError{Err: ctx.Err()}
+		}
+		if poll *= 2; poll > maxIdlePoll {
+			poll = maxIdlePoll
+		}
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 是否空闲
+//return :      没有正在执行的触发与后台任务
+//***************************************************
+func (trigger *Trigger) idle() bool {
+	return 0 == trigger.inFlight.Load() && 0 == trigger.background.Load()
+}
+
+//***************************************************
+//Description : 关闭触发器, 移除所有监听并释放其资源
+//param :       上下文
+//return :      第一个释放失败的错误, 上下文结束时返回TimeoutError
+//***************************************************
+func (trigger *Trigger) Close(ctx context.Context) error {
+	trigger.StopHeartbeat()
+	trigger.DisableLeakDetection()
+	trigger.SetAutoCompact(0)
+	trigger.stopAggregators()
+	trigger.stopJoins()
+	trigger.stopSchedules()
+	trigger.stopDurables()
+	trigger.stopBackfills()
+	tenantErr := trigger.closeTenants(ctx)
+
+	trigger.Lock()
+	events := trigger.loadRegistry()
+	trigger.events.Store(&registry{})
+	trigger.intercepting.Store(false)
+	trigger.closed = true
+	trigger.Unlock()
+
+	var first error
+	for event, handlers := range events {
+		for _, h := range handlers {
+			// 等待正在执行的调用结束
+			select {
+			case <-h.gate.shut():
+			case <-ctx.Done():
+				return &TimeoutError{Event: event, Listener: h.source, Err: ctx.Err()}
+			}
+			if err := shutdownListener(ctx, h.source); nil != err {
+				err = &RegistrationError{Event: event, Listener: h.source, Err: err}
+				if nil != trigger.recoverer {
+					trigger.recoverer(event, h.source, err)
+				}
+				if nil == first {
+					first = err
+				}
+			}
+		}
+	}
+	if nil == first {
+		first = tenantErr
+	}
+	return first
+}
@@ -0,0 +1,159 @@
+package trigger
+
+import (
+	"time"
+)
+
+// 触发的继承属性, 通过EmitWith指定, 开启继承后监听中发起的触发自动沿用
+type Lineage struct {
+	// 优先级, 大于0时降级模式下所有监听都会执行
+	Priority int
+	// 截止时间, 零值表示不限
+	Deadline time.Time
+	// 关联ID, 用于串联整条触发链
+	CorrelationID string
+	// 经过的桥接节点, 用于跨桥接转发的环路检测
+	Hops []string
+}
+
+//***************************************************
+//Description : 开启或关闭继承, 开启后监听中发起的触发沿用当前触发的优先级, 截止时间与关联ID
+//param :       是否开启
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithInheritance(enabled bool) *Trigger {
+	trigger.inherit.Store(enabled)
+	return trigger
+}
+
+//***************************************************
+//Description : 以指定的继承属性触发事件
+//              在继承链中调用时优先级取较大值, 截止时间取较早值, 关联ID与经过的节点为空时沿用
+//param :       继承属性
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitWith(lineage Lineage, event interface{}, arguments ...interface{}) *Trigger {
+	defer trigger.enterLineage(trigger.inheritFrom(lineage))()
+	return trigger.Emit(event, arguments...)
+}
+
+//***************************************************
+//Description : 以指定的继承属性同步触发事件, 规则同EmitWith
+//param :       继承属性
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitSyncWith(lineage Lineage, event interface{}, arguments ...interface{}) *Trigger {
+	defer trigger.enterLineage(trigger.inheritFrom(lineage))()
+	return trigger.EmitSync(event, arguments...)
+}
+
+//***************************************************
+//Description : 以指定的触发方与继承属性同步触发事件, 规则同EmitSyncAs与EmitWith, 用于桥接收到带截止时间的远程触发
+//param :       触发方
+//param :       继承属性
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitSyncAsWith(source interface{}, lineage Lineage, event interface{}, arguments ...interface{}) *Trigger {
+	defer trigger.enterLineage(trigger.inheritFrom(lineage))()
+	return trigger.EmitSyncAs(source, event, arguments...)
+}
+
+//***************************************************
+//Description : 以指定的继承属性通过协程组触发事件, 规则同EmitGroup与EmitWith, 用于桥接的消费方
+//param :       协程组
+//param :       继承属性
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitGroupWith(g *ListenerGroup, lineage Lineage, event interface{}, arguments ...interface{}) *Trigger {
+	defer trigger.enterLineage(trigger.inheritFrom(lineage))()
+	return trigger.EmitGroup(g, event, arguments...)
+}
+
+//***************************************************
+//Description : 获取当前监听所属触发的继承属性, 在监听中调用, 未开启继承时监听中获取不到
+//return :      继承属性
+//return :      是否存在
+//***************************************************
+func (trigger *Trigger) Lineage() (Lineage, bool) {
+	if lineage := trigger.currentLineage(); nil != lineage {
+		return *lineage, true
+	}
+	return Lineage{}, false
+}
+
+//***************************************************
+//Description : 合并当前协程的继承属性
+//param :       指定的继承属性
+//return :      合并结果
+//***************************************************
+func (trigger *Trigger) inheritFrom(lineage Lineage) *Lineage {
+	if parent := trigger.currentLineage(); nil != parent {
+		if parent.Priority > lineage.Priority {
+			lineage.Priority = parent.Priority
+		}
+		if !parent.Deadline.IsZero() && (lineage.Deadline.IsZero() || parent.Deadline.Before(lineage.Deadline)) {
+			lineage.Deadline = parent.Deadline
+		}
+		if "" == lineage.CorrelationID {
+			lineage.CorrelationID = parent.CorrelationID
+		}
+		if 0 == len(lineage.Hops) {
+			lineage.Hops = parent.Hops
+		}
+	}
+	return &lineage
+}
+
+//***************************************************
+//Description : 获取当前协程的继承属性
+//return :      继承属性, 没有时为nil
+//***************************************************
+func (trigger *Trigger) currentLineage() *Lineage {
+	if 0 == trigger.lineageCount.Load() {
+		return nil
+	}
+	if value, ok := trigger.lineages.Load(goroutineID()); ok {
+		return value.(*Lineage)
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 传给监听的继承属性, 未开启继承时为nil
+//param :       当前触发的继承属性
+//return :      继承属性
+//***************************************************
+func (trigger *Trigger) inheritable(lineage *Lineage) *Lineage {
+	if !trigger.inherit.Load() {
+		return nil
+	}
+	return lineage
+}
+
+//***************************************************
+//Description : 标记当前协程的继承属性
+//param :       继承属性, nil表示屏蔽外层的继承属性
+//return :      恢复函数
+//***************************************************
+func (trigger *Trigger) enterLineage(lineage *Lineage) func() {
+	id := goroutineID()
+	previous, ok := trigger.lineages.Load(id)
+	trigger.lineages.Store(id, lineage)
+	trigger.lineageCount.Add(1)
+	return func() {
+		if ok {
+			trigger.lineages.Store(id, previous)
+		} else {
+			trigger.lineages.Delete(id)
+		}
+		trigger.lineageCount.Add(-1)
+	}
+}
@@ -0,0 +1,87 @@
+package trigger
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// 主循环执行器, 交给它的调用都在执行Run或RunPending的协程中按提交顺序依次执行
+// 适用于界面主循环、游戏循环或Actor, 绑定它的监听都在同一协程中执行, 无需加锁
+type LoopExecutor struct {
+	// 保护queue
+	mu sync.Mutex
+	// 等待执行的调用
+	queue []func()
+	// 有新调用时通知Run
+	wake chan struct{}
+	// 最近一次执行循环的协程ID, 0表示尚未执行
+	owner atomic.Uint64
+}
+
+//***************************************************
+//Description : 创建主循环执行器
+//return :      主循环执行器
+//***************************************************
+func NewLoopExecutor() *LoopExecutor {
+	return &LoopExecutor{wake: make(chan struct{}, 1)}
+}
+
+//***************************************************
+//Description : 提交调用, 在循环协程中提交时直接执行, 因此在循环中触发绑定此执行器的监听不会死锁
+//              其他协程提交的调用排队等待循环执行, 循环协程为最近一次执行Run或RunPending的协程
+//param :       调用
+//***************************************************
+func (loop *LoopExecutor) Execute(task func()) {
+	if owner := loop.owner.Load(); 0 != owner && owner == goroutineID() {
+		task()
+		return
+	}
+
+	loop.mu.Lock()
+	loop.queue = append(loop.queue, task)
+	loop.mu.Unlock()
+
+	select {
+	case loop.wake <- struct{}{}:
+	default:
+	}
+}
+
+//***************************************************
+//Description : 在当前协程中执行循环, 直到上下文结束
+//param :       上下文
+//return :      上下文结束的原因
+//***************************************************
+func (loop *LoopExecutor) Run(ctx context.Context) error {
+	for {
+		loop.RunPending()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-loop.wake:
+		}
+	}
+}
+
+//***************************************************
+//Description : 执行当前排队的调用后返回, 不等待新的调用, 适合在游戏循环的每一帧中调用
+//              执行期间提交的调用留到下一次执行
+//return :      执行的调用数量
+//***************************************************
+func (loop *LoopExecutor) RunPending() int {
+	loop.owner.Store(goroutineID())
+
+	loop.mu.Lock()
+	queue := loop.queue
+	loop.queue = nil
+	loop.mu.Unlock()
+	if 0 == len(queue) {
+		return 0
+	}
+
+	for _, task := range queue {
+		task()
+	}
+	return len(queue)
+}
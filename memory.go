@@ -0,0 +1,111 @@
+package trigger
+
+import (
+	"encoding/json"
+	"sync/atomic"
+)
+
+// 参数大小函数, 返回一次触发的参数的近似字节数
+type SizeFunc func(event interface{}, arguments []interface{}) int
+
+// 内存预算统计
+type MemoryStats struct {
+	// 预算字节数
+	Budget int64 `json:"budget"`
+	// 正在执行的触发的参数字节数
+	InFlight int64 `json:"in_flight"`
+	// 超出预算被拒绝的关键事件次数
+	Rejected uint64 `json:"rejected"`
+	// 超出预算被丢弃的尽力而为事件次数
+	Shed uint64 `json:"shed"`
+}
+
+// 内存预算的运行状态
+type memoryBudget struct {
+	// 预算字节数
+	limit int64
+	// 参数大小函数
+	size SizeFunc
+	// 正在执行的触发的参数字节数
+	used atomic.Int64
+	// 被拒绝的次数
+	rejected atomic.Uint64
+	// 被丢弃的次数
+	shed atomic.Uint64
+}
+
+//***************************************************
+//Description : 开启内存预算, 统计正在执行的触发(包括等待互斥组与串行执行的触发)的参数字节数
+//              加上本次触发超出预算时, 尽力而为的事件直接丢弃, 其他事件报告包装ErrMemoryBudget的DispatchError
+//              没有其他触发在执行时总是允许, 因此单个超出预算的触发不会永远无法执行
+//param :       预算字节数, 小于等于0表示关闭
+//param :       参数大小函数, nil表示按JSON编码的长度估算, 无法编码的参数不计
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithMemoryBudget(budget int64, size SizeFunc) *Trigger {
+	if budget <= 0 {
+		trigger.memory.Store(nil)
+		return trigger
+	}
+	if nil == size {
+		size = jsonSize
+	}
+	trigger.memory.Store(&memoryBudget{limit: budget, size: size})
+	return trigger
+}
+
+//***************************************************
+//Description : 获取内存预算统计
+//return :      统计, 未开启时为零值
+//***************************************************
+func (trigger *Trigger) MemoryStats() MemoryStats {
+	budget := trigger.memory.Load()
+	if nil == budget {
+		return MemoryStats{}
+	}
+	return MemoryStats{Budget: budget.limit, InFlight: budget.used.Load(), Rejected: budget.rejected.Load(), Shed: budget.shed.Load()}
+}
+
+//***************************************************
+//Description : 为本次触发占用内存预算, 超出时丢弃或报告
+//param :       内存预算
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      占用的字节数, 触发结束后归还
+//return :      不允许触发时的错误
+//***************************************************
+func (trigger *Trigger) reserve(budget *memoryBudget, event interface{}, arguments []interface{}) (int64, error) {
+	size := int64(budget.size(event, arguments))
+	if size <= 0 {
+		return 0, nil
+	}
+	if used := budget.used.Add(size); used <= budget.limit || used == size {
+		return size, nil
+	}
+	budget.used.Add(-size)
+
+	err := &DispatchError{Event: event, Err: ErrMemoryBudget}
+	if classes := trigger.classes.Load(); nil != classes && ClassBestEffort == (*classes)[event] {
+		budget.shed.Add(1)
+		return 0, err
+	}
+	budget.rejected.Add(1)
+	trigger.report(event, nil, err)
+	return 0, err
+}
+
+//***************************************************
+//Description : 按JSON编码的长度估算参数大小
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      字节数
+//***************************************************
+func jsonSize(event interface{}, arguments []interface{}) int {
+	size := 0
+	for _, argument := range arguments {
+		if data, err := json.Marshal(argument); nil == err {
+			size += len(data)
+		}
+	}
+	return size
+}
@@ -0,0 +1,72 @@
+package trigger
+
+import (
+	"reflect"
+)
+
+//***************************************************
+//Description : 添加方法监听, 记录接收者以便准确移除
+//              直接注册方法值(如svc.Handle)时, 不同接收者的同名方法无法区分, 应使用此方法
+//param :       事件名称
+//param :       接收者, 需为可比较类型, 通常为指针
+//param :       导出方法名
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddMethodListener(event, receiver interface{}, method string) *Trigger {
+	if nil == receiver {
+		trigger.report(event, receiver, &RegistrationError{Event: event, Listener: receiver, Err: ErrNilReceiver})
+		return trigger
+	}
+	fn := reflect.ValueOf(receiver).MethodByName(method)
+	if !fn.IsValid() {
+		trigger.report(event, receiver, &RegistrationError{Event: event, Listener: receiver, Err: ErrMethodNotFound})
+		return trigger
+	}
+
+	// 接收者作为生命周期回调对象
+	return trigger.register(event, fn.Interface(), &handler{source: receiver, receiver: receiver, method: method})
+}
+
+//***************************************************
+//Description : 调用的AddMethodListener
+//param :       事件名称
+//param :       接收者
+//param :       导出方法名
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnMethod(event, receiver interface{}, method string) *Trigger {
+	return trigger.AddMethodListener(event, receiver, method)
+}
+
+//***************************************************
+//Description : 删除方法监听, 只删除此接收者的监听
+//param :       事件名称
+//param :       接收者
+//param :       导出方法名
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveMethodListener(event, receiver interface{}, method string) *Trigger {
+	if nil == receiver {
+		trigger.report(event, receiver, &RegistrationError{Event: event, Listener: receiver, Err: ErrNilReceiver})
+		return trigger
+	}
+	if !reflect.TypeOf(receiver).Comparable() {
+		return trigger
+	}
+
+	trigger.removeMatching(event, true, func(h *handler) bool {
+		return h.method == method && nil != h.receiver && h.receiver == receiver
+	})
+	return trigger
+}
+
+//***************************************************
+//Description : 调用的RemoveMethodListener
+//param :       事件名称
+//param :       接收者
+//param :       导出方法名
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OffMethod(event, receiver interface{}, method string) *Trigger {
+	return trigger.RemoveMethodListener(event, receiver, method)
+}
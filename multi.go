@@ -0,0 +1,173 @@
+package trigger
+
+import (
+	"regexp"
+	"sync"
+)
+
+// 总线路由规则, 返回此总线是否处理该事件
+type EventMatcher func(event interface{}) bool
+
+//***************************************************
+//Description : 按事件名称正则路由, 只匹配字符串类型的事件
+//param :       事件名称正则
+//return :      路由规则
+//***************************************************
+func MatchPattern(pattern *regexp.Regexp) EventMatcher {
+	return func(event interface{}) bool {
+		name, ok := event.(string)
+		return ok && pattern.MatchString(name)
+	}
+}
+
+//***************************************************
+//Description : 只路由指定的事件
+//param :       事件类型
+//return :      路由规则
+//***************************************************
+func MatchEvents(events ...interface{}) EventMatcher {
+	set := make(map[interface{}]struct{}, len(events))
+	for _, event := range events {
+		set[event] = struct{}{}
+	}
+	return func(event interface{}) bool {
+		_, ok := set[event]
+		return ok
+	}
+}
+
+// 组合总线中的单个总线
+type bus struct {
+	// 总线名称
+	name string
+	// 总线
+	emitter Emitter
+	// 路由规则, nil表示处理所有事件
+	match EventMatcher
+}
+
+// 组合总线, 将注册与触发按路由规则分发到多个总线, 如本地触发器与远程桥接
+type MultiTrigger struct {
+	mu sync.RWMutex
+	// 按添加顺序的总线
+	buses []bus
+}
+
+//***************************************************
+//Description : 创建组合总线
+//return :      组合总线
+//***************************************************
+func NewMultiTrigger() *MultiTrigger {
+	return &MultiTrigger{}
+}
+
+//***************************************************
+//Description : 添加总线, 已有的注册不会同步到新总线
+//param :       总线名称
+//param :       总线
+//param :       路由规则, nil表示处理所有事件
+//return :      组合总线
+//***************************************************
+func (multi *MultiTrigger) AddBus(name string, emitter Emitter, match EventMatcher) *MultiTrigger {
+	multi.mu.Lock()
+	defer multi.mu.Unlock()
+
+	multi.buses = append(multi.buses, bus{name: name, emitter: emitter, match: match})
+	return multi
+}
+
+//***************************************************
+//Description : 获取处理该事件的总线名称
+//param :       事件类型
+//return :      总线名称数组, 按添加顺序
+//***************************************************
+func (multi *MultiTrigger) Route(event interface{}) []string {
+	var names []string
+	for _, b := range multi.route(event) {
+		names = append(names, b.name)
+	}
+	return names
+}
+
+//***************************************************
+//Description : 获取处理该事件的总线
+//param :       事件类型
+//return :      总线数组
+//***************************************************
+func (multi *MultiTrigger) route(event interface{}) []bus {
+	multi.mu.RLock()
+	defer multi.mu.RUnlock()
+
+	var matched []bus
+	for _, b := range multi.buses {
+		if nil == b.match || b.match(event) {
+			matched = append(matched, b)
+		}
+	}
+	return matched
+}
+
+//***************************************************
+//Description : 在处理该事件的所有总线上添加监听
+//param :       事件名称
+//param :       回调函数
+//return :      组合总线
+//***************************************************
+func (multi *MultiTrigger) On(event, listener interface{}) Emitter {
+	for _, b := range multi.route(event) {
+		b.emitter.On(event, listener)
+	}
+	return multi
+}
+
+//***************************************************
+//Description : 在处理该事件的所有总线上添加只执行一次的监听, 每个总线各执行一次
+//param :       事件名称
+//param :       回调函数
+//return :      组合总线
+//***************************************************
+func (multi *MultiTrigger) Once(event, listener interface{}) Emitter {
+	for _, b := range multi.route(event) {
+		b.emitter.Once(event, listener)
+	}
+	return multi
+}
+
+//***************************************************
+//Description : 在处理该事件的所有总线上删除监听
+//param :       事件名称
+//param :       回调函数
+//return :      组合总线
+//***************************************************
+func (multi *MultiTrigger) Off(event, listener interface{}) Emitter {
+	for _, b := range multi.route(event) {
+		b.emitter.Off(event, listener)
+	}
+	return multi
+}
+
+//***************************************************
+//Description : 在处理该事件的所有总线上触发事件
+//param :       事件名称
+//param :       回调函数中的参数
+//return :      组合总线
+//***************************************************
+func (multi *MultiTrigger) Emit(event interface{}, arguments ...interface{}) Emitter {
+	for _, b := range multi.route(event) {
+		b.emitter.Emit(event, arguments...)
+	}
+	return multi
+}
+
+//***************************************************
+//Description : 在处理该事件的所有总线上按添加顺序同步触发事件
+//param :       事件名称
+//param :       回调函数中的参数
+//return :      组合总线
+//***************************************************
+func (multi *MultiTrigger) EmitSync(event interface{}, arguments ...interface{}) Emitter {
+	for _, b := range multi.route(event) {
+		b.emitter.EmitSync(event, arguments...)
+	}
+	return multi
+}
@@ -0,0 +1,159 @@
+package trigger
+
+import (
+	"context"
+	"hash"
+	"hash/fnv"
+	"math"
+	"reflect"
+)
+
+var (
+	// context.Context类型反射
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	// 惰性参数类型反射
+	lazyValueType = reflect.TypeOf((*lazyValue)(nil))
+)
+
+//***************************************************
+//Description : 开启或关闭参数修改检查, 仅用于调试
+//              每个监听执行前后对参数做深度校验和, 不一致时以包装ErrArgumentMutated的DispatchError报告该监听
+//              Bag, 惰性参数, context.Context与sync包中的类型本身允许改变, 不参与校验
+//              并发执行的监听之间无法区分修改方, 报告的可能是同时执行的另一个监听, 可配合EmitSync定位
+//param :       是否开启
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithMutationGuard(enabled bool) *Trigger {
+	trigger.mutationGuard.Store(enabled)
+	return trigger
+}
+
+//***************************************************
+//Description : 开始检查单次监听调用, 记录执行前的校验和
+//param :       事件类型
+//param :       监听者
+//param :       回调函数中的参数
+//return :      执行后调用的检查函数, 未开启时为nil
+//***************************************************
+func (trigger *Trigger) guardArguments(event interface{}, h *handler, arguments []interface{}) func() {
+	if !trigger.mutationGuard.Load() || 0 == len(arguments) {
+		return nil
+	}
+
+	before := checksum(arguments)
+	return func() {
+		if checksum(arguments) != before {
+			trigger.report(event, h.source, &DispatchError{Event: event, Listener: h.source, Err: ErrArgumentMutated})
+		}
+	}
+}
+
+//***************************************************
+//Description : 计算参数的深度校验和, 沿指针, 切片, 映射与结构体(包括未导出字段)递归
+//param :       回调函数中的参数
+//return :      校验和
+//***************************************************
+func checksum(arguments []interface{}) uint64 {
+	h := fnv.New64a()
+	seen := make(map[uintptr]bool)
+	for _, argument := range arguments {
+		hashValue(h, reflect.ValueOf(argument), seen)
+	}
+	return h.Sum64()
+}
+
+//***************************************************
+//Description : 把值写入校验和
+//param :       校验和
+//param :       值
+//param :       已访问的指针, 避免循环引用
+//***************************************************
+func hashValue(h hash.Hash64, v reflect.Value, seen map[uintptr]bool) {
+	if !v.IsValid() {
+		h.Write([]byte{0})
+		return
+	}
+	if sharedArgument(v.Type()) {
+		return
+	}
+
+	var buf [8]byte
+	writeUint := func(n uint64) {
+		for i := range buf {
+			buf[i] = byte(n >> (8 * i))
+		}
+		h.Write(buf[:])
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			writeUint(1)
+		} else {
+			writeUint(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeUint(math.Float64bits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		writeUint(math.Float64bits(real(v.Complex())))
+		writeUint(math.Float64bits(imag(v.Complex())))
+	case reflect.String:
+		writeUint(uint64(v.Len()))
+		h.Write([]byte(v.String()))
+	case reflect.Ptr:
+		if v.IsNil() {
+			writeUint(0)
+			return
+		}
+		if seen[v.Pointer()] {
+			return
+		}
+		seen[v.Pointer()] = true
+		hashValue(h, v.Elem(), seen)
+	case reflect.Interface:
+		hashValue(h, v.Elem(), seen)
+	case reflect.Slice, reflect.Array:
+		writeUint(uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			hashValue(h, v.Index(i), seen)
+		}
+	case reflect.Map:
+		// 映射的遍历顺序不固定, 各键值对单独计算后求和
+		var sum uint64
+		iter := v.MapRange()
+		for iter.Next() {
+			entry := fnv.New64a()
+			hashValue(entry, iter.Key(), seen)
+			hashValue(entry, iter.Value(), seen)
+			sum += entry.Sum64()
+		}
+		writeUint(uint64(v.Len()))
+		writeUint(sum)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			hashValue(h, v.Field(i), seen)
+		}
+	default:
+		// 函数, 通道等只比较地址
+		writeUint(uint64(v.Pointer()))
+	}
+}
+
+//***************************************************
+//Description : 是否为各监听之间共享且允许改变的类型, 不参与校验与复制
+//param :       类型
+//return :      是否共享
+//***************************************************
+func sharedArgument(t reflect.Type) bool {
+	switch {
+	case bagType == t, lazyValueType == t, t.Implements(contextType):
+		return true
+	case reflect.Struct == t.Kind() && ("sync" == t.PkgPath() || "sync/atomic" == t.PkgPath()):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package trigger
+
+import (
+	"sync"
+)
+
+// 事件类型 -> 所属互斥组的锁, 写入时复制, 触发时无需加锁即可读取
+type mutexTable map[interface{}]*sync.Mutex
+
+//***************************************************
+//Description : 设置互斥组的事件, 同一组内的事件不会同时分发: 一次触发的全部监听执行完后才开始组内下一次触发
+//              用于保护多个事件的监听共用的外部资源, 同一次触发的多个监听之间仍按原方式执行
+//              事件只属于最后设置的组, 组内事件的监听中同步触发组内事件会因等待自己而死锁
+//param :       组名称
+//param :       组内的事件, 替换此组原有的事件, 为空表示删除此组
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithMutexGroup(name string, events ...interface{}) *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	lock := trigger.mutexGroups[name]
+	if nil == lock {
+		lock = new(sync.Mutex)
+	}
+
+	// 复制并移除此组原有的事件
+	next := make(mutexTable)
+	if current := trigger.mutexes.Load(); nil != current {
+		for event, mutex := range *current {
+			if mutex != lock {
+				next[event] = mutex
+			}
+		}
+	}
+	for _, event := range events {
+		next[event] = lock
+	}
+	trigger.mutexes.Store(&next)
+
+	if 0 == len(events) {
+		delete(trigger.mutexGroups, name)
+		return trigger
+	}
+	if nil == trigger.mutexGroups {
+		trigger.mutexGroups = make(map[string]*sync.Mutex)
+	}
+	trigger.mutexGroups[name] = lock
+	return trigger
+}
+
+//***************************************************
+//Description : 获取事件所属互斥组的锁
+//param :       事件类型
+//return :      锁, 不属于任何组时为nil
+//***************************************************
+func (trigger *Trigger) mutexOf(event interface{}) *sync.Mutex {
+	table := trigger.mutexes.Load()
+	if nil == table {
+		return nil
+	}
+	return (*table)[event]
+}
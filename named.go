@@ -0,0 +1,87 @@
+package trigger
+
+//***************************************************
+//Description : 以名称添加监听, 同一事件下已有同名监听时替换旧监听, 见ReplaceListener
+//              适用于重新加载配置时重复注册, 不会产生重复的监听
+//param :       事件名称
+//param :       监听名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddNamedListener(event interface{}, key string, listener interface{}) *Trigger {
+	return trigger.ReplaceListener(event, key, listener)
+}
+
+//***************************************************
+//Description : 调用的AddNamedListener
+//param :       事件名称
+//param :       监听名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnNamed(event interface{}, key string, listener interface{}) *Trigger {
+	return trigger.AddNamedListener(event, key, listener)
+}
+
+//***************************************************
+//Description : 原子替换同名监听, 不存在时直接添加
+//              新监听占据旧监听的位置, 替换过程中不会出现同名监听缺失或重复的情况
+//              返回时旧监听正在执行的调用已全部结束
+//param :       事件名称
+//param :       监听名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) ReplaceListener(event interface{}, key string, listener interface{}) *Trigger {
+	return trigger.registerAt(event, listener, &handler{key: key}, replaceHandler)
+}
+
+//***************************************************
+//Description : 替换监听数组中的同名监听者, 没有同名监听者时追加到末尾
+//param :       监听数组
+//param :       监听者
+//return :      新的监听数组
+//return :      被替换掉的监听者
+//***************************************************
+func replaceHandler(handlers []*handler, h *handler) ([]*handler, []*handler) {
+	placed := make([]*handler, 0, len(handlers)+1)
+	var replaced []*handler
+	for _, old := range handlers {
+		if h.key != old.key {
+			placed = append(placed, old)
+			continue
+		}
+		// 新监听占据第一个同名监听的位置, 其余同名监听一并移除
+		if 0 == len(replaced) {
+			placed = append(placed, h)
+		}
+		replaced = append(replaced, old)
+	}
+	if 0 == len(replaced) {
+		placed = append(placed, h)
+	}
+	return placed, replaced
+}
+
+//***************************************************
+//Description : 按名称删除监听
+//param :       事件名称
+//param :       监听名称
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RemoveNamedListener(event interface{}, key string) *Trigger {
+	trigger.removeMatching(event, true, func(h *handler) bool {
+		return key == h.key
+	})
+	return trigger
+}
+
+//***************************************************
+//Description : 调用的RemoveNamedListener
+//param :       事件名称
+//param :       监听名称
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OffNamed(event interface{}, key string) *Trigger {
+	return trigger.RemoveNamedListener(event, key)
+}
@@ -0,0 +1,143 @@
+package trigger
+
+import (
+	"fmt"
+	"strings"
+)
+
+//***************************************************
+//Description : 声明同一事件的命名监听之间的执行顺序: 名称为key的监听在after中的监听之后执行
+//              同步触发按此顺序执行, 没有依赖关系的监听保持注册顺序, 异步触发的监听并发执行不受影响
+//              依赖的监听可以稍后注册, 形成循环依赖时报告包装ErrDependencyCycle的错误且不生效
+//param :       事件类型
+//param :       监听名称
+//param :       需要先执行的监听名称
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) RunAfter(event interface{}, key string, after ...string) *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	for _, dependency := range after {
+		if path := trigger.dependencyPath(event, dependency, key); nil != path {
+			err := fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(append([]string{key}, path...), " -> "))
+			trigger.report(event, nil, &RegistrationError{Event: event, Err: err})
+			return trigger
+		}
+	}
+
+	if nil == trigger.dependencies {
+		trigger.dependencies = make(map[interface{}]map[string][]string)
+	}
+	if nil == trigger.dependencies[event] {
+		trigger.dependencies[event] = make(map[string][]string)
+	}
+	trigger.dependencies[event][key] = append(trigger.dependencies[event][key], after...)
+	trigger.storeHandlers(event, trigger.handlersOf(event))
+	return trigger
+}
+
+//***************************************************
+//Description : 清除命名监听声明的执行顺序, 已有监听保持当前顺序
+//param :       事件类型
+//param :       监听名称
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) ClearRunAfter(event interface{}, key string) *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	delete(trigger.dependencies[event], key)
+	if 0 == len(trigger.dependencies[event]) {
+		delete(trigger.dependencies, event)
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 查找依赖路径, 调用方需持有锁
+//param :       事件类型
+//param :       起点监听名称
+//param :       终点监听名称
+//return :      从起点沿依赖到达终点的路径, 不可达时为nil
+//***************************************************
+func (trigger *Trigger) dependencyPath(event interface{}, from, to string) []string {
+	if from == to {
+		return []string{to}
+	}
+	visited := make(map[string]bool)
+	var walk func(key string) []string
+	walk = func(key string) []string {
+		if visited[key] {
+			return nil
+		}
+		visited[key] = true
+		for _, next := range trigger.dependencies[event][key] {
+			if next == to {
+				return []string{key, to}
+			}
+			if path := walk(next); nil != path {
+				return append([]string{key}, path...)
+			}
+		}
+		return nil
+	}
+	return walk(from)
+}
+
+//***************************************************
+//Description : 按声明的执行顺序排列监听者, 调用方需持有锁
+//param :       事件类型
+//param :       监听者数组
+//return :      排列后的新数组, 没有声明时为原数组
+//***************************************************
+func (trigger *Trigger) orderHandlers(event interface{}, handlers []*handler) []*handler {
+	dependencies := trigger.dependencies[event]
+	if 0 == len(dependencies) || len(handlers) < 2 {
+		return handlers
+	}
+
+	// 监听名称 -> 下标
+	indexes := make(map[string][]int)
+	for i, h := range handlers {
+		if "" != h.key {
+			indexes[h.key] = append(indexes[h.key], i)
+		}
+	}
+	// 每个监听需要先执行的监听数量, 以及执行后可以解除的监听
+	waiting := make([]int, len(handlers))
+	unblocks := make([][]int, len(handlers))
+	for i, h := range handlers {
+		if "" == h.key {
+			continue
+		}
+		for _, dependency := range dependencies[h.key] {
+			for _, j := range indexes[dependency] {
+				unblocks[j] = append(unblocks[j], i)
+				waiting[i]++
+			}
+		}
+	}
+
+	// 每次取注册顺序最靠前的可执行监听, 保证没有依赖关系的监听顺序不变
+	ordered := make([]*handler, 0, len(handlers))
+	placed := make([]bool, len(handlers))
+	for len(ordered) < len(handlers) {
+		next := -1
+		for i := range handlers {
+			if !placed[i] && 0 == waiting[i] {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		placed[next] = true
+		ordered = append(ordered, handlers[next])
+		for _, i := range unblocks[next] {
+			waiting[i]--
+		}
+	}
+	return ordered
+}
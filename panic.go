@@ -0,0 +1,55 @@
+package trigger
+
+// 监听panic的处理策略
+type PanicPolicy int
+
+const (
+	// 恢复panic并交给recoverer处理, 未设置recoverer时使用默认处理函数
+	PanicRecover PanicPolicy = iota
+	// 不恢复, panic继续向上抛出
+	PanicPropagate
+	// 恢复panic并交给recoverer处理, 同时移除此监听
+	PanicRecoverAndRemove
+)
+
+//***************************************************
+//Description : 设置监听panic的处理策略
+//param :       处理策略
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithPanicPolicy(policy PanicPolicy) *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	trigger.panicPolicy = policy
+	return trigger
+}
+
+//***************************************************
+//Description : 按处理策略处理监听中的panic
+//param :       事件类型
+//param :       监听者
+//param :       panic的值
+//param :       包装后的错误
+//***************************************************
+func (trigger *Trigger) handlePanic(event interface{}, h *handler, r interface{}, err error) {
+	trigger.RLock()
+	policy := trigger.panicPolicy
+	recoverer := trigger.recoverer
+	trigger.RUnlock()
+
+	if PanicPropagate == policy {
+		panic(r)
+	}
+
+	if nil == recoverer {
+		recoverer = defaultRecoveryFunc
+	}
+	recoverer(event, h.source, err)
+
+	if PanicRecoverAndRemove == policy {
+		trigger.removeMatching(event, false, func(other *handler) bool {
+			return other == h
+		})
+	}
+}
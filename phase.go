@@ -0,0 +1,137 @@
+package trigger
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// 启动阶段事件, 组件的启动监听以组件名称注册
+	AppStartEvent = "app.start"
+	// 关闭阶段事件, 组件的关闭监听以组件名称注册
+	AppStopEvent = "app.stop"
+)
+
+//***************************************************
+//Description : 添加应用组件, 以组件名称注册启动与关闭监听并声明依赖
+//              启动时依赖的组件先启动, 关闭时按相反顺序, 依赖的组件后关闭
+//              监听通常为func(ctx context.Context) error, 收到带单个组件超时的上下文
+//              也可以直接以OnNamed注册AppStartEvent/AppStopEvent的监听并用RunAfter声明顺序
+//param :       组件名称
+//param :       启动监听, nil表示不需要启动
+//param :       关闭监听, nil表示不需要关闭
+//param :       依赖的组件名称
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddComponent(name string, start, stop interface{}, dependsOn ...string) *Trigger {
+	if nil != start {
+		trigger.AddNamedListener(AppStartEvent, name, start).RunAfter(AppStartEvent, name, dependsOn...)
+	}
+	if nil != stop {
+		trigger.AddNamedListener(AppStopEvent, name, stop)
+		for _, dependency := range dependsOn {
+			trigger.RunAfter(AppStopEvent, dependency, name)
+		}
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 按依赖顺序依次启动组件, 第一个失败或超时的组件结束启动, 已启动的组件需由调用方StopApp关闭
+//param :       上下文, 结束时停止启动
+//param :       单个组件的超时时间, 0表示不限
+//return :      失败时返回*PhaseError, 其中包含失败的组件
+//***************************************************
+func (trigger *Trigger) StartApp(ctx context.Context, timeout time.Duration) error {
+	return trigger.runPhase(ctx, AppStartEvent, timeout, false)
+}
+
+//***************************************************
+//Description : 按依赖的相反顺序依次关闭组件, 失败或超时的组件报告后继续关闭其余组件
+//              超时的组件不会被中断, 其监听在后台继续执行
+//param :       上下文, 结束时剩余组件不再关闭
+//param :       单个组件的超时时间, 0表示不限
+//return :      第一个失败的*PhaseError, 其中包含阻塞关闭的组件
+//***************************************************
+func (trigger *Trigger) StopApp(ctx context.Context, timeout time.Duration) error {
+	return trigger.runPhase(ctx, AppStopEvent, timeout, true)
+}
+
+//***************************************************
+//Description : 按监听顺序依次执行阶段中的组件
+//param :       上下文
+//param :       阶段事件
+//param :       单个组件的超时时间
+//param :       失败后是否继续
+//return :      第一个失败的*PhaseError
+//***************************************************
+func (trigger *Trigger) runPhase(ctx context.Context, phase string, timeout time.Duration, keepGoing bool) error {
+	var first error
+	for _, h := range trigger.handlersOf(phase) {
+		component := h.key
+		if "" == component {
+			component = h.describe()
+		}
+
+		var err error
+		if err = ctx.Err(); nil != err {
+			err = &TimeoutError{Event: phase, Listener: h.source, Err: err}
+		} else {
+			err = trigger.runComponent(ctx, phase, h, timeout)
+		}
+		if nil == err {
+			continue
+		}
+
+		err = &PhaseError{Phase: phase, Component: component, Err: err}
+		trigger.report(phase, h.source, err)
+		if nil == first {
+			first = err
+		}
+		if !keepGoing || nil != ctx.Err() {
+			return first
+		}
+	}
+	return first
+}
+
+//***************************************************
+//Description : 执行单个组件, 超时后不再等待
+//param :       上下文
+//param :       阶段事件
+//param :       监听者
+//param :       超时时间, 0表示不限
+//return :      监听失败, 返回非nil的error或超时时的错误
+//***************************************************
+func (trigger *Trigger) runComponent(ctx context.Context, phase string, h *handler, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	panics := make(chan interface{}, 1)
+	go func() {
+		// 按PanicPropagate继续抛出的panic交给调用方协程
+		defer func() {
+			if r := recover(); nil != r {
+				panics <- r
+			}
+		}()
+		results, failure := trigger.invoke(phase, h, []interface{}{ctx})
+		if nil == failure {
+			failure = resultError(results)
+		}
+		done <- failure
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case r := <-panics:
+		panic(r)
+	case <-ctx.Done():
+		return &TimeoutError{Event: phase, Listener: h.source, Err: ctx.Err()}
+	}
+}
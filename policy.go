@@ -0,0 +1,131 @@
+package trigger
+
+// 触发权限策略, 返回非nil错误时拒绝本次触发
+// source为触发方, 由EmitAs/EmitSyncAs传入, Emit/EmitSync与触发器自身的元事件为nil
+type EmitPolicy func(source, event interface{}, arguments []interface{}) error
+
+//***************************************************
+//Description : 设置触发权限策略, 在执行任何监听之前校验
+//              拒绝的触发以包装策略错误的AuthorizationError报告给错误处理函数
+//param :       权限策略, nil表示不校验
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithEmitPolicy(policy EmitPolicy) *Trigger {
+	if nil == policy {
+		trigger.emitPolicy.Store(nil)
+		return trigger
+	}
+	trigger.emitPolicy.Store(&policy)
+	return trigger
+}
+
+//***************************************************
+//Description : 以指定的触发方触发事件, 同Emit
+//param :       触发方, 如插件名称或租户
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitAs(source, event interface{}, arguments ...interface{}) *Trigger {
+	if !trigger.authorize(source, event, arguments) {
+		return trigger
+	}
+	return trigger.emit(event, arguments)
+}
+
+//***************************************************
+//Description : 以指定的触发方同步触发事件, 同EmitSync
+//param :       触发方
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitSyncAs(source, event interface{}, arguments ...interface{}) *Trigger {
+	if !trigger.authorize(source, event, arguments) {
+		return trigger
+	}
+	return trigger.emitSync(event, arguments)
+}
+
+//***************************************************
+//Description : 获取绑定触发方的Emitter, 交给插件等组件后其触发都以此触发方校验
+//param :       触发方
+//return :      Emitter
+//***************************************************
+func (trigger *Trigger) EmitterAs(source interface{}) Emitter {
+	return sourceEmitter{trigger: trigger, source: source}
+}
+
+//***************************************************
+//Description : 校验触发权限与事件目录, 拒绝时报告错误
+//param :       触发方
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      是否允许触发
+//***************************************************
+func (trigger *Trigger) authorize(source, event interface{}, arguments []interface{}) bool {
+	if err := trigger.permit(source, event, arguments); nil != err {
+		trigger.report(event, nil, err)
+		return false
+	}
+	if err := trigger.checkEmit(event, arguments); nil != err {
+		trigger.report(event, nil, err)
+		return false
+	}
+	return true
+}
+
+//***************************************************
+//Description : 按触发权限策略校验
+//param :       触发方
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      拒绝时为*AuthorizationError
+//***************************************************
+func (trigger *Trigger) permit(source, event interface{}, arguments []interface{}) error {
+	policy := trigger.emitPolicy.Load()
+	if nil == policy {
+		return nil
+	}
+	if err := (*policy)(source, event, arguments); nil != err {
+		return &AuthorizationError{Event: event, Source: source, Err: err}
+	}
+	return nil
+}
+
+// 绑定触发方的Emitter
+type sourceEmitter struct {
+	trigger *Trigger
+	// 触发方
+	source interface{}
+}
+
+// 调用的Trigger.On
+func (e sourceEmitter) On(event, listener interface{}) Emitter {
+	e.trigger.On(event, listener)
+	return e
+}
+
+// 调用的Trigger.Once
+func (e sourceEmitter) Once(event, listener interface{}) Emitter {
+	e.trigger.Once(event, listener)
+	return e
+}
+
+// 调用的Trigger.Off
+func (e sourceEmitter) Off(event, listener interface{}) Emitter {
+	e.trigger.Off(event, listener)
+	return e
+}
+
+// 调用的Trigger.EmitAs
+func (e sourceEmitter) Emit(event interface{}, arguments ...interface{}) Emitter {
+	e.trigger.EmitAs(e.source, event, arguments...)
+	return e
+}
+
+// 调用的Trigger.EmitSyncAs
+func (e sourceEmitter) EmitSync(event interface{}, arguments ...interface{}) Emitter {
+	e.trigger.EmitSyncAs(e.source, event, arguments...)
+	return e
+}
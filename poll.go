@@ -0,0 +1,102 @@
+package trigger
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// 长轮询默认等待时间
+	defaultPollTimeout = 30 * time.Second
+	// 长轮询最长等待时间
+	maxPollTimeout = 2 * time.Minute
+	// 每次最多返回的记录数量
+	defaultPollLimit = 100
+)
+
+// 长轮询的返回结果
+type pollResult struct {
+	// 记录, 参数已脱敏
+	Records []Record `json:"records"`
+	// 下一次请求使用的游标
+	Cursor uint64 `json:"cursor"`
+}
+
+//***************************************************
+//Description : 长轮询接口, 用于无法使用websocket或SSE的客户端消费触发日志, 需先开启WithJournal
+//              GET 参数cursor为已收到的最后序号, limit为最多返回数量, timeout为没有新记录时的等待时间
+//              有新记录时立即返回, 等待超时返回空数组与原游标, 未开启日志时返回404
+//return :      http处理器
+//***************************************************
+func (trigger *Trigger) PollHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if http.MethodGet != r.Method {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		journal := trigger.Journal()
+		if nil == journal {
+			http.Error(w, "未开启触发日志", http.StatusNotFound)
+			return
+		}
+
+		query := r.URL.Query()
+		cursor, _ := strconv.ParseUint(query.Get("cursor"), 10, 64)
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if nil != err || limit <= 0 || limit > defaultPollLimit {
+			limit = defaultPollLimit
+		}
+		timeout, err := time.ParseDuration(query.Get("timeout"))
+		if nil != err || timeout < 0 {
+			timeout = defaultPollTimeout
+		}
+		if timeout > maxPollTimeout {
+			timeout = maxPollTimeout
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		for {
+			// 先取通知通道再读取, 避免读取后追加的记录被错过
+			changed := trigger.journalChanged()
+			records, err := journal.Read(cursor, limit)
+			if nil != err {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if 0 != len(records) {
+				trigger.writePoll(w, records, cursor)
+				return
+			}
+
+			select {
+			case <-changed:
+			case <-timer.C:
+				trigger.writePoll(w, nil, cursor)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+//***************************************************
+//Description : 输出长轮询结果, 参数按事件脱敏
+//param :       响应
+//param :       记录
+//param :       请求的游标, 没有记录时原样返回
+//***************************************************
+func (trigger *Trigger) writePoll(w http.ResponseWriter, records []Record, cursor uint64) {
+	result := pollResult{Records: make([]Record, 0, len(records)), Cursor: cursor}
+	for _, record := range records {
+		record.Arguments = trigger.redact(record.Event, record.Arguments)
+		result.Records = append(result.Records, record)
+		result.Cursor = record.Seq
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,135 @@
+package trigger
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// 参数反射数组的复用池
+var valuesPool = sync.Pool{
+	New: func() interface{} {
+		values := make([]reflect.Value, 0, 8)
+		return &values
+	},
+}
+
+//***************************************************
+//Description : 清空参数反射数组并放回复用池
+//param :       参数反射数组
+//***************************************************
+func putValues(values *[]reflect.Value) {
+	// 清空元素, 避免复用池持有参数的引用
+	for i := range *values {
+		(*values)[i] = reflect.Value{}
+	}
+	*values = (*values)[:0]
+	valuesPool.Put(values)
+}
+
+// 单次异步触发的共享状态
+type emitTask struct {
+	// 事件类型
+	event interface{}
+	// 回调函数中的参数
+	arguments []interface{}
+	// 等待所有监听执行完毕
+	wg sync.WaitGroup
+	// 保护panicValue
+	mu sync.Mutex
+	// 协程中未被处理的panic, 只保留第一个
+	panicValue interface{}
+	// 是否有监听失败
+	failed bool
+	// 有影子监听时记录的主监听结果
+	outcomes map[string]outcome
+	// 开启调试记录时本次触发的记录
+	trace *emitTrace
+	// 开启继承时传给监听的继承属性
+	lineage *Lineage
+	// 截止时间, 零值表示不限
+	deadline time.Time
+}
+
+// 异步触发共享状态的复用池
+var taskPool = sync.Pool{
+	New: func() interface{} {
+		return new(emitTask)
+	},
+}
+
+//***************************************************
+//Description : 清空共享状态以便复用
+//***************************************************
+func (task *emitTask) reset() {
+	task.event = nil
+	task.arguments = nil
+	task.panicValue = nil
+	task.failed = false
+	task.outcomes = nil
+	task.trace = nil
+	task.lineage = nil
+	task.deadline = time.Time{}
+}
+
+//***************************************************
+//Description : 生成交给执行器的单个监听的执行函数
+//param :       共享状态
+//param :       监听者
+//param :       串行监听取到的号码
+//return :      执行函数
+//***************************************************
+func (trigger *Trigger) taskFunc(task *emitTask, h *handler, ticket uint64) func() {
+	return func() {
+		trigger.runTask(task, h, ticket)
+	}
+}
+
+//***************************************************
+//Description : 在协程中执行单个监听
+//param :       共享状态
+//param :       监听者
+//param :       串行监听取到的号码
+//***************************************************
+func (trigger *Trigger) runTask(task *emitTask, h *handler, ticket uint64) {
+	defer task.wg.Done()
+	if q := h.serial; nil != q {
+		q.wait(ticket)
+		defer q.done()
+	}
+	// 协程中的panic无法被调用方捕获, 拦截后转交给调用方所在协程
+	defer func() {
+		if r := recover(); nil != r {
+			task.mu.Lock()
+			if nil == task.panicValue {
+				task.panicValue = r
+			}
+			task.mu.Unlock()
+		}
+	}()
+	// 等待调度期间已过期则跳过
+	if expired(task.deadline) {
+		trigger.reportExpired(Expiry{Event: task.event, Listener: h.source, Arguments: task.arguments, Deadline: task.deadline})
+		return
+	}
+	// 开启调试记录时标记此协程所属的触发, 用于记录监听结果与原因链
+	if nil != task.trace {
+		defer task.trace.tracer.enter(task.trace)()
+	}
+	if nil != task.lineage {
+		defer trigger.enterLineage(task.lineage)()
+	}
+	results, err := trigger.deliver(task.event, h, task.arguments, trigger.dispatch)
+	if nil != err {
+		task.mu.Lock()
+		task.failed = true
+		task.mu.Unlock()
+	}
+
+	// 记录主监听的结果, 供影子监听对比
+	if nil != task.outcomes && "" != h.key {
+		task.mu.Lock()
+		task.outcomes[h.key] = newOutcome(results, err)
+		task.mu.Unlock()
+	}
+}
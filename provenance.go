@@ -0,0 +1,58 @@
+package trigger
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// 触发的来源: 发起触发的调用处与协程
+type Provenance struct {
+	// 调用处的函数名
+	Function string
+	// 调用处的文件
+	File string
+	// 调用处的行号
+	Line int
+	// 发起触发的协程ID
+	Goroutine uint64
+}
+
+//***************************************************
+//Description : 格式化为"函数 文件:行号 goroutine ID", 便于写入日志
+//return :      描述
+//***************************************************
+func (provenance Provenance) String() string {
+	return fmt.Sprintf("%s %s:%d goroutine %d", provenance.Function, provenance.File, provenance.Line, provenance.Goroutine)
+}
+
+//***************************************************
+//Description : 开启或关闭触发来源记录, 开启后钩子的DispatchInfo.Caller为发起触发的调用处与协程ID
+//              每次触发需要获取调用栈与协程ID, 仅用于调试
+//param :       是否开启
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithProvenance(enabled bool) *Trigger {
+	trigger.provenance.Store(enabled)
+	return trigger
+}
+
+//***************************************************
+//Description : 获取触发来源, 跳过触发器自身的方法
+//return :      来源, 未开启时为nil
+//***************************************************
+func (trigger *Trigger) caller() *Provenance {
+	if !trigger.provenance.Load() {
+		return nil
+	}
+
+	pcs := make([]uintptr, maxTraceStack)
+	// 跳过runtime.Callers与caller
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, triggerMethodPrefix) || !more {
+			return &Provenance{Function: frame.Function, File: frame.File, Line: frame.Line, Goroutine: goroutineID()}
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package pubsubbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+type order struct {
+	ID    int    `json:"id"`
+	Token string `json:"token" trigger:"redact"`
+}
+
+// 测试用的订阅消息
+type stored struct {
+	message pubsubMessage
+	ackID   string
+	until   time.Time
+}
+
+// 测试用的Pub/Sub, 主题只有一个订阅, 同一排序键有未确认的消息时不投递其后的消息
+type fakePubSub struct {
+	mu         sync.Mutex
+	messages   []*stored
+	acks       int
+	extensions int32
+}
+
+func (f *fakePubSub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if "Bearer token" != req.Header.Get("Authorization") {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"code":401,"message":"unauthenticated","status":"UNAUTHENTICATED"}}`))
+		return
+	}
+	var in struct {
+		Messages           []pubsubMessage `json:"messages"`
+		AckIDs             []string        `json:"ackIds"`
+		AckDeadlineSeconds int             `json:"ackDeadlineSeconds"`
+	}
+	json.NewDecoder(req.Body).Decode(&in)
+	switch {
+	case strings.HasSuffix(req.URL.Path, ":publish"):
+		for _, message := range in.Messages {
+			message.MessageID = strconv.Itoa(len(f.messages))
+			f.messages = append(f.messages, &stored{message: message})
+		}
+		json.NewEncoder(w).Encode(map[string][]string{"messageIds": {"1"}})
+	case strings.HasSuffix(req.URL.Path, ":pull"):
+		var received []receivedMessage
+		blocked := map[string]bool{}
+		for _, s := range f.messages {
+			key := s.message.OrderingKey
+			if time.Now().Before(s.until) || blocked[key] {
+				if "" != key {
+					blocked[key] = true
+				}
+				continue
+			}
+			f.acks++
+			s.ackID, s.until = strconv.Itoa(f.acks), time.Now().Add(10*time.Second)
+			received = append(received, receivedMessage{AckID: s.ackID, Message: s.message})
+		}
+		if 0 == len(received) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"receivedMessages": received})
+	case strings.HasSuffix(req.URL.Path, ":acknowledge"), strings.HasSuffix(req.URL.Path, ":modifyAckDeadline"):
+		for _, id := range in.AckIDs {
+			for i, s := range f.messages {
+				if s.ackID != id {
+					continue
+				}
+				if strings.HasSuffix(req.URL.Path, ":acknowledge") {
+					f.messages = append(f.messages[:i], f.messages[i+1:]...)
+				} else {
+					if 0 != in.AckDeadlineSeconds {
+						atomic.AddInt32(&f.extensions, 1)
+					}
+					s.until = time.Now().Add(time.Duration(in.AckDeadlineSeconds) * time.Second)
+				}
+				break
+			}
+		}
+		w.Write([]byte(`{}`))
+	}
+}
+
+func (f *fakePubSub) pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.messages)
+}
+
+func TestBridge(t *testing.T) {
+	fake := new(fakePubSub)
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	config := Config{Project: "shop", Endpoint: server.URL, Token: func(ctx context.Context) (string, error) { return "token", nil }}
+
+	t.Log("测试带排序键发布")
+	local := trigger.NewTrigger()
+	publisher := &Publisher{Config: config, Topic: "orders", OrderingKey: func(event string, arguments []interface{}) string {
+		if o := arguments[0].(order); 0 != o.ID {
+			return "customer-1"
+		}
+		return ""
+	}}
+	publisher.Attach(local, "order.paid")
+	local.EmitSync("order.paid", order{ID: 1, Token: "secret"}).
+		EmitSync("order.paid", order{ID: 2}).
+		EmitSync("order.paid", order{ID: 3}).
+		EmitSync("order.paid", order{})
+	if 4 != fake.pending() || "customer-1" != fake.messages[0].message.OrderingKey || "" != fake.messages[3].message.OrderingKey ||
+		strings.Contains(string(fake.messages[0].message.Data), "secret") || "order.paid" != fake.messages[0].message.Attributes["event"] {
+		t.Fatalf("发布错误: %d", fake.pending())
+	}
+
+	t.Log("测试同一排序键按顺序执行, 失败后重新投递仍保持顺序")
+	var (
+		mu       sync.Mutex
+		ordered  []int
+		running  int32
+		overlap  int32
+		attempts int32
+	)
+	remote := trigger.NewTrigger().WithCoercion(true).
+		On("order.paid", func(o order) error {
+			if 0 == o.ID {
+				return nil
+			}
+			if 1 != atomic.AddInt32(&running, 1) {
+				atomic.StoreInt32(&overlap, 1)
+			}
+			defer atomic.AddInt32(&running, -1)
+			if 2 == o.ID && 1 == atomic.AddInt32(&attempts, 1) {
+				return errors.New("暂时失败")
+			}
+			if 3 == o.ID {
+				// 执行超过确认期限的一半, 需延长
+				time.Sleep(700 * time.Millisecond)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			ordered = append(ordered, o.ID)
+			return nil
+		})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	subscriber := &Subscriber{Config: config, Subscription: "orders-sub", AckDeadline: time.Second}
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- subscriber.Run(ctx, remote)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for 0 != fake.pending() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	if "[1 2 3]" != fmt.Sprint(ordered) || 0 != atomic.LoadInt32(&overlap) || 2 != atomic.LoadInt32(&attempts) {
+		t.Fatalf("排序执行错误: %v %d %d", ordered, overlap, attempts)
+	}
+	mu.Unlock()
+	if 0 == atomic.LoadInt32(&fake.extensions) {
+		t.Fatalf("未延长确认期限")
+	}
+
+	cancel()
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Fatalf("退出错误: %v", err)
+	}
+
+	t.Log("测试服务返回的错误")
+	_, err := (&Publisher{Config: Config{Project: "shop", Endpoint: server.URL}, Topic: "orders"}).Publish(context.Background(), "x")
+	var failure *Error
+	if !errors.As(err, &failure) || http.StatusUnauthorized != failure.Code {
+		t.Fatalf("错误解析失败: %v", err)
+	}
+}
+
+func TestLineage(t *testing.T) {
+	t.Log("测试发布时写入截止时间与经过的节点")
+	deadline := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	config := &Config{Node: "node-b"}
+	var message Message
+	local := trigger.NewTrigger().WithInheritance(true)
+	local.On("order.paid", func(id int) {
+		encoded, _ := config.encodeMessage(local, "order.paid", []interface{}{id})
+		json.Unmarshal([]byte(encoded), &message)
+	})
+	local.EmitSyncWith(trigger.Lineage{Deadline: deadline, Hops: []string{"node-a"}}, "order.paid", 1)
+	if deadline.UnixMilli() != message.Deadline || "[node-a node-b]" != fmt.Sprint(message.Hops) || "node-b" != message.Origin {
+		t.Fatalf("消息的继承属性错误: %+v", message)
+	}
+
+	t.Log("测试接收时丢弃环路与过期的触发")
+	var received []time.Time
+	remote := trigger.NewTrigger().WithInheritance(true)
+	remote.On("order.paid", func() {
+		lineage, _ := remote.Lineage()
+		received = append(received, lineage.Deadline)
+	})
+	g := new(trigger.ListenerGroup)
+	if config.emit(remote, g, message, nil) {
+		t.Fatalf("已经过本节点的消息不应触发")
+	}
+	message.Hops = []string{"node-a"}
+	if !config.emit(remote, g, message, nil) || nil != g.Wait() || 1 != len(received) || !deadline.Equal(received[0]) {
+		t.Fatalf("消息未触发: %v", received)
+	}
+	message.Deadline = time.Now().Add(-time.Second).UnixMilli()
+	g = new(trigger.ListenerGroup)
+	if !config.emit(remote.RecoverWith(func(event, listener interface{}, err error) {}), g, message, nil) || !errors.Is(g.Wait(), trigger.ErrExpired) || 1 != len(received) {
+		t.Fatalf("过期的消息应丢弃: %v", received)
+	}
+}
@@ -0,0 +1,91 @@
+package pubsubbridge
+
+import (
+	"context"
+
+	"github.com/yann1989/trigger"
+)
+
+// 将本地事件发布到Pub/Sub主题
+type Publisher struct {
+	Config
+	// 主题ID
+	Topic string
+	// 排序键, 同一排序键的消息按发布顺序投递, 主题的订阅需开启消息排序, 为nil或返回空字符串时不排序
+	OrderingKey func(event string, arguments []interface{}) string
+	// Attach发布失败时的回调, 为nil时忽略
+	OnError func(event string, err error)
+}
+
+// 发布的消息
+type pubsubMessage struct {
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	MessageID   string            `json:"messageId,omitempty"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+//***************************************************
+//Description : 发布事件, 事件名称同时写入event属性
+//param :       上下文
+//param :       事件名称
+//param :       参数
+//return :      消息ID
+//return :      编码或发布失败的错误
+//***************************************************
+func (p *Publisher) Publish(ctx context.Context, event string, arguments ...interface{}) (string, error) {
+	return p.publish(ctx, nil, event, arguments)
+}
+
+//***************************************************
+//Description : 发布事件, 参数按触发器的脱敏配置脱敏
+//param :       上下文
+//param :       触发器, nil时只按结构体标签脱敏
+//param :       事件名称
+//param :       参数
+//return :      消息ID
+//return :      编码或发布失败的错误
+//***************************************************
+func (p *Publisher) publish(ctx context.Context, t *trigger.Trigger, event string, arguments []interface{}) (string, error) {
+	data, err := p.encodeMessage(t, event, arguments)
+	if nil != err {
+		return "", err
+	}
+	message := pubsubMessage{Data: data, Attributes: map[string]string{"event": event}}
+	if nil != p.OrderingKey {
+		message.OrderingKey = p.OrderingKey(event, arguments)
+	}
+
+	var out struct {
+		MessageIDs []string `json:"messageIds"`
+	}
+	in := map[string][]pubsubMessage{"messages": {message}}
+	if err := p.call(ctx, "topics/"+p.Topic+":publish", in, &out); nil != err {
+		return "", err
+	}
+	if 0 == len(out.MessageIDs) {
+		return "", nil
+	}
+	return out.MessageIDs[0], nil
+}
+
+//***************************************************
+//Description : 在触发器上监听指定事件并发布, 发布失败时调用OnError, 同时作为监听的返回值
+//              需要保持顺序的事件应以串行监听或同步触发, 否则并发的发布不保证顺序
+//param :       触发器
+//param :       字符串类型的事件名称
+//return :      Publisher
+//***************************************************
+func (p *Publisher) Attach(t *trigger.Trigger, events ...string) *Publisher {
+	for _, event := range events {
+		event := event
+		t.On(event, func(arguments ...interface{}) error {
+			_, err := p.publish(context.Background(), t, event, arguments)
+			if nil != err && nil != p.OnError {
+				p.OnError(event, err)
+			}
+			return err
+		})
+	}
+	return p
+}
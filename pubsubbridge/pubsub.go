@@ -0,0 +1,224 @@
+// pubsubbridge 通过Google Cloud Pub/Sub的REST接口发布本地事件, 并把订阅中的消息在本地触发, 只依赖net/http
+//
+// 消息的data为JSON, 事件名称同时写入名为event的属性, 可用于订阅的过滤条件:
+//
+//	{"event":"order.paid","args":[1,"a"]}
+//
+// 本地监听收到的参数为json.RawMessage, 使用具体类型时需开启WithCoercion
+// 发布时可指定排序键, 接收时同一排序键的消息按顺序逐条执行, 不同排序键与没有排序键的消息并发执行
+// 监听全部成功后才确认, 执行期间按确认期限的一半定期延长, 失败时立即放弃确认以便重新投递
+// 排序键的消息失败时, 同一批中其后同一排序键的消息也放弃确认, 保持重新投递的顺序
+// 与wsbridge相同, 发布方开启WithInheritance时触发的截止时间与经过的节点写入消息的deadline与hops, 配置Config.Node后origin为本节点
+// 接收方携带截止时间触发, 已过期的触发由触发器丢弃并报告, 已经过本节点或超出Config.MaxHops的消息不触发, 两者都直接确认而不重试
+//
+//	{"event":"order.paid","args":[1],"deadline":1700000000000,"hops":["node-a"],"origin":"node-a"}
+package pubsubbridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 默认的服务地址
+const defaultEndpoint = "https://pubsub.googleapis.com"
+
+// 单个响应体的大小上限
+const maxBodySize = 4 << 20
+
+// 消息内容
+type Message struct {
+	// 事件名称
+	Event string `json:"event"`
+	// 参数
+	Args []json.RawMessage `json:"args"`
+	// 截止时间, Unix毫秒, 0表示没有
+	Deadline int64 `json:"deadline,omitempty"`
+	// 经过的节点, 用于环路检测
+	Hops []string `json:"hops,omitempty"`
+	// 发送方节点
+	Origin string `json:"origin,omitempty"`
+}
+
+// Pub/Sub返回的错误
+type Error struct {
+	// HTTP状态码
+	Code int `json:"code"`
+	// 错误信息
+	Message string `json:"message"`
+	// 状态, 如NOT_FOUND
+	Status string `json:"status"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Pub/Sub错误[%d/%s]: %s", e.Code, e.Status, e.Message)
+}
+
+// 访问配置
+type Config struct {
+	// 项目ID
+	Project string
+	// 获取OAuth 2.0访问令牌, 为nil时不认证, 可用于模拟器
+	Token func(ctx context.Context) (string, error)
+	// 服务地址, 为空时为https://pubsub.googleapis.com, 可指向模拟器
+	Endpoint string
+	// HTTP客户端, 为nil时使用http.DefaultClient
+	HTTP *http.Client
+	// 本节点名称, 为空表示不做环路检测
+	Node string
+	// 触发最多经过的节点数, 超出时丢弃, 0表示不限
+	MaxHops int
+}
+
+//***************************************************
+//Description : 编码消息内容, 参数按触发器的结构体标签与脱敏函数脱敏
+//              发布方协程的截止时间与经过的节点写入消息, 需开启WithInheritance
+//param :       触发器, nil时只按结构体标签脱敏且不携带截止时间
+//param :       事件名称
+//param :       参数
+//return :      JSON
+//return :      参数编码失败的错误
+//***************************************************
+func (config *Config) encodeMessage(t *trigger.Trigger, event string, arguments []interface{}) ([]byte, error) {
+	arguments = t.RedactArguments(event, arguments)
+	message := Message{Event: event, Args: make([]json.RawMessage, len(arguments)), Origin: config.Node}
+	var lineage trigger.Lineage
+	if nil != t {
+		lineage, _ = t.Lineage()
+	}
+	if !lineage.Deadline.IsZero() {
+		message.Deadline = lineage.Deadline.UnixMilli()
+	}
+	message.Hops = config.via(lineage.Hops)
+	for i, argument := range arguments {
+		raw, err := json.Marshal(argument)
+		if nil != err {
+			return nil, fmt.Errorf("第%d个参数编码失败: %w", i+1, err)
+		}
+		message.Args[i] = raw
+	}
+	return json.Marshal(message)
+}
+
+//***************************************************
+//Description : 在本地通过协程组触发收到的消息, 携带消息的截止时间与经过的节点, 已过期的触发由触发器丢弃并报告
+//param :       本地触发器
+//param :       协程组
+//param :       消息
+//param :       参数
+//return :      是否触发, 已经过本节点或超出最大跳数时丢弃
+//***************************************************
+func (config *Config) emit(t *trigger.Trigger, g *trigger.ListenerGroup, message Message, arguments []interface{}) bool {
+	if config.looped(message.Hops) {
+		return false
+	}
+	hops := config.via(message.Hops)
+	if 0 == message.Deadline && 0 == len(hops) {
+		t.EmitGroup(g, message.Event, arguments...)
+		return true
+	}
+	lineage := trigger.Lineage{Hops: hops}
+	if 0 != message.Deadline {
+		lineage.Deadline = time.UnixMilli(message.Deadline)
+	}
+	t.EmitGroupWith(g, lineage, message.Event, arguments...)
+	return true
+}
+
+//***************************************************
+//Description : 是否已经过本节点或超出最大跳数
+//param :       经过的节点
+//return :      是否形成环路
+//***************************************************
+func (config *Config) looped(hops []string) bool {
+	if config.MaxHops > 0 && len(hops) > config.MaxHops {
+		return true
+	}
+	if "" == config.Node {
+		return false
+	}
+	for _, hop := range hops {
+		if config.Node == hop {
+			return true
+		}
+	}
+	return false
+}
+
+//***************************************************
+//Description : 追加本节点
+//param :       经过的节点
+//return :      追加后的节点
+//***************************************************
+func (config *Config) via(hops []string) []string {
+	if "" == config.Node || config.looped(hops) {
+		return hops
+	}
+	return append(hops[:len(hops):len(hops)], config.Node)
+}
+
+//***************************************************
+//Description : 调用REST接口
+//param :       上下文
+//param :       资源路径与方法, 如topics/orders:publish
+//param :       请求体
+//param :       响应的解码目标, nil表示忽略
+//return :      请求失败或服务返回错误时的错误, 后者为*Error
+//***************************************************
+func (config *Config) call(ctx context.Context, resource string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if nil != err {
+		return err
+	}
+	endpoint := config.Endpoint
+	if "" == endpoint {
+		endpoint = defaultEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v1/projects/"+config.Project+"/"+resource, bytes.NewReader(body))
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if nil != config.Token {
+		token, err := config.Token(ctx)
+		if nil != err {
+			return fmt.Errorf("获取访问令牌失败: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := config.HTTP
+	if nil == client {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if nil != err {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var failure struct {
+			Error Error `json:"error"`
+		}
+		json.Unmarshal(data, &failure)
+		if 0 == failure.Error.Code {
+			failure.Error.Code, failure.Error.Message = resp.StatusCode, resp.Status
+		}
+		return &failure.Error
+	}
+	if nil == out {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
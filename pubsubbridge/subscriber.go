@@ -0,0 +1,256 @@
+package pubsubbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 默认配置
+const (
+	defaultAckDeadline = 10 * time.Second
+	defaultMaxMessages = 10
+	// 拉取失败后的等待时间
+	retryInterval = time.Second
+)
+
+// 拉取到的消息
+type receivedMessage struct {
+	AckID           string        `json:"ackId"`
+	Message         pubsubMessage `json:"message"`
+	DeliveryAttempt int           `json:"deliveryAttempt"`
+}
+
+// 拉取订阅并在本地触发
+type Subscriber struct {
+	Config
+	// 订阅ID
+	Subscription string
+	// 确认期限, 执行期间按其一半定期延长, 为0时为10秒, 按秒取整
+	AckDeadline time.Duration
+	// 每次拉取的消息数量上限, 为0时为10
+	MaxMessages int
+	// 拉取、确认或消息格式错误时的回调, 为nil时忽略
+	OnError func(err error)
+}
+
+//***************************************************
+//Description : 持续拉取消息并在本地触发, 处理完一批再拉取下一批
+//              同一排序键的消息按顺序逐条执行, 相当于按排序键分区, 其他消息并发执行
+//param :       上下文, 取消时退出
+//param :       本地触发器
+//return :      上下文的错误
+//***************************************************
+func (s *Subscriber) Run(ctx context.Context, t *trigger.Trigger) error {
+	for {
+		messages, err := s.pull(ctx)
+		if nil != ctx.Err() {
+			return ctx.Err()
+		}
+		if nil != err {
+			s.fail(err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryInterval):
+			}
+			continue
+		}
+
+		var wg sync.WaitGroup
+		for _, partition := range partition(messages) {
+			wg.Add(1)
+			go func(partition []receivedMessage) {
+				defer wg.Done()
+				s.handlePartition(ctx, t, partition)
+			}(partition)
+		}
+		wg.Wait()
+	}
+}
+
+//***************************************************
+//Description : 按排序键分区, 保持同一排序键的先后顺序, 没有排序键的消息各自一个分区
+//param :       消息
+//return :      分区
+//***************************************************
+func partition(messages []receivedMessage) [][]receivedMessage {
+	var partitions [][]receivedMessage
+	index := make(map[string]int)
+	for _, message := range messages {
+		key := message.Message.OrderingKey
+		if "" == key {
+			partitions = append(partitions, []receivedMessage{message})
+			continue
+		}
+		if i, ok := index[key]; ok {
+			partitions[i] = append(partitions[i], message)
+			continue
+		}
+		index[key] = len(partitions)
+		partitions = append(partitions, []receivedMessage{message})
+	}
+	return partitions
+}
+
+//***************************************************
+//Description : 依次处理一个分区, 失败后放弃确认其余的消息
+//param :       上下文
+//param :       本地触发器
+//param :       分区中的消息
+//***************************************************
+func (s *Subscriber) handlePartition(ctx context.Context, t *trigger.Trigger, messages []receivedMessage) {
+	// 确认不因退出而中断, 避免执行完的消息重复投递
+	ack := context.WithoutCancel(ctx)
+	for i, message := range messages {
+		if !s.handle(ctx, ack, t, message) {
+			s.modifyDeadline(ack, ackIDs(messages[i+1:]), 0)
+			return
+		}
+	}
+}
+
+//***************************************************
+//Description : 处理一条消息
+//param :       上下文
+//param :       确认使用的上下文
+//param :       本地触发器
+//param :       消息
+//return :      是否成功
+//***************************************************
+func (s *Subscriber) handle(ctx, ack context.Context, t *trigger.Trigger, message receivedMessage) bool {
+	var decoded Message
+	if err := json.Unmarshal(message.Message.Data, &decoded); nil != err || "" == decoded.Event {
+		s.fail(fmt.Errorf("消息[%s]格式错误: %v", message.Message.MessageID, err))
+		s.modifyDeadline(ack, []string{message.AckID}, 0)
+		return false
+	}
+	arguments := make([]interface{}, len(decoded.Args))
+	for i, arg := range decoded.Args {
+		arguments[i] = arg
+	}
+
+	// 执行期间定期延长确认期限
+	done := make(chan struct{})
+	extended := make(chan struct{})
+	go func() {
+		defer close(extended)
+		s.extend(ctx, message.AckID, done)
+	}()
+	// 已经过本节点的消息与已过期的触发直接确认
+	var err error
+	g := new(trigger.ListenerGroup)
+	if s.emit(t, g, decoded, arguments) {
+		err = g.Wait()
+	}
+	close(done)
+	<-extended
+
+	if nil != err && !errors.Is(err, trigger.ErrExpired) {
+		s.modifyDeadline(ack, []string{message.AckID}, 0)
+		return false
+	}
+	if err := s.call(ack, "subscriptions/"+s.Subscription+":acknowledge", map[string][]string{"ackIds": {message.AckID}}, nil); nil != err {
+		s.fail(err)
+	}
+	return true
+}
+
+//***************************************************
+//Description : 拉取一批消息
+//param :       上下文
+//return :      消息
+//return :      拉取失败的错误
+//***************************************************
+func (s *Subscriber) pull(ctx context.Context) ([]receivedMessage, error) {
+	max := s.MaxMessages
+	if 0 == max {
+		max = defaultMaxMessages
+	}
+	var out struct {
+		ReceivedMessages []receivedMessage `json:"receivedMessages"`
+	}
+	if err := s.call(ctx, "subscriptions/"+s.Subscription+":pull", map[string]int{"maxMessages": max}, &out); nil != err {
+		return nil, err
+	}
+	return out.ReceivedMessages, nil
+}
+
+//***************************************************
+//Description : 监听执行期间按确认期限的一半定期延长
+//param :       上下文
+//param :       确认ID
+//param :       监听执行完毕时关闭
+//***************************************************
+func (s *Subscriber) extend(ctx context.Context, ackID string, done <-chan struct{}) {
+	seconds := s.deadlineSeconds()
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.modifyDeadline(ctx, []string{ackID}, seconds)
+		}
+	}
+}
+
+//***************************************************
+//Description : 修改确认期限, 0表示放弃确认并立即重新投递
+//param :       上下文
+//param :       确认ID
+//param :       期限秒数
+//***************************************************
+func (s *Subscriber) modifyDeadline(ctx context.Context, ids []string, seconds int) {
+	if 0 == len(ids) {
+		return
+	}
+	in := map[string]interface{}{"ackIds": ids, "ackDeadlineSeconds": seconds}
+	if err := s.call(ctx, "subscriptions/"+s.Subscription+":modifyAckDeadline", in, nil); nil != err {
+		s.fail(err)
+	}
+}
+
+//***************************************************
+//Description : 确认期限的秒数, 至少为1
+//return :      秒数
+//***************************************************
+func (s *Subscriber) deadlineSeconds() int {
+	deadline := s.AckDeadline
+	if 0 == deadline {
+		deadline = defaultAckDeadline
+	}
+	if deadline < time.Second {
+		return 1
+	}
+	return int(deadline / time.Second)
+}
+
+//***************************************************
+//Description : 获取消息的确认ID
+//param :       消息
+//return :      确认ID
+//***************************************************
+func ackIDs(messages []receivedMessage) []string {
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.AckID
+	}
+	return ids
+}
+
+//***************************************************
+//Description : 报告错误
+//param :       错误
+//***************************************************
+func (s *Subscriber) fail(err error) {
+	if nil != s.OnError {
+		s.OnError(err)
+	}
+}
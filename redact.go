@@ -0,0 +1,224 @@
+package trigger
+
+import (
+	"reflect"
+	"sync"
+)
+
+// 脱敏后的字符串字段值
+const redactedText = "[REDACTED]"
+
+// 类型 -> 是否需要脱敏
+var redactionCache sync.Map
+
+// 参数脱敏函数, 返回脱敏后的参数, 不能修改传入的参数
+type Redactor func(event interface{}, arguments []interface{}) []interface{}
+
+//***************************************************
+//Description : 设置参数脱敏函数, 参数在离开监听之前(如调试记录)先经过结构体标签脱敏, 再经过此函数
+//param :       脱敏函数, nil表示只按结构体标签脱敏
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithRedactor(redactor Redactor) *Trigger {
+	if nil == redactor {
+		trigger.redactor.Store(nil)
+		return trigger
+	}
+	trigger.redactor.Store(&redactor)
+	return trigger
+}
+
+//***************************************************
+//Description : 获取脱敏后的参数, 用于日志与持久化, 监听收到的参数不受影响
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      脱敏后的参数
+//***************************************************
+func (trigger *Trigger) redact(event interface{}, arguments []interface{}) []interface{} {
+	redacted := make([]interface{}, len(arguments))
+	copy(redacted, redactArguments(arguments))
+	if redactor := trigger.redactor.Load(); nil != redactor {
+		redacted = (*redactor)(event, redacted)
+	}
+	return redacted
+}
+
+//***************************************************
+//Description : 获取脱敏后的参数, 先按结构体标签脱敏, 再经过WithRedactor设置的脱敏函数
+//              供桥接等在参数离开进程前使用
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      脱敏后的参数, 触发器为nil时只按结构体标签脱敏
+//***************************************************
+func (trigger *Trigger) RedactArguments(event interface{}, arguments []interface{}) []interface{} {
+	if nil == trigger {
+		return redactArguments(arguments)
+	}
+	return trigger.redact(event, arguments)
+}
+
+//***************************************************
+//Description : 按结构体标签脱敏, 带有`trigger:"redact"`标签的导出字段
+//              字符串替换为[REDACTED], 其他类型置为零值, 嵌套的结构体、指针、切片、数组、映射与接口递归处理
+//param :       值, 其他类型原样返回
+//return :      脱敏后的副本, 没有需要脱敏的字段时返回原值
+//***************************************************
+func Redact(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || !needsRedaction(v.Type()) {
+		return value
+	}
+	return redactValue(v).Interface()
+}
+
+//***************************************************
+//Description : 脱敏参数列表中的每个参数
+//param :       回调函数中的参数
+//return :      脱敏后的参数, 没有需要脱敏的参数时返回原切片
+//***************************************************
+func redactArguments(arguments []interface{}) []interface{} {
+	redacted, copied := arguments, false
+	for i, argument := range arguments {
+		v := reflect.ValueOf(argument)
+		if !v.IsValid() || !needsRedaction(v.Type()) {
+			continue
+		}
+		if !copied {
+			redacted, copied = append([]interface{}(nil), arguments...), true
+		}
+		redacted[i] = redactValue(v).Interface()
+	}
+	return redacted
+}
+
+//***************************************************
+//Description : 按类型复制并脱敏
+//param :       值反射
+//return :      脱敏后的副本, 类型与原值相同
+//***************************************************
+func redactValue(v reflect.Value) reflect.Value {
+	if !needsRedaction(v.Type()) {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(redactValue(v.Elem()))
+		return copied
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(redactValue(v.Elem()))
+		return copied
+	case reflect.Struct:
+		return redactStruct(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			copied.SetMapIndex(iter.Key(), redactValue(iter.Value()))
+		}
+		return copied
+	}
+	return v
+}
+
+//***************************************************
+//Description : 复制结构体并脱敏
+//param :       结构体反射
+//return :      脱敏后的结构体副本
+//***************************************************
+func redactStruct(v reflect.Value) reflect.Value {
+	copied := reflect.New(v.Type()).Elem()
+	copied.Set(v)
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		target := copied.Field(i)
+		if !target.CanSet() {
+			continue
+		}
+		switch {
+		case "redact" == field.Tag.Get("trigger"):
+			if reflect.String == target.Kind() {
+				target.SetString(redactedText)
+			} else {
+				target.Set(reflect.Zero(field.Type))
+			}
+		case needsRedaction(field.Type):
+			target.Set(redactValue(v.Field(i)))
+		}
+	}
+	return copied
+}
+
+//***************************************************
+//Description : 类型中是否可能有需要脱敏的字段, 接口类型需按实际的值判断
+//param :       类型
+//return :      是否需要脱敏
+//***************************************************
+func needsRedaction(t reflect.Type) bool {
+	if needs, ok := redactionCache.Load(t); ok {
+		return needs.(bool)
+	}
+	needs := hasRedactTag(t, map[reflect.Type]bool{})
+	redactionCache.Store(t, needs)
+	return needs
+}
+
+//***************************************************
+//Description : 递归检查脱敏标签, 接口视为可能需要脱敏
+//param :       类型
+//param :       已检查的类型, 避免递归类型死循环
+//return :      是否有脱敏标签
+//***************************************************
+func hasRedactTag(t reflect.Type, seen map[reflect.Type]bool) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return hasRedactTag(t.Elem(), seen)
+	case reflect.Struct:
+	default:
+		return false
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if "redact" == field.Tag.Get("trigger") || hasRedactTag(field.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package trigger
+
+import (
+	"time"
+)
+
+// 事件与监听者数组的映射
+// 写入时复制: 发布后的映射与其中的数组都不再修改, 触发时无需加锁即可读取
+type registry map[interface{}][]*handler
+
+//***************************************************
+//Description : 获取当前的监听映射, 只读
+//return :      监听映射
+//***************************************************
+func (trigger *Trigger) loadRegistry() registry {
+	return *trigger.events.Load()
+}
+
+//***************************************************
+//Description : 获取某事件当前的监听者数组, 只读
+//param :       事件类型
+//return :      监听者数组
+//***************************************************
+func (trigger *Trigger) handlersOf(event interface{}) []*handler {
+	return trigger.loadRegistry()[event]
+}
+
+//***************************************************
+//Description : 复制监听映射并替换某事件的监听者数组, 按声明的执行顺序排列, 调用方需持有写锁
+//param :       事件类型
+//param :       新的监听者数组
+//***************************************************
+func (trigger *Trigger) storeHandlers(event interface{}, handlers []*handler) {
+	handlers = trigger.orderHandlers(event, handlers)
+	trigger.prepareConflation(event, handlers)
+	current := trigger.loadRegistry()
+	next := make(registry, len(current)+1)
+	for key, value := range current {
+		next[key] = value
+	}
+	next[event] = handlers
+	trigger.events.Store(&next)
+	trigger.intercepting.Store(0 != len(next[anyEvent{}]) || 0 != len(next[matchEvent{}]) || 0 != len(next[unhandledEvent{}]))
+}
+
+//***************************************************
+//Description : 没有监听时从监听映射中删除事件, 用于只使用一次的事件, 如请求的回复事件
+//param :       事件类型
+//***************************************************
+func (trigger *Trigger) dropEvent(event interface{}) {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	current := trigger.loadRegistry()
+	if handlers, ok := current[event]; !ok || 0 != len(handlers) {
+		return
+	}
+	next := make(registry, len(current))
+	for key, value := range current {
+		if key != event {
+			next[key] = value
+		}
+	}
+	trigger.events.Store(&next)
+}
+
+//***************************************************
+//Description : 整理监听映射, 删除没有监听的事件并收缩监听者数组
+//              频繁添加移除监听的触发器会留下空事件与过大的数组
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) Compact() *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	current := trigger.loadRegistry()
+	next := make(registry, len(current))
+	for event, handlers := range current {
+		if 0 == len(handlers) {
+			continue
+		}
+		if cap(handlers) != len(handlers) {
+			handlers = append([]*handler(nil), handlers...)
+		}
+		next[event] = handlers
+	}
+	trigger.events.Store(&next)
+	return trigger
+}
+
+//***************************************************
+//Description : 设置自动整理周期
+//param :       整理周期, 小于等于0表示关闭自动整理
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) SetAutoCompact(interval time.Duration) *Trigger {
+	trigger.Lock()
+	if nil != trigger.compactStop {
+		close(trigger.compactStop)
+		trigger.compactStop = nil
+	}
+	if interval <= 0 {
+		trigger.Unlock()
+		return trigger
+	}
+	stop := make(chan struct{})
+	trigger.compactStop = stop
+	trigger.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				trigger.Compact()
+			}
+		}
+	}()
+	return trigger
+}
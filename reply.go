@@ -0,0 +1,135 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Request生成的回复事件名称前缀
+const ReplyEventPrefix = "trigger.reply."
+
+// 请求的序号
+var requestSeq atomic.Uint64
+
+// 回复地址, 作为请求触发与回复触发的第一个参数
+// 请求触发携带回复事件与关联ID, 监听调用Reply以相同的关联ID触发回复事件
+type ReplyAddress struct {
+	// 回复事件, 回复触发中为空
+	ReplyTo string `json:"reply_to,omitempty"`
+	// 关联ID, 回复时原样带回
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// 回复所用的触发器, 经过JSON等编码后丢失, 需由桥接方通过NewReplyAddress重新绑定
+	trigger *Trigger
+}
+
+//***************************************************
+//Description : 创建绑定此触发器的回复地址, 供桥接等组件把收到的回复地址交给本地监听
+//param :       回复事件
+//param :       关联ID
+//return :      回复地址
+//***************************************************
+func (trigger *Trigger) NewReplyAddress(replyTo, correlationID string) *ReplyAddress {
+	return &ReplyAddress{ReplyTo: replyTo, CorrelationID: correlationID, trigger: trigger}
+}
+
+//***************************************************
+//Description : 回复请求: 以带相同关联ID的回复地址作为第一个参数触发回复事件
+//param :       上下文, 已结束时不回复
+//param :       回复的参数
+//return :      没有回复事件或未绑定触发器时返回包装ErrNoReplyTarget的错误
+//***************************************************
+func (address *ReplyAddress) Reply(ctx context.Context, arguments ...interface{}) error {
+	if nil == address || "" == address.ReplyTo || nil == address.trigger {
+		return ErrNoReplyTarget
+	}
+	if err := ctx.Err(); nil != err {
+		return &TimeoutError{Event: address.ReplyTo, Err: err}
+	}
+	reply := &ReplyAddress{CorrelationID: address.CorrelationID, trigger: address.trigger}
+	address.trigger.Emit(address.ReplyTo, append([]interface{}{reply}, arguments...)...)
+	return nil
+}
+
+//***************************************************
+//Description : 发起请求并等待第一个回复: 以新的回复地址作为第一个参数触发事件
+//              监听通过ReplyAddress.Reply回复, 跨桥接的请求同样适用
+//              开启WithRequestCache时参数相同的请求在有效期内直接返回缓存的回复
+//              开启WithRequestCollapsing时参数相同的并发请求只触发一次
+//param :       上下文, 结束时返回TimeoutError
+//param :       事件类型
+//param :       请求的参数
+//return :      回复的参数, 不含回复地址
+//return :      错误
+//***************************************************
+func (trigger *Trigger) Request(ctx context.Context, event interface{}, arguments ...interface{}) ([]interface{}, error) {
+	cache, flights := trigger.requestCacheOf(event), trigger.flightsOf(event)
+	if nil == cache && nil == flights {
+		return trigger.request(ctx, event, arguments)
+	}
+	digest, ok := requestDigest(event, arguments)
+	if !ok {
+		return trigger.request(ctx, event, arguments)
+	}
+	var generation uint64
+	if nil != cache {
+		reply, current, hit := cache.load(digest)
+		if hit {
+			return reply, nil
+		}
+		generation = current
+	}
+
+	var reply []interface{}
+	var err error
+	if nil != flights {
+		reply, err = flights.do(ctx, event, digest, func(ctx context.Context) ([]interface{}, error) {
+			return trigger.request(ctx, event, arguments)
+		})
+	} else {
+		reply, err = trigger.request(ctx, event, arguments)
+	}
+	if nil == err && nil != cache {
+		cache.store(digest, reply, generation)
+	}
+	return reply, err
+}
+
+//***************************************************
+//Description : 发起请求并等待第一个回复
+//param :       上下文
+//param :       事件类型
+//param :       请求的参数
+//return :      回复的参数
+//return :      错误
+//***************************************************
+func (trigger *Trigger) request(ctx context.Context, event interface{}, arguments []interface{}) ([]interface{}, error) {
+	id := fmt.Sprintf("%d-%d", time.Now().UnixNano(), requestSeq.Add(1))
+	address := trigger.NewReplyAddress(ReplyEventPrefix+id, id)
+
+	replies := make(chan []interface{}, 1)
+	trigger.OnNamed(address.ReplyTo, "request", func(reply *ReplyAddress, arguments ...interface{}) {
+		select {
+		case replies <- arguments:
+		default:
+		}
+	})
+	defer trigger.dropEvent(address.ReplyTo)
+	defer trigger.OffNamed(address.ReplyTo, "request")
+
+	// 互斥组等会在触发方协程中阻塞, 在新协程中触发以便及时响应上下文结束
+	lineage := trigger.currentLineage()
+	go func() {
+		if nil != lineage {
+			defer trigger.enterLineage(lineage)()
+		}
+		trigger.Emit(event, append([]interface{}{address}, arguments...)...)
+	}()
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-ctx.Done():
+		return nil, &TimeoutError{Event: event, Err: ctx.Err()}
+	}
+}
@@ -0,0 +1,170 @@
+package trigger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 请求缓存失效监听的名称前缀
+const requestCacheKeyPrefix = "request-cache:"
+
+// 单个事件的请求回复缓存
+type requestCache struct {
+	// 有效期
+	ttl time.Duration
+	// 失效事件
+	invalidateOn []interface{}
+	// 保护以下字段
+	mu sync.Mutex
+	// 请求参数的摘要 -> 回复
+	entries map[string]cachedReply
+	// 失效次数, 失效前发出的请求不再写入缓存
+	generation uint64
+}
+
+// 缓存的回复
+type cachedReply struct {
+	// 回复的参数
+	reply []interface{}
+	// 过期时间
+	expires time.Time
+}
+
+//***************************************************
+//Description : 开启事件的请求回复缓存, 有效期内参数相同的Request直接返回缓存的回复, 不再触发事件
+//              参数按JSON编码计算摘要, 无法编码的请求不缓存, 任一失效事件触发时清空此事件的缓存
+//param :       请求事件
+//param :       有效期, 小于等于0表示关闭
+//param :       失效事件, 如数据变更事件
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithRequestCache(event interface{}, ttl time.Duration, invalidateOn ...interface{}) *Trigger {
+	var cache *requestCache
+	if ttl > 0 {
+		cache = &requestCache{ttl: ttl, invalidateOn: append([]interface{}(nil), invalidateOn...), entries: make(map[string]cachedReply)}
+	}
+
+	trigger.Lock()
+	old := trigger.requestCaches[event]
+	if nil == cache {
+		delete(trigger.requestCaches, event)
+	} else {
+		if nil == trigger.requestCaches {
+			trigger.requestCaches = make(map[interface{}]*requestCache)
+		}
+		trigger.requestCaches[event] = cache
+	}
+	trigger.Unlock()
+
+	key := requestCacheKeyPrefix + fmt.Sprint(event)
+	if nil != old {
+		for _, invalidation := range old.invalidateOn {
+			trigger.OffNamed(invalidation, key)
+		}
+	}
+	if nil != cache {
+		for _, invalidation := range cache.invalidateOn {
+			trigger.OnNamed(invalidation, key, func(...interface{}) {
+				cache.invalidate()
+			})
+		}
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 清空事件的请求回复缓存
+//param :       请求事件
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) InvalidateRequestCache(event interface{}) *Trigger {
+	if cache := trigger.requestCacheOf(event); nil != cache {
+		cache.invalidate()
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 获取事件的请求回复缓存
+//param :       请求事件
+//return :      缓存, 未开启时为nil
+//***************************************************
+func (trigger *Trigger) requestCacheOf(event interface{}) *requestCache {
+	trigger.RLock()
+	defer trigger.RUnlock()
+
+	return trigger.requestCaches[event]
+}
+
+//***************************************************
+//Description : 读取未过期的回复
+//param :       请求参数的摘要
+//return :      回复的参数
+//return :      当前的失效次数, 写入时校验
+//return :      是否命中
+//***************************************************
+func (cache *requestCache) load(digest string) ([]interface{}, uint64, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry, ok := cache.entries[digest]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, cache.generation, false
+	}
+	return append([]interface{}(nil), entry.reply...), cache.generation, true
+}
+
+//***************************************************
+//Description : 写入回复, 期间已失效时不写入, 同时清理过期的回复
+//param :       请求参数的摘要
+//param :       回复的参数
+//param :       发出请求时的失效次数
+//***************************************************
+func (cache *requestCache) store(digest string, reply []interface{}, generation uint64) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if generation != cache.generation {
+		return
+	}
+	now := time.Now()
+	for key, entry := range cache.entries {
+		if now.After(entry.expires) {
+			delete(cache.entries, key)
+		}
+	}
+	cache.entries[digest] = cachedReply{reply: append([]interface{}(nil), reply...), expires: now.Add(cache.ttl)}
+}
+
+//***************************************************
+//Description : 清空缓存
+//***************************************************
+func (cache *requestCache) invalidate() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.generation++
+	cache.entries = make(map[string]cachedReply)
+}
+
+//***************************************************
+//Description : 计算请求的摘要
+//param :       请求事件
+//param :       请求的参数
+//return :      事件与参数JSON编码的SHA-256
+//return :      是否可以编码
+//***************************************************
+func requestDigest(event interface{}, arguments []interface{}) (string, bool) {
+	data, err := json.Marshal(arguments)
+	if nil != err {
+		return "", false
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%T:%v\n", event, event)
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil)), true
+}
@@ -0,0 +1,259 @@
+package trigger
+
+import (
+	"encoding/json"
+	"fmt"
+	glob "path"
+	"sync/atomic"
+)
+
+// 桥接路由的方向
+const (
+	// 本地事件转发给对方
+	RouteOut = "out"
+	// 对方的触发进入本地
+	RouteIn = "in"
+)
+
+// 桥接路由规则, 决定事件是否经过某个桥接, 以及经过时的改写
+type Route struct {
+	// 规则名称, 用于报告错误
+	Name string `json:"name"`
+	// 桥接名称, 为空表示所有桥接
+	Bridge string `json:"bridge"`
+	// 方向, RouteOut或RouteIn, 为空表示双向
+	Direction string `json:"direction"`
+	// 事件名称, 支持path.Match的通配符如order.*, 为空表示所有事件
+	Events []string `json:"events"`
+	// 条件表达式, 语法见Expression, 为空表示不限
+	Where string `json:"where"`
+	// 拒绝匹配的事件
+	Deny bool `json:"deny"`
+	// 改写后的事件名称, 为空表示不改名
+	Rename string `json:"rename"`
+	// 投影: 名称 -> 表达式, 非空时以求值结果组成的map[string]interface{}作为唯一参数
+	Select map[string]string `json:"select"`
+	// 自定义改写, 在Rename与Select之后执行, 不能从配置加载
+	Transform func(event string, arguments []interface{}) (string, []interface{}) `json:"-"`
+}
+
+// 编译后的路由规则
+type compiledRoute struct {
+	Route
+	// 条件表达式, 没有时为nil
+	where *Expression
+	// 投影表达式
+	projection map[string]*Expression
+}
+
+// 桥接路由表, 规则按顺序匹配, 第一条匹配的规则决定转发或拒绝, 没有匹配的规则时拒绝
+// 可在运行时通过SetRoutes整体替换, 正在路由的事件使用替换前的规则
+type Router struct {
+	// 编译后的规则
+	routes atomic.Pointer[[]compiledRoute]
+}
+
+//***************************************************
+//Description : 从JSON配置加载路由规则
+//param :       JSON数组
+//return :      规则数组
+//return :      解析失败的错误
+//***************************************************
+func LoadRoutes(data []byte) ([]Route, error) {
+	var routes []Route
+	if err := json.Unmarshal(data, &routes); nil != err {
+		return nil, err
+	}
+	return routes, nil
+}
+
+//***************************************************
+//Description : 创建路由表
+//param :       规则数组
+//return :      路由表
+//return :      规则无效时包装ErrInvalidRule的错误
+//***************************************************
+func NewRouter(routes []Route) (*Router, error) {
+	router := &Router{}
+	if err := router.SetRoutes(routes); nil != err {
+		return nil, err
+	}
+	return router, nil
+}
+
+//***************************************************
+//Description : 替换全部路由规则, 有规则无效时不替换
+//param :       规则数组
+//return :      包装ErrInvalidRule的错误
+//***************************************************
+func (router *Router) SetRoutes(routes []Route) error {
+	compiled := make([]compiledRoute, 0, len(routes))
+	for _, route := range routes {
+		c, err := route.compile()
+		if nil != err {
+			return err
+		}
+		compiled = append(compiled, c)
+	}
+	router.routes.Store(&compiled)
+	return nil
+}
+
+//***************************************************
+//Description : 获取当前的路由规则
+//return :      规则数组
+//***************************************************
+func (router *Router) Routes() []Route {
+	current := router.routes.Load()
+	if nil == current {
+		return nil
+	}
+	routes := make([]Route, len(*current))
+	for i, c := range *current {
+		routes[i] = c.Route
+	}
+	return routes
+}
+
+//***************************************************
+//Description : 路由事件, 由桥接在转发与接收时调用
+//              json.RawMessage类型的参数在求值条件与投影前解码
+//param :       桥接名称
+//param :       方向, RouteOut或RouteIn
+//param :       事件名称
+//param :       回调函数中的参数
+//return :      改写后的事件名称
+//return :      改写后的参数
+//return :      是否允许经过此桥接
+//***************************************************
+func (router *Router) Route(bridge, direction, event string, arguments []interface{}) (string, []interface{}, bool) {
+	current := router.routes.Load()
+	if nil == current {
+		return event, arguments, false
+	}
+
+	var decoded []interface{}
+	for i := range *current {
+		route := &(*current)[i]
+		if !route.matches(bridge, direction, event) {
+			continue
+		}
+		if nil != route.where || 0 != len(route.projection) {
+			if nil == decoded {
+				decoded = decodeRaw(arguments)
+			}
+			if nil != route.where && !route.where.Match(decoded) {
+				continue
+			}
+		}
+		if route.Deny {
+			return event, arguments, false
+		}
+		return route.rewrite(event, arguments, decoded)
+	}
+	return event, arguments, false
+}
+
+//***************************************************
+//Description : 校验规则并编译其中的表达式
+//return :      编译后的规则
+//return :      包装ErrInvalidRule的错误
+//***************************************************
+func (route Route) compile() (compiledRoute, error) {
+	c := compiledRoute{Route: route}
+	switch route.Direction {
+	case "", RouteOut, RouteIn:
+	default:
+		return c, fmt.Errorf("%w: 路由[%s]不支持的方向%q", ErrInvalidRule, route.Name, route.Direction)
+	}
+	for _, pattern := range route.Events {
+		if _, err := glob.Match(pattern, ""); nil != err {
+			return c, fmt.Errorf("%w: 路由[%s]的事件名称%q无效", ErrInvalidRule, route.Name, pattern)
+		}
+	}
+	if "" != route.Where {
+		expression, err := CompileExpression(route.Where)
+		if nil != err {
+			return c, fmt.Errorf("%w: 路由[%s]: %v", ErrInvalidRule, route.Name, err)
+		}
+		c.where = expression
+	}
+	if 0 != len(route.Select) {
+		c.projection = make(map[string]*Expression, len(route.Select))
+		for name, source := range route.Select {
+			expression, err := CompileExpression(source)
+			if nil != err {
+				return c, fmt.Errorf("%w: 路由[%s]: %v", ErrInvalidRule, route.Name, err)
+			}
+			c.projection[name] = expression
+		}
+	}
+	return c, nil
+}
+
+//***************************************************
+//Description : 桥接, 方向与事件名称是否匹配
+//param :       桥接名称
+//param :       方向
+//param :       事件名称
+//return :      是否匹配
+//***************************************************
+func (route *compiledRoute) matches(bridge, direction, event string) bool {
+	if ("" != route.Bridge && bridge != route.Bridge) || ("" != route.Direction && direction != route.Direction) {
+		return false
+	}
+	if 0 == len(route.Events) {
+		return true
+	}
+	for _, pattern := range route.Events {
+		if matched, _ := glob.Match(pattern, event); matched {
+			return true
+		}
+	}
+	return false
+}
+
+//***************************************************
+//Description : 按规则改写事件
+//param :       事件名称
+//param :       原参数
+//param :       解码后的参数, 没有投影时可以为nil
+//return :      改写后的事件名称
+//return :      改写后的参数
+//return :      总是true
+//***************************************************
+func (route *compiledRoute) rewrite(event string, arguments, decoded []interface{}) (string, []interface{}, bool) {
+	if "" != route.Rename {
+		event = route.Rename
+	}
+	if 0 != len(route.projection) {
+		projected := make(map[string]interface{}, len(route.projection))
+		for name, expression := range route.projection {
+			projected[name] = expression.Eval(decoded)
+		}
+		arguments = []interface{}{projected}
+	}
+	if nil != route.Transform {
+		event, arguments = route.Transform(event, arguments)
+	}
+	return event, arguments, true
+}
+
+//***************************************************
+//Description : 解码json.RawMessage类型的参数, 解码失败的保持原样
+//param :       参数
+//return :      解码后的参数
+//***************************************************
+func decodeRaw(arguments []interface{}) []interface{} {
+	decoded := make([]interface{}, len(arguments))
+	for i, argument := range arguments {
+		decoded[i] = argument
+		if raw, ok := argument.(json.RawMessage); ok {
+			var value interface{}
+			if nil == json.Unmarshal(raw, &value) {
+				decoded[i] = value
+			}
+		}
+	}
+	return decoded
+}
@@ -0,0 +1,428 @@
+package trigger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 计划触发的ID序号
+var scheduleSeq atomic.Uint64
+
+// 重启等原因错过周期触发时的补发策略
+type CatchUpPolicy int
+
+const (
+	// 补发一次, 单次计划触发错过时也立即触发
+	CatchUpOnce CatchUpPolicy = iota
+	// 跳过错过的触发, 单次计划触发错过时直接删除
+	CatchUpSkip
+	// 按错过的次数全部补发
+	CatchUpAll
+)
+
+// 计划触发
+type Scheduled struct {
+	// 唯一ID, 为空时自动生成
+	ID string `json:"id"`
+	// 事件类型, 需要持久化时应为可JSON序列化的值
+	Event interface{} `json:"event"`
+	// 回调函数中的参数, 从JSON文件恢复后为JSON解码的通用类型
+	Arguments []interface{} `json:"arguments"`
+	// 触发时间, 周期触发为下一次触发时间
+	At time.Time `json:"at"`
+	// 触发周期, 大于0时为周期触发
+	Every time.Duration `json:"every"`
+	// 错过触发时间时的补发策略
+	CatchUp CatchUpPolicy `json:"catch_up"`
+}
+
+// 计划触发的持久化存储, 设置后计划触发在重启后可以恢复
+type ScheduleStore interface {
+	// 保存计划触发, ID相同时覆盖
+	Save(entry Scheduled) error
+	// 删除计划触发, 不存在时不报错
+	Delete(id string) error
+	// 加载所有未执行的计划触发
+	Load() ([]Scheduled, error)
+}
+
+//***************************************************
+//Description : 设置计划触发的持久化存储, 并恢复存储中未执行的计划触发
+//              已过触发时间的计划触发立即触发
+//param :       存储
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithScheduleStore(store ScheduleStore) *Trigger {
+	trigger.Lock()
+	trigger.scheduleStore = store
+	trigger.Unlock()
+
+	entries, err := store.Load()
+	if nil != err {
+		trigger.report(nil, nil, &DispatchError{Err: fmt.Errorf("加载计划触发失败: %w", err)})
+		return trigger
+	}
+	for _, entry := range entries {
+		trigger.arm(entry, nil)
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 延迟触发事件, 设置了持久化存储时重启后仍会按时触发
+//param :       事件类型
+//param :       延迟时间
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitDelayed(event interface{}, delay time.Duration, arguments ...interface{}) *Trigger {
+	return trigger.Schedule(Scheduled{Event: event, Arguments: arguments, At: time.Now().Add(delay)})
+}
+
+//***************************************************
+//Description : 周期触发事件, 第一次在一个周期后触发
+//              需要持久化时应使用固定ID的Schedule, 以便启动时重复注册不会产生多个周期触发
+//param :       事件类型
+//param :       触发周期, 需大于0
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitEvery(event interface{}, every time.Duration, arguments ...interface{}) *Trigger {
+	if every <= 0 {
+		trigger.report(event, nil, &DispatchError{Event: event, Err: fmt.Errorf("周期触发的周期需大于0")})
+		return trigger
+	}
+	return trigger.Schedule(Scheduled{Event: event, Arguments: arguments, Every: every})
+}
+
+//***************************************************
+//Description : 添加计划触发, ID相同时替换之前的计划触发
+//              触发时间为零值时沿用同ID计划触发(如从存储恢复的)的触发时间, 没有时为一个周期后
+//              因此启动时以固定ID重复注册周期触发不会丢失停机期间错过的触发
+//param :       计划触发
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) Schedule(entry Scheduled) *Trigger {
+	if "" == entry.ID {
+		entry.ID = fmt.Sprintf("%d-%d", time.Now().UnixNano(), scheduleSeq.Add(1))
+	}
+	if entry.Every < 0 {
+		trigger.report(entry.Event, nil, &DispatchError{Event: entry.Event, Err: fmt.Errorf("计划触发[%s]的周期不能小于0", entry.ID)})
+		return trigger
+	}
+	if entry.At.IsZero() {
+		trigger.RLock()
+		existing := trigger.schedules[entry.ID]
+		trigger.RUnlock()
+		if nil != existing {
+			entry.At = existing.entry.At
+		} else {
+			entry.At = time.Now().Add(entry.Every)
+		}
+	}
+
+	trigger.RLock()
+	store := trigger.scheduleStore
+	trigger.RUnlock()
+	if nil != store {
+		if err := store.Save(entry); nil != err {
+			trigger.report(entry.Event, nil, &DispatchError{Event: entry.Event, Err: fmt.Errorf("保存计划触发失败: %w", err)})
+			return trigger
+		}
+	}
+	trigger.arm(entry, nil)
+	return trigger
+}
+
+//***************************************************
+//Description : 取消计划触发, 同时从持久化存储中删除
+//param :       计划触发ID
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) CancelScheduled(id string) *Trigger {
+	trigger.Lock()
+	timer := trigger.schedules[id]
+	delete(trigger.schedules, id)
+	store := trigger.scheduleStore
+	trigger.Unlock()
+
+	// 等待正在执行的周期触发保存下一次触发时间后再删除, 避免删除后又被保存
+	if nil != timer {
+		timer.timer.Stop()
+		timer.mu.Lock()
+		timer.mu.Unlock()
+	}
+	if nil != store {
+		if err := store.Delete(id); nil != err {
+			trigger.report(nil, nil, &DispatchError{Err: fmt.Errorf("删除计划触发失败: %w", err)})
+		}
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 获取未执行的计划触发, 按触发时间排序
+//return :      计划触发数组
+//***************************************************
+func (trigger *Trigger) ScheduledEmits() []Scheduled {
+	trigger.RLock()
+	entries := make([]Scheduled, 0, len(trigger.schedules))
+	for _, timer := range trigger.schedules {
+		entries = append(entries, timer.entry)
+	}
+	trigger.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].At.Before(entries[j].At)
+	})
+	return entries
+}
+
+// 等待执行的计划触发
+type scheduleTimer struct {
+	// 计划触发
+	entry Scheduled
+	// 定时器
+	timer *time.Timer
+	// 周期触发在持有期间确认未被取消并保存下一次触发时间, 取消时等待其释放后再删除存储
+	mu sync.Mutex
+}
+
+//***************************************************
+//Description : 启动计划触发的定时器, 已错过触发时间的按补发策略处理
+//param :       计划触发
+//param :       周期触发的上一个定时器, 不为nil时只在其未被取消或替换时启动
+//***************************************************
+func (trigger *Trigger) arm(entry Scheduled, last *scheduleTimer) {
+	// 错过的周期触发
+	missed, advanced := 0, false
+	if now := time.Now(); now.After(entry.At) {
+		if entry.Every > 0 {
+			n := int(now.Sub(entry.At)/entry.Every) + 1
+			entry.At = entry.At.Add(time.Duration(n) * entry.Every)
+			advanced = true
+			switch entry.CatchUp {
+			case CatchUpOnce:
+				missed = 1
+			case CatchUpAll:
+				missed = n
+			}
+		} else if CatchUpSkip == entry.CatchUp {
+			trigger.unschedule(entry)
+			return
+		}
+	}
+
+	trigger.Lock()
+	if trigger.closed || (nil != last && trigger.schedules[entry.ID] != last) {
+		trigger.Unlock()
+		return
+	}
+	if previous := trigger.schedules[entry.ID]; nil != previous {
+		previous.timer.Stop()
+	}
+	if nil == trigger.schedules {
+		trigger.schedules = make(map[string]*scheduleTimer)
+	}
+	timer := &scheduleTimer{entry: entry}
+	// 保存前移后的触发时间时同样不能被取消打断
+	if advanced {
+		timer.mu.Lock()
+		defer timer.mu.Unlock()
+	}
+	timer.timer = time.AfterFunc(time.Until(entry.At), func() {
+		trigger.fire(timer)
+	})
+	trigger.schedules[entry.ID] = timer
+	store := trigger.scheduleStore
+	trigger.Unlock()
+
+	if !advanced {
+		return
+	}
+	// 先保存下一次触发时间再补发, 补发中途退出时不会重复补发
+	if nil != store {
+		if err := store.Save(entry); nil != err {
+			trigger.report(entry.Event, nil, &DispatchError{Event: entry.Event, Err: fmt.Errorf("保存计划触发失败: %w", err)})
+		}
+	}
+	trigger.background.Add(1)
+	go func() {
+		defer trigger.background.Add(-1)
+		for i := 0; i < missed; i++ {
+			trigger.Emit(entry.Event, entry.Arguments...)
+		}
+	}()
+}
+
+//***************************************************
+//Description : 从持久化存储中删除计划触发
+//param :       计划触发
+//***************************************************
+func (trigger *Trigger) unschedule(entry Scheduled) {
+	trigger.RLock()
+	store := trigger.scheduleStore
+	trigger.RUnlock()
+
+	if nil != store {
+		if err := store.Delete(entry.ID); nil != err {
+			trigger.report(entry.Event, nil, &DispatchError{Event: entry.Event, Err: fmt.Errorf("删除计划触发失败: %w", err)})
+		}
+	}
+}
+
+//***************************************************
+//Description : 执行计划触发
+//param :       等待执行的计划触发
+//***************************************************
+func (trigger *Trigger) fire(timer *scheduleTimer) {
+	entry := timer.entry
+	trigger.Lock()
+	// 已被取消或替换
+	if trigger.schedules[entry.ID] != timer {
+		trigger.Unlock()
+		return
+	}
+	// 周期触发在触发后再确认是否被取消
+	if 0 == entry.Every {
+		delete(trigger.schedules, entry.ID)
+	}
+	store := trigger.scheduleStore
+	trigger.Unlock()
+
+	trigger.Emit(entry.Event, entry.Arguments...)
+	if 0 == entry.Every {
+		trigger.unschedule(entry)
+		return
+	}
+
+	// 确认与保存在timer.mu内完成, 之后取消的CancelScheduled等待保存结束再删除存储
+	timer.mu.Lock()
+	defer timer.mu.Unlock()
+	trigger.RLock()
+	current := trigger.schedules[entry.ID]
+	trigger.RUnlock()
+	if current != timer {
+		return
+	}
+	entry.At = entry.At.Add(entry.Every)
+	if nil != store {
+		if err := store.Save(entry); nil != err {
+			trigger.report(entry.Event, nil, &DispatchError{Event: entry.Event, Err: fmt.Errorf("保存计划触发失败: %w", err)})
+		}
+	}
+	trigger.arm(entry, timer)
+}
+
+//***************************************************
+//Description : 停止所有计划触发的定时器, 持久化存储中的计划触发保留, 用于关闭触发器
+//***************************************************
+func (trigger *Trigger) stopSchedules() {
+	trigger.Lock()
+	schedules := trigger.schedules
+	trigger.schedules = nil
+	trigger.Unlock()
+
+	for _, timer := range schedules {
+		timer.timer.Stop()
+	}
+}
+
+// 以JSON文件保存的计划触发存储
+type fileScheduleStore struct {
+	// 保护文件读写
+	mu sync.Mutex
+	// 文件路径
+	path string
+}
+
+//***************************************************
+//Description : 创建以JSON文件保存的计划触发存储, 每次修改都会重写整个文件
+//param :       文件路径, 不存在时自动创建
+//return :      存储
+//***************************************************
+func NewFileScheduleStore(path string) ScheduleStore {
+	return &fileScheduleStore{path: path}
+}
+
+// 保存计划触发
+func (store *fileScheduleStore) Save(entry Scheduled) error {
+	return store.update(func(entries map[string]Scheduled) {
+		entries[entry.ID] = entry
+	})
+}
+
+// 删除计划触发
+func (store *fileScheduleStore) Delete(id string) error {
+	return store.update(func(entries map[string]Scheduled) {
+		delete(entries, id)
+	})
+}
+
+// 加载所有计划触发
+func (store *fileScheduleStore) Load() ([]Scheduled, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	entries, err := store.read()
+	if nil != err {
+		return nil, err
+	}
+	list := make([]Scheduled, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	return list, nil
+}
+
+//***************************************************
+//Description : 读取, 修改并写回文件
+//param :       修改函数
+//return :      读写失败的错误
+//***************************************************
+func (store *fileScheduleStore) update(modify func(entries map[string]Scheduled)) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	entries, err := store.read()
+	if nil != err {
+		return err
+	}
+	modify(entries)
+	data, err := json.Marshal(entries)
+	if nil != err {
+		return err
+	}
+	// 先写临时文件再改名, 避免写入中途退出损坏文件
+	temp := store.path + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); nil != err {
+		return err
+	}
+	return os.Rename(temp, store.path)
+}
+
+//***************************************************
+//Description : 读取文件
+//return :      ID -> 计划触发
+//return :      读取失败的错误
+//***************************************************
+func (store *fileScheduleStore) read() (map[string]Scheduled, error) {
+	entries := make(map[string]Scheduled)
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &entries); nil != err {
+		return nil, err
+	}
+	return entries, nil
+}
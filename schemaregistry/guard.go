@@ -0,0 +1,150 @@
+package schemaregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 访问注册中心的默认超时时间
+const defaultTimeout = 5 * time.Second
+
+// 触发时的模式校验, 作为触发权限策略安装到触发器上
+// 开启AutoRegister时注册参数的模式, 由注册中心按兼容性级别拒绝不兼容的版本, 否则只校验与最新版本是否兼容
+type Guard struct {
+	// 注册中心客户端
+	Client *Client
+	// 是否自动注册新的模式
+	AutoRegister bool
+	// 事件对应的主题, 为nil时为"事件名称-value", 返回空字符串表示不校验此事件
+	// 触发器自身的元事件也经过触发权限策略, 主题没有模式时会被拒绝, 只校验部分事件时应跳过其他事件
+	Subject func(event interface{}) string
+	// 注册中心不可用时是否放行, 默认拒绝
+	FailOpen bool
+	// 每次请求的超时时间, 为0时为5秒
+	Timeout time.Duration
+
+	// 主题与模式 -> 校验结果, 请求失败的结果不缓存
+	verdicts sync.Map
+}
+
+//***************************************************
+//Description : 安装到触发器, 替换已设置的触发权限策略, 需要同时生效时以Policy组合后设置
+//param :       触发器
+//return :      Guard
+//***************************************************
+func (g *Guard) Attach(t *trigger.Trigger) *Guard {
+	t.WithEmitPolicy(g.Policy(nil))
+	return g
+}
+
+//***************************************************
+//Description : 获取触发权限策略, 拒绝时触发器以*trigger.AuthorizationError报告, 可用errors.Is判断ErrIncompatible
+//param :       先校验的策略, 可以为nil
+//return :      触发权限策略
+//***************************************************
+func (g *Guard) Policy(next trigger.EmitPolicy) trigger.EmitPolicy {
+	return func(source, event interface{}, arguments []interface{}) error {
+		if nil != next {
+			if err := next(source, event, arguments); nil != err {
+				return err
+			}
+		}
+		return g.Check(event, arguments)
+	}
+}
+
+//***************************************************
+//Description : 校验一次触发的参数, 同一主题与参数类型只请求一次注册中心
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      不兼容时包装ErrIncompatible, 主题没有模式时包装ErrSubjectNotFound
+//***************************************************
+func (g *Guard) Check(event interface{}, arguments []interface{}) error {
+	subject := g.subjectOf(event)
+	if "" == subject {
+		return nil
+	}
+	schema := argumentsSchema(arguments)
+	key := subject + "\x00" + schema
+	if verdict, ok := g.verdicts.Load(key); ok {
+		if nil == verdict {
+			return nil
+		}
+		return verdict.(error)
+	}
+
+	verdict, err := g.verify(subject, schema)
+	if nil != err {
+		if g.FailOpen {
+			return nil
+		}
+		return fmt.Errorf("主题[%s]校验模式失败: %w", subject, err)
+	}
+	g.verdicts.Store(key, verdict)
+	return verdict
+}
+
+//***************************************************
+//Description : 请求注册中心校验模式
+//param :       主题
+//param :       模式
+//return :      校验结果, 通过时为nil
+//return :      请求失败的错误
+//***************************************************
+func (g *Guard) verify(subject, schema string) (verdict, err error) {
+	timeout := g.Timeout
+	if 0 == timeout {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if g.AutoRegister {
+		_, err = g.Client.Register(ctx, subject, schema)
+	} else {
+		var compatible bool
+		if compatible, err = g.Client.Compatible(ctx, subject, schema); nil == err && !compatible {
+			err = &Error{Status: http.StatusConflict, Code: http.StatusConflict, Message: "模式与最新版本不兼容"}
+		}
+	}
+	if errors.Is(err, ErrIncompatible) || errors.Is(err, ErrSubjectNotFound) {
+		return fmt.Errorf("主题[%s]: %w", subject, err), nil
+	}
+	return nil, err
+}
+
+//***************************************************
+//Description : 获取事件对应的主题
+//param :       事件类型
+//return :      主题, 空字符串表示不校验
+//***************************************************
+func (g *Guard) subjectOf(event interface{}) string {
+	if nil != g.Subject {
+		return g.Subject(event)
+	}
+	return fmt.Sprint(event) + "-value"
+}
+
+//***************************************************
+//Description : 生成参数列表的模式, 一个参数时为该参数的模式, 否则为依次对应的数组
+//param :       回调函数中的参数
+//return :      模式
+//***************************************************
+func argumentsSchema(arguments []interface{}) string {
+	if 1 == len(arguments) {
+		return SchemaOf(reflect.TypeOf(arguments[0]))
+	}
+	items := make([]string, len(arguments))
+	for i, argument := range arguments {
+		items[i] = SchemaOf(reflect.TypeOf(argument))
+	}
+	return `{"items":[` + strings.Join(items, ",") + `],"type":"array"}`
+}
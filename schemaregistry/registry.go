@@ -0,0 +1,187 @@
+// schemaregistry 对接Confluent兼容的模式注册中心, 在触发时按参数的Go类型生成JSON Schema并注册或校验兼容性
+// 不兼容的负载在触发时被拒绝, 不会进入下游的消息系统, 每个主题与模式的组合只请求一次注册中心
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// 注册中心的内容类型
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+// 单个响应体的大小上限
+const maxBodySize = 1 << 20
+
+// 注册中心的错误码
+const (
+	// 主题不存在
+	codeSubjectNotFound = 40401
+	// 版本不存在
+	codeVersionNotFound = 40402
+)
+
+var (
+	// 负载的模式与主题已注册的模式不兼容
+	ErrIncompatible = errors.New("负载与注册的模式不兼容")
+	// 主题没有注册的模式
+	ErrSubjectNotFound = errors.New("主题没有注册的模式")
+)
+
+// 注册中心返回的错误
+type Error struct {
+	// HTTP状态码
+	Status int `json:"-"`
+	// 注册中心的错误码
+	Code int `json:"error_code"`
+	// 错误信息
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("模式注册中心错误[%d/%d]: %s", e.Status, e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	switch {
+	case http.StatusConflict == e.Status:
+		return ErrIncompatible
+	case codeSubjectNotFound == e.Code || codeVersionNotFound == e.Code:
+		return ErrSubjectNotFound
+	}
+	return nil
+}
+
+// 已注册的模式
+type Schema struct {
+	// 主题
+	Subject string `json:"subject"`
+	// 全局唯一的模式ID
+	ID int `json:"id"`
+	// 主题内的版本
+	Version int `json:"version"`
+	// 模式内容
+	Schema string `json:"schema"`
+	// 模式类型, JSON Schema为JSON, 为空时表示AVRO
+	SchemaType string `json:"schemaType"`
+}
+
+// 模式注册中心客户端
+type Client struct {
+	// 注册中心地址, 如http://localhost:8081
+	URL string
+	// HTTP客户端, 为nil时使用http.DefaultClient
+	HTTP *http.Client
+	// Basic认证的用户名与密码, 为空时不认证
+	Username string
+	Password string
+}
+
+//***************************************************
+//Description : 在主题下注册JSON Schema, 已存在相同模式时返回其ID
+//param :       上下文
+//param :       主题
+//param :       模式内容
+//return :      模式ID
+//return :      请求失败的错误, 与已有版本不兼容时包装ErrIncompatible
+//***************************************************
+func (c *Client) Register(ctx context.Context, subject, schema string) (int, error) {
+	var out struct {
+		ID int `json:"id"`
+	}
+	body := map[string]string{"schema": schema, "schemaType": "JSON"}
+	if err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", body, &out); nil != err {
+		return 0, err
+	}
+	return out.ID, nil
+}
+
+//***************************************************
+//Description : 获取主题最新版本的模式
+//param :       上下文
+//param :       主题
+//return :      模式
+//return :      请求失败的错误, 主题不存在时包装ErrSubjectNotFound
+//***************************************************
+func (c *Client) Latest(ctx context.Context, subject string) (Schema, error) {
+	var out Schema
+	err := c.do(ctx, http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/latest", nil, &out)
+	return out, err
+}
+
+//***************************************************
+//Description : 校验模式与主题最新版本是否兼容, 按主题配置的兼容性级别由注册中心判断
+//param :       上下文
+//param :       主题
+//param :       模式内容
+//return :      是否兼容
+//return :      请求失败的错误, 主题不存在时包装ErrSubjectNotFound
+//***************************************************
+func (c *Client) Compatible(ctx context.Context, subject, schema string) (bool, error) {
+	var out struct {
+		Compatible bool `json:"is_compatible"`
+	}
+	body := map[string]string{"schema": schema, "schemaType": "JSON"}
+	if err := c.do(ctx, http.MethodPost, "/compatibility/subjects/"+url.PathEscape(subject)+"/versions/latest", body, &out); nil != err {
+		return false, err
+	}
+	return out.Compatible, nil
+}
+
+//***************************************************
+//Description : 发送请求并解码响应
+//param :       上下文
+//param :       HTTP方法
+//param :       路径
+//param :       请求体, nil表示没有
+//param :       响应的解码目标
+//return :      请求失败或注册中心返回错误时的错误, 后者为*Error
+//***************************************************
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if nil != body {
+		encoded, err := json.Marshal(body)
+		if nil != err {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, reader)
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Accept", contentType)
+	if nil != body {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if "" != c.Username {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	client := c.HTTP
+	if nil == client {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if nil != err {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		failure := &Error{Status: resp.StatusCode, Message: resp.Status}
+		json.Unmarshal(data, failure)
+		return failure
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,149 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/yann1989/trigger"
+)
+
+type order struct {
+	ID    int    `json:"id"`
+	Note  string `json:"note,omitempty"`
+	Items []item `json:"items"`
+}
+
+type item struct {
+	SKU string
+}
+
+// 测试用的注册中心, 只有与最新版本相同的模式才兼容
+type fakeRegistry struct {
+	mu       sync.Mutex
+	subjects map[string][]string
+	requests int32
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	atomic.AddInt32(&f.requests, 1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body struct {
+		Schema string `json:"schema"`
+	}
+	json.NewDecoder(req.Body).Decode(&body)
+	w.Header().Set("Content-Type", contentType)
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/compatibility/subjects/"):
+		subject := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/compatibility/subjects/"), "/versions/latest")
+		versions := f.subjects[subject]
+		if 0 == len(versions) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40401,"message":"Subject not found"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]bool{"is_compatible": versions[len(versions)-1] == body.Schema})
+	case strings.HasSuffix(req.URL.Path, "/versions") && http.MethodPost == req.Method:
+		subject := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/subjects/"), "/versions")
+		versions := f.subjects[subject]
+		if 0 != len(versions) && versions[len(versions)-1] != body.Schema {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error_code":409,"message":"Schema being registered is incompatible"}`))
+			return
+		}
+		if 0 == len(versions) {
+			f.subjects[subject] = append(versions, body.Schema)
+		}
+		json.NewEncoder(w).Encode(map[string]int{"id": len(f.subjects[subject])})
+	case strings.HasSuffix(req.URL.Path, "/versions/latest"):
+		subject := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/subjects/"), "/versions/latest")
+		versions := f.subjects[subject]
+		if 0 == len(versions) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40401,"message":"Subject not found"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(Schema{Subject: subject, ID: 1, Version: len(versions), Schema: versions[len(versions)-1], SchemaType: "JSON"})
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	t.Log("测试按json标签生成模式")
+	var schema struct {
+		Type       string                            `json:"type"`
+		Properties map[string]map[string]interface{} `json:"properties"`
+		Required   []string                          `json:"required"`
+	}
+	if err := json.Unmarshal([]byte(SchemaOf(order{})), &schema); nil != err {
+		t.Fatalf("模式不是有效的JSON: %v", err)
+	}
+	if "object" != schema.Type || "integer" != schema.Properties["id"]["type"] || "array" != schema.Properties["items"]["type"] {
+		t.Fatalf("模式错误: %+v", schema)
+	}
+	if 2 != len(schema.Required) || "id" != schema.Required[0] || "items" != schema.Required[1] {
+		t.Fatalf("必需字段错误: %v", schema.Required)
+	}
+	if SchemaOf(order{}) != SchemaOf(&order{ID: 1}) {
+		t.Fatalf("相同类型的模式应相同")
+	}
+}
+
+func TestGuard(t *testing.T) {
+	registry := &fakeRegistry{subjects: map[string][]string{}}
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	client := &Client{URL: server.URL}
+
+	var (
+		calls    int32
+		reported []error
+	)
+	local := trigger.NewTrigger().
+		RecoverWith(func(event, listener interface{}, err error) { reported = append(reported, err) }).
+		On("order.created", func(arguments ...interface{}) { atomic.AddInt32(&calls, 1) })
+	(&Guard{Client: client, AutoRegister: true}).Attach(local)
+
+	t.Log("测试自动注册模式且只请求一次")
+	local.EmitSync("order.created", order{ID: 1}).EmitSync("order.created", order{ID: 2})
+	if 2 != atomic.LoadInt32(&calls) || 1 != atomic.LoadInt32(&registry.requests) || 1 != len(registry.subjects["order.created-value"]) {
+		t.Fatalf("注册错误: %d %d", calls, registry.requests)
+	}
+	if latest, err := client.Latest(context.Background(), "order.created-value"); nil != err || SchemaOf(order{}) != latest.Schema {
+		t.Fatalf("获取最新模式失败: %+v %v", latest, err)
+	}
+
+	t.Log("测试不兼容的负载在触发时被拒绝")
+	local.EmitSync("order.created", "1")
+	var authorization *trigger.AuthorizationError
+	if 2 != atomic.LoadInt32(&calls) || 1 != len(reported) || !errors.As(reported[0], &authorization) || !errors.Is(reported[0], ErrIncompatible) {
+		t.Fatalf("不兼容的负载未拒绝: %v", reported)
+	}
+
+	t.Log("测试只校验兼容性时主题不存在")
+	err := (&Guard{Client: client}).Check("order.paid", []interface{}{order{}})
+	if !errors.Is(err, ErrSubjectNotFound) {
+		t.Fatalf("主题不存在时错误: %v", err)
+	}
+	if err := (&Guard{Client: client}).Check("order.created", []interface{}{order{}}); nil != err {
+		t.Fatalf("兼容的负载被拒绝: %v", err)
+	}
+
+	t.Log("测试注册中心不可用")
+	down := &Client{URL: "http://127.0.0.1:1"}
+	if err := (&Guard{Client: down}).Check("order.created", []interface{}{1}); nil == err {
+		t.Fatalf("注册中心不可用时应拒绝")
+	}
+	if err := (&Guard{Client: down, FailOpen: true}).Check("order.created", []interface{}{1}); nil != err {
+		t.Fatalf("FailOpen时应放行: %v", err)
+	}
+}
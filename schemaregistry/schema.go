@@ -0,0 +1,123 @@
+package schemaregistry
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+	marshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+//***************************************************
+//Description : 按Go类型生成JSON Schema, 字段名与可选性遵循encoding/json的标签
+//              自定义MarshalJSON的类型与接口不限制类型, 递归类型的重复出现处不限制类型
+//param :       值或reflect.Type
+//return :      JSON Schema, 键有序, 相同类型的结果相同
+//***************************************************
+func SchemaOf(value interface{}) string {
+	t, ok := value.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(value)
+	}
+	schema := schemaOf(t, map[reflect.Type]bool{})
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	encoded, _ := json.Marshal(schema)
+	return string(encoded)
+}
+
+//***************************************************
+//Description : 生成类型的模式
+//param :       类型, nil表示参数为nil
+//param :       正在生成的结构体类型, 避免递归类型死循环
+//return :      模式
+//***************************************************
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	if nil == t {
+		return map[string]interface{}{"type": "null"}
+	}
+	if timeType == t {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if rawMessageType == t || t.Implements(marshalerType) {
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), visiting)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		// []byte编码为base64字符串
+		if reflect.Uint8 == t.Elem().Kind() && reflect.Slice == t.Kind() {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]interface{}{}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		return structSchema(t, visiting)
+	}
+	return map[string]interface{}{}
+}
+
+//***************************************************
+//Description : 生成结构体的模式, 未标记omitempty的导出字段为必需
+//param :       结构体类型
+//param :       正在生成的结构体类型
+//return :      模式
+//***************************************************
+func structSchema(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if "-" == name && "" == options {
+			continue
+		}
+		// 没有命名的嵌入结构体按encoding/json的规则展开到外层
+		embedded := field.Type
+		if reflect.Ptr == embedded.Kind() {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && "" == name && reflect.Struct == embedded.Kind() && !visiting[embedded] {
+			visiting[embedded] = true
+			inner := structSchema(embedded, visiting)
+			delete(visiting, embedded)
+			for key, value := range inner["properties"].(map[string]interface{}) {
+				properties[key] = value
+			}
+			required = append(required, inner["required"].([]string)...)
+			continue
+		}
+		if "" == name {
+			name = field.Name
+		}
+		properties[name] = schemaOf(field.Type, visiting)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{"type": "object", "properties": properties, "required": required}
+}
@@ -0,0 +1,95 @@
+package trigger
+
+import (
+	"sync"
+)
+
+// 串行执行队列, 按取号顺序依次执行同一监听的调用
+type serialQueue struct {
+	// 保护以下字段
+	mu sync.Mutex
+	// 等待轮到自己的调用
+	cond *sync.Cond
+	// 下一个号码
+	next uint64
+	// 正在执行的号码
+	serving uint64
+}
+
+//***************************************************
+//Description : 创建串行执行队列
+//return :      串行执行队列
+//***************************************************
+func newSerialQueue() *serialQueue {
+	q := new(serialQueue)
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+//***************************************************
+//Description : 取号, 在触发方协程中调用以保证按触发顺序执行
+//return :      号码
+//***************************************************
+func (q *serialQueue) take() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ticket := q.next
+	q.next++
+	return ticket
+}
+
+//***************************************************
+//Description : 等待轮到此号码
+//param :       号码
+//***************************************************
+func (q *serialQueue) wait(ticket uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for ticket != q.serving {
+		q.cond.Wait()
+	}
+}
+
+//***************************************************
+//Description : 执行结束, 轮到下一个号码
+//***************************************************
+func (q *serialQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.serving++
+	q.cond.Broadcast()
+}
+
+//***************************************************
+//Description : 添加串行执行的监听, 同一监听的调用按触发顺序依次执行, 不会并发, 不同监听之间仍然并行
+//              监听内部无需加锁, 但在监听中同步触发自身事件会因等待自己而死锁
+//param :       事件名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddSerialListener(event, listener interface{}) *Trigger {
+	return trigger.register(event, listener, &handler{serial: newSerialQueue()})
+}
+
+//***************************************************
+//Description : 调用的AddSerialListener
+//param :       事件名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnSerial(event, listener interface{}) *Trigger {
+	return trigger.AddSerialListener(event, listener)
+}
+
+//***************************************************
+//Description : 开启或关闭串行模式, 开启后注册的所有监听都按AddSerialListener的方式执行, 已注册的监听不受影响
+//param :       是否开启
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithSerialListeners(enabled bool) *Trigger {
+	trigger.serialListeners.Store(enabled)
+	return trigger
+}
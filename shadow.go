@@ -0,0 +1,215 @@
+package trigger
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// 最多保留的影子监听报告数量
+const maxShadowReports = 256
+
+// 监听的执行结果
+type outcome struct {
+	// 返回值
+	results []interface{}
+	// 失败的错误
+	err error
+}
+
+//***************************************************
+//Description : 由返回值反射构造执行结果
+//param :       返回值反射
+//param :       失败的错误
+//return :      执行结果
+//***************************************************
+func newOutcome(results []reflect.Value, err error) outcome {
+	o := outcome{err: err}
+	for _, result := range results {
+		o.results = append(o.results, result.Interface())
+	}
+	return o
+}
+
+// 影子监听报告
+type ShadowReport struct {
+	// 事件类型
+	Event interface{}
+	// 影子监听回调函数
+	Shadow interface{}
+	// 对比的主监听名称, 为空表示不对比
+	PrimaryKey string
+	// 主监听的返回值
+	PrimaryResults []interface{}
+	// 主监听失败的错误
+	PrimaryError error
+	// 影子监听的返回值
+	ShadowResults []interface{}
+	// 影子监听失败的错误
+	ShadowError error
+	// 影子监听耗时
+	Latency time.Duration
+	// 是否与主监听进行了对比, 本次触发主监听未执行时为false
+	Compared bool
+	// 对比结果是否一致
+	Match bool
+	// 执行时间
+	Time time.Time
+}
+
+//***************************************************
+//Description : 添加影子监听, 用于在生产流量下验证新监听
+//              影子监听在其他监听执行完后在单独的协程中执行, panic和错误只记录不报告
+//              不影响触发结果, 见ShadowReports
+//param :       事件名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddShadowListener(event, listener interface{}) *Trigger {
+	return trigger.register(event, listener, &handler{shadow: true})
+}
+
+//***************************************************
+//Description : 调用的AddShadowListener
+//param :       事件名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnShadow(event, listener interface{}) *Trigger {
+	return trigger.AddShadowListener(event, listener)
+}
+
+//***************************************************
+//Description : 添加影子监听, 并与指定名称的主监听对比返回值和错误
+//param :       事件名称
+//param :       主监听名称, 见OnNamed
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddShadowListenerOf(event interface{}, primaryKey string, listener interface{}) *Trigger {
+	return trigger.register(event, listener, &handler{shadow: true, shadowOf: primaryKey})
+}
+
+//***************************************************
+//Description : 调用的AddShadowListenerOf
+//param :       事件名称
+//param :       主监听名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnShadowOf(event interface{}, primaryKey string, listener interface{}) *Trigger {
+	return trigger.AddShadowListenerOf(event, primaryKey, listener)
+}
+
+//***************************************************
+//Description : 获取最近的影子监听报告
+//return :      报告数组, 按执行顺序
+//***************************************************
+func (trigger *Trigger) ShadowReports() []ShadowReport {
+	trigger.shadowMu.Lock()
+	defer trigger.shadowMu.Unlock()
+
+	return append([]ShadowReport(nil), trigger.shadowReports...)
+}
+
+//***************************************************
+//Description : 拆分出影子监听, 没有影子监听时返回原数组
+//param :       监听者数组
+//return :      普通监听者数组
+//return :      影子监听者数组
+//***************************************************
+func splitShadows(handlers []*handler) ([]*handler, []*handler) {
+	found := false
+	for _, h := range handlers {
+		if h.shadow {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return handlers, nil
+	}
+
+	var primaries, shadows []*handler
+	for _, h := range handlers {
+		if h.shadow {
+			shadows = append(shadows, h)
+		} else {
+			primaries = append(primaries, h)
+		}
+	}
+	return primaries, shadows
+}
+
+//***************************************************
+//Description : 执行影子监听并记录报告
+//param :       事件类型
+//param :       影子监听者数组
+//param :       回调函数中的参数
+//param :       主监听的执行结果
+//***************************************************
+func (trigger *Trigger) runShadows(event interface{}, shadows []*handler, arguments []interface{}, outcomes map[string]outcome) {
+	defer trigger.background.Add(-1)
+	for _, h := range shadows {
+		start := time.Now()
+		result, ok := trigger.callShadow(h, arguments)
+		if !ok {
+			continue
+		}
+
+		report := ShadowReport{
+			Event:         event,
+			Shadow:        h.source,
+			PrimaryKey:    h.shadowOf,
+			ShadowResults: result.results,
+			ShadowError:   result.err,
+			Latency:       time.Since(start),
+			Time:          start,
+		}
+		if primary, found := outcomes[h.shadowOf]; found && "" != h.shadowOf {
+			report.PrimaryResults = primary.results
+			report.PrimaryError = primary.err
+			report.Compared = true
+			report.Match = (nil == primary.err) == (nil == result.err) && reflect.DeepEqual(primary.results, result.results)
+		}
+
+		trigger.shadowMu.Lock()
+		trigger.shadowReports = append(trigger.shadowReports, report)
+		if len(trigger.shadowReports) > maxShadowReports {
+			trigger.shadowReports = trigger.shadowReports[len(trigger.shadowReports)-maxShadowReports:]
+		}
+		trigger.shadowMu.Unlock()
+	}
+}
+
+//***************************************************
+//Description : 调用影子监听, 拦截所有panic, 不经过recoverer与panic策略
+//param :       影子监听者
+//param :       回调函数中的参数
+//return :      执行结果
+//return :      监听已被移除时返回false
+//***************************************************
+func (trigger *Trigger) callShadow(h *handler, arguments []interface{}) (result outcome, ok bool) {
+	if !h.gate.acquire() {
+		return outcome{}, false
+	}
+	defer h.gate.release()
+
+	start := time.Now()
+	defer func() {
+		if r := recover(); nil != r {
+			result, ok = outcome{err: fmt.Errorf("%v", r)}, true
+		}
+		h.stat.record(time.Since(start), result.err)
+	}()
+
+	// 接收*Bag的影子监听使用独立的Bag, 不影响主监听
+	if acceptsBag(h.fn.Type()) {
+		arguments = append([]interface{}{NewBag()}, arguments...)
+	}
+	values, err := bindArguments(nil, h.fn.Type(), arguments, trigger.coercion.Load())
+	if nil != err {
+		return outcome{err: err}, true
+	}
+	return newOutcome(h.fn.Call(values), nil), true
+}
@@ -0,0 +1,200 @@
+package trigger
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 事件等级, 决定过载时是否丢弃
+type EventClass int
+
+const (
+	// 关键事件, 过载时也不丢弃, 未设置等级的事件默认为关键事件
+	ClassCritical EventClass = iota
+	// 尽力而为的事件, 过载时丢弃
+	ClassBestEffort
+)
+
+const (
+	// 堆内存的采样间隔, 读取内存统计代价较高
+	heapSampleInterval = 100 * time.Millisecond
+	// 平均分发耗时超过此时长没有更新时视为已恢复
+	latencyStaleAfter = time.Second
+)
+
+// 过载丢弃策略, 任一条件超出时丢弃尽力而为的事件, 零值表示不检查此条件
+type SheddingPolicy struct {
+	// 正在执行的触发数量上限
+	MaxInFlight int64
+	// 堆内存字节数上限
+	MaxHeapBytes uint64
+	// 平均分发耗时上限
+	MaxLatency time.Duration
+}
+
+// 过载丢弃统计
+type SheddingStats struct {
+	// 累计丢弃次数
+	Shed uint64 `json:"shed"`
+	// 各事件的丢弃次数
+	ByEvent map[interface{}]uint64 `json:"-"`
+}
+
+// 事件类型 -> 等级, 写入时复制, 触发时无需加锁即可读取
+type classTable map[interface{}]EventClass
+
+// 过载丢弃的运行状态
+type shedder struct {
+	// 策略
+	policy SheddingPolicy
+	// 平均分发耗时, 纳秒
+	latency atomic.Int64
+	// 平均分发耗时的更新时间, Unix纳秒
+	latencyAt atomic.Int64
+	// 最近采样的堆内存字节数
+	heap atomic.Uint64
+	// 堆内存的采样时间, Unix纳秒
+	heapAt atomic.Int64
+	// 累计丢弃次数
+	shed atomic.Uint64
+	// 保护byEvent
+	mu sync.Mutex
+	// 各事件的丢弃次数
+	byEvent map[interface{}]uint64
+}
+
+//***************************************************
+//Description : 设置事件等级
+//param :       事件类型
+//param :       等级
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) SetEventClass(event interface{}, class EventClass) *Trigger {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	next := make(classTable)
+	if current := trigger.classes.Load(); nil != current {
+		for key, value := range *current {
+			next[key] = value
+		}
+	}
+	if ClassCritical == class {
+		delete(next, event)
+	} else {
+		next[event] = class
+	}
+	trigger.classes.Store(&next)
+	return trigger
+}
+
+//***************************************************
+//Description : 开启过载丢弃, 正在执行的触发过多、堆内存或平均分发耗时超出时丢弃尽力而为的事件
+//              丢弃的触发不执行任何监听也不报告错误, 只计入SheddingStats, 避免过载时产生更多负载
+//param :       策略, 零值表示关闭
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) WithLoadShedding(policy SheddingPolicy) *Trigger {
+	if (SheddingPolicy{}) == policy {
+		trigger.shedding.Store(nil)
+		return trigger
+	}
+	trigger.shedding.Store(&shedder{policy: policy, byEvent: make(map[interface{}]uint64)})
+	return trigger
+}
+
+//***************************************************
+//Description : 获取过载丢弃统计
+//return :      统计, 未开启时为零值
+//***************************************************
+func (trigger *Trigger) SheddingStats() SheddingStats {
+	s := trigger.shedding.Load()
+	if nil == s {
+		return SheddingStats{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := SheddingStats{Shed: s.shed.Load(), ByEvent: make(map[interface{}]uint64, len(s.byEvent))}
+	for event, count := range s.byEvent {
+		stats.ByEvent[event] = count
+	}
+	return stats
+}
+
+//***************************************************
+//Description : 判断是否丢弃本次触发, 丢弃时计数
+//param :       过载丢弃的运行状态
+//param :       事件类型
+//return :      是否丢弃
+//***************************************************
+func (trigger *Trigger) shouldShed(s *shedder, event interface{}) bool {
+	classes := trigger.classes.Load()
+	if nil == classes || ClassBestEffort != (*classes)[event] {
+		return false
+	}
+	if !s.overloaded(trigger) {
+		return false
+	}
+
+	s.shed.Add(1)
+	s.mu.Lock()
+	s.byEvent[event]++
+	s.mu.Unlock()
+	return true
+}
+
+//***************************************************
+//Description : 是否过载
+//param :       事件触发器
+//return :      是否过载
+//***************************************************
+func (s *shedder) overloaded(trigger *Trigger) bool {
+	policy := s.policy
+	if policy.MaxInFlight > 0 && trigger.inFlight.Load() > policy.MaxInFlight {
+		return true
+	}
+	now := time.Now().UnixNano()
+	if policy.MaxLatency > 0 && now-s.latencyAt.Load() < int64(latencyStaleAfter) && s.latency.Load() > int64(policy.MaxLatency) {
+		return true
+	}
+	if policy.MaxHeapBytes > 0 {
+		// 采样过期时由一个协程重新读取
+		if at := s.heapAt.Load(); now-at >= int64(heapSampleInterval) && s.heapAt.CompareAndSwap(at, now) {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			s.heap.Store(stats.HeapAlloc)
+		}
+		if s.heap.Load() > policy.MaxHeapBytes {
+			return true
+		}
+	}
+	return false
+}
+
+//***************************************************
+//Description : 记录分发耗时, 按指数移动平均计算平均分发耗时
+//param :       开始分发的时间
+//***************************************************
+func (s *shedder) observe(start time.Time) {
+	if s.policy.MaxLatency <= 0 {
+		return
+	}
+	latency := time.Since(start)
+	now := time.Now().UnixNano()
+	for {
+		old := s.latency.Load()
+		next := int64(latency)
+		// 长时间没有更新时重新开始计算
+		if now-s.latencyAt.Load() < int64(latencyStaleAfter) {
+			next = old + (int64(latency)-old)/8
+		}
+		if s.latency.CompareAndSwap(old, next) {
+			break
+		}
+	}
+	s.latencyAt.Store(now)
+}
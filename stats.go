@@ -0,0 +1,174 @@
+package trigger
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// 触发器运行统计
+type Stats struct {
+	// 统计时间
+	Time time.Time `json:"time"`
+	// 有监听的事件数量
+	Events int `json:"events"`
+	// 监听总数
+	Listeners int `json:"listeners"`
+	// 累计触发次数
+	Emitted uint64 `json:"emitted"`
+	// 正在执行的触发数量
+	InFlight int64 `json:"in_flight"`
+	// 等待执行的调用数量
+	QueueDepth int `json:"queue_depth"`
+	// 是否处于降级模式
+	Degraded bool `json:"degraded"`
+	// 是否已关闭
+	Closed bool `json:"closed"`
+}
+
+//***************************************************
+//Description : 获取触发器当前运行统计
+//return :      统计信息
+//***************************************************
+func (trigger *Trigger) Stats() Stats {
+	trigger.RLock()
+	defer trigger.RUnlock()
+
+	stats := Stats{
+		Time:       time.Now(),
+		Emitted:    trigger.emitted.Load(),
+		InFlight:   trigger.inFlight.Load(),
+		QueueDepth: trigger.degradeDepth(),
+		Degraded:   trigger.degraded.Load(),
+		Closed:     trigger.closed,
+	}
+	for _, handlers := range trigger.loadRegistry() {
+		if 0 != len(handlers) {
+			stats.Events++
+			stats.Listeners += len(handlers)
+		}
+	}
+	return stats
+}
+
+// 单个监听的调用统计, 调用路径上只使用原子操作
+type listenerStat struct {
+	// 调用次数
+	calls atomic.Uint64
+	// 失败次数
+	failures atomic.Uint64
+	// 累计耗时
+	total atomic.Int64
+	// 最大耗时
+	max atomic.Int64
+	// 最后一次错误
+	lastErr atomic.Pointer[error]
+}
+
+//***************************************************
+//Description : 记录一次调用
+//param :       耗时
+//param :       错误, 成功为nil
+//***************************************************
+func (stat *listenerStat) record(elapsed time.Duration, err error) {
+	stat.calls.Add(1)
+	stat.total.Add(int64(elapsed))
+	for {
+		max := stat.max.Load()
+		if int64(elapsed) <= max || stat.max.CompareAndSwap(max, int64(elapsed)) {
+			break
+		}
+	}
+	if nil != err {
+		stat.failures.Add(1)
+		// 复制后取地址, 避免成功路径上err逃逸到堆
+		lastErr := err
+		stat.lastErr.Store(&lastErr)
+	}
+}
+
+// 监听统计
+type ListenerStat struct {
+	// 事件类型
+	Event interface{}
+	// 监听回调函数
+	Listener interface{}
+	// 监听名称
+	Key string
+	// 灰度发布的版本名称
+	Variant string
+	// 注册时间
+	Registered time.Time
+	// 调用次数
+	Calls uint64
+	// 失败次数
+	Failures uint64
+	// 平均耗时
+	MeanLatency time.Duration
+	// 最大耗时
+	MaxLatency time.Duration
+	// 最后一次错误
+	LastError error
+}
+
+//***************************************************
+//Description : 生成监听统计快照
+//param :       事件类型
+//return :      监听统计
+//***************************************************
+func (h *handler) snapshot(event interface{}) ListenerStat {
+	stat := ListenerStat{
+		Event:      event,
+		Listener:   h.source,
+		Key:        h.key,
+		Variant:    h.variant,
+		Registered: h.registered,
+		Calls:      h.stat.calls.Load(),
+		Failures:   h.stat.failures.Load(),
+		MaxLatency: time.Duration(h.stat.max.Load()),
+	}
+	if 0 != stat.Calls {
+		stat.MeanLatency = time.Duration(h.stat.total.Load()) / time.Duration(stat.Calls)
+	}
+	if err := h.stat.lastErr.Load(); nil != err {
+		stat.LastError = *err
+	}
+	return stat
+}
+
+//***************************************************
+//Description : 获取某事件所有监听的统计
+//param :       事件类型
+//return :      监听统计数组, 按注册顺序
+//***************************************************
+func (trigger *Trigger) ListenerStats(event interface{}) []ListenerStat {
+	handlers := trigger.handlersOf(event)
+
+	stats := make([]ListenerStat, 0, len(handlers))
+	for _, h := range handlers {
+		stats = append(stats, h.snapshot(event))
+	}
+	return stats
+}
+
+//***************************************************
+//Description : 获取平均耗时最长的n个监听
+//param :       数量
+//return :      监听统计数组, 按平均耗时降序
+//***************************************************
+func (trigger *Trigger) SlowestListeners(n int) []ListenerStat {
+	var stats []ListenerStat
+	for event, handlers := range trigger.loadRegistry() {
+		for _, h := range handlers {
+			stats = append(stats, h.snapshot(event))
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].MeanLatency > stats[j].MeanLatency
+	})
+	if n < len(stats) {
+		stats = stats[:n]
+	}
+	return stats
+}
@@ -0,0 +1,285 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 租户配额, 零值表示不限制
+type TenantQuota struct {
+	// 每个事件的最大监听数量
+	MaxListeners int
+	// 同时执行的最大触发数量
+	MaxQueue int
+	// 每秒最多触发次数
+	Rate float64
+	// 触发次数的突发上限, 小于1时为1
+	Burst int
+}
+
+// 租户统计
+type TenantStats struct {
+	// 租户名称
+	Name string `json:"name"`
+	// 租户总线的统计
+	Stats Stats `json:"stats"`
+	// 因频率限制被拒绝的触发次数
+	RateLimited uint64 `json:"rate_limited"`
+	// 因同时执行的触发过多被拒绝的触发次数
+	QueueRejected uint64 `json:"queue_rejected"`
+}
+
+// 租户总线, 拥有独立的监听注册表与配额, 触发经过父触发器的权限策略校验
+type Tenant struct {
+	// 租户名称
+	name string
+	// 父触发器
+	parent *Trigger
+	// 租户自己的触发器
+	bus *Trigger
+	// 配额
+	quota TenantQuota
+	// 频率限制
+	limiter *tokenBucket
+	// 正在执行的触发数量
+	inFlight atomic.Int64
+	// 因频率限制被拒绝的触发次数
+	rateLimited atomic.Uint64
+	// 因同时执行的触发过多被拒绝的触发次数
+	queueRejected atomic.Uint64
+}
+
+//***************************************************
+//Description : 获取租户总线, 不存在时按配额创建
+//              租户总线继承父触发器的错误处理函数与panic策略, 父触发器关闭时一并关闭
+//param :       租户名称, 同时作为权限策略中的触发方
+//param :       配额, 仅在创建时生效
+//return :      租户总线
+//***************************************************
+func (trigger *Trigger) Tenant(name string, quota TenantQuota) *Tenant {
+	trigger.Lock()
+	defer trigger.Unlock()
+
+	if tenant, ok := trigger.tenants[name]; ok {
+		return tenant
+	}
+
+	bus := NewTrigger()
+	bus.recoverer = trigger.recoverer
+	bus.panicPolicy = trigger.panicPolicy
+	if quota.MaxListeners > 0 {
+		bus.maxListeners = quota.MaxListeners
+	}
+
+	tenant := &Tenant{name: name, parent: trigger, bus: bus, quota: quota}
+	if quota.Rate > 0 {
+		tenant.limiter = newTokenBucket(quota.Rate, quota.Burst)
+	}
+	if nil == trigger.tenants {
+		trigger.tenants = make(map[string]*Tenant)
+	}
+	trigger.tenants[name] = tenant
+	return tenant
+}
+
+//***************************************************
+//Description : 获取所有租户的统计
+//return :      租户统计数组
+//***************************************************
+func (trigger *Trigger) TenantStats() []TenantStats {
+	trigger.RLock()
+	tenants := make([]*Tenant, 0, len(trigger.tenants))
+	for _, tenant := range trigger.tenants {
+		tenants = append(tenants, tenant)
+	}
+	trigger.RUnlock()
+
+	stats := make([]TenantStats, 0, len(tenants))
+	for _, tenant := range tenants {
+		stats = append(stats, tenant.Stats())
+	}
+	return stats
+}
+
+//***************************************************
+//Description : 关闭所有租户总线
+//param :       上下文
+//return :      第一个错误
+//***************************************************
+func (trigger *Trigger) closeTenants(ctx context.Context) error {
+	trigger.Lock()
+	tenants := trigger.tenants
+	trigger.tenants = nil
+	trigger.Unlock()
+
+	var first error
+	for _, tenant := range tenants {
+		if err := tenant.bus.Close(ctx); nil != err && nil == first {
+			first = err
+		}
+	}
+	return first
+}
+
+// 租户名称
+func (tenant *Tenant) Name() string {
+	return tenant.name
+}
+
+//***************************************************
+//Description : 获取租户统计
+//return :      租户统计
+//***************************************************
+func (tenant *Tenant) Stats() TenantStats {
+	return TenantStats{
+		Name:          tenant.name,
+		Stats:         tenant.bus.Stats(),
+		RateLimited:   tenant.rateLimited.Load(),
+		QueueRejected: tenant.queueRejected.Load(),
+	}
+}
+
+//***************************************************
+//Description : 添加监听, 超出监听数量配额时报告ErrExceedMaxListeners
+//param :       事件名称
+//param :       回调函数
+//return :      租户总线
+//***************************************************
+func (tenant *Tenant) On(event, listener interface{}) *Tenant {
+	tenant.bus.On(event, listener)
+	return tenant
+}
+
+//***************************************************
+//Description : 添加只执行一次的监听
+//param :       事件名称
+//param :       回调函数
+//return :      租户总线
+//***************************************************
+func (tenant *Tenant) Once(event, listener interface{}) *Tenant {
+	tenant.bus.Once(event, listener)
+	return tenant
+}
+
+//***************************************************
+//Description : 删除监听
+//param :       事件名称
+//param :       回调函数
+//return :      租户总线
+//***************************************************
+func (tenant *Tenant) Off(event, listener interface{}) *Tenant {
+	tenant.bus.Off(event, listener)
+	return tenant
+}
+
+//***************************************************
+//Description : 触发事件, 超出配额时不触发并报告包装ErrRateLimited或ErrQueueFull的DispatchError
+//param :       事件名称
+//param :       回调函数中的参数
+//return :      租户总线
+//***************************************************
+func (tenant *Tenant) Emit(event interface{}, arguments ...interface{}) *Tenant {
+	if tenant.admit(event, arguments) {
+		defer tenant.inFlight.Add(-1)
+		tenant.bus.emit(event, arguments)
+	}
+	return tenant
+}
+
+//***************************************************
+//Description : 同步触发事件, 配额同Emit
+//param :       事件名称
+//param :       回调函数中的参数
+//return :      租户总线
+//***************************************************
+func (tenant *Tenant) EmitSync(event interface{}, arguments ...interface{}) *Tenant {
+	if tenant.admit(event, arguments) {
+		defer tenant.inFlight.Add(-1)
+		tenant.bus.emitSync(event, arguments)
+	}
+	return tenant
+}
+
+//***************************************************
+//Description : 校验权限与配额, 通过时占用一个执行名额
+//param :       事件名称
+//param :       回调函数中的参数
+//return :      是否允许触发
+//***************************************************
+func (tenant *Tenant) admit(event interface{}, arguments []interface{}) bool {
+	if !tenant.parent.authorize(tenant.name, event, arguments) {
+		return false
+	}
+	if nil != tenant.limiter && !tenant.limiter.take() {
+		tenant.rateLimited.Add(1)
+		tenant.reject(event, ErrRateLimited)
+		return false
+	}
+	if depth := tenant.inFlight.Add(1); tenant.quota.MaxQueue > 0 && depth > int64(tenant.quota.MaxQueue) {
+		tenant.inFlight.Add(-1)
+		tenant.queueRejected.Add(1)
+		tenant.reject(event, ErrQueueFull)
+		return false
+	}
+	return true
+}
+
+//***************************************************
+//Description : 报告超出配额的触发
+//param :       事件名称
+//param :       原因
+//***************************************************
+func (tenant *Tenant) reject(event interface{}, err error) {
+	tenant.bus.report(event, nil, &DispatchError{Event: event, Err: fmt.Errorf("租户[%s]: %w", tenant.name, err)})
+}
+
+// 令牌桶频率限制
+type tokenBucket struct {
+	mu sync.Mutex
+	// 每秒补充的令牌数量
+	rate float64
+	// 令牌上限
+	burst float64
+	// 当前令牌数量
+	tokens float64
+	// 上次补充时间
+	last time.Time
+}
+
+//***************************************************
+//Description : 创建令牌桶, 初始为满
+//param :       每秒补充的令牌数量
+//param :       令牌上限, 小于1时为1
+//return :      令牌桶
+//***************************************************
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+//***************************************************
+//Description : 获取一个令牌
+//return :      是否获取成功
+//***************************************************
+func (bucket *tokenBucket) take() bool {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	now := time.Now()
+	bucket.tokens += now.Sub(bucket.last).Seconds() * bucket.rate
+	if bucket.tokens > bucket.burst {
+		bucket.tokens = bucket.burst
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
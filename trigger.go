@@ -1,20 +1,19 @@
 package trigger
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"os"
 	"reflect"
+	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // 事件默认最大监听数量
 const defaultMaxListeners = 16
 
-// 错误
-var ErrNotFunction = errors.New("传入参数不是函数类型")
-var ErrExceedMaxListeners = errors.New("此事件超过最大监听数量")
-
 // 错误处理函数
 type RecoveryFunc func(interface{}, interface{}, error)
 
@@ -23,16 +22,189 @@ var defaultRecoveryFunc RecoveryFunc = func(event interface{}, listener interfac
 	fmt.Fprintf(os.Stdout, "Error: 事件[%v]\n%v.\n", event, err)
 }
 
+// 监听者
+type handler struct {
+	// 回调函数反射
+	fn reflect.Value
+	// 原始监听对象, 用于生命周期回调
+	source interface{}
+	// 是否为核心监听, 降级模式下仍会执行
+	essential bool
+	// 注册时间
+	registered time.Time
+	// 是否已被泄漏检测标记
+	flagged bool
+	// 调用统计
+	stat listenerStat
+	// 调用闸门
+	gate gate
+	// 方法监听的接收者, 普通函数监听为nil
+	receiver interface{}
+	// 方法监听的方法名
+	method string
+	// 监听的名称, 未命名为空字符串
+	key string
+	// 回调函数
+	callable interface{}
+	// 此签名预先生成的直接调用函数, 没有则为nil
+	invoker Invoker
+	// 灰度发布的版本名称
+	variant string
+	// 灰度发布的流量权重, 大于0时同名监听每次只执行其中一个
+	weight int
+	// 是否为影子监听, 影子监听的结果只记录不影响触发
+	shadow bool
+	// 影子监听对比的主监听名称
+	shadowOf string
+	// 正则监听的事件名称匹配器
+	matcher *matcher
+	// 合并模式下的执行状态, nil表示不合并
+	conflation atomic.Pointer[conflation]
+	// 串行执行队列, nil表示允许并发调用
+	serial *serialQueue
+	// 执行器, nil表示由触发器启动的协程或触发方协程执行
+	executor Executor
+}
+
+//***************************************************
+//Description : 是否为此回调函数注册的普通监听
+//              按原始监听匹配, 因此Once注册的监听也可以用原回调函数移除
+//              方法值的函数指针与接收者无关, 因此方法监听不参与匹配
+//param :       回调函数反射
+//return :      是否匹配
+//***************************************************
+func (h *handler) matchFunc(fn reflect.Value) bool {
+	if nil != h.receiver {
+		return false
+	}
+	source := reflect.ValueOf(h.source)
+	return reflect.Func == source.Kind() && fn.Pointer() == source.Pointer()
+}
+
 // 事件触发器
 type Trigger struct {
 	// 读写锁
 	*sync.RWMutex
-	// 存放事件与事件执行函数的反射数组
-	events map[interface{}][]reflect.Value
+	// 存放事件与事件监听者数组, 写入时复制, 读取无需加锁
+	events atomic.Pointer[registry]
 	// 最大监听数量
 	maxListeners int
 	// 错误处理函数
 	recoverer RecoveryFunc
+	// 监听panic的处理策略
+	panicPolicy PanicPolicy
+	// 参数类型转换配置, nil表示不转换
+	coercion atomic.Pointer[coercion]
+	// 是否处于降级模式
+	degraded atomic.Bool
+	// 保护degradeMode与degradeBuffer, 降级时的触发不占用注册表的锁
+	degradeMu sync.Mutex
+	// 降级模式下非核心监听的处理方式
+	degradeMode DegradeMode
+	// 降级模式下缓存的调用
+	degradeBuffer []bufferedCall
+	// 是否已关闭
+	closed bool
+	// 外部组件注册的健康检查
+	healthChecks map[string]HealthCheck
+	// 累计触发次数
+	emitted atomic.Uint64
+	// 正在执行的触发数量
+	inFlight atomic.Int64
+	// 触发返回后仍在后台执行的任务数量, 如影子监听
+	background atomic.Int64
+	// 停止心跳的通道
+	heartbeatStop chan struct{}
+	// 是否开启泄漏检测
+	leakDetect atomic.Bool
+	// 是否记录触发来源
+	provenance atomic.Bool
+	// 是否检查监听修改参数
+	mutationGuard atomic.Bool
+	// 分发时的参数复制函数, nil表示不复制
+	copier atomic.Pointer[Copier]
+	// 停止泄漏检测的通道
+	leakStop chan struct{}
+	// 检测窗口内没有监听的事件及触发次数
+	unhandled map[interface{}]int
+	// 停止自动整理的通道
+	compactStop chan struct{}
+	// 保护shadowReports
+	shadowMu sync.Mutex
+	// 最近的影子监听报告
+	shadowReports []ShadowReport
+	// 触发调试记录器, nil表示未开启
+	tracer atomic.Pointer[debugTracer]
+	// 触发权限策略, nil表示不校验
+	emitPolicy atomic.Pointer[EmitPolicy]
+	// 触发阶段钩子, nil表示未设置
+	hooks atomic.Pointer[Hooks]
+	// 是否注册了拦截监听, 在写入监听映射时更新
+	intercepting atomic.Bool
+	// 互斥组名称 -> 组内共用的锁
+	mutexGroups map[string]*sync.Mutex
+	// 事件类型 -> 所属互斥组的锁
+	mutexes atomic.Pointer[mutexTable]
+	// 事件类型 -> 自适应并发控制
+	limiters atomic.Pointer[limiterTable]
+	// 事件类型 -> 等级
+	classes atomic.Pointer[classTable]
+	// 事件的送达策略
+	deliveries atomic.Pointer[deliveryTable]
+	// 功能开关提供方
+	flags atomic.Pointer[FlagProvider]
+	// 事件目录
+	catalog atomic.Pointer[eventCatalog]
+	// 是否只允许目录中声明的事件
+	strictEvents atomic.Bool
+	// 过载丢弃的运行状态, nil表示未开启
+	shedding atomic.Pointer[shedder]
+	// 内存预算, nil表示未开启
+	memory atomic.Pointer[memoryBudget]
+	// 请求事件 -> 回复缓存
+	requestCaches map[interface{}]*requestCache
+	// 请求事件 -> 并发请求合并
+	requestFlights map[interface{}]*flightGroup
+	// 事件类型 -> 一次性初始化
+	inits map[interface{}]*onceInit
+	// 触发的读取周期, 平滑升级监听时等待旧触发结束
+	epoch readEpoch
+	// 用于屏障的事件 -> 事件的读取周期
+	barrierEpochs atomic.Pointer[epochTable]
+	// 租户名称 -> 租户总线
+	tenants map[string]*Tenant
+	// 参数脱敏函数, nil表示只按结构体标签脱敏
+	redactor atomic.Pointer[Redactor]
+	// 窗口名称 -> 运行中的聚合窗口
+	aggregators map[string]*aggregator
+	// 关联名称 -> 运行中的关联
+	joins map[string]*joiner
+	// 是否开启继承
+	inherit atomic.Bool
+	// 协程ID -> 正在执行的触发的继承属性, nil表示屏蔽外层的继承属性
+	lineages sync.Map
+	// 生效中的继承属性数量, 为0时跳过查找
+	lineageCount atomic.Int64
+	// 计划触发ID -> 等待执行的计划触发
+	schedules map[string]*scheduleTimer
+	// 计划触发的持久化存储, nil表示只保存在内存中
+	scheduleStore ScheduleStore
+	// 事件 -> 监听名称 -> 需要先执行的监听名称
+	dependencies map[interface{}]map[string][]string
+	// 开启合并模式的事件
+	conflated map[interface{}]bool
+	// 是否以串行模式注册监听
+	serialListeners atomic.Bool
+	// 触发日志, nil表示未开启
+	journal Journal
+	// 追加日志记录后关闭并替换, 用于唤醒长轮询
+	journalNotify chan struct{}
+	// 监听名称 -> 运行中的持久监听
+	durables map[string]*durableWorker
+	// 监听名称 -> 运行中的补发监听
+	backfills map[string]*durableWorker
+	// 事件名称 -> 日志压缩键
+	compactions map[string]CompactionKey
 }
 
 //***************************************************
@@ -42,37 +214,134 @@ type Trigger struct {
 //return :      事件触发器
 //***************************************************
 func (trigger *Trigger) AddListener(event, listener interface{}) *Trigger {
-	// 加锁
-	trigger.Lock()
-	defer trigger.Unlock()
+	return trigger.register(event, listener, &handler{})
+}
+
+// 把监听者放入此事件的监听数组, 返回新的监听数组以及被替换掉的监听者
+// 正在执行的触发可能持有原数组, 因此不能修改原数组中已有的元素
+type placement func(handlers []*handler, h *handler) ([]*handler, []*handler)
+
+//***************************************************
+//Description : 追加到监听数组末尾
+//param :       监听数组
+//param :       监听者
+//return :      新的监听数组
+//return :      被替换掉的监听者
+//***************************************************
+func appendHandler(handlers []*handler, h *handler) ([]*handler, []*handler) {
+	return append(handlers, h), nil
+}
+
+//***************************************************
+//Description : 注册监听者, 追加到监听数组末尾
+//param :       事件名称
+//param :       回调函数
+//param :       监听者, 回调函数由此方法填充
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) register(event, listener interface{}, h *handler) *Trigger {
+	return trigger.registerAt(event, listener, h, appendHandler)
+}
+
+//***************************************************
+//Description : 注册监听者
+//param :       事件名称
+//param :       回调函数
+//param :       监听者, 回调函数由此方法填充
+//param :       放入监听数组的方式
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) registerAt(event, listener interface{}, h *handler, place placement) *Trigger {
+	replaced, _ := trigger.install(event, listener, h, place)
+
+	// 等待被替换的监听正在执行的调用结束后释放其资源
+	for _, old := range replaced {
+		trigger.quiesce(event, old)
+	}
+
+	// 返回本对象, 链式编程
+	return trigger
+}
 
+//***************************************************
+//Description : 放入监听者, 不等待被替换的监听
+//param :       事件名称
+//param :       回调函数
+//param :       监听者, 回调函数由此方法填充
+//param :       放入监听数组的方式
+//return :      被替换掉的监听者
+//return :      是否注册成功
+//***************************************************
+func (trigger *Trigger) install(event, listener interface{}, h *handler, place placement) ([]*handler, bool) {
 	// 反射回调函数
 	fn := reflect.ValueOf(listener)
 
-	// 判断参数2是否是函数类型
+	// 判断参数2是否是函数类型, 不是则报告错误并放弃注册
 	if reflect.Func != fn.Kind() {
-		// 如果未对recoverer赋值, 则直接panic, 否则调用recoverer
-		if nil == trigger.recoverer {
-			panic(ErrNotFunction)
-		} else {
-			trigger.recoverer(event, listener, ErrNotFunction)
-		}
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrNotFunction})
+		return nil, false
 	}
 
-	// 判断此事件是否超过最大监听数量, 如果超过panic或者调用recoverer
-	if trigger.maxListeners != -1 && trigger.maxListeners < len(trigger.events[event])+1 {
-		if nil == trigger.recoverer {
-			panic(ErrExceedMaxListeners)
-		} else {
-			trigger.recoverer(event, listener, ErrExceedMaxListeners)
-		}
+	// 严格模式下拒绝未声明的事件与无法接收载荷的监听
+	if err := trigger.checkListen(event, fn.Type()); nil != err {
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: err})
+		return nil, false
 	}
 
-	// 对此事件追加监听者
-	trigger.events[event] = append(trigger.events[event], fn)
+	h.fn = fn
+	h.callable = listener
+	// 直接调用函数不返回结果, 只用于没有返回值的监听
+	if 0 == fn.Type().NumOut() {
+		h.invoker = lookupInvoker(fn.Type())
+	}
+	h.registered = time.Now()
+	if nil == h.serial && trigger.serialListeners.Load() {
+		h.serial = newSerialQueue()
+	}
+	if nil == h.source {
+		h.source = listener
+	}
 
-	// 返回本对象, 链式编程
-	return trigger
+	// 关闭后不再接受注册, 避免初始化的资源无人释放
+	trigger.RLock()
+	closed := trigger.closed
+	trigger.RUnlock()
+	if closed {
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrClosed})
+		return nil, false
+	}
+
+	// 初始化监听, 失败则不添加
+	if err := initListener(h.source); nil != err {
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: err})
+		return nil, false
+	}
+
+	// 加锁
+	trigger.Lock()
+
+	// 初始化期间被关闭时释放此监听
+	if trigger.closed {
+		trigger.Unlock()
+		trigger.shutdownHandler(event, h)
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrClosed})
+		return nil, false
+	}
+
+	// 判断此事件是否超过最大监听数量, 如果超过则报告错误并放弃注册
+	handlers, replaced := place(trigger.handlersOf(event), h)
+	if trigger.maxListeners != -1 && trigger.maxListeners < len(handlers) {
+		trigger.Unlock()
+		trigger.shutdownHandler(event, h)
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrExceedMaxListeners})
+		return nil, false
+	}
+
+	// 对此事件放入监听者
+	trigger.storeHandlers(event, handlers)
+	trigger.Unlock()
+
+	return replaced, true
 }
 
 //***************************************************
@@ -86,39 +355,212 @@ func (trigger *Trigger) On(event, listener interface{}) *Trigger {
 }
 
 //***************************************************
-//Description : 删除监听
+//Description : 添加监听到监听数组开头, 触发时最先执行
+//param :       事件名称
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) PrependListener(event, listener interface{}) *Trigger {
+	return trigger.InsertListenerAt(event, 0, listener)
+}
+
+//***************************************************
+//Description : 添加监听到监听数组的指定位置
+//param :       事件名称
+//param :       位置, 小于0视为0, 超过监听数量视为追加到末尾
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) InsertListenerAt(event interface{}, index int, listener interface{}) *Trigger {
+	return trigger.registerAt(event, listener, &handler{}, func(handlers []*handler, h *handler) ([]*handler, []*handler) {
+		if index < 0 {
+			index = 0
+		}
+		if index > len(handlers) {
+			index = len(handlers)
+		}
+
+		// 复制到新数组, 不影响正在执行的触发
+		placed := make([]*handler, 0, len(handlers)+1)
+		placed = append(placed, handlers[:index]...)
+		placed = append(placed, h)
+		placed = append(placed, handlers[index:]...)
+		return placed, nil
+	})
+}
+
+//***************************************************
+//Description : 删除监听, 返回时此监听正在执行的调用已全部结束
+//              在监听自身的回调中移除自己时只等待其他调用, 自身返回后再释放资源
 //param :       事件类型
 //param :       监听回调函数
 //return :      事件触发器
 //***************************************************
 func (trigger *Trigger) RemoveListener(event, listener interface{}) *Trigger {
-	trigger.Lock()
-	defer trigger.Unlock()
-
 	// 获取回调函数类型
 	fn := reflect.ValueOf(listener)
 	if reflect.Func != fn.Kind() {
-		if nil == trigger.recoverer {
-			panic(ErrNotFunction)
-		} else {
-			trigger.recoverer(event, listener, ErrNotFunction)
-		}
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrNotFunction})
+		return trigger
 	}
 
-	// 从事件map中获取回调函数数组
-	if events, ok := trigger.events[event]; ok {
-		newEvents := []reflect.Value{}
-		// 遍历数组,把其他回调函数放入新的数组中
-		for _, listener := range events {
-			if fn.Pointer() != listener.Pointer() {
-				newEvents = append(newEvents, listener)
+	trigger.removeMatching(event, true, func(h *handler) bool {
+		return h.matchFunc(fn)
+	})
+	return trigger
+}
+
+//***************************************************
+//Description : 删除监听并返回删除的数量, 语义同RemoveListener
+//param :       事件类型
+//param :       监听回调函数
+//return :      删除的数量
+//return :      没有找到该监听时返回包装ErrListenerNotFound的RegistrationError
+//***************************************************
+func (trigger *Trigger) TryRemoveListener(event, listener interface{}) (int, error) {
+	fn := reflect.ValueOf(listener)
+	if reflect.Func != fn.Kind() {
+		return 0, &RegistrationError{Event: event, Listener: listener, Err: ErrNotFunction}
+	}
+
+	removed := trigger.removeMatching(event, true, func(h *handler) bool {
+		return h.matchFunc(fn)
+	})
+	if 0 == removed {
+		return 0, &RegistrationError{Event: event, Listener: listener, Err: ErrListenerNotFound}
+	}
+	return removed, nil
+}
+
+//***************************************************
+//Description : 调用的TryRemoveListener
+//param :       事件类型
+//param :       监听回调函数
+//return :      删除的数量
+//return :      错误
+//***************************************************
+func (trigger *Trigger) TryOff(event, listener interface{}) (int, error) {
+	return trigger.TryRemoveListener(event, listener)
+}
+
+//***************************************************
+//Description : 移除满足条件的监听者, 并在正在执行的调用结束后释放其资源
+//param :       事件类型
+//param :       是否等待正在执行的调用结束, 在监听自身的回调中移除时不能等待
+//param :       匹配函数
+//return :      移除的数量
+//***************************************************
+func (trigger *Trigger) removeMatching(event interface{}, wait bool, match func(*handler) bool) int {
+	trigger.Lock()
+	var removed []*handler
+	// 从事件map中获取监听者数组
+	if handlers, ok := trigger.loadRegistry()[event]; ok {
+		newHandlers := []*handler{}
+		// 遍历数组,把其他监听者放入新的数组中
+		for _, h := range handlers {
+			if match(h) {
+				removed = append(removed, h)
+			} else {
+				newHandlers = append(newHandlers, h)
 			}
 		}
 		// 从新赋值
-		trigger.events[event] = newEvents
+		trigger.storeHandlers(event, newHandlers)
 	}
+	trigger.Unlock()
 
-	return trigger
+	// 在锁外关闭闸门并释放被移除监听的资源
+	for _, h := range removed {
+		if wait {
+			trigger.quiesce(event, h)
+			continue
+		}
+		idle := h.gate.shut()
+		go func(h *handler) {
+			<-idle
+			trigger.shutdownHandler(event, h)
+		}(h)
+	}
+	return len(removed)
+}
+
+//***************************************************
+//Description : 关闭监听的闸门, 等待正在执行的调用结束后释放其资源
+//              当前协程正处于此监听的调用中时(监听移除或替换自己), 只等待其他调用, 资源在自身返回后释放
+//param :       事件类型
+//param :       监听者
+//***************************************************
+func (trigger *Trigger) quiesce(event interface{}, h *handler) {
+	idle := h.gate.shut()
+	self := h.reentrant()
+	if 0 == self {
+		<-idle
+		trigger.shutdownHandler(event, h)
+		return
+	}
+
+	h.gate.waitRunning(self)
+	go func() {
+		<-idle
+		trigger.shutdownHandler(event, h)
+	}()
+}
+
+//***************************************************
+//Description : 当前协程正在执行的此监听的调用数量, 按调用栈中监听回调函数的栈帧计算
+//              只在有正在执行的调用时检查调用栈, 不影响触发路径
+//return :      调用数量
+//***************************************************
+func (h *handler) reentrant() int64 {
+	if 0 == h.gate.running() {
+		return 0
+	}
+	entry := h.entry()
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	for n == len(pcs) {
+		pcs = make([]uintptr, 2*len(pcs))
+		n = runtime.Callers(2, pcs)
+	}
+
+	var count int64
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if nil != frame.Func && entry == frame.Func.Entry() {
+			count++
+		}
+		if !more {
+			return count
+		}
+	}
+}
+
+//***************************************************
+//Description : 监听回调函数的代码地址, 方法监听为方法本身, 包装后的监听为注册时的原函数
+//return :      代码地址
+//***************************************************
+func (h *handler) entry() uintptr {
+	if nil != h.receiver && "" != h.method {
+		if method, ok := reflect.TypeOf(h.receiver).MethodByName(h.method); ok {
+			return method.Func.Pointer()
+		}
+	}
+	if source := reflect.ValueOf(h.source); reflect.Func == source.Kind() {
+		return source.Pointer()
+	}
+	return h.fn.Pointer()
+}
+
+//***************************************************
+//Description : 释放被移除监听的资源
+//param :       事件类型
+//param :       监听者
+//***************************************************
+func (trigger *Trigger) shutdownHandler(event interface{}, h *handler) {
+	if err := shutdownListener(context.Background(), h.source); nil != err && nil != trigger.recoverer {
+		trigger.recoverer(event, h.source, &RegistrationError{Event: event, Listener: h.source, Err: err})
+	}
 }
 
 //***************************************************
@@ -141,32 +583,103 @@ func (trigger *Trigger) Once(event, listener interface{}) *Trigger {
 	// 获取回调函数类型
 	fn := reflect.ValueOf(listener)
 	if reflect.Func != fn.Kind() {
-		if nil == trigger.recoverer {
-			panic(ErrNotFunction)
-		} else {
-			trigger.recoverer(event, listener, ErrNotFunction)
-		}
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrNotFunction})
+		return trigger
 	}
 
-	// 包装回调函数, 在调用回调函数之后调用RemoveListener移除此监听
-	var run func(...interface{})
-	run = func(arguments ...interface{}) {
-		defer trigger.RemoveListener(event, run)
-
-		var values []reflect.Value
+	// 包装回调函数, 在调用回调函数之后移除此监听
+	// 包装函数与原回调函数签名一致, 参数绑定(包括可变参数)与普通监听相同
+	// 移除发生在监听自身的回调中, 不能等待调用结束
+	h := &handler{source: listener}
+	run := reflect.MakeFunc(fn.Type(), func(values []reflect.Value) []reflect.Value {
+		defer trigger.removeMatching(event, false, func(other *handler) bool {
+			return other == h
+		})
 
-		for i := 0; i < len(arguments); i++ {
-			values = append(values, reflect.ValueOf(arguments[i]))
+		if fn.Type().IsVariadic() {
+			return fn.CallSlice(values)
 		}
+		return fn.Call(values)
+	}).Interface()
+
+	// 添加监听, 函数为包装后的函数, 生命周期回调仍作用于原始监听
+	trigger.register(event, run, h)
+	return trigger
+}
 
-		fn.Call(values)
+//***************************************************
+//Description : 添加条件满足时只执行一次的监听, 条件不满足的触发跳过并继续保留此监听
+//              并发触发时也只会执行一次, 执行后移除
+//param :       事件名称
+//param :       条件, 参数为按回调函数参数列表绑定后的值, 可变参数展开, nil时同Once
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnceWhen(event interface{}, predicate func(arguments []interface{}) bool, listener interface{}) *Trigger {
+	fn := reflect.ValueOf(listener)
+	if reflect.Func != fn.Kind() {
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrNotFunction})
+		return trigger
 	}
+	if nil == predicate {
+		return trigger.Once(event, listener)
+	}
+
+	// 包装方式同Once, 条件不满足或已执行时返回零值
+	h := &handler{source: listener}
+	fnType := fn.Type()
+	var fired atomic.Bool
+	run := reflect.MakeFunc(fnType, func(values []reflect.Value) []reflect.Value {
+		if fired.Load() || !predicate(boundArguments(fnType, values)) || !fired.CompareAndSwap(false, true) {
+			return zeroResults(fnType)
+		}
+		defer trigger.removeMatching(event, false, func(other *handler) bool {
+			return other == h
+		})
+
+		if fnType.IsVariadic() {
+			return fn.CallSlice(values)
+		}
+		return fn.Call(values)
+	}).Interface()
 
-	// 添加监听, 函数为包装后的函数
-	trigger.AddListener(event, run)
+	trigger.register(event, run, h)
 	return trigger
 }
 
+//***************************************************
+//Description : 回调函数返回值的零值, 用于跳过执行的包装监听
+//param :       回调函数类型
+//return :      零值数组
+//***************************************************
+func zeroResults(fnType reflect.Type) []reflect.Value {
+	results := make([]reflect.Value, fnType.NumOut())
+	for i := range results {
+		results[i] = reflect.Zero(fnType.Out(i))
+	}
+	return results
+}
+
+//***************************************************
+//Description : 把绑定后的参数反射转换为参数数组, 可变参数展开
+//param :       回调函数类型
+//param :       参数反射数组
+//return :      参数数组
+//***************************************************
+func boundArguments(fnType reflect.Type, values []reflect.Value) []interface{} {
+	arguments := make([]interface{}, 0, len(values))
+	for i, value := range values {
+		if fnType.IsVariadic() && i == len(values)-1 {
+			for j := 0; j < value.Len(); j++ {
+				arguments = append(arguments, value.Index(j).Interface())
+			}
+			break
+		}
+		arguments = append(arguments, value.Interface())
+	}
+	return arguments
+}
+
 //***************************************************
 //Description : 触发事件
 //param :       事件类型
@@ -174,98 +687,339 @@ func (trigger *Trigger) Once(event, listener interface{}) *Trigger {
 //return :      事件触发器
 //***************************************************
 func (trigger *Trigger) Emit(event interface{}, arguments ...interface{}) *Trigger {
-	// 获取此事件的监听回调函数数组,如果为空则直接返回
-	listeners := trigger.GetListenersByEvent(event)
-	if nil == listeners {
+	return trigger.EmitAs(nil, event, arguments...)
+}
+
+//***************************************************
+//Description : 触发事件, 权限校验通过后执行
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) emit(event interface{}, arguments []interface{}) *Trigger {
+	// 统计触发次数与正在执行的触发数量
+	trigger.emitted.Add(1)
+	if trigger.unobserved(event) {
+		return trigger
+	}
+	arguments = wrapLazy(arguments)
+	a, ok := trigger.admit(event, arguments, false)
+	defer a.finish(trigger)
+	if !ok {
 		return trigger
 	}
+	lineage, trace := a.lineage, a.trace
+
+	// 影子监听在其他监听执行完后单独执行
+	handlers, shadows := splitShadows(a.handlers)
 
-	var wg sync.WaitGroup
-	wg.Add(len(listeners))
+	// 本次触发的共享状态, 复用以减少分配
+	task := taskPool.Get().(*emitTask)
+	task.event = event
+	task.trace = trace
+	task.lineage = trigger.inheritable(lineage)
+	task.deadline = lineage.deadline()
+	task.arguments = arguments
+	if 0 != len(shadows) {
+		task.outcomes = make(map[string]outcome)
+	}
+	task.wg.Add(len(handlers))
 
 	// 遍历监听函调函数
-	for _, fn := range listeners {
+	for _, h := range handlers {
 		// 开启协程同步执行此事件的所有监听, 同时 WaitGroup - 1
-		go func(fn reflect.Value) {
-			defer wg.Done()
-
-			// 拦截监听回调函数中的panic
-			if nil != trigger.recoverer {
-				defer func() {
-					if r := recover(); nil != r {
-						err := fmt.Errorf("%v", r)
-						trigger.recoverer(event, fn.Interface(), err)
-					}
-				}()
-			}
+		// 串行监听在触发方协程中取号, 保证按触发顺序执行
+		var ticket uint64
+		if nil != h.serial {
+			ticket = h.serial.take()
+		}
+		if nil != h.executor {
+			submit(h.executor, event, h, lineage, trigger.taskFunc(task, h, ticket))
+			continue
+		}
+		go trigger.runTask(task, h, ticket)
+	}
+	// 等待所有回调执行完毕
+	task.wg.Wait()
 
-			// 传入参数数组
-			var values []reflect.Value
-			for i := 0; i < len(arguments); i++ {
-				if arguments[i] == nil {
-					values = append(values, reflect.New(fn.Type().In(i)).Elem())
-				} else {
-					values = append(values, reflect.ValueOf(arguments[i]))
-				}
-			}
+	panicValue := task.panicValue
+	outcomes := task.outcomes
+	a.failed = task.failed || nil != panicValue
+	task.reset()
+	taskPool.Put(task)
 
-			// 调用
-			fn.Call(values)
-		}(fn)
+	// 在调用方协程中继续抛出, 由调用方决定是否恢复
+	if nil != panicValue {
+		panic(panicValue)
+	}
+
+	if 0 != len(shadows) {
+		trigger.background.Add(1)
+		go trigger.runShadows(event, shadows, arguments, outcomes)
 	}
-	// 等待所有回调执行完毕
-	wg.Wait()
 	return trigger
 }
 
 //***************************************************
-//Description : 同Emit, 不过会同步执行所有回调函数时
+//Description : 同Emit, 不过会按注册顺序同步执行所有回调函数
+//              第一个参数为*Bag的监听会收到本次触发共享的Bag, 前面的监听写入的值后面的监听可以读取
+//              调用方可以把*Bag作为第一个参数传入以读取结果, 此Bag不会传给不接收*Bag的监听
 //param :       事件名称
 //param :       回调函数中的参数, 按照回调函数的参数列表顺序传入
 //return :      事件触发器
 //***************************************************
 func (trigger *Trigger) EmitSync(event interface{}, arguments ...interface{}) *Trigger {
-	// 获取此事件的监听回调函数数组,如果为空则直接返回
-	listeners := trigger.GetListenersByEvent(event)
-	if nil == listeners {
+	return trigger.EmitSyncAs(nil, event, arguments...)
+}
+
+//***************************************************
+//Description : 同步触发事件, 权限校验通过后执行
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) emitSync(event interface{}, arguments []interface{}) *Trigger {
+	// 统计触发次数与正在执行的触发数量
+	trigger.emitted.Add(1)
+	if trigger.unobserved(event) {
+		return trigger
+	}
+	arguments = wrapLazy(arguments)
+	a, ok := trigger.admit(event, arguments, true)
+	defer a.finish(trigger)
+	if nil != a.trace {
+		defer a.trace.tracer.enter(a.trace)()
+	}
+	if !ok {
 		return trigger
 	}
 
-	for _, fn := range listeners {
-		if nil != trigger.recoverer {
-			defer func() {
-				if r := recover(); nil != r {
-					err := fmt.Errorf("%v", r)
-					trigger.recoverer(event, fn.Interface(), err)
-				}
-			}()
-		}
+	// 未开启继承时监听在当前协程执行, 屏蔽当前的继承属性
+	if nil != a.lineage && nil == trigger.inheritable(a.lineage) {
+		defer trigger.enterLineage(nil)()
+	}
 
-		var values []reflect.Value
+	// 影子监听在其他监听执行完后单独执行
+	handlers, shadows := splitShadows(a.handlers)
+	var outcomes map[string]outcome
+	if 0 != len(shadows) {
+		outcomes = make(map[string]outcome)
+	}
 
-		for i := 0; i < len(arguments); i++ {
-			if arguments[i] == nil {
-				values = append(values, reflect.New(fn.Type().In(i)).Elem())
-			} else {
-				values = append(values, reflect.ValueOf(arguments[i]))
+	// 第一个参数为*Bag时作为本次触发共享的上下文, 否则按需创建
+	bag, rest := splitBag(arguments)
+	for _, h := range handlers {
+		var results []reflect.Value
+		var err error
+		if acceptsBag(h.fn.Type()) {
+			if nil == bag {
+				bag = NewBag()
 			}
+			results, err = trigger.deliver(event, h, append([]interface{}{bag}, rest...), trigger.invoke)
+		} else {
+			results, err = trigger.deliver(event, h, rest, trigger.invoke)
+		}
+		if nil != err {
+			a.failed = true
 		}
 
-		fn.Call(values)
+		// 记录主监听的结果, 供影子监听对比
+		if nil != outcomes && "" != h.key {
+			outcomes[h.key] = newOutcome(results, err)
+		}
 	}
 
+	if 0 != len(shadows) {
+		trigger.background.Add(1)
+		go trigger.runShadows(event, shadows, rest, outcomes)
+	}
 	return trigger
 }
 
+//***************************************************
+//Description : 只在此事件有监听时才构造参数并触发, 避免为没有监听的事件做昂贵的准备工作
+//param :       事件名称
+//param :       参数构造函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitIfListeners(event interface{}, lazyArgs func() []interface{}) *Trigger {
+	if 0 == trigger.GetListenerCount(event) {
+		if trigger.leakDetect.Load() {
+			trigger.recordUnhandled(event)
+		}
+		return trigger
+	}
+	return trigger.Emit(event, lazyArgs()...)
+}
+
+//***************************************************
+//Description : 触发是否可以直接返回: 没有监听, 也没有拦截监听、泄漏检测、调试记录、钩子与继承属性需要观察此次触发
+//              只查找一次监听映射, 不加锁, 不分配内存, 不启动协程
+//param :       事件类型
+//return :      是否可以直接返回
+//***************************************************
+func (trigger *Trigger) unobserved(event interface{}) bool {
+	return !trigger.intercepting.Load() && !trigger.leakDetect.Load() && nil == trigger.tracer.Load() &&
+		nil == trigger.hooks.Load() && 0 == trigger.lineageCount.Load() && 0 == len(trigger.handlersOf(event))
+}
+
+//***************************************************
+//Description : 获取本次触发需要执行的监听者
+//param :       事件类型
+//param :       回调函数中的参数
+//param :       继承属性, 没有时为nil
+//return :      监听者数组
+//***************************************************
+func (trigger *Trigger) dispatchable(event interface{}, arguments []interface{}, lineage *Lineage) []*handler {
+	handlers := trigger.handlersOf(event)
+	degraded := trigger.degraded.Load()
+
+	// 拦截监听在普通监听之前执行
+	trigger.intercept(event, arguments, handlers)
+
+	// 记录没有监听的事件
+	if 0 == len(handlers) && trigger.leakDetect.Load() {
+		trigger.recordUnhandled(event)
+	}
+
+	// 同名的灰度监听按权重只保留一个
+	handlers = selectVariants(handlers)
+
+	// 降级模式下过滤非核心监听, 高优先级的触发不过滤
+	if degraded && 0 != len(handlers) && (nil == lineage || lineage.Priority <= 0) {
+		return trigger.degradeFilter(event, handlers, arguments, lineage.deadline())
+	}
+	return handlers
+}
+
+//***************************************************
+//Description : 调用单个监听, 绑定执行器的监听交给执行器调用
+//param :       事件类型
+//param :       监听者
+//param :       回调函数中的参数
+//return :      回调函数的返回值
+//return :      调用失败的错误
+//***************************************************
+func (trigger *Trigger) invoke(event interface{}, h *handler, arguments []interface{}) ([]reflect.Value, error) {
+	if nil != h.executor {
+		return trigger.execute(h.executor, event, h, arguments)
+	}
+	return trigger.invokeHere(event, h, arguments)
+}
+
+//***************************************************
+//Description : 在当前协程调用单个监听, 串行监听按顺序调用, 合并模式的事件按合并规则调用
+//param :       事件类型
+//param :       监听者
+//param :       回调函数中的参数
+//return :      回调函数的返回值
+//return :      调用失败的错误
+//***************************************************
+func (trigger *Trigger) invokeHere(event interface{}, h *handler, arguments []interface{}) ([]reflect.Value, error) {
+	if q := h.serial; nil != q {
+		q.wait(q.take())
+		defer q.done()
+	}
+	return trigger.dispatch(event, h, arguments)
+}
+
+//***************************************************
+//Description : 调用单个监听, 串行监听需已轮到此次调用
+//param :       事件类型
+//param :       监听者
+//param :       回调函数中的参数
+//return :      回调函数的返回值
+//return :      调用失败的错误
+//***************************************************
+func (trigger *Trigger) dispatch(event interface{}, h *handler, arguments []interface{}) ([]reflect.Value, error) {
+	if c := h.conflation.Load(); nil != c {
+		return trigger.invokeConflated(event, h, c, arguments)
+	}
+	return trigger.call(event, h, arguments)
+}
+
+//***************************************************
+//Description : 调用单个监听回调函数
+//param :       事件类型
+//param :       监听者
+//param :       回调函数中的参数
+//return :      回调函数的返回值
+//return :      调用失败的错误
+//***************************************************
+func (trigger *Trigger) call(event interface{}, h *handler, arguments []interface{}) (results []reflect.Value, failure error) {
+	// 监听已被移除则跳过
+	if !h.gate.acquire() {
+		if tracer := trigger.tracer.Load(); nil != tracer {
+			tracer.record(h, nil, nil, true, 0)
+		}
+		return nil, nil
+	}
+	defer h.gate.release()
+
+	fn := h.fn
+	// 检查的是共享的原参数, 复制后监听对副本的修改不会报告
+	guard := trigger.guardArguments(event, h, arguments)
+	arguments = trigger.copyArguments(arguments)
+	start := time.Now()
+
+	// 记录调用统计, 并拦截监听回调函数中的panic
+	defer func() {
+		r := recover()
+		if nil != r {
+			failure = &DispatchError{Event: event, Listener: h.source, Err: newPanicError(event, h, r)}
+		}
+		if nil != guard {
+			guard()
+		}
+		latency := time.Since(start)
+		h.stat.record(latency, failure)
+		if tracer := trigger.tracer.Load(); nil != tracer {
+			tracer.record(h, results, failure, false, latency)
+		}
+		if hooks := trigger.hooks.Load(); nil != hooks && nil != hooks.AfterListener {
+			hooks.AfterListener(ListenerInfo{Event: event, Listener: h.source, Name: h.key, Arguments: trigger.redact(event, arguments), Start: start, Duration: latency, Err: failure})
+		}
+
+		if nil != r {
+			trigger.handlePanic(event, h, r, failure)
+		}
+	}()
+
+	// 优先使用预先生成的直接调用函数, 参数不能直接匹配时回退到反射调用
+	if nil != h.invoker && h.invoker(h.callable, arguments) {
+		return nil, nil
+	}
+
+	// 传入参数数组, 参数与回调函数不匹配时不调用
+	buffer := valuesPool.Get().(*[]reflect.Value)
+	defer putValues(buffer)
+	values, err := bindArguments((*buffer)[:0], fn.Type(), arguments, trigger.coercion.Load())
+	*buffer = values
+	if nil != err {
+		failure = &ValidationError{Event: event, Listener: h.source, Err: err}
+		trigger.report(event, fn.Interface(), failure)
+		return nil, failure
+	}
+
+	// 调用
+	return fn.Call(values), nil
+}
+
 //***************************************************
 //Description : 根据时间类型获取监听回调函数数组
 //param :       时间类型
 //return :      监听回调函数数组 或者 nil
 //***************************************************
 func (trigger *Trigger) GetListenersByEvent(event interface{}) []reflect.Value {
-	trigger.RLock()
-	defer trigger.RUnlock()
-	listeners, _ := trigger.events[event]
+	handlers, ok := trigger.loadRegistry()[event]
+	if !ok {
+		return nil
+	}
+
+	listeners := make([]reflect.Value, 0, len(handlers))
+	for _, h := range handlers {
+		listeners = append(listeners, h.fn)
+	}
 	return listeners
 }
 
@@ -301,10 +1055,7 @@ func (trigger *Trigger) SetMaxListeners(max int) *Trigger {
 //return :      数量
 //***************************************************
 func (trigger *Trigger) GetListenerCount(event interface{}) int {
-	trigger.RLock()
-	defer trigger.RUnlock()
-	listeners, _ := trigger.events[event]
-	return len(listeners)
+	return len(trigger.handlersOf(event))
 }
 
 //***************************************************
@@ -314,7 +1065,7 @@ func (trigger *Trigger) GetListenerCount(event interface{}) int {
 func NewTrigger() (trigger *Trigger) {
 	trigger = new(Trigger)
 	trigger.RWMutex = new(sync.RWMutex)
-	trigger.events = make(map[interface{}][]reflect.Value)
+	trigger.events.Store(&registry{})
 	trigger.maxListeners = defaultMaxListeners
 	trigger.recoverer = defaultRecoveryFunc
 	return
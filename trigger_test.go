@@ -1,8 +1,22 @@
 package trigger
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var (
@@ -38,3 +52,3528 @@ func TestTrigger(t *testing.T) {
 	trigger.
 		Emit("sad", 1)
 }
+
+func TestDegraded(t *testing.T) {
+	var calls []string
+	trigger := NewTrigger().
+		OnEssential("order", func(arg string) { calls = append(calls, "essential:"+arg) }).
+		On("order", func(arg string) { calls = append(calls, "normal:"+arg) })
+
+	t.Log("测试降级跳过")
+	trigger.EnterDegraded(DegradeSkip).EmitSync("order", "a").ExitDegraded()
+	if 1 != len(calls) || "essential:a" != calls[0] {
+		t.Fatalf("降级跳过结果错误: %v", calls)
+	}
+
+	t.Log("测试降级缓存")
+	calls = nil
+	trigger.EnterDegraded(DegradeBuffer).EmitSync("order", "b")
+	if !trigger.IsDegraded() || 1 != len(calls) {
+		t.Fatalf("降级缓存结果错误: %v", calls)
+	}
+	trigger.ExitDegraded().WaitIdle(context.Background())
+	if 2 != len(calls) || "normal:b" != calls[1] {
+		t.Fatalf("退出降级后未补发: %v", calls)
+	}
+
+	t.Log("测试后台补发时按PanicPropagate抛出的panic交给recoverer")
+	var recovered error
+	panicking := NewTrigger().WithPanicPolicy(PanicPropagate).
+		RecoverWith(func(event, listener interface{}, err error) { recovered = err }).
+		On("order", func(arg string) { panic("补发失败") })
+	panicking.EnterDegraded(DegradeBuffer).EmitSync("order", "c")
+	server := httptest.NewServer(panicking.DegradedHandler())
+	defer server.Close()
+	request, _ := http.NewRequest(http.MethodDelete, server.URL, nil)
+	if response, err := http.DefaultClient.Do(request); nil != err || http.StatusOK != response.StatusCode {
+		t.Fatalf("退出降级模式失败: %v", err)
+	}
+	panicking.WaitIdle(context.Background())
+	var panicErr *ListenerPanicError
+	if panicking.IsDegraded() || !errors.As(recovered, &panicErr) {
+		t.Fatalf("补发的panic未交给recoverer: %v", recovered)
+	}
+}
+
+// 带生命周期的监听
+type lifecycleListener func(string)
+
+var lifecycleLog []string
+
+func (lifecycleListener) Init(ctx context.Context) error {
+	lifecycleLog = append(lifecycleLog, "init")
+	return nil
+}
+
+func (lifecycleListener) Shutdown(ctx context.Context) error {
+	lifecycleLog = append(lifecycleLog, "shutdown")
+	return nil
+}
+
+func TestLifecycle(t *testing.T) {
+	lifecycleLog = nil
+	var listener lifecycleListener = func(arg string) {}
+
+	trigger := NewTrigger().On("a", listener).On("b", listener)
+	trigger.Off("a", listener)
+	if err := trigger.Close(context.Background()); nil != err {
+		t.Fatal(err)
+	}
+	if "init,init,shutdown,shutdown" != strings.Join(lifecycleLog, ",") {
+		t.Fatalf("生命周期回调顺序错误: %v", lifecycleLog)
+	}
+
+	t.Log("测试关闭后注册监听返回ErrClosed")
+	var rejected error
+	trigger.RecoverWith(func(event, listener interface{}, err error) { rejected = err }).On("order", listener)
+	if !errors.Is(rejected, ErrClosed) || 0 != trigger.GetListenerCount("order") || 4 != len(lifecycleLog) {
+		t.Fatalf("关闭后注册未被拒绝: %v", rejected)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	trigger := NewTrigger().On("happy", happy).On("sad", sad)
+	if report := trigger.Health(); StatusUp != report.Status || 2 != report.Listeners {
+		t.Fatalf("健康报告错误: %+v", report)
+	}
+
+	trigger.RegisterHealthCheck("bridge", func() error { return errors.New("连接断开") })
+	recorder := httptest.NewRecorder()
+	trigger.HealthHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if http.StatusServiceUnavailable != recorder.Code {
+		t.Fatalf("检查失败时应返回503, 实际为%d", recorder.Code)
+	}
+
+	t.Log("测试主备监听熔断时报告降级")
+	trigger = NewTrigger().RecoverWith(func(event, listener interface{}, err error) {}).
+		OnFailover("order.paid", "settle", func(id int) error { return errors.New("下游不可用") },
+			func(id int) error { return nil }, Failover{Threshold: 1, OpenFor: time.Minute})
+	if report := trigger.Health(); StatusUp != report.Status || 1 != len(report.Circuits) || report.Circuits[0].Open {
+		t.Fatalf("熔断前健康报告错误: %+v", report)
+	}
+	trigger.EmitSync("order.paid", 1)
+	report := trigger.Health()
+	if StatusDegraded != report.Status || !report.Circuits[0].Open || "settle" != report.Circuits[0].Key || report.Circuits[0].Until.IsZero() {
+		t.Fatalf("熔断后应降级: %+v", report)
+	}
+
+	t.Log("测试报告持久监听的消费延迟")
+	release := make(chan struct{})
+	trigger = NewTrigger().WithJournal(NewMemoryJournal(16)).
+		OnDurable("invoice", "mailer", func(id int) { <-release })
+	trigger.EmitSync("invoice", 1).EmitSync("invoice", 2).EmitSync("invoice", 3)
+	if lag := trigger.Health().Lag["mailer"]; lag < 2 {
+		t.Fatalf("消费延迟错误: %d", lag)
+	}
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for 0 != trigger.Health().Lag["mailer"] && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if lag := trigger.Health().Lag["mailer"]; 0 != lag {
+		t.Fatalf("消费完成后延迟应为0: %d", lag)
+	}
+	trigger.RemoveDurableListener("mailer")
+}
+
+func TestHeartbeat(t *testing.T) {
+	beats := make(chan Stats, 1)
+	trigger := NewTrigger().On(HeartbeatEvent, func(stats Stats) {
+		select {
+		case beats <- stats:
+		default:
+		}
+	})
+	trigger.StartHeartbeat(time.Millisecond)
+	defer trigger.StopHeartbeat()
+
+	select {
+	case stats := <-beats:
+		if 1 != stats.Listeners {
+			t.Fatalf("心跳统计错误: %+v", stats)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("未收到心跳")
+	}
+
+	t.Log("测试周期小于等于0时停止心跳")
+	trigger.StartHeartbeat(0).StartHeartbeat(-time.Second)
+	time.Sleep(5 * time.Millisecond)
+	select {
+	case <-beats:
+	default:
+	}
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case stats := <-beats:
+		t.Fatalf("停止后仍收到心跳: %+v", stats)
+	default:
+	}
+}
+
+func TestListenerStats(t *testing.T) {
+	slow := func() { time.Sleep(2 * time.Millisecond) }
+	broken := func() { panic("失败") }
+	trigger := NewTrigger().
+		RecoverWith(func(interface{}, interface{}, error) {}).
+		On("slow", slow).
+		On("broken", broken).
+		EmitSync("slow").EmitSync("slow").EmitSync("broken")
+
+	stats := trigger.ListenerStats("slow")
+	if 1 != len(stats) || 2 != stats[0].Calls || stats[0].MeanLatency < 2*time.Millisecond {
+		t.Fatalf("监听统计错误: %+v", stats)
+	}
+	stats = trigger.ListenerStats("broken")
+	if 1 != stats[0].Failures || nil == stats[0].LastError {
+		t.Fatalf("失败统计错误: %+v", stats)
+	}
+	if slowest := trigger.SlowestListeners(1); 1 != len(slowest) || "slow" != slowest[0].Event {
+		t.Fatalf("最慢监听错误: %+v", slowest)
+	}
+}
+
+func TestLeakDetection(t *testing.T) {
+	unused := make(chan ListenerStat, 1)
+	unhandled := make(chan interface{}, 1)
+	trigger := NewTrigger().
+		On(UnusedListenerEvent, func(stat ListenerStat) { unused <- stat }).
+		On(UnhandledEvent, func(event interface{}, count int) { unhandled <- event }).
+		On("never", happy)
+
+	trigger.EnableLeakDetection(10 * time.Millisecond)
+	defer trigger.DisableLeakDetection()
+	trigger.Emit("nobody")
+
+	for i := 0; i < 2; i++ {
+		select {
+		case stat := <-unused:
+			if "never" != stat.Event {
+				t.Fatalf("未使用监听错误: %+v", stat)
+			}
+		case event := <-unhandled:
+			if "nobody" != event {
+				t.Fatalf("无监听事件错误: %v", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("未检测到泄漏")
+		}
+	}
+
+	t.Log("测试窗口小于等于0时关闭泄漏检测")
+	trigger.EnableLeakDetection(0).EnableLeakDetection(-time.Second)
+	if trigger.leakDetect.Load() || nil != trigger.leakStop {
+		t.Fatalf("窗口小于等于0时应关闭泄漏检测")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var reported []error
+	trigger := NewTrigger().
+		RecoverWith(func(event interface{}, listener interface{}, err error) { reported = append(reported, err) }).
+		On("bad", "不是函数").
+		On("typed", happy).
+		EmitSync("typed", 1)
+
+	var registration *RegistrationError
+	var validation *ValidationError
+	if 2 != len(reported) || !errors.As(reported[0], &registration) || !errors.Is(reported[0], ErrNotFunction) {
+		t.Fatalf("注册错误类型错误: %v", reported)
+	}
+	if !errors.As(reported[1], &validation) || !errors.Is(reported[1], ErrArgumentMismatch) || "typed" != validation.Event {
+		t.Fatalf("参数错误类型错误: %v", reported)
+	}
+	if 0 != trigger.GetListenerCount("bad") {
+		t.Fatal("非函数类型不应被注册")
+	}
+}
+
+func TestPanicPolicy(t *testing.T) {
+	broken := func() { panic("失败") }
+
+	t.Log("测试恢复并移除")
+	trigger := NewTrigger().
+		RecoverWith(func(interface{}, interface{}, error) {}).
+		WithPanicPolicy(PanicRecoverAndRemove).
+		On("broken", broken).
+		EmitSync("broken")
+	if 0 != trigger.GetListenerCount("broken") {
+		t.Fatal("panic的监听未被移除")
+	}
+
+	t.Log("测试recoverer收到原始监听而不是包装函数")
+	var recovered interface{}
+	NewTrigger().
+		RecoverWith(func(event, listener interface{}, err error) { recovered = listener }).
+		Once("broken", broken).
+		EmitSync("broken")
+	if fn, ok := recovered.(func()); !ok || reflect.ValueOf(broken).Pointer() != reflect.ValueOf(fn).Pointer() {
+		t.Fatalf("recoverer收到的监听错误: %T", recovered)
+	}
+
+	t.Log("测试继续抛出")
+	defer func() {
+		if r := recover(); "失败" != r {
+			t.Fatalf("panic未被继续抛出: %v", r)
+		}
+	}()
+	NewTrigger().WithPanicPolicy(PanicPropagate).On("broken", broken).EmitSync("broken")
+}
+
+func TestEmitPanicBarrier(t *testing.T) {
+	var calls atomic.Int32
+	trigger := NewTrigger().
+		WithPanicPolicy(PanicPropagate).
+		On("broken", func() { panic("失败") }).
+		On("broken", func() { calls.Add(1) })
+
+	// 协程中的panic应在Emit调用方协程中抛出, 且不影响其他监听执行
+	defer func() {
+		if r := recover(); "失败" != r {
+			t.Fatalf("panic未在调用方协程中抛出: %v", r)
+		}
+		if 1 != calls.Load() {
+			t.Fatal("其他监听未执行")
+		}
+	}()
+	trigger.Emit("broken")
+}
+
+func TestOffQuiescence(t *testing.T) {
+	started := make(chan struct{})
+	var finished atomic.Bool
+	slow := func() {
+		close(started)
+		time.Sleep(20 * time.Millisecond)
+		finished.Store(true)
+	}
+	trigger := NewTrigger().On("slow", slow)
+
+	go trigger.Emit("slow")
+	<-started
+	trigger.Off("slow", slow)
+	if !finished.Load() {
+		t.Fatal("Off返回时监听仍在执行")
+	}
+
+	t.Log("测试移除后跳过尚未开始的调用")
+	var calls []string
+	second := func() { calls = append(calls, "second") }
+	trigger.
+		On("chain", func() {
+			calls = append(calls, "first")
+			trigger.Off("chain", second)
+		}).
+		On("chain", second).
+		EmitSync("chain")
+	if "first" != strings.Join(calls, ",") {
+		t.Fatalf("已移除的监听不应再被调用: %v", calls)
+	}
+
+	t.Log("测试监听在自身的回调中移除或替换自己")
+	var self func()
+	var selfCalls atomic.Int32
+	self = func() {
+		selfCalls.Add(1)
+		trigger.Off("self", self)
+	}
+	svc := &orderService{name: "svc"}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		trigger.On("self", self).EmitSync("self").EmitSync("self")
+		trigger.OnNamed("named", "v1", func() {
+			trigger.ReplaceListener("named", "v1", func() {})
+		}).EmitSync("named")
+		trigger.OnMethod("order", svc, "HandleOrder").
+			On("order", func(id string) { trigger.OffMethod("order", svc, "HandleOrder") }).
+			EmitSync("order", "1")
+		removed := make(chan struct{})
+		var async func()
+		async = func() {
+			trigger.Off("async", async)
+			close(removed)
+		}
+		trigger.On("async", async).Emit("async")
+		<-removed
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("在监听自身的回调中移除自己时死锁")
+	}
+	if 1 != selfCalls.Load() || 0 != trigger.GetListenerCount("self") || 1 != trigger.GetListenerCount("named") || 1 != trigger.GetListenerCount("order") || 0 != trigger.GetListenerCount("async") {
+		t.Fatalf("移除自己后的监听错误: %d", selfCalls.Load())
+	}
+}
+
+func TestTryOff(t *testing.T) {
+	trigger := NewTrigger().On("happy", happy).On("happy", happy)
+	if removed, err := trigger.TryOff("happy", happy); 2 != removed || nil != err {
+		t.Fatalf("删除结果错误: %d %v", removed, err)
+	}
+	if removed, err := trigger.TryOff("happy", happy); 0 != removed || !errors.Is(err, ErrListenerNotFound) {
+		t.Fatalf("未找到监听时应返回错误: %d %v", removed, err)
+	}
+}
+
+// 测试方法监听的服务
+type orderService struct {
+	name  string
+	calls []string
+}
+
+func (svc *orderService) HandleOrder(id string) {
+	svc.calls = append(svc.calls, svc.name+":"+id)
+}
+
+func TestMethodListener(t *testing.T) {
+	a, b := &orderService{name: "a"}, &orderService{name: "b"}
+	trigger := NewTrigger().
+		OnMethod("order", a, "HandleOrder").
+		OnMethod("order", b, "HandleOrder").
+		OffMethod("order", a, "HandleOrder").
+		EmitSync("order", "1")
+
+	if 0 != len(a.calls) || 1 != len(b.calls) {
+		t.Fatalf("只应移除a的监听: %v %v", a.calls, b.calls)
+	}
+	if 1 != trigger.GetListenerCount("order") {
+		t.Fatal("监听数量错误")
+	}
+
+	t.Log("测试接收者为nil时报告错误")
+	var reported []error
+	trigger.RecoverWith(func(event, listener interface{}, err error) { reported = append(reported, err) }).
+		OnMethod("order", nil, "HandleOrder").
+		OffMethod("order", nil, "HandleOrder")
+	if 2 != len(reported) || !errors.Is(reported[0], ErrNilReceiver) || !errors.Is(reported[1], ErrNilReceiver) {
+		t.Fatalf("接收者为nil时的错误: %v", reported)
+	}
+	if 1 != trigger.GetListenerCount("order") {
+		t.Fatal("接收者为nil时不应改变监听")
+	}
+}
+
+func TestNamedListener(t *testing.T) {
+	var calls []string
+	trigger := NewTrigger().
+		OnNamed("order", "email", func() { calls = append(calls, "v1") }).
+		OnNamed("order", "email", func() { calls = append(calls, "v2") }).
+		EmitSync("order")
+	if "v2" != strings.Join(calls, ",") {
+		t.Fatalf("同名监听应被替换: %v", calls)
+	}
+
+	trigger.OffNamed("order", "email")
+	if 0 != trigger.GetListenerCount("order") {
+		t.Fatal("按名称删除失败")
+	}
+}
+
+func TestReplaceListener(t *testing.T) {
+	var calls []string
+	trigger := NewTrigger().
+		On("order", func() { calls = append(calls, "audit") }).
+		ReplaceListener("order", "billing", func() { calls = append(calls, "v1") }).
+		On("order", func() { calls = append(calls, "log") }).
+		ReplaceListener("order", "billing", func() { calls = append(calls, "v2") }).
+		EmitSync("order")
+
+	if "audit,v2,log" != strings.Join(calls, ",") {
+		t.Fatalf("替换后应保持原位置: %v", calls)
+	}
+	if 3 != trigger.GetListenerCount("order") {
+		t.Fatal("监听数量错误")
+	}
+}
+
+func TestInsertListener(t *testing.T) {
+	var calls []string
+	NewTrigger().
+		On("order", func() { calls = append(calls, "business") }).
+		PrependListener("order", func() { calls = append(calls, "auth") }).
+		InsertListenerAt("order", 1, func() { calls = append(calls, "audit") }).
+		InsertListenerAt("order", 100, func() { calls = append(calls, "log") }).
+		EmitSync("order")
+
+	if "auth,audit,business,log" != strings.Join(calls, ",") {
+		t.Fatalf("执行顺序错误: %v", calls)
+	}
+}
+
+func TestBag(t *testing.T) {
+	bag := NewBag()
+	NewTrigger().
+		On("upload", func(b *Bag, raw string) { b.Set("parsed", strings.ToUpper(raw)) }).
+		On("upload", func(raw string) {}).
+		On("upload", func(b *Bag, raw string) {
+			parsed, _ := b.Get("parsed")
+			b.Set("persisted", parsed)
+		}).
+		EmitSync("upload", bag, "data")
+
+	if persisted, ok := bag.Get("persisted"); !ok || "DATA" != persisted {
+		t.Fatalf("Bag传递错误: %v", persisted)
+	}
+	if "parsed,persisted" != strings.Join(bag.Keys(), ",") {
+		t.Fatalf("Bag顺序错误: %v", bag.Keys())
+	}
+}
+
+func TestEmitIfListeners(t *testing.T) {
+	built := 0
+	lazy := func() []interface{} {
+		built++
+		return []interface{}{"payload"}
+	}
+
+	trigger := NewTrigger().EmitIfListeners("happy", lazy)
+	if 0 != built {
+		t.Fatal("没有监听时不应构造参数")
+	}
+	trigger.On("happy", happy).EmitIfListeners("happy", lazy)
+	if 1 != built {
+		t.Fatal("有监听时应构造参数")
+	}
+}
+
+func TestLazy(t *testing.T) {
+	var evaluated atomic.Int32
+	snapshot := Lazy(func() string {
+		evaluated.Add(1)
+		return "snapshot"
+	})
+
+	NewTrigger().Emit("nobody", snapshot)
+	if 0 != evaluated.Load() {
+		t.Fatal("没有监听时不应求值")
+	}
+
+	var received atomic.Int32
+	listener := func(arg string) {
+		if "snapshot" == arg {
+			received.Add(1)
+		}
+	}
+	NewTrigger().On("report", listener).On("report", listener).Emit("report", snapshot)
+	if 1 != evaluated.Load() || 2 != received.Load() {
+		t.Fatalf("每次触发应只求值一次: %d %d", evaluated.Load(), received.Load())
+	}
+
+	t.Log("测试拦截监听收到求值后的参数")
+	var intercepted []interface{}
+	NewTrigger().OnAny(func(event interface{}, arguments []interface{}) { intercepted = arguments }).
+		On("report", listener).EmitSync("report", snapshot)
+	if 2 != evaluated.Load() || 3 != received.Load() || "[snapshot]" != fmt.Sprint(intercepted) {
+		t.Fatalf("拦截监听的参数错误: %d %v", evaluated.Load(), intercepted)
+	}
+
+	t.Log("测试触发日志只在有监听时求值")
+	journal := NewMemoryJournal(8)
+	NewTrigger().WithJournal(journal).EmitSync("nobody", snapshot, 1).On("report", listener).EmitSync("report", snapshot)
+	records, _ := journal.Read(0, 8)
+	if 3 != evaluated.Load() || 2 != len(records) || "[<nil> 1]" != fmt.Sprint(records[0].Arguments) || "[snapshot]" != fmt.Sprint(records[1].Arguments) {
+		t.Fatalf("触发日志的延迟参数错误: %d %v", evaluated.Load(), records)
+	}
+}
+
+func TestOffOnce(t *testing.T) {
+	trigger := NewTrigger().Once("once", once).Off("once", once)
+	if 0 != trigger.GetListenerCount("once") {
+		t.Fatal("Once注册的监听应可用原回调函数移除")
+	}
+}
+
+func TestInvoker(t *testing.T) {
+	type direct func(string, uint8)
+	var directCalls int
+	RegisterInvoker(direct(nil), func(listener interface{}, arguments []interface{}) bool {
+		if 2 != len(arguments) {
+			return false
+		}
+		a0, ok := arguments[0].(string)
+		if !ok {
+			return false
+		}
+		a1, ok := arguments[1].(uint8)
+		if !ok {
+			return false
+		}
+		directCalls++
+		listener.(direct)(a0, a1)
+		return true
+	})
+
+	var received []string
+	trigger := NewTrigger().On("direct", direct(func(s string, n uint8) { received = append(received, fmt.Sprint(s, n)) }))
+	trigger.EmitSync("direct", "a", uint8(1)).EmitSync("direct", nil, uint8(2))
+	if 1 != directCalls || "a1,2" != strings.Join(received, ",") {
+		t.Fatalf("直接调用或反射回退错误: %d %v", directCalls, received)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	trigger := NewTrigger()
+	for i := 0; i < 10; i++ {
+		trigger.On(i, happy).Off(i, happy)
+	}
+	trigger.On("keep", happy).On("keep", sad).Off("keep", sad)
+	if 11 != len(trigger.loadRegistry()) {
+		t.Fatalf("整理前应保留空事件: %d", len(trigger.loadRegistry()))
+	}
+
+	trigger.Compact()
+	registry := trigger.loadRegistry()
+	if 1 != len(registry) || 1 != cap(registry["keep"]) {
+		t.Fatalf("整理结果错误: %d %d", len(registry), cap(registry["keep"]))
+	}
+}
+
+func TestWeightedListener(t *testing.T) {
+	var stable, canary atomic.Int32
+	trigger := NewTrigger().
+		OnWeighted("order", "billing", "v1", 95, func() { stable.Add(1) }).
+		OnWeighted("order", "billing", "v2", 5, func() { canary.Add(1) })
+
+	for i := 0; i < 2000; i++ {
+		trigger.EmitSync("order")
+	}
+	if 2000 != stable.Load()+canary.Load() {
+		t.Fatalf("每次触发应只执行一个版本: %d %d", stable.Load(), canary.Load())
+	}
+	if canary.Load() < 40 || canary.Load() > 200 {
+		t.Fatalf("灰度流量比例异常: %d", canary.Load())
+	}
+
+	stats := trigger.ListenerStats("order")
+	if "v2" != stats[1].Variant || uint64(canary.Load()) != stats[1].Calls {
+		t.Fatalf("统计应按版本区分: %+v", stats)
+	}
+}
+
+func TestShadowListener(t *testing.T) {
+	trigger := NewTrigger().
+		OnNamed("price", "calculator", func(amount int) int { return amount * 2 }).
+		OnShadowOf("price", "calculator", func(amount int) int { return amount + amount }).
+		OnShadowOf("price", "calculator", func(amount int) int { return amount * 3 }).
+		OnShadow("price", func(amount int) { panic("影子监听的panic不影响触发") })
+
+	trigger.EmitSync("price", 5)
+
+	var reports []ShadowReport
+	for i := 0; i < 100 && len(reports) < 3; i++ {
+		time.Sleep(time.Millisecond)
+		reports = trigger.ShadowReports()
+	}
+	if 3 != len(reports) {
+		t.Fatalf("影子监听报告数量错误: %d", len(reports))
+	}
+	if !reports[0].Match || reports[1].Match || 15 != reports[1].ShadowResults[0] {
+		t.Fatalf("对比结果错误: %+v", reports[:2])
+	}
+	if reports[2].Compared || nil == reports[2].ShadowError {
+		t.Fatalf("未对比的影子监听报告错误: %+v", reports[2])
+	}
+}
+
+func TestDebugTrace(t *testing.T) {
+	trigger := NewTrigger().
+		RecoverWith(func(interface{}, interface{}, error) {}).
+		WithDebugTrace(3)
+	trigger.
+		On("order.paid", func(id int) { trigger.Emit("order.created", id) }).
+		On("order.created", func(id int) error { return errors.New("库存不足") }).
+		On("order.created", func(id int) { panic("监听panic") })
+
+	trigger.EmitSync("user.login")
+	trigger.EmitSync("order.paid", 1)
+	trigger.EmitSync("order.refund")
+
+	var buf strings.Builder
+	if err := trigger.DebugTrace(&buf); nil != err {
+		t.Fatalf("输出调试记录失败: %v", err)
+	}
+	dump := buf.String()
+	t.Log(dump)
+
+	for _, want := range []string{"#2 order.paid sync", "#3 order.created async", "cause: #2 order.paid", "func3: error", "库存不足", "func4: panic", "(none)", "TestDebugTrace"} {
+		if !strings.Contains(dump, want) {
+			t.Fatalf("调试记录缺少%q", want)
+		}
+	}
+	if strings.Contains(dump, "user.login") {
+		t.Fatalf("超出数量的调试记录未被丢弃")
+	}
+	if nil == NewTrigger().DebugTrace(&buf) {
+		t.Fatalf("未开启调试记录时应返回错误")
+	}
+}
+
+func TestInterceptListener(t *testing.T) {
+	var seen, unhandled []interface{}
+	onAny := func(event interface{}, arguments []interface{}) { seen = append(seen, event, len(arguments)) }
+	onUnhandled := func(event interface{}, arguments []interface{}) { unhandled = append(unhandled, event) }
+	trigger := NewTrigger().
+		OnAny(onAny).
+		OnUnhandled(onUnhandled).
+		On("happy", func(string) {})
+
+	t.Log("测试拦截监听")
+	trigger.EmitSync("happy", "哈哈").Emit("sad", 1, 2)
+	trigger.EmitSync(HeartbeatEvent)
+	if fmt.Sprint(seen) != fmt.Sprint([]interface{}{"happy", 1, "sad", 2, HeartbeatEvent, 0}) {
+		t.Fatalf("拦截监听收到的触发错误: %v", seen)
+	}
+	if fmt.Sprint(unhandled) != "[sad]" {
+		t.Fatalf("无人处理监听收到的触发错误: %v", unhandled)
+	}
+
+	t.Log("测试删除拦截监听")
+	trigger.OffAny(onAny).OffUnhandled(onUnhandled).Emit("sad")
+	if 6 != len(seen) || 1 != len(unhandled) {
+		t.Fatalf("删除后拦截监听仍被执行")
+	}
+}
+
+func TestMatchListener(t *testing.T) {
+	var seen []interface{}
+	onOrder := func(event interface{}, arguments []interface{}) { seen = append(seen, event) }
+	pattern := regexp.MustCompile(`^order\.`)
+	trigger := NewTrigger().OnMatch(pattern, onOrder)
+
+	t.Log("测试正则监听")
+	trigger.EmitSync("order.paid").EmitSync("user.login").EmitSync("order.paid", 1).Emit(42)
+	if fmt.Sprint(seen) != "[order.paid order.paid]" {
+		t.Fatalf("正则监听收到的触发错误: %v", seen)
+	}
+
+	t.Log("测试删除正则监听")
+	trigger.OffMatch(regexp.MustCompile(`^order\.`), onOrder).EmitSync("order.paid")
+	if 2 != len(seen) {
+		t.Fatalf("删除后正则监听仍被执行")
+	}
+
+	var errs []error
+	NewTrigger().RecoverWith(func(event interface{}, listener interface{}, err error) { errs = append(errs, err) }).OnMatch(nil, onOrder)
+	if 1 != len(errs) || !errors.Is(errs[0], ErrInvalidPattern) {
+		t.Fatalf("空正则未报告错误: %v", errs)
+	}
+}
+
+func TestMultiTrigger(t *testing.T) {
+	local, remote := NewTrigger(), NewTrigger()
+	multi := NewMultiTrigger().
+		AddBus("local", local.AsEmitter(), nil).
+		AddBus("remote", remote.AsEmitter(), MatchPattern(regexp.MustCompile(`^order\.`)))
+
+	var calls []string
+	onOrder := func(id int) { calls = append(calls, fmt.Sprint("order", id)) }
+	onUser := func(id int) { calls = append(calls, fmt.Sprint("user", id)) }
+	multi.On("order.paid", onOrder).On("user.login", onUser)
+
+	t.Log("测试按路由规则注册")
+	if 1 != local.GetListenerCount("order.paid") || 1 != remote.GetListenerCount("order.paid") || 0 != remote.GetListenerCount("user.login") {
+		t.Fatalf("注册路由错误")
+	}
+	if fmt.Sprint(multi.Route("user.login")) != "[local]" {
+		t.Fatalf("路由错误: %v", multi.Route("user.login"))
+	}
+
+	t.Log("测试按路由规则触发")
+	multi.EmitSync("order.paid", 1).EmitSync("user.login", 2)
+	if fmt.Sprint(calls) != "[order1 order1 user2]" {
+		t.Fatalf("触发路由错误: %v", calls)
+	}
+
+	multi.Off("order.paid", onOrder)
+	if 0 != local.GetListenerCount("order.paid") || 0 != remote.GetListenerCount("order.paid") {
+		t.Fatalf("删除路由错误")
+	}
+}
+
+func TestReadOnlyView(t *testing.T) {
+	trigger := NewTrigger()
+	view := trigger.ReadOnlyView()
+
+	t.Log("测试只读视图订阅")
+	var got string
+	view.On("happy", func(arg string) { got = arg })
+	trigger.EmitSync("happy", "哈哈")
+	if "哈哈" != got {
+		t.Fatalf("只读视图的监听未执行")
+	}
+	type emitter interface {
+		Emit(event interface{}, arguments ...interface{}) *Trigger
+	}
+	if _, ok := view.(emitter); ok {
+		t.Fatalf("只读视图不应暴露Emit")
+	}
+
+	t.Log("测试WaitFor")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		trigger.Emit("done", 1, "ok")
+	}()
+	arguments, err := view.WaitFor(context.Background(), "done")
+	if nil != err || fmt.Sprint(arguments) != "[1 ok]" || 0 != trigger.GetListenerCount("done") {
+		t.Fatalf("WaitFor结果错误: %v %v", arguments, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var timeout *TimeoutError
+	if _, err := view.WaitFor(ctx, "never"); !errors.As(err, &timeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitFor超时错误: %v", err)
+	}
+	if 0 != trigger.GetListenerCount("never") {
+		t.Fatalf("WaitFor超时后未移除监听")
+	}
+}
+
+func TestEmitPolicy(t *testing.T) {
+	var denied []error
+	trigger := NewTrigger().
+		RecoverWith(func(event interface{}, listener interface{}, err error) { denied = append(denied, err) }).
+		WithEmitPolicy(func(source, event interface{}, arguments []interface{}) error {
+			if "plugin" == source && "admin.reset" == event {
+				return ErrEmitDenied
+			}
+			return nil
+		})
+
+	var calls int
+	trigger.On("admin.reset", func() { calls++ })
+
+	t.Log("测试权限策略")
+	trigger.Emit("admin.reset").EmitSyncAs("core", "admin.reset")
+	plugin := trigger.EmitterAs("plugin")
+	plugin.On("user.login", func() {}).Emit("admin.reset").EmitSync("admin.reset")
+	trigger.EmitAs("plugin", "admin.reset")
+
+	if 2 != calls || 3 != len(denied) {
+		t.Fatalf("权限策略执行错误: %d次调用, %d次拒绝", calls, len(denied))
+	}
+	var authorization *AuthorizationError
+	if !errors.As(denied[0], &authorization) || "plugin" != authorization.Source || !errors.Is(denied[0], ErrEmitDenied) {
+		t.Fatalf("拒绝错误类型错误: %v", denied[0])
+	}
+
+	t.Log("测试取消权限策略")
+	trigger.WithEmitPolicy(nil).EmitAs("plugin", "admin.reset")
+	if 3 != calls {
+		t.Fatalf("取消权限策略后未触发")
+	}
+}
+
+func TestTenant(t *testing.T) {
+	var reported []error
+	trigger := NewTrigger().
+		RecoverWith(func(event interface{}, listener interface{}, err error) { reported = append(reported, err) })
+
+	t.Log("测试租户隔离")
+	var calls int
+	acme := trigger.Tenant("acme", TenantQuota{MaxListeners: 1, Rate: 1, Burst: 2})
+	acme.On("order.paid", func() { calls++ }).On("order.paid", func() {})
+	trigger.Tenant("other", TenantQuota{}).EmitSync("order.paid")
+	trigger.EmitSync("order.paid")
+	if 0 != calls || 1 != len(reported) || !errors.Is(reported[0], ErrExceedMaxListeners) {
+		t.Fatalf("租户未隔离: %d次调用, %v", calls, reported)
+	}
+
+	t.Log("测试频率配额")
+	acme.EmitSync("order.paid").EmitSync("order.paid").EmitSync("order.paid")
+	if 2 != calls || !errors.Is(reported[len(reported)-1], ErrRateLimited) {
+		t.Fatalf("频率配额错误: %d次调用, %v", calls, reported)
+	}
+
+	t.Log("测试同时执行配额")
+	block := make(chan struct{})
+	busy := trigger.Tenant("busy", TenantQuota{MaxQueue: 1}).On("slow", func() { <-block })
+	go busy.EmitSync("slow")
+	for 0 == busy.Stats().Stats.InFlight {
+		time.Sleep(time.Millisecond)
+	}
+	busy.EmitSync("slow")
+	close(block)
+	if !errors.Is(reported[len(reported)-1], ErrQueueFull) {
+		t.Fatalf("同时执行配额错误: %v", reported)
+	}
+
+	stats := acme.Stats()
+	if "acme" != stats.Name || 1 != stats.RateLimited || 2 != stats.Stats.Emitted || 3 != len(trigger.TenantStats()) {
+		t.Fatalf("租户统计错误: %+v", stats)
+	}
+	if trigger.Tenant("acme", TenantQuota{}) != acme {
+		t.Fatalf("同名租户应返回同一总线")
+	}
+	if err := trigger.Close(context.Background()); nil != err || 0 != len(trigger.TenantStats()) {
+		t.Fatalf("关闭租户失败: %v", err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	type card struct {
+		Number string `trigger:"redact"`
+		CVV    int    `trigger:"redact"`
+		Brand  string
+	}
+	type payment struct {
+		User string
+		Card card
+	}
+
+	t.Log("测试结构体标签脱敏")
+	original := &payment{User: "yann", Card: card{Number: "4111111111111111", CVV: 123, Brand: "visa"}}
+	redacted := Redact(original).(*payment)
+	if "[REDACTED]" != redacted.Card.Number || 0 != redacted.Card.CVV || "visa" != redacted.Card.Brand || "yann" != redacted.User {
+		t.Fatalf("脱敏结果错误: %+v", redacted)
+	}
+	if "4111111111111111" != original.Card.Number {
+		t.Fatalf("脱敏不应修改原值")
+	}
+	if "plain" != Redact("plain") {
+		t.Fatalf("没有标签的值应原样返回")
+	}
+
+	t.Log("测试调试记录脱敏")
+	var got payment
+	trigger := NewTrigger().
+		WithDebugTrace(2).
+		WithRedactor(func(event interface{}, arguments []interface{}) []interface{} {
+			return append(arguments[:len(arguments):len(arguments)], "redactor")
+		}).
+		On("pay", func(p *payment) { got = *p })
+	trigger.EmitSync("pay", original).EmitSync("audit", *original)
+
+	var buf strings.Builder
+	trigger.DebugTrace(&buf)
+	if strings.Contains(buf.String(), "4111") || !strings.Contains(buf.String(), "redactor") || "4111111111111111" != got.Card.Number {
+		t.Fatalf("调试记录未脱敏: %s", buf.String())
+	}
+
+	t.Log("测试切片, 映射与接口中的结构体脱敏")
+	nested := map[string][]interface{}{"cards": {card{Number: "4111"}, &card{Number: "5500"}, "plain"}}
+	redactedMap := Redact(nested).(map[string][]interface{})
+	if "[REDACTED]" != redactedMap["cards"][0].(card).Number || "[REDACTED]" != redactedMap["cards"][1].(*card).Number || "plain" != redactedMap["cards"][2] {
+		t.Fatalf("嵌套脱敏结果错误: %+v", redactedMap)
+	}
+	if "4111" != nested["cards"][0].(card).Number {
+		t.Fatalf("嵌套脱敏不应修改原值")
+	}
+	if cards := Redact([2]card{{Number: "4111"}}).([2]card); "[REDACTED]" != cards[0].Number {
+		t.Fatalf("数组脱敏结果错误: %+v", cards)
+	}
+
+	t.Log("测试钩子中的参数脱敏")
+	var dispatched, listened []interface{}
+	NewTrigger().
+		WithHooks(Hooks{
+			BeforeDispatch: func(info DispatchInfo) { dispatched = info.Arguments },
+			AfterListener:  func(info ListenerInfo) { listened = info.Arguments },
+		}).
+		On("pay", func(p []payment) {}).
+		EmitSync("pay", []payment{*original})
+	if "[REDACTED]" != dispatched[0].([]payment)[0].Card.Number || "[REDACTED]" != listened[0].([]payment)[0].Card.Number {
+		t.Fatalf("钩子参数未脱敏: %+v %+v", dispatched, listened)
+	}
+
+	t.Log("测试触发日志文件中的参数脱敏")
+	path := t.TempDir() + "/trigger.log"
+	journal, err := OpenFileJournal(path)
+	if nil != err {
+		t.Fatalf("打开日志失败: %v", err)
+	}
+	defer journal.Close()
+	NewTrigger().WithJournal(journal).EmitSync("pay", []*payment{original})
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "4111") || !strings.Contains(string(data), "[REDACTED]") {
+		t.Fatalf("日志文件未脱敏: %s", data)
+	}
+
+	t.Log("测试触发日志文件经过脱敏函数")
+	NewTrigger().WithRedactor(func(event interface{}, arguments []interface{}) []interface{} {
+		masked := append([]interface{}(nil), arguments...)
+		masked[0] = "138****0000"
+		return masked
+	}).WithJournal(journal).EmitSync("sms", "13800000000")
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "13800000000") || !strings.Contains(string(data), "138****0000") {
+		t.Fatalf("日志文件未经过脱敏函数: %s", data)
+	}
+}
+
+func TestDerivedEvents(t *testing.T) {
+	type order struct {
+		ID    string
+		Total int `json:"total"`
+	}
+	var large, vip []string
+	trigger := NewTrigger().
+		On("order.large", func(o order) { large = append(large, o.ID) }).
+		On("order.vip", func(o order) { vip = append(vip, o.ID) })
+
+	t.Log("测试配置的派生规则")
+	rules, err := LoadRules([]byte(`[{"name":"large","event":"order.created","when":[{"field":"total","op":">","value":1000}],"emit":"order.large"}]`))
+	if nil != err {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+	trigger.AddRules(rules).
+		Derive("order.created", "order.vip", func(arguments []interface{}) bool { return "vip" == arguments[0].(order).ID })
+
+	trigger.EmitSync("order.created", order{ID: "a", Total: 5000}).
+		EmitSync("order.created", order{ID: "b", Total: 10}).
+		EmitSync("order.created", order{ID: "vip", Total: 10})
+	if fmt.Sprint(large) != "[a]" || fmt.Sprint(vip) != "[vip]" {
+		t.Fatalf("派生结果错误: %v %v", large, vip)
+	}
+
+	t.Log("测试删除规则")
+	trigger.RemoveRule("order.created", "large").EmitSync("order.created", order{ID: "c", Total: 5000})
+	if 1 != len(large) {
+		t.Fatalf("删除后规则仍生效")
+	}
+
+	var errs []error
+	NewTrigger().
+		RecoverWith(func(event interface{}, listener interface{}, err error) { errs = append(errs, err) }).
+		AddRule(Rule{Name: "bad", Event: "a", Emit: "b", When: []Condition{{Op: "~"}}})
+	if 1 != len(errs) || !errors.Is(errs[0], ErrInvalidRule) {
+		t.Fatalf("无效规则未报告: %v", errs)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	type request struct {
+		Latency int `json:"latency"`
+	}
+	summaries := make(chan Summary, 16)
+	trigger := NewTrigger().
+		On("metrics.request.tumbling", func(s Summary) { summaries <- s }).
+		Aggregate(Window{Name: "metrics.request.tumbling", Event: "request", Field: "latency", Size: 50 * time.Millisecond})
+	defer trigger.Close(context.Background())
+
+	t.Log("测试滚动窗口")
+	<-summaries
+	trigger.EmitSync("request", request{Latency: 10}).
+		EmitSync("request", request{Latency: 30}).
+		EmitSync("request", "没有字段")
+	s := <-summaries
+	if 3 != s.Count || 2 != s.Values || 40 != s.Sum || 20 != s.Avg || 10 != s.Min || 30 != s.Max {
+		t.Fatalf("滚动窗口汇总错误: %+v", s)
+	}
+	if s = <-summaries; 0 != s.Count {
+		t.Fatalf("滚动窗口未清空: %+v", s)
+	}
+
+	t.Log("测试滑动窗口")
+	trigger.StopAggregate("metrics.request.tumbling").
+		On("metrics.request.sliding", func(s Summary) { summaries <- s }).
+		Aggregate(Window{Name: "metrics.request.sliding", Event: "request", Size: 100 * time.Millisecond, Slide: 25 * time.Millisecond})
+	for len(summaries) > 0 {
+		<-summaries
+	}
+	<-summaries
+	trigger.EmitSync("request", request{Latency: 1})
+	counts := make([]int, 6)
+	for i := range counts {
+		counts[i] = (<-summaries).Count
+	}
+	if 1 != counts[0] || 0 != counts[5] {
+		t.Fatalf("滑动窗口汇总错误: %v", counts)
+	}
+
+	var errs []error
+	NewTrigger().
+		RecoverWith(func(event interface{}, listener interface{}, err error) { errs = append(errs, err) }).
+		Aggregate(Window{Name: "bad", Event: "request"})
+	if 1 != len(errs) || !errors.Is(errs[0], ErrInvalidWindow) {
+		t.Fatalf("无效窗口未报告: %v", errs)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	type payment struct {
+		ID     string `json:"id"`
+		Amount int
+	}
+	joined := make(chan Joined, 2)
+	timeouts := make(chan Joined, 2)
+	trigger := NewTrigger().
+		On("payment.completed", func(j Joined) { joined <- j }).
+		On("payment.timeout", func(j Joined) { timeouts <- j }).
+		AddJoin(Join{
+			Name:    "payment.completed",
+			Left:    "payment.initiated",
+			Right:   "payment.confirmed",
+			Key:     "id",
+			Window:  50 * time.Millisecond,
+			Timeout: "payment.timeout",
+		})
+
+	t.Log("测试关联成功")
+	trigger.EmitSync("payment.confirmed", payment{ID: "a"}).
+		EmitSync("payment.initiated", payment{ID: "b"}).
+		EmitSync("payment.initiated", payment{ID: "a", Amount: 100})
+	j := <-joined
+	if "a" != j.Key || 100 != j.Left[0].(payment).Amount || nil == j.Right {
+		t.Fatalf("关联结果错误: %+v", j)
+	}
+
+	t.Log("测试关联超时")
+	select {
+	case j = <-timeouts:
+		if "b" != j.Key || nil != j.Right {
+			t.Fatalf("超时结果错误: %+v", j)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("未触发超时事件")
+	}
+
+	t.Log("测试删除关联")
+	trigger.RemoveJoin("payment.completed")
+	if 0 != trigger.GetListenerCount("payment.initiated") || 0 != trigger.GetListenerCount("payment.confirmed") {
+		t.Fatalf("删除关联后监听未移除")
+	}
+}
+
+func TestExpression(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+		VIP  bool
+	}
+	type order struct {
+		Total int `json:"total"`
+		User  *user
+	}
+	arguments := []interface{}{order{Total: 1500, User: &user{Name: "张三", VIP: true}}, "web"}
+
+	t.Log("测试表达式求值")
+	for source, expected := range map[string]bool{
+		`total > 1000`:                               true,
+		`total >= 1500 && User.name == '张三'`:         true,
+		`total < 1000 or User.VIP`:                   true,
+		`not (User.VIP and $1 == "web")`:             false,
+		`$1 != "app" && missing == null`:             true,
+		`missing > 1 || !($0.total <= -1)`:           true,
+		`User.name == "李四" || User.nickname != null`: false,
+	} {
+		if actual := MustCompileExpression(source).Match(arguments); expected != actual {
+			t.Fatalf("表达式%s的结果错误: %v", source, actual)
+		}
+	}
+	if 1500 != MustCompileExpression("total").Eval(arguments).(int) {
+		t.Fatalf("表达式取值错误")
+	}
+
+	t.Log("测试无效表达式")
+	for _, source := range []string{``, `total >`, `(total > 1`, `total > 1 1`, `'abc`, `total # 1`, `$x.total`, `total > null`} {
+		if _, err := CompileExpression(source); !errors.Is(err, ErrInvalidExpression) {
+			t.Fatalf("表达式%q未报错: %v", source, err)
+		}
+	}
+
+	t.Log("测试规则中的表达式与投影")
+	projected := make(chan map[string]interface{}, 2)
+	rules, err := LoadRules([]byte(`[{"name":"vip","event":"order.created","where":"User.VIP && total > 1000","emit":"order.vip","select":{"name":"User.name","channel":"$1"}}]`))
+	if nil != err {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+	NewTrigger().
+		On("order.vip", func(m map[string]interface{}) { projected <- m }).
+		AddRules(rules).
+		EmitSync("order.created", order{Total: 10, User: &user{VIP: true}}, "app").
+		EmitSync("order.created", arguments...)
+	if m := <-projected; "张三" != m["name"] || "web" != m["channel"] || 0 != len(projected) {
+		t.Fatalf("投影结果错误: %v", m)
+	}
+}
+
+func TestLineage(t *testing.T) {
+	deadline := time.Now().Add(time.Minute)
+	lineages := make(chan Lineage, 4)
+	var skipped int32
+	trigger := NewTrigger()
+	trigger.On("order.created", func() {
+		trigger.EmitWith(Lineage{Deadline: deadline.Add(time.Hour)}, "order.paid")
+	}).On("order.paid", func() {
+		trigger.EmitSync("order.shipped")
+	}).On("order.shipped", func() {
+		lineage, _ := trigger.Lineage()
+		lineages <- lineage
+	}).On("order.shipped", func() {
+		atomic.AddInt32(&skipped, 1)
+	})
+
+	t.Log("测试继承属性沿触发链传递")
+	trigger.WithInheritance(true).EnterDegraded(DegradeSkip).
+		EmitWith(Lineage{Priority: 1, Deadline: deadline, CorrelationID: "c-1"}, "order.created")
+	if lineage := <-lineages; 1 != lineage.Priority || !deadline.Equal(lineage.Deadline) || "c-1" != lineage.CorrelationID {
+		t.Fatalf("继承属性错误: %+v", lineage)
+	}
+	if 1 != atomic.LoadInt32(&skipped) {
+		t.Fatalf("高优先级的触发在降级模式下被过滤")
+	}
+
+	t.Log("测试普通触发在降级模式下被过滤")
+	trigger.ExitDegraded().EmitSync("order.shipped")
+	<-lineages
+	trigger.EnterDegraded(DegradeSkip).EmitSync("order.shipped")
+	if 2 != atomic.LoadInt32(&skipped) || 0 != len(lineages) {
+		t.Fatalf("普通触发在降级模式下未被过滤")
+	}
+
+	t.Log("测试关闭继承")
+	trigger.ExitDegraded().WithInheritance(false).EmitWith(Lineage{CorrelationID: "c-2"}, "order.paid")
+	if lineage, ok := trigger.Lineage(); ok || "" != (<-lineages).CorrelationID {
+		t.Fatalf("关闭继承后仍传递: %+v", lineage)
+	}
+}
+
+func TestEmitGroup(t *testing.T) {
+	var calls int32
+	insufficient := errors.New("库存不足")
+	trigger := NewTrigger().
+		On("order.created", func(id int) { atomic.AddInt32(&calls, 1) }).
+		On("order.created", func(id int) error { return insufficient })
+
+	t.Log("测试监听的错误交给协程组")
+	g := new(ListenerGroup)
+	trigger.EmitGroup(g, "order.created", 1)
+	var dispatch *DispatchError
+	if err := g.Wait(); !errors.Is(err, insufficient) || !errors.As(err, &dispatch) || 1 != atomic.LoadInt32(&calls) {
+		t.Fatalf("协程组错误: %v", err)
+	}
+
+	t.Log("测试监听panic")
+	g = new(ListenerGroup)
+	NewTrigger().
+		RecoverWith(func(event interface{}, listener interface{}, err error) {}).
+		On("order.created", func() { panic("崩溃") }).
+		EmitGroup(g, "order.created")
+	if err := g.Wait(); !errors.As(err, &dispatch) {
+		t.Fatalf("panic未交给协程组: %v", err)
+	}
+
+	t.Log("测试没有权限")
+	g = new(ListenerGroup)
+	trigger.WithEmitPolicy(func(source, event interface{}, arguments []interface{}) error { return ErrEmitDenied }).
+		EmitGroup(g, "order.created", 2)
+	var authorization *AuthorizationError
+	if err := g.Wait(); !errors.As(err, &authorization) || 1 != atomic.LoadInt32(&calls) {
+		t.Fatalf("没有权限时错误: %v", err)
+	}
+
+	t.Log("测试严格模式下未登记的事件")
+	g = new(ListenerGroup)
+	strict := NewTrigger().WithStrictEvents(true).On("order.created", func(id int) { atomic.AddInt32(&calls, 1) })
+	strict.EmitGroup(g, "order.created", 3)
+	if err := g.Wait(); !errors.Is(err, ErrUnregisteredEvent) || 1 != atomic.LoadInt32(&calls) {
+		t.Fatalf("未登记的事件错误: %v", err)
+	}
+
+	t.Log("测试钩子在最后一个监听结束后调用")
+	var before, after int32
+	hooked := NewTrigger().
+		WithHooks(Hooks{
+			BeforeDispatch: func(info DispatchInfo) { atomic.AddInt32(&before, 1) },
+			AfterDispatch:  func(info DispatchInfo) { atomic.AddInt32(&after, 1) },
+		}).
+		On("order.created", func(id int) { atomic.AddInt32(&calls, 1) })
+	g = new(ListenerGroup)
+	hooked.EmitGroup(g, "order.created", 4)
+	if err := g.Wait(); nil != err || 1 != atomic.LoadInt32(&before) || 1 != atomic.LoadInt32(&after) || 2 != atomic.LoadInt32(&calls) {
+		t.Fatalf("钩子调用错误: %v %d %d", err, before, after)
+	}
+
+	t.Log("测试互斥组在协程组的监听结束前保持")
+	var running, overlapped int32
+	exclusive := func(id int) {
+		if 1 != atomic.AddInt32(&running, 1) {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	}
+	mutexed := NewTrigger().WithMutexGroup("order", "order.created", "order.paid").
+		On("order.created", exclusive).On("order.paid", exclusive)
+	g = new(ListenerGroup)
+	mutexed.EmitGroup(g, "order.created", 5)
+	mutexed.Emit("order.paid", 5)
+	if err := g.Wait(); nil != err || 0 != atomic.LoadInt32(&overlapped) {
+		t.Fatalf("互斥组的触发重叠执行: %v", err)
+	}
+
+	t.Log("测试过期的触发交给协程组")
+	quiet := func(event, listener interface{}, err error) {}
+	expiring := NewTrigger().RecoverWith(quiet).On("order.created", func(id int) { atomic.AddInt32(&calls, 1) })
+	leave := expiring.enterLineage(&Lineage{Deadline: time.Now().Add(-time.Second)})
+	g = new(ListenerGroup)
+	expiring.EmitGroup(g, "order.created", 6)
+	leave()
+	if err := g.Wait(); !errors.Is(err, ErrExpired) || 2 != atomic.LoadInt32(&calls) {
+		t.Fatalf("过期的触发错误: %v", err)
+	}
+
+	t.Log("测试过载丢弃的触发交给协程组")
+	g = new(ListenerGroup)
+	NewTrigger().SetEventClass("order.created", ClassBestEffort).WithLoadShedding(SheddingPolicy{MaxHeapBytes: 1}).
+		On("order.created", func(id int) { atomic.AddInt32(&calls, 1) }).
+		EmitGroup(g, "order.created", 7)
+	if err := g.Wait(); !errors.Is(err, ErrShed) || 2 != atomic.LoadInt32(&calls) {
+		t.Fatalf("过载丢弃的触发错误: %v", err)
+	}
+
+	// 阻塞一次同步触发, 在其执行期间通过协程组触发
+	blocked := func(limited *Trigger) error {
+		release := make(chan struct{})
+		limited.RecoverWith(quiet).On("order.created", func(id int) {
+			if 0 == id {
+				<-release
+			}
+		})
+		done := make(chan struct{})
+		go func() {
+			limited.EmitSync("order.created", 0)
+			close(done)
+		}()
+		for 1 != limited.inFlight.Load() {
+			time.Sleep(time.Millisecond)
+		}
+		g := new(ListenerGroup)
+		limited.EmitGroup(g, "order.created", 8)
+		close(release)
+		<-done
+		return g.Wait()
+	}
+	t.Log("测试超出内存预算的触发交给协程组")
+	budgeted := NewTrigger().WithMemoryBudget(1, func(event interface{}, arguments []interface{}) int { return 1 })
+	if err := blocked(budgeted); !errors.Is(err, ErrMemoryBudget) {
+		t.Fatalf("超出内存预算的触发错误: %v", err)
+	}
+	t.Log("测试超出并发上限的触发交给协程组")
+	limited := NewTrigger().WithAdaptiveConcurrency("order.created", AdaptiveConcurrency{Initial: 1, Max: 1})
+	if err := blocked(limited); !errors.Is(err, ErrConcurrencyLimit) {
+		t.Fatalf("超出并发上限的触发错误: %v", err)
+	}
+}
+
+// 测试用的业务错误
+type stockError struct {
+	sku string
+}
+
+func (e *stockError) Error() string {
+	return "库存不足: " + e.sku
+}
+
+func TestListenerPanicError(t *testing.T) {
+	var reported error
+	trigger := NewTrigger().
+		RecoverWith(func(event interface{}, listener interface{}, err error) { reported = err }).
+		On("order.created", func() { panic(&stockError{sku: "A1"}) })
+
+	t.Log("测试panic转换为可识别的错误")
+	trigger.EmitSync("order.created")
+	var panicErr *ListenerPanicError
+	var stock *stockError
+	if !errors.As(reported, &panicErr) || !errors.As(reported, &stock) || "A1" != stock.sku {
+		t.Fatalf("panic错误无法识别: %v", reported)
+	}
+	if !strings.Contains(panicErr.Name, "TestListenerPanicError") || !strings.Contains(string(panicErr.Stack), "trigger_test.go") {
+		t.Fatalf("panic错误缺少监听或堆栈: %s", panicErr.Name)
+	}
+
+	t.Log("测试非error的panic值")
+	trigger.On("order.paid", func() { panic(42) }).EmitSync("order.paid")
+	if !errors.As(reported, &panicErr) || 42 != panicErr.Value || nil != errors.Unwrap(panicErr) {
+		t.Fatalf("panic原始值错误: %v", reported)
+	}
+}
+
+func TestEmitTTL(t *testing.T) {
+	expiries := make(chan Expiry, 4)
+	var calls int32
+	trigger := NewTrigger().
+		On(ExpiredEvent, func(e Expiry) { expiries <- e }).
+		On("presence", func(user string) { atomic.AddInt32(&calls, 1) })
+
+	t.Log("测试有效期内正常分发")
+	trigger.EmitTTL(time.Minute, "presence", "张三")
+	if 1 != atomic.LoadInt32(&calls) {
+		t.Fatalf("有效期内未分发")
+	}
+
+	t.Log("测试已过期的触发被丢弃")
+	trigger.EmitTTL(-time.Second, "presence", "李四")
+	if e := <-expiries; "presence" != e.Event || nil != e.Listener || "李四" != e.Arguments[0] || 1 != atomic.LoadInt32(&calls) {
+		t.Fatalf("过期触发错误: %+v", e)
+	}
+
+	t.Log("测试降级缓存中过期的调用")
+	trigger.EnterDegraded(DegradeBuffer).
+		EmitTTL(10*time.Millisecond, "presence", "王五").
+		Emit("presence", "赵六")
+	time.Sleep(20 * time.Millisecond)
+	trigger.ExitDegraded()
+	if e := <-expiries; "王五" != e.Arguments[0] || nil == e.Listener || 2 != atomic.LoadInt32(&calls) {
+		t.Fatalf("降级缓存过期错误: %+v", e)
+	}
+}
+
+func TestEmitDelayed(t *testing.T) {
+	reminders := make(chan string, 4)
+	listener := func(user string) { reminders <- user }
+
+	t.Log("测试延迟触发")
+	trigger := NewTrigger().On("reminder", listener).EmitDelayed("reminder", 10*time.Millisecond, "张三")
+	if 1 != len(trigger.ScheduledEmits()) {
+		t.Fatalf("计划触发数量错误")
+	}
+	if user := <-reminders; "张三" != user || 0 != len(trigger.ScheduledEmits()) {
+		t.Fatalf("延迟触发错误: %s", user)
+	}
+
+	t.Log("测试持久化后重启恢复")
+	path := t.TempDir() + "/schedule.json"
+	trigger = NewTrigger().On("reminder", listener).
+		WithScheduleStore(NewFileScheduleStore(path)).
+		EmitDelayed("reminder", time.Hour, "李四").
+		Schedule(Scheduled{ID: "cancelled", Event: "reminder", Arguments: []interface{}{"赵六"}, At: time.Now().Add(time.Hour)}).
+		CancelScheduled("cancelled")
+	trigger.Close(context.Background())
+
+	restored := NewTrigger().On("reminder", listener).WithScheduleStore(NewFileScheduleStore(path))
+	entries := restored.ScheduledEmits()
+	if 1 != len(entries) || "reminder" != entries[0].Event || "李四" != entries[0].Arguments[0] {
+		t.Fatalf("恢复的计划触发错误: %+v", entries)
+	}
+
+	t.Log("测试已过触发时间的计划触发立即触发")
+	restored.Schedule(Scheduled{ID: entries[0].ID, Event: "reminder", Arguments: []interface{}{"王五"}, At: time.Now().Add(-time.Hour)})
+	if user := <-reminders; "王五" != user {
+		t.Fatalf("过期计划触发错误: %s", user)
+	}
+	// 触发完成后才从存储中删除, 保证至少执行一次
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		entries, _ := NewFileScheduleStore(path).Load()
+		if 0 == len(entries) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("执行后未从存储中删除: %+v", entries)
+		}
+	}
+}
+
+func TestRecurringSchedule(t *testing.T) {
+	var ticks int32
+	trigger := NewTrigger().On("tick", func() { atomic.AddInt32(&ticks, 1) })
+	defer trigger.Close(context.Background())
+
+	t.Log("测试周期触发")
+	trigger.EmitEvery("tick", 5*time.Millisecond)
+	for 3 > atomic.LoadInt32(&ticks) {
+		time.Sleep(time.Millisecond)
+	}
+	trigger.CancelScheduled(trigger.ScheduledEmits()[0].ID)
+	stopped := atomic.LoadInt32(&ticks)
+	time.Sleep(20 * time.Millisecond)
+	if stopped+1 < atomic.LoadInt32(&ticks) {
+		t.Fatalf("取消后仍在触发")
+	}
+
+	t.Log("测试停机期间错过触发的补发策略")
+	path := t.TempDir() + "/schedule.json"
+	store := NewFileScheduleStore(path)
+	start := time.Now().Add(-3*time.Hour - 30*time.Minute)
+	for id, policy := range map[string]CatchUpPolicy{"skip": CatchUpSkip, "once": CatchUpOnce, "all": CatchUpAll} {
+		store.Save(Scheduled{ID: id, Event: "report", Arguments: []interface{}{id}, At: start, Every: time.Hour, CatchUp: policy})
+	}
+	store.Save(Scheduled{ID: "expired", Event: "report", Arguments: []interface{}{"expired"}, At: start, CatchUp: CatchUpSkip})
+
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	restored := NewTrigger().On("report", func(id string) {
+		mu.Lock()
+		counts[id]++
+		mu.Unlock()
+	}).WithScheduleStore(store)
+	defer restored.Close(context.Background())
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		mu.Lock()
+		done := 5 == counts["once"]+counts["all"]
+		mu.Unlock()
+		if done {
+			break
+		}
+	}
+	mu.Lock()
+	if 0 != counts["skip"] || 1 != counts["once"] || 4 != counts["all"] || 0 != counts["expired"] {
+		t.Fatalf("补发次数错误: %v", counts)
+	}
+	mu.Unlock()
+
+	entries, _ := store.Load()
+	if 3 != len(entries) {
+		t.Fatalf("存储中的计划触发错误: %+v", entries)
+	}
+	for _, entry := range entries {
+		if !entry.At.Equal(start.Add(4 * time.Hour)) {
+			t.Fatalf("下一次触发时间错误: %+v", entry)
+		}
+	}
+
+	t.Log("测试以固定ID重复注册保留触发时间")
+	restored.Schedule(Scheduled{ID: "all", Event: "report", Arguments: []interface{}{"all"}, Every: time.Hour, CatchUp: CatchUpAll})
+	for _, entry := range restored.ScheduledEmits() {
+		if "all" == entry.ID && !entry.At.Equal(start.Add(4*time.Hour)) {
+			t.Fatalf("重复注册后触发时间错误: %+v", entry)
+		}
+	}
+}
+
+// 保存较慢的计划触发存储, 每次保存开始时通知
+type slowScheduleStore struct {
+	ScheduleStore
+	saving chan struct{}
+}
+
+func (store *slowScheduleStore) Save(entry Scheduled) error {
+	select {
+	case store.saving <- struct{}{}:
+	default:
+	}
+	time.Sleep(20 * time.Millisecond)
+	return store.ScheduleStore.Save(entry)
+}
+
+func TestCancelRecurringSchedule(t *testing.T) {
+	var rejected error
+	store := &slowScheduleStore{ScheduleStore: NewFileScheduleStore(t.TempDir() + "/schedule.json"), saving: make(chan struct{}, 1)}
+	trigger := NewTrigger().RecoverWith(func(event, listener interface{}, err error) { rejected = err }).
+		WithScheduleStore(store)
+	defer trigger.Close(context.Background())
+
+	t.Log("测试周期需大于0")
+	trigger.EmitEvery("tick", 0)
+	if nil == rejected || 0 != len(trigger.ScheduledEmits()) {
+		t.Fatalf("周期为0时应报告错误: %v", rejected)
+	}
+
+	t.Log("测试周期触发保存下一次触发时间时取消")
+	trigger.Schedule(Scheduled{ID: "tick", Event: "tick", Every: 5 * time.Millisecond})
+	<-store.saving
+	<-store.saving
+	trigger.CancelScheduled("tick")
+	time.Sleep(30 * time.Millisecond)
+	if entries, err := store.Load(); nil != err || 0 != len(entries) || 0 != len(trigger.ScheduledEmits()) {
+		t.Fatalf("取消后计划触发被重新保存: %+v %v", entries, err)
+	}
+}
+
+func TestWaitIdle(t *testing.T) {
+	release := make(chan struct{})
+	var done int32
+	trigger := NewTrigger().
+		On("sync", func() {
+			<-release
+			atomic.AddInt32(&done, 1)
+		}).
+		OnShadow("sync", func() { time.Sleep(20 * time.Millisecond) })
+
+	t.Log("测试空闲时立即返回")
+	if err := trigger.WaitIdle(context.Background()); nil != err {
+		t.Fatalf("空闲时等待失败: %v", err)
+	}
+
+	t.Log("测试等待触发与影子监听结束")
+	go trigger.Emit("sync")
+	for 0 == trigger.Stats().InFlight {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var timeout *TimeoutError
+	if err := trigger.WaitIdle(ctx); !errors.As(err, &timeout) {
+		t.Fatalf("上下文结束时未返回超时: %v", err)
+	}
+	close(release)
+	if err := trigger.WaitIdle(context.Background()); nil != err || 1 != atomic.LoadInt32(&done) || 1 != len(trigger.ShadowReports()) {
+		t.Fatalf("等待空闲错误: %v", err)
+	}
+}
+
+func TestRunAfter(t *testing.T) {
+	var order []string
+	record := func(name string) func() {
+		return func() { order = append(order, name) }
+	}
+	var errs []error
+	trigger := NewTrigger().
+		RecoverWith(func(event interface{}, listener interface{}, err error) { errs = append(errs, err) }).
+		OnNamed("order.created", "notify", record("notify")).
+		On("order.created", record("audit")).
+		OnNamed("order.created", "charge", record("charge")).
+		RunAfter("order.created", "notify", "charge", "stock").
+		OnNamed("order.created", "stock", record("stock"))
+
+	t.Log("测试按声明的顺序执行")
+	trigger.EmitSync("order.created")
+	if "[audit charge stock notify]" != fmt.Sprint(order) {
+		t.Fatalf("执行顺序错误: %v", order)
+	}
+
+	t.Log("测试循环依赖")
+	trigger.RunAfter("order.created", "stock", "notify")
+	if 1 != len(errs) || !errors.Is(errs[0], ErrDependencyCycle) || !strings.Contains(errs[0].Error(), "stock -> notify -> stock") {
+		t.Fatalf("循环依赖未报告: %v", errs)
+	}
+
+	t.Log("测试清除顺序后保持当前顺序")
+	order = nil
+	trigger.ClearRunAfter("order.created", "notify").OnNamed("order.created", "refund", record("refund")).EmitSync("order.created")
+	if "[audit charge stock notify refund]" != fmt.Sprint(order) {
+		t.Fatalf("清除后顺序错误: %v", order)
+	}
+}
+
+func TestOnceWhen(t *testing.T) {
+	var calls int32
+	succeeded := func(arguments []interface{}) bool { return arguments[0].(bool) }
+	trigger := NewTrigger().OnceWhen("sync.done", succeeded, func(ok bool, names ...string) {
+		atomic.AddInt32(&calls, 1)
+	})
+
+	t.Log("测试条件不满足时保留监听")
+	trigger.EmitSync("sync.done", false)
+	if 0 != atomic.LoadInt32(&calls) || 1 != trigger.GetListenerCount("sync.done") {
+		t.Fatalf("条件不满足时执行或移除了监听")
+	}
+
+	t.Log("测试并发触发只执行一次")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			trigger.Emit("sync.done", true, "a", "b")
+		}()
+	}
+	wg.Wait()
+	if 1 != atomic.LoadInt32(&calls) || 0 != trigger.GetListenerCount("sync.done") {
+		t.Fatalf("执行次数错误: %d", calls)
+	}
+
+	t.Log("测试可变参数展开")
+	var seen []interface{}
+	NewTrigger().
+		OnceWhen("sync.done", func(arguments []interface{}) bool { seen = arguments; return true }, func(ok bool, names ...string) {}).
+		EmitSync("sync.done", true, "a", "b")
+	if "[true a b]" != fmt.Sprint(seen) {
+		t.Fatalf("条件参数错误: %v", seen)
+	}
+}
+
+func TestConflation(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var versions []int
+	trigger := NewTrigger().SetConflation("cache.invalidate", true).
+		On("cache.invalidate", func(version int) {
+			mu.Lock()
+			versions = append(versions, version)
+			mu.Unlock()
+			if 1 == version {
+				close(started)
+				<-release
+			}
+		})
+
+	t.Log("测试执行期间到达的触发只保留最新值")
+	go trigger.Emit("cache.invalidate", 1)
+	<-started
+	for version := 2; version <= 5; version++ {
+		trigger.Emit("cache.invalidate", version)
+	}
+	close(release)
+	trigger.WaitIdle(context.Background())
+	if "[1 5]" != fmt.Sprint(versions) {
+		t.Fatalf("合并结果错误: %v", versions)
+	}
+
+	t.Log("测试关闭合并模式")
+	versions = nil
+	trigger.SetConflation("cache.invalidate", false).EmitSync("cache.invalidate", 6).EmitSync("cache.invalidate", 7)
+	if "[6 7]" != fmt.Sprint(versions) {
+		t.Fatalf("关闭合并后结果错误: %v", versions)
+	}
+}
+
+func TestSerialListener(t *testing.T) {
+	var active, overlapped int32
+	total := 0
+	trigger := NewTrigger().OnSerial("counter", func(n int) {
+		if 1 != atomic.AddInt32(&active, 1) {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		// 串行执行时不加锁也不会产生数据竞争
+		total += n
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&active, -1)
+	})
+
+	t.Log("测试同一监听不会并发执行")
+	var wg sync.WaitGroup
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			if 0 == n%2 {
+				trigger.Emit("counter", n)
+			} else {
+				trigger.EmitSync("counter", n)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if 0 != overlapped || 55 != total {
+		t.Fatalf("串行执行错误: %d %d", overlapped, total)
+	}
+
+	t.Log("测试串行模式")
+	var concurrent, peak int32
+	serial := NewTrigger().WithSerialListeners(true)
+	for i := 0; i < 2; i++ {
+		serial.OnNamed("work", fmt.Sprint(i), func() {
+			for n, p := atomic.AddInt32(&concurrent, 1), atomic.LoadInt32(&peak); n > p && !atomic.CompareAndSwapInt32(&peak, p, n); p = atomic.LoadInt32(&peak) {
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&concurrent, -1)
+		})
+	}
+	serial.Emit("work")
+	if 2 != atomic.LoadInt32(&peak) {
+		t.Fatalf("不同监听未并行执行: %d", peak)
+	}
+}
+
+func TestRequestReply(t *testing.T) {
+	trigger := NewTrigger().On("price.query", func(reply *ReplyAddress, sku string) {
+		if "A1" == sku {
+			reply.Reply(context.Background(), sku, 100)
+		}
+	})
+
+	t.Log("测试请求与回复")
+	reply, err := trigger.Request(context.Background(), "price.query", "A1")
+	if nil != err || "[A1 100]" != fmt.Sprint(reply) {
+		t.Fatalf("回复错误: %v %v", reply, err)
+	}
+
+	t.Log("测试没有回复时超时")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var timeout *TimeoutError
+	if _, err := trigger.Request(ctx, "price.query", "B2"); !errors.As(err, &timeout) {
+		t.Fatalf("没有回复时未超时: %v", err)
+	}
+	for event := range trigger.loadRegistry() {
+		if name, ok := event.(string); ok && strings.HasPrefix(name, ReplyEventPrefix) {
+			t.Fatalf("请求结束后仍保留回复事件: %s", name)
+		}
+	}
+
+	t.Log("测试触发阻塞时仍按上下文超时")
+	release := make(chan struct{})
+	trigger.WithMutexGroup("price", "price.query", "price.sync").On("price.sync", func() { <-release })
+	go trigger.EmitSync("price.sync")
+	for 0 == trigger.inFlight.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := trigger.Request(ctx, "price.query", "A1"); !errors.As(err, &timeout) {
+		t.Fatalf("触发阻塞时未超时: %v", err)
+	}
+	close(release)
+
+	t.Log("测试没有回复地址")
+	if err := (&ReplyAddress{CorrelationID: "1"}).Reply(context.Background(), 1); !errors.Is(err, ErrNoReplyTarget) {
+		t.Fatalf("没有回复地址时未报错: %v", err)
+	}
+}
+
+func TestPollHandler(t *testing.T) {
+	type login struct {
+		User     string
+		Password string `trigger:"redact"`
+	}
+	trigger := NewTrigger()
+	server := httptest.NewServer(trigger.PollHandler())
+	defer server.Close()
+	poll := func(query string) (result pollResult, status int) {
+		response, err := http.Get(server.URL + "?" + query)
+		if nil != err {
+			t.Fatalf("请求失败: %v", err)
+		}
+		defer response.Body.Close()
+		json.NewDecoder(response.Body).Decode(&result)
+		return result, response.StatusCode
+	}
+
+	t.Log("测试未开启触发日志")
+	if _, status := poll(""); http.StatusNotFound != status {
+		t.Fatalf("未开启日志时状态码错误: %d", status)
+	}
+
+	t.Log("测试读取已有记录")
+	trigger.WithJournal(NewMemoryJournal(2), "login", "logout").
+		Emit("login", login{User: "张三", Password: "123456"}).
+		Emit("ignored").
+		Emit("logout", "张三")
+	result, _ := poll("cursor=0")
+	if 2 != len(result.Records) || 2 != result.Cursor || strings.Contains(fmt.Sprint(result.Records[0].Arguments), "123456") {
+		t.Fatalf("记录错误: %+v", result)
+	}
+
+	t.Log("测试超出容量后丢弃最早的记录")
+	trigger.Emit("login", login{User: "李四"})
+	if result, _ = poll("cursor=0&limit=1"); 1 != len(result.Records) || 2 != result.Records[0].Seq {
+		t.Fatalf("容量限制错误: %+v", result)
+	}
+
+	t.Log("测试等待新记录")
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		trigger.Emit("logout", "李四")
+	}()
+	if result, _ = poll("cursor=3&timeout=5s"); 1 != len(result.Records) || 4 != result.Cursor || "logout" != result.Records[0].Event {
+		t.Fatalf("等待新记录错误: %+v", result)
+	}
+
+	t.Log("测试等待超时")
+	if result, _ = poll("cursor=4&timeout=10ms"); 0 != len(result.Records) || 4 != result.Cursor {
+		t.Fatalf("超时结果错误: %+v", result)
+	}
+}
+
+func TestConsumer(t *testing.T) {
+	t.Log("测试未开启日志")
+	if _, err := NewTrigger().NewConsumer("billing", 0); !errors.Is(err, ErrNoJournal) {
+		t.Fatalf("未开启日志时未报错: %v", err)
+	}
+
+	path := t.TempDir() + "/journal.log"
+	journal, err := OpenFileJournal(path)
+	if nil != err {
+		t.Fatalf("打开日志失败: %v", err)
+	}
+	trigger := NewTrigger().WithJournal(journal)
+	for i := 1; i <= 5; i++ {
+		trigger.Emit("order.created", i)
+	}
+
+	t.Log("测试消费组各自消费")
+	billing, _ := trigger.NewConsumer("billing", 0)
+	audit, _ := trigger.NewConsumer("audit", 4)
+	records, _ := billing.Fetch(2)
+	if 2 != len(records) || 2 != billing.Position() || 1 != records[0].Arguments[0].(int) {
+		t.Fatalf("billing读取错误: %+v", records)
+	}
+	billing.Commit(records[1].Seq)
+	if records, _ = audit.Fetch(0); 2 != len(records) || 4 != records[0].Seq {
+		t.Fatalf("audit读取错误: %+v", records)
+	}
+
+	t.Log("测试重启后从提交序号继续")
+	journal.Close()
+	// 模拟写入中途退出留下的不完整记录
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"seq":6,"event":"order.cre`)
+	file.Close()
+	if journal, err = OpenFileJournal(path); nil != err {
+		t.Fatalf("重新打开日志失败: %v", err)
+	}
+	defer journal.Close()
+
+	restarted := NewTrigger().WithJournal(journal)
+	billing, _ = restarted.NewConsumer("billing", 0)
+	if records, _ = billing.Fetch(0); 3 != len(records) || 3 != records[0].Seq || "3" != string(records[0].Arguments[0].(json.RawMessage)) {
+		t.Fatalf("重启后读取错误: %+v", records)
+	}
+
+	t.Log("测试等待新记录")
+	go restarted.Emit("order.created", 6)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if records, err = billing.FetchWait(ctx, 0); nil != err || 1 != len(records) || 6 != records[0].Seq {
+		t.Fatalf("等待新记录错误: %+v %v", records, err)
+	}
+}
+
+func TestDurableListener(t *testing.T) {
+	t.Log("测试未开启日志")
+	var failure error
+	NewTrigger().RecoverWith(func(event, listener interface{}, err error) {
+		failure = err
+	}).OnDurable("order.created", "mailer", func(interface{}) {})
+	if !errors.Is(failure, ErrNoJournal) {
+		t.Fatalf("未开启日志时未报错: %v", failure)
+	}
+
+	path := t.TempDir() + "/journal.log"
+	journal, err := OpenFileJournal(path)
+	if nil != err {
+		t.Fatalf("打开日志失败: %v", err)
+	}
+	defer journal.Close()
+
+	type order struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	var (
+		mu       sync.Mutex
+		received []order
+	)
+	mailer := func(o order) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, o)
+	}
+	waitReceived := func(n int) []order {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			if len(received) >= n {
+				out := append([]order(nil), received...)
+				mu.Unlock()
+				return out
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]order(nil), received...)
+	}
+
+	t.Log("测试按日志顺序消费")
+	trigger := NewTrigger().WithJournal(journal)
+	trigger.Emit("order.created", order{ID: 0})
+	trigger.OnDurable("order.created", "mailer", mailer)
+	trigger.Emit("order.created", order{ID: 1}).Emit("order.paid", 1).Emit("order.created", order{ID: 2, Name: "书"})
+	if got := waitReceived(2); 2 != len(got) || 1 != got[0].ID || 2 != got[1].ID {
+		t.Fatalf("消费错误: %v", got)
+	}
+	trigger.Close(context.Background())
+
+	t.Log("测试重新打开日志后未开启类型转换时不提交")
+	restarted := NewTrigger().WithJournal(journal)
+	restarted.Emit("order.created", order{ID: 3, Name: "笔"}).Emit("order.paid", 3)
+	journal.Close()
+	if journal, err = OpenFileJournal(path); nil != err {
+		t.Fatalf("重新打开日志失败: %v", err)
+	}
+	defer journal.Close()
+	var failures []error
+	reopened := NewTrigger().WithJournal(journal).RecoverWith(func(event, listener interface{}, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, err)
+	})
+	reopened.OnDurable("order.created", "mailer", mailer)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(failures)
+		mu.Unlock()
+		if 0 != n || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if committed, _ := journal.Committed(durableGroupPrefix + "mailer"); 4 != committed || !errors.Is(failures[0], ErrArgumentMismatch) {
+		t.Fatalf("参数不匹配时不应提交: %d %v", committed, failures)
+	}
+
+	t.Log("测试开启类型转换后从未提交的记录继续")
+	reopened.WithCoercion(true).OnDurable("order.created", "mailer", mailer)
+	reopened.Emit("order.created", order{ID: 4})
+	if got := waitReceived(4); 4 != len(got) || (order{ID: 3, Name: "笔"}) != got[2] || 4 != got[3].ID {
+		t.Fatalf("重新打开后消费错误: %v", got)
+	}
+
+	t.Log("测试删除后不再消费")
+	reopened.RemoveDurableListener("mailer")
+	reopened.EmitSync("order.created", order{ID: 5})
+	time.Sleep(10 * time.Millisecond)
+	if got := waitReceived(4); 4 != len(got) {
+		t.Fatalf("删除后仍在消费: %v", got)
+	}
+	if committed, _ := journal.Committed(durableGroupPrefix + "mailer"); 7 != committed {
+		t.Fatalf("提交序号错误: %d", committed)
+	}
+	reopened.Close(context.Background())
+}
+
+func TestCompactJournal(t *testing.T) {
+	t.Log("测试日志不支持压缩")
+	if _, err := NewTrigger().WithJournal(NewMemoryJournal(8)).CompactJournal(); !errors.Is(err, ErrNoJournal) {
+		t.Fatalf("不支持压缩时未报错: %v", err)
+	}
+
+	path := t.TempDir() + "/journal.log"
+	journal, err := OpenFileJournal(path)
+	if nil != err {
+		t.Fatalf("打开日志失败: %v", err)
+	}
+	trigger := NewTrigger().WithJournal(journal).WithCompaction("device.status", func(arguments []interface{}) string {
+		return fmt.Sprint(arguments[0])
+	})
+	trigger.Emit("device.status", "a", "online").
+		Emit("device.status", "b", "online").
+		Emit("device.alarm", "a").
+		Emit("device.status", "a", "offline").
+		Emit("device.status", "a", "online")
+
+	t.Log("测试按压缩键只保留最新记录")
+	if removed, err := trigger.CompactJournal(); nil != err || 2 != removed {
+		t.Fatalf("压缩数量错误: %d %v", removed, err)
+	}
+	records, _ := journal.Read(0, 0)
+	if 3 != len(records) || 2 != records[0].Seq || 3 != records[1].Seq || 5 != records[2].Seq {
+		t.Fatalf("压缩后记录错误: %+v", records)
+	}
+
+	t.Log("测试压缩后追加与重新打开")
+	trigger.Emit("device.status", "b", "offline")
+	journal.Close()
+	if journal, err = OpenFileJournal(path); nil != err {
+		t.Fatalf("重新打开日志失败: %v", err)
+	}
+	defer journal.Close()
+	if records, _ = journal.Read(2, 0); 3 != len(records) || 6 != records[2].Seq || 6 != journal.Last() {
+		t.Fatalf("重新打开后记录错误: %+v", records)
+	}
+	if removed, _ := NewTrigger().WithJournal(journal).WithCompaction("device.status", func(arguments []interface{}) string {
+		return fmt.Sprint(arguments[0])
+	}).CompactJournal(); 1 != removed {
+		t.Fatalf("重新打开后压缩数量错误: %d", removed)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		phases    []string
+		listeners []ListenerInfo
+		finished  DispatchInfo
+	)
+	trigger := NewTrigger().RecoverWith(func(interface{}, interface{}, error) {}).WithHooks(Hooks{
+		BeforeDispatch: func(info DispatchInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			phases = append(phases, "before")
+		},
+		AfterListener: func(info ListenerInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			phases = append(phases, "listener")
+			listeners = append(listeners, info)
+		},
+		AfterDispatch: func(info DispatchInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			phases = append(phases, "after")
+			finished = info
+		},
+	})
+
+	t.Log("测试同步触发的阶段顺序")
+	trigger.OnNamed("order.created", "stock", func(id int) {
+		time.Sleep(2 * time.Millisecond)
+	}).OnNamed("order.created", "audit", func(id int) {
+		panic("库存不足")
+	})
+	trigger.EmitSync("order.created", 1)
+	if "before,listener,listener,after" != strings.Join(phases, ",") {
+		t.Fatalf("阶段顺序错误: %v", phases)
+	}
+	if "stock" != listeners[0].Name || nil != listeners[0].Err || listeners[0].Duration < 2*time.Millisecond {
+		t.Fatalf("监听信息错误: %+v", listeners[0])
+	}
+	var panicErr *ListenerPanicError
+	if !errors.As(listeners[1].Err, &panicErr) {
+		t.Fatalf("监听失败未记录: %+v", listeners[1])
+	}
+	if !finished.Sync || 2 != finished.Listeners || finished.Duration < 2*time.Millisecond {
+		t.Fatalf("分发信息错误: %+v", finished)
+	}
+
+	t.Log("测试异步触发与没有监听的触发")
+	phases = nil
+	trigger.RemoveNamedListener("order.created", "audit")
+	trigger.Emit("order.created", 2).Emit("order.paid", 2)
+	if "before,listener,after,before,after" != strings.Join(phases, ",") || 0 != finished.Listeners || finished.Sync {
+		t.Fatalf("异步触发阶段错误: %v %+v", phases, finished)
+	}
+
+	t.Log("测试取消钩子")
+	phases = nil
+	trigger.WithHooks(Hooks{}).EmitSync("order.created", 3)
+	if 0 != len(phases) {
+		t.Fatalf("取消后仍调用钩子: %v", phases)
+	}
+}
+
+func TestEmitNoListenersCost(t *testing.T) {
+	trigger := NewTrigger().On("order.created", func(int) {})
+	arguments := []interface{}{1}
+	goroutines := runtime.NumGoroutine()
+
+	t.Log("测试没有监听的触发不分配内存")
+	if allocs := testing.AllocsPerRun(100, func() {
+		trigger.Emit("order.paid", arguments...).EmitSync("order.paid", arguments...)
+	}); 0 != allocs {
+		t.Fatalf("没有监听的触发分配了内存: %v", allocs)
+	}
+	if runtime.NumGoroutine() > goroutines {
+		t.Fatalf("没有监听的触发启动了协程")
+	}
+
+	t.Log("测试拦截监听仍能观察没有监听的触发")
+	var unhandled []interface{}
+	listener := AnyListener(func(event interface{}, arguments []interface{}) {
+		unhandled = append(unhandled, event)
+	})
+	trigger.OnUnhandled(listener).Emit("order.paid", 1)
+	if 1 != len(unhandled) || "order.paid" != unhandled[0] {
+		t.Fatalf("无人处理监听未执行: %v", unhandled)
+	}
+	trigger.OffUnhandled(listener)
+	if allocs := testing.AllocsPerRun(100, func() {
+		trigger.Emit("order.paid", arguments...)
+	}); 0 != allocs {
+		t.Fatalf("移除拦截监听后分配了内存: %v", allocs)
+	}
+}
+
+func TestExecutorListener(t *testing.T) {
+	// 模拟专用工作协程
+	tasks := make(chan func(), 16)
+	var worker uint64
+	go func() {
+		worker = goroutineID()
+		for task := range tasks {
+			task()
+		}
+	}()
+	defer close(tasks)
+	executor := ExecutorFunc(func(task func()) {
+		tasks <- task
+	})
+
+	var (
+		mu    sync.Mutex
+		ran   []uint64
+		stock = map[string]int{}
+	)
+	trigger := NewTrigger().OnExecutor("order.created", executor, func(item string) {
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, goroutineID())
+		stock[item]--
+	})
+
+	t.Log("测试监听在执行器中执行且触发等待执行完毕")
+	trigger.Emit("order.created", "apple").EmitSync("order.created", "apple")
+	if -2 != stock["apple"] || 2 != len(ran) || ran[0] != worker || ran[1] != worker {
+		t.Fatalf("执行器执行错误: %v %v", stock, ran)
+	}
+
+	t.Log("测试执行器中的panic在触发方协程中抛出")
+	trigger.OnExecutor("order.paid", executor, func() {
+		panic("支付失败")
+	}).WithPanicPolicy(PanicPropagate)
+	func() {
+		defer func() {
+			if nil == recover() {
+				t.Fatalf("panic未在触发方抛出")
+			}
+		}()
+		trigger.EmitSync("order.paid")
+	}()
+}
+
+func TestLoopExecutor(t *testing.T) {
+	loop := NewLoopExecutor()
+	ctx, cancel := context.WithCancel(context.Background())
+	owner := make(chan uint64, 1)
+	stopped := make(chan error, 1)
+	go func() {
+		owner <- goroutineID()
+		stopped <- loop.Run(ctx)
+	}()
+	mainLoop := <-owner
+
+	var frames []string
+	trigger := NewTrigger()
+	trigger.OnExecutor("ui.click", loop, func(button string) {
+		if goroutineID() != mainLoop {
+			t.Errorf("监听未在主循环中执行")
+		}
+		frames = append(frames, "click:"+button)
+		// 在主循环中同步触发同一循环的监听不会死锁
+		trigger.EmitSync("ui.redraw", button)
+	}).OnExecutor("ui.redraw", loop, func(button string) {
+		frames = append(frames, "redraw:"+button)
+	})
+
+	t.Log("测试其他协程触发的监听在主循环中依次执行")
+	trigger.Emit("ui.click", "ok")
+	trigger.EmitSync("ui.click", "cancel")
+	if "click:ok,redraw:ok,click:cancel,redraw:cancel" != strings.Join(frames, ",") {
+		t.Fatalf("执行顺序错误: %v", frames)
+	}
+
+	cancel()
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Fatalf("退出原因错误: %v", err)
+	}
+
+	t.Log("测试按帧执行排队的调用")
+	frame := NewLoopExecutor()
+	var count int
+	trigger.OnExecutor("game.tick", frame, func() { count++ })
+	if 0 != frame.RunPending() {
+		t.Fatalf("没有排队时执行了调用")
+	}
+	done := make(chan struct{})
+	go func() {
+		trigger.Emit("game.tick")
+		close(done)
+	}()
+	for 0 == frame.RunPending() {
+		time.Sleep(time.Millisecond)
+	}
+	<-done
+	// 循环协程中的触发直接执行
+	trigger.EmitSync("game.tick")
+	if 2 != count {
+		t.Fatalf("按帧执行次数错误: %d", count)
+	}
+}
+
+// 按截止时间排序的测试执行器, 调用Flush时执行
+type deadlineExecutor struct {
+	mu    sync.Mutex
+	infos []TaskInfo
+	tasks []func()
+}
+
+func (e *deadlineExecutor) Execute(task func()) {
+	e.ExecuteTask(TaskInfo{}, task)
+}
+
+func (e *deadlineExecutor) ExecuteTask(info TaskInfo, task func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.infos = append(e.infos, info)
+	e.tasks = append(e.tasks, task)
+}
+
+func (e *deadlineExecutor) pending() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.tasks)
+}
+
+func (e *deadlineExecutor) flush() {
+	e.mu.Lock()
+	infos, tasks := e.infos, e.tasks
+	e.infos, e.tasks = nil, nil
+	e.mu.Unlock()
+
+	order := make([]int, len(tasks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return infos[order[i]].Deadline.Before(infos[order[j]].Deadline)
+	})
+	for _, i := range order {
+		tasks[i]()
+	}
+}
+
+func TestTaskExecutor(t *testing.T) {
+	executor := &deadlineExecutor{}
+	var handled []string
+	trigger := NewTrigger().OnExecutor("order.created", executor, func(id string) {
+		handled = append(handled, id)
+	})
+
+	t.Log("测试执行器收到优先级与截止时间")
+	now := time.Now()
+	var wg sync.WaitGroup
+	for i, id := range []string{"late", "early"} {
+		wg.Add(1)
+		go func(id string, deadline time.Time) {
+			defer wg.Done()
+			trigger.EmitWith(Lineage{Priority: 2, Deadline: deadline}, "order.created", id)
+		}(id, now.Add(time.Duration(2-i)*time.Hour))
+		for i+1 != executor.pending() {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if info := executor.infos[0]; 2 != info.Priority || !info.Deadline.Equal(now.Add(2*time.Hour)) || "order.created" != info.Event {
+		t.Fatalf("调用信息错误: %+v", info)
+	}
+
+	t.Log("测试执行器按截止时间调度")
+	executor.flush()
+	wg.Wait()
+	if "early,late" != strings.Join(handled, ",") {
+		t.Fatalf("调度顺序错误: %v", handled)
+	}
+}
+
+func TestBackfillListener(t *testing.T) {
+	trigger := NewTrigger().WithJournal(NewMemoryJournal(16))
+	trigger.Emit("price.changed", 1).Emit("price.changed", 2).Emit("stock.changed", 9)
+
+	var (
+		mu     sync.Mutex
+		prices []int
+	)
+	waitPrices := func(n int) []int {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			mu.Lock()
+			got := append([]int(nil), prices...)
+			mu.Unlock()
+			if len(got) >= n || time.Now().After(deadline) {
+				return got
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	t.Log("测试先补发历史再执行新触发")
+	trigger.OnBackfill("price.changed", "chart", BackfillFromSeq(2), func(price int) {
+		mu.Lock()
+		defer mu.Unlock()
+		prices = append(prices, price)
+	})
+	for i := 3; i <= 5; i++ {
+		trigger.Emit("price.changed", i)
+	}
+	if got := waitPrices(4); "[2 3 4 5]" != fmt.Sprint(got) {
+		t.Fatalf("补发顺序错误: %v", got)
+	}
+
+	t.Log("测试按时间补发")
+	var count atomic.Int32
+	trigger.OnBackfill("stock.changed", "audit", BackfillFromTime(time.Now().Add(time.Hour)), func(int) {
+		count.Add(1)
+	})
+	trigger.Emit("stock.changed", 10)
+	trigger.RemoveBackfillListener("chart")
+	if 0 != count.Load() {
+		t.Fatalf("补发了起点之前的记录: %d", count.Load())
+	}
+
+	t.Log("测试删除后不再执行")
+	trigger.Emit("price.changed", 6)
+	time.Sleep(10 * time.Millisecond)
+	if got := waitPrices(4); 4 != len(got) {
+		t.Fatalf("删除后仍在执行: %v", got)
+	}
+	trigger.Close(context.Background())
+}
+
+func TestMutexGroup(t *testing.T) {
+	var active, peak atomic.Int32
+	// 模拟共用的外部资源
+	useDevice := func() {
+		current := active.Add(1)
+		for {
+			max := peak.Load()
+			if current <= max || peak.CompareAndSwap(max, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		active.Add(-1)
+	}
+	trigger := NewTrigger().WithMutexGroup("printer", "print.receipt", "print.label").
+		On("print.receipt", func(int) { useDevice() }).
+		On("print.label", func(int) { useDevice() })
+
+	t.Log("测试组内事件不会同时分发")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			trigger.Emit("print.receipt", i)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			trigger.EmitSync("print.label", i)
+		}(i)
+	}
+	wg.Wait()
+	if 1 != peak.Load() {
+		t.Fatalf("组内事件并发分发: %d", peak.Load())
+	}
+
+	t.Log("测试删除互斥组")
+	trigger.WithMutexGroup("printer")
+	if nil != trigger.mutexOf("print.receipt") || 0 != len(trigger.mutexGroups) {
+		t.Fatalf("互斥组未删除")
+	}
+}
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	var rejected atomic.Int32
+	release := make(chan struct{})
+	var slow atomic.Bool
+	trigger := NewTrigger().RecoverWith(func(event, listener interface{}, err error) {
+		if errors.Is(err, ErrConcurrencyLimit) {
+			rejected.Add(1)
+		}
+	}).WithAdaptiveConcurrency("inventory.sync", AdaptiveConcurrency{
+		Initial: 2, Max: 3, TargetLatency: 100 * time.Millisecond, Window: 20 * time.Millisecond,
+	}).On("inventory.sync", func() {
+		if slow.Load() {
+			time.Sleep(120 * time.Millisecond)
+			return
+		}
+		<-release
+	})
+
+	t.Log("测试超出上限时拒绝")
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			trigger.EmitSync("inventory.sync")
+		}()
+	}
+	for stats, _ := trigger.ConcurrencyStats("inventory.sync"); 2 != stats.InFlight; stats, _ = trigger.ConcurrencyStats("inventory.sync") {
+		time.Sleep(time.Millisecond)
+	}
+	trigger.Emit("inventory.sync")
+	if 1 != rejected.Load() {
+		t.Fatalf("超出上限未拒绝: %d", rejected.Load())
+	}
+	close(release)
+	wg.Wait()
+
+	t.Log("测试正常时加性增加上限")
+	time.Sleep(20 * time.Millisecond)
+	trigger.EmitSync("inventory.sync")
+	if stats, _ := trigger.ConcurrencyStats("inventory.sync"); 3 != stats.Limit || 1 != stats.Rejected {
+		t.Fatalf("上限未增加: %+v", stats)
+	}
+
+	t.Log("测试过载时乘性减小上限")
+	slow.Store(true)
+	for i := 0; i < 2; i++ {
+		trigger.EmitSync("inventory.sync")
+	}
+	if stats, _ := trigger.ConcurrencyStats("inventory.sync"); 1 != stats.Limit {
+		t.Fatalf("上限未减小: %+v", stats)
+	}
+
+	t.Log("测试关闭自适应并发控制")
+	if _, ok := trigger.RemoveAdaptiveConcurrency("inventory.sync").ConcurrencyStats("inventory.sync"); ok {
+		t.Fatalf("关闭后仍在控制")
+	}
+}
+
+func TestLoadShedding(t *testing.T) {
+	var delivered atomic.Int32
+	release := make(chan struct{})
+	trigger := NewTrigger().SetEventClass("metrics.sample", ClassBestEffort).
+		WithLoadShedding(SheddingPolicy{MaxInFlight: 1}).
+		On("order.created", func() { <-release }).
+		On("metrics.sample", func() { delivered.Add(1) })
+
+	t.Log("测试正在执行的触发过多时丢弃尽力而为的事件")
+	done := make(chan struct{})
+	go func() {
+		trigger.EmitSync("order.created")
+		close(done)
+	}()
+	for 1 != trigger.inFlight.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	trigger.EmitSync("metrics.sample")
+	close(release)
+	<-done
+	trigger.EmitSync("metrics.sample")
+	if stats := trigger.SheddingStats(); 1 != delivered.Load() || 1 != stats.Shed || 1 != stats.ByEvent["metrics.sample"] {
+		t.Fatalf("丢弃错误: %d %+v", delivered.Load(), stats)
+	}
+
+	t.Log("测试平均分发耗时超出时丢弃")
+	trigger.WithLoadShedding(SheddingPolicy{MaxLatency: time.Millisecond}).
+		On("report.build", func() { time.Sleep(5 * time.Millisecond) })
+	trigger.EmitSync("report.build").EmitSync("metrics.sample")
+	if 1 != delivered.Load() || 1 != trigger.SheddingStats().Shed {
+		t.Fatalf("耗时超出时未丢弃: %d", delivered.Load())
+	}
+
+	t.Log("测试堆内存超出时丢弃, 关键事件不受影响")
+	trigger.WithLoadShedding(SheddingPolicy{MaxHeapBytes: 1}).EmitSync("metrics.sample").EmitSync("report.build")
+	if 1 != delivered.Load() || 1 != trigger.SheddingStats().Shed {
+		t.Fatalf("堆内存超出时未丢弃: %d", delivered.Load())
+	}
+	trigger.SetEventClass("metrics.sample", ClassCritical).EmitSync("metrics.sample")
+	if 2 != delivered.Load() {
+		t.Fatalf("关键事件被丢弃")
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	var rejected atomic.Int32
+	var delivered atomic.Int32
+	release := make(chan struct{})
+	trigger := NewTrigger().RecoverWith(func(event, listener interface{}, err error) {
+		if errors.Is(err, ErrMemoryBudget) {
+			rejected.Add(1)
+		}
+	}).WithMemoryBudget(10, func(event interface{}, arguments []interface{}) int {
+		return len(arguments[0].(string))
+	}).SetEventClass("image.preview", ClassBestEffort).
+		On("image.upload", func(data string) {
+			if "12345678" == data {
+				<-release
+			}
+			delivered.Add(1)
+		}).
+		On("image.preview", func(data string) { delivered.Add(1) })
+
+	t.Log("测试超出预算时拒绝关键事件并丢弃尽力而为的事件")
+	done := make(chan struct{})
+	go func() {
+		trigger.EmitSync("image.upload", "12345678")
+		close(done)
+	}()
+	for 8 != trigger.MemoryStats().InFlight {
+		time.Sleep(time.Millisecond)
+	}
+	trigger.Emit("image.upload", "12345").EmitSync("image.preview", "12345").EmitSync("image.upload", "12")
+	close(release)
+	<-done
+	if stats := trigger.MemoryStats(); 2 != delivered.Load() || 1 != rejected.Load() || 1 != stats.Rejected || 1 != stats.Shed || 0 != stats.InFlight {
+		t.Fatalf("内存预算错误: %d %+v", delivered.Load(), stats)
+	}
+
+	t.Log("测试没有其他触发时允许超出预算的触发")
+	trigger.EmitSync("image.upload", "0123456789abc")
+	if 3 != delivered.Load() {
+		t.Fatalf("单个超出预算的触发被拒绝")
+	}
+
+	t.Log("测试默认按JSON编码长度估算")
+	if size := jsonSize("x", []interface{}{"ab", 12, make(chan int)}); 6 != size {
+		t.Fatalf("估算大小错误: %d", size)
+	}
+}
+
+func TestBlobStore(t *testing.T) {
+	store, err := NewFileBlobStore(t.TempDir())
+	if nil != err {
+		t.Fatalf("创建大参数存储失败: %v", err)
+	}
+
+	t.Log("测试转存与取回")
+	raws := []json.RawMessage{json.RawMessage(`"small"`), json.RawMessage(`"` + strings.Repeat("x", 64) + `"`)}
+	claims, err := CheckIn(context.Background(), store, 16, raws)
+	if nil != err || 1 != len(claims) || "null" != string(raws[1]) {
+		t.Fatalf("转存错误: %v %v %s", claims, err, raws[1])
+	}
+	if err := CheckOut(context.Background(), store, raws, claims); nil != err || 66 != len(raws[1]) {
+		t.Fatalf("取回错误: %v %s", err, raws[1])
+	}
+	if err := CheckOut(context.Background(), nil, raws, claims); !errors.Is(err, ErrNoBlobStore) {
+		t.Fatalf("没有存储时未报错: %v", err)
+	}
+	if _, err := store.Get(context.Background(), "../journal.log"); nil == err {
+		t.Fatalf("无效引用未报错")
+	}
+
+	t.Log("测试日志转存大参数")
+	path := t.TempDir() + "/journal.log"
+	journal, _ := OpenFileJournal(path)
+	journal.WithBlobStore(store, 16)
+	report := strings.Repeat("r", 100)
+	trigger := NewTrigger().WithJournal(journal)
+	trigger.Emit("report.ready", "r1", report)
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), report) || !strings.Contains(string(data), `"claims"`) {
+		t.Fatalf("日志文件未转存: %s", data)
+	}
+	if records, err := journal.Read(0, 0); nil != err || `"`+report+`"` != string(records[0].Arguments[1].(json.RawMessage)) || "r1" != records[0].Arguments[0] {
+		t.Fatalf("读取转存的参数错误: %+v %v", records, err)
+	}
+
+	t.Log("测试重新打开后取回")
+	journal.Close()
+	journal, _ = OpenFileJournal(path)
+	defer journal.Close()
+	if _, err := journal.Read(0, 0); !errors.Is(err, ErrNoBlobStore) {
+		t.Fatalf("未设置存储时未报错: %v", err)
+	}
+	if records, err := journal.WithBlobStore(store, 16).Read(0, 0); nil != err || `"`+report+`"` != string(records[0].Arguments[1].(json.RawMessage)) {
+		t.Fatalf("重新打开后读取错误: %+v %v", records, err)
+	}
+}
+
+func TestCachedListener(t *testing.T) {
+	var calls atomic.Int32
+	price := func(sku string) (int, error) {
+		calls.Add(1)
+		if "" == sku {
+			return 0, errors.New("缺少商品")
+		}
+		return 100 + int(calls.Load()), nil
+	}
+	trigger := NewTrigger().OnCached("price.query", func(arguments []interface{}) string {
+		return arguments[0].(string)
+	}, price, 20*time.Millisecond)
+	query := func(sku string) int {
+		results, _ := trigger.invoke("price.query", trigger.handlersOf("price.query")[0], []interface{}{sku})
+		return int(results[0].Int())
+	}
+
+	t.Log("测试有效期内相同缓存键返回缓存的结果")
+	if first, second := query("A1"), query("A1"); 101 != first || 101 != second || 1 != calls.Load() {
+		t.Fatalf("缓存未命中: %d %d %d", first, second, calls.Load())
+	}
+	trigger.EmitSync("price.query", "B2")
+	if 2 != calls.Load() {
+		t.Fatalf("不同缓存键未执行: %d", calls.Load())
+	}
+
+	t.Log("测试失败的结果不缓存")
+	query("")
+	query("")
+	if 4 != calls.Load() {
+		t.Fatalf("失败的结果被缓存: %d", calls.Load())
+	}
+
+	t.Log("测试过期后重新执行")
+	time.Sleep(30 * time.Millisecond)
+	if got := query("A1"); 105 != got {
+		t.Fatalf("过期后未重新执行: %d", got)
+	}
+
+	t.Log("测试按原回调函数移除")
+	if 0 != trigger.Off("price.query", price).GetListenerCount("price.query") {
+		t.Fatalf("缓存监听未移除")
+	}
+}
+
+func TestRequestCache(t *testing.T) {
+	var calls atomic.Int32
+	trigger := NewTrigger().WithRequestCache("price.query", time.Minute, "price.changed").
+		On("price.query", func(reply *ReplyAddress, sku string) {
+			calls.Add(1)
+			reply.Reply(context.Background(), sku, 100+int(calls.Load()))
+		})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	query := func(sku string) interface{} {
+		reply, err := trigger.Request(ctx, "price.query", sku)
+		if nil != err {
+			t.Fatalf("请求失败: %v", err)
+		}
+		return reply[1]
+	}
+
+	t.Log("测试相同参数的请求返回缓存的回复")
+	if first, second := query("A1"), query("A1"); 101 != first || 101 != second || 1 != calls.Load() {
+		t.Fatalf("缓存未命中: %v %v %d", first, second, calls.Load())
+	}
+	if 102 != query("B2") {
+		t.Fatalf("不同参数返回了缓存")
+	}
+
+	t.Log("测试失效事件清空缓存")
+	trigger.EmitSync("price.changed", "A1")
+	if got := query("A1"); 103 != got {
+		t.Fatalf("失效后返回了缓存: %v", got)
+	}
+	trigger.InvalidateRequestCache("price.query")
+	if got := query("A1"); 104 != got {
+		t.Fatalf("清空后返回了缓存: %v", got)
+	}
+
+	t.Log("测试关闭缓存")
+	trigger.WithRequestCache("price.query", 0)
+	query("A1")
+	query("A1")
+	if 6 != calls.Load() || 0 != trigger.GetListenerCount("price.changed") {
+		t.Fatalf("关闭后仍在缓存: %d", calls.Load())
+	}
+}
+
+func TestRequestCollapsing(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	trigger := NewTrigger().WithRequestCollapsing("report.build").
+		On("report.build", func(reply *ReplyAddress, id int) {
+			calls.Add(1)
+			<-release
+			reply.Reply(context.Background(), id*10)
+		})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Log("测试参数相同的并发请求只执行一次")
+	var wg sync.WaitGroup
+	results := make([]interface{}, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reply, err := trigger.Request(ctx, "report.build", 7)
+			if nil != err {
+				t.Errorf("请求失败: %v", err)
+				return
+			}
+			results[i] = reply[0]
+		}(i)
+	}
+	for 0 == calls.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if 1 != calls.Load() {
+		t.Fatalf("并发请求未合并: %d", calls.Load())
+	}
+	for _, result := range results {
+		if 70 != result {
+			t.Fatalf("回复不一致: %v", results)
+		}
+	}
+
+	t.Log("测试请求结束后重新执行")
+	if _, err := trigger.Request(ctx, "report.build", 7); nil != err || 2 != calls.Load() {
+		t.Fatalf("请求结束后未重新执行: %v %d", err, calls.Load())
+	}
+
+	t.Log("测试等待方全部超时后取消请求")
+	trigger.WithRequestCollapsing("report.silent").On("report.silent", func(reply *ReplyAddress, id int) {})
+	short, stop := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer stop()
+	if _, err := trigger.Request(short, "report.silent", 8); !errors.As(err, new(*TimeoutError)) {
+		t.Fatalf("超时未返回TimeoutError: %v", err)
+	}
+	group := trigger.flightsOf("report.silent")
+	group.mu.Lock()
+	pending := len(group.flights)
+	group.mu.Unlock()
+	if 0 != pending {
+		t.Fatalf("超时后请求未取消: %d", pending)
+	}
+}
+
+func TestFailoverListener(t *testing.T) {
+	var primary, standby atomic.Int32
+	var broken atomic.Bool
+	broken.Store(true)
+	trigger := NewTrigger().RecoverWith(func(event, listener interface{}, err error) {}).
+		OnFailover("order.paid", "settle", func(id int) error {
+			primary.Add(1)
+			if broken.Load() {
+				return errors.New("下游不可用")
+			}
+			return nil
+		}, func(id int) error {
+			standby.Add(1)
+			return nil
+		}, Failover{Threshold: 2, OpenFor: 50 * time.Millisecond})
+
+	t.Log("测试主监听连续失败后切换到备用监听")
+	for i := 0; i < 4; i++ {
+		trigger.EmitSync("order.paid", i)
+	}
+	if 2 != primary.Load() || 2 != standby.Load() || !trigger.FailedOver("order.paid", "settle") {
+		t.Fatalf("未切换到备用监听: 主%d 备%d", primary.Load(), standby.Load())
+	}
+
+	t.Log("测试熔断结束后试探并恢复主监听")
+	broken.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	trigger.EmitSync("order.paid", 5).EmitSync("order.paid", 6)
+	if 4 != primary.Load() || 2 != standby.Load() || trigger.FailedOver("order.paid", "settle") {
+		t.Fatalf("未恢复主监听: 主%d 备%d", primary.Load(), standby.Load())
+	}
+
+	t.Log("测试注销主监听后由备用监听处理")
+	trigger.RemoveFailoverPrimary("order.paid", "settle").EmitSync("order.paid", 7)
+	if 4 != primary.Load() || 3 != standby.Load() || !trigger.FailedOver("order.paid", "settle") {
+		t.Fatalf("注销后未切换: 主%d 备%d", primary.Load(), standby.Load())
+	}
+
+	t.Log("测试主备监听类型不一致")
+	var rejected error
+	NewTrigger().RecoverWith(func(event, listener interface{}, err error) { rejected = err }).
+		OnFailover("order.paid", "settle", func(id int) {}, func(id string) {}, Failover{})
+	if !errors.Is(rejected, ErrFailoverMismatch) {
+		t.Fatalf("类型不一致未报告: %v", rejected)
+	}
+}
+
+func TestUpgradeListener(t *testing.T) {
+	var v1, v2 atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	trigger := NewTrigger().OnNamed("invoice.created", "mailer", func(id int) {
+		v1.Add(1)
+		close(started)
+		<-release
+	})
+
+	t.Log("测试升级时旧监听执行完已分发的触发, 新监听接收之后的触发")
+	done := make(chan struct{})
+	go func() {
+		trigger.Emit("invoice.created", 1)
+		close(done)
+	}()
+	<-started
+	trigger.UpgradeListener("invoice.created", "mailer", func(id int) {
+		v2.Add(1)
+	})
+	trigger.EmitSync("invoice.created", 2)
+	if 1 != v2.Load() || 1 != trigger.GetListenerCount("invoice.created") {
+		t.Fatalf("新监听未接收触发: %d", v2.Load())
+	}
+	select {
+	case <-done:
+		t.Fatalf("旧监听的触发被中断")
+	default:
+	}
+
+	t.Log("测试旧监听空闲后移除")
+	close(release)
+	<-done
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := trigger.WaitIdle(ctx); nil != err {
+		t.Fatalf("旧监听未移除: %v", err)
+	}
+	trigger.EmitSync("invoice.created", 3)
+	if 1 != v1.Load() || 2 != v2.Load() {
+		t.Fatalf("升级后调用错误: v1 %d v2 %d", v1.Load(), v2.Load())
+	}
+}
+
+func TestRouter(t *testing.T) {
+	routes, err := LoadRoutes([]byte(`[
+		{"name":"internal","events":["internal.*"],"deny":true},
+		{"name":"vip","bridge":"ws","direction":"out","events":["order.*"],"where":"total >= 100","rename":"order.vip","select":{"id":"id"}},
+		{"name":"orders","direction":"out","events":["order.*"]},
+		{"name":"inbound","direction":"in"}
+	]`))
+	if nil != err {
+		t.Fatalf("加载路由失败: %v", err)
+	}
+	router, err := NewRouter(routes)
+	if nil != err {
+		t.Fatalf("创建路由表失败: %v", err)
+	}
+
+	t.Log("测试按顺序匹配路由规则")
+	if _, _, ok := router.Route("ws", RouteOut, "internal.audit", nil); ok {
+		t.Fatalf("拒绝的事件被放行")
+	}
+	event, arguments, ok := router.Route("ws", RouteOut, "order.paid", []interface{}{map[string]interface{}{"id": 7, "total": 150}})
+	if !ok || "order.vip" != event || 7 != arguments[0].(map[string]interface{})["id"] {
+		t.Fatalf("改写错误: %v %v %v", ok, event, arguments)
+	}
+	if event, _, ok := router.Route("ws", RouteOut, "order.paid", []interface{}{map[string]interface{}{"id": 8, "total": 10}}); !ok || "order.paid" != event {
+		t.Fatalf("条件不满足时未匹配下一条规则: %v %v", ok, event)
+	}
+	if _, _, ok := router.Route("ws", RouteOut, "user.created", nil); ok {
+		t.Fatalf("没有匹配的规则时应拒绝")
+	}
+
+	t.Log("测试解码桥接收到的参数")
+	router.SetRoutes([]Route{{Name: "big", Direction: RouteIn, Where: "total > 100"}})
+	if _, _, ok := router.Route("ws", RouteIn, "order.paid", []interface{}{json.RawMessage(`{"total":101}`)}); !ok {
+		t.Fatalf("未解码json.RawMessage参数")
+	}
+
+	t.Log("测试无效规则不替换")
+	if err := router.SetRoutes([]Route{{Name: "bad", Where: "total >"}}); !errors.Is(err, ErrInvalidRule) {
+		t.Fatalf("无效规则未报告: %v", err)
+	}
+	if routes := router.Routes(); 1 != len(routes) || "big" != routes[0].Name {
+		t.Fatalf("无效规则替换了路由表: %v", routes)
+	}
+}
+
+func TestDelivery(t *testing.T) {
+	var attempts atomic.Int32
+	flaky := func(id int) error {
+		if attempts.Add(1)%3 != 0 {
+			return errors.New("暂时失败")
+		}
+		return nil
+	}
+	trigger := NewTrigger().RecoverWith(func(event, listener interface{}, err error) {}).
+		WithDelivery("invoice.sent", DeliveryPolicy{Guarantee: AtLeastOnce, MaxAttempts: 3, Backoff: time.Millisecond}).
+		WithDelivery("metric.tick", DeliveryPolicy{Guarantee: AtMostOnce}).
+		On("invoice.sent", flaky).On("metric.tick", flaky)
+
+	t.Log("测试至少一次的事件失败时重试")
+	trigger.EmitSync("invoice.sent", 1)
+	trigger.Emit("invoice.sent", 2)
+	if 6 != attempts.Load() {
+		t.Fatalf("重试次数错误: %d", attempts.Load())
+	}
+
+	t.Log("测试至多一次的事件不重试")
+	attempts.Store(0)
+	trigger.EmitSync("metric.tick", 1)
+	if 1 != attempts.Load() || AtMostOnce != trigger.DeliveryOf("metric.tick").Guarantee {
+		t.Fatalf("至多一次重试了: %d", attempts.Load())
+	}
+
+	t.Log("测试至少一次的持久监听成功后才提交")
+	journal, err := OpenFileJournal(t.TempDir() + "/journal.log")
+	if nil != err {
+		t.Fatalf("打开日志失败: %v", err)
+	}
+	defer journal.Close()
+	attempts.Store(0)
+	done := make(chan struct{})
+	durable := NewTrigger().RecoverWith(func(event, listener interface{}, err error) {}).WithCoercion(true).WithJournal(journal).
+		WithDelivery("invoice.sent", DeliveryPolicy{Guarantee: AtLeastOnce, MaxAttempts: 2, Backoff: time.Millisecond}).
+		OnDurable("invoice.sent", "ledger", func(id int) error {
+			if err := flaky(id); nil != err {
+				return err
+			}
+			close(done)
+			return nil
+		})
+	defer durable.Close(context.Background())
+	durable.Emit("invoice.sent", 7)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("持久监听未重试成功: %d", attempts.Load())
+	}
+	if 3 != attempts.Load() {
+		t.Fatalf("持久监听重试次数错误: %d", attempts.Load())
+	}
+}
+
+func TestFlaggedListener(t *testing.T) {
+	var legacy, billing []int
+	newBilling := func(user int, amount float64) { billing = append(billing, user) }
+	trigger := NewTrigger().
+		On("invoice.issued", func(user int, amount float64) { legacy = append(legacy, user) }).
+		OnWhenFlag("new-billing", "invoice.issued", newBilling)
+
+	t.Log("测试没有提供方时开关关闭")
+	trigger.EmitSync("invoice.issued", 1, 9.9)
+	if 0 != len(billing) {
+		t.Fatalf("开关关闭时执行了监听")
+	}
+
+	t.Log("测试按参数定向开启")
+	trigger.WithFlagProvider(FlagProviderFunc(func(flag string, event interface{}, arguments []interface{}) bool {
+		return "new-billing" == flag && 0 == arguments[0].(int)%2
+	}))
+	for user := 2; user <= 5; user++ {
+		trigger.EmitSync("invoice.issued", user, 9.9)
+	}
+	if "[2 4]" != fmt.Sprint(billing) || 5 != len(legacy) {
+		t.Fatalf("定向错误: %v %v", billing, legacy)
+	}
+
+	t.Log("测试按原回调函数移除")
+	trigger.Off("invoice.issued", newBilling)
+	if 1 != trigger.GetListenerCount("invoice.issued") {
+		t.Fatalf("未移除受开关控制的监听")
+	}
+}
+
+func TestExperiment(t *testing.T) {
+	var mu sync.Mutex
+	handled := make(map[string]string)
+	var records []ExperimentRecord
+	experiment := Experiment{Name: "ranking-v2", Percent: 30}
+	trigger := NewTrigger().
+		On(ExperimentEvent, func(record ExperimentRecord) {
+			mu.Lock()
+			records = append(records, record)
+			mu.Unlock()
+		}).
+		AddExperiment("search", experiment,
+			[]interface{}{func(user string) { handled[user] = VariantControl }},
+			[]interface{}{func(user string) { handled[user] = VariantTreatment }, func(user string) {}})
+
+	t.Log("测试按分流键确定分组")
+	treated := 0
+	for i := 0; i < 1000; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		trigger.EmitSync("search", user)
+		if handled[user] != experiment.Assign(user) {
+			t.Fatalf("分组与Assign不一致: %s", user)
+		}
+		if VariantTreatment == handled[user] {
+			treated++
+		}
+	}
+	if treated < 230 || treated > 370 {
+		t.Fatalf("实验组比例偏差过大: %d/1000", treated)
+	}
+	trigger.EmitSync("search", "user-1")
+	if handled["user-1"] != experiment.Assign("user-1") {
+		t.Fatalf("相同分流键分组不稳定")
+	}
+
+	t.Log("测试记录处理的组")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	trigger.WaitIdle(ctx)
+	mu.Lock()
+	if 1001 != len(records) || "ranking-v2" != records[0].Experiment || handled["user-0"] != records[0].Variant {
+		t.Fatalf("分流记录错误: %d %+v", len(records), records[0])
+	}
+	mu.Unlock()
+
+	t.Log("测试删除实验")
+	trigger.RemoveExperiment("search", "ranking-v2")
+	if 0 != trigger.GetListenerCount("search") || 0 != trigger.GetListenerCount(experimentEvent{name: "ranking-v2", variant: VariantTreatment}) {
+		t.Fatalf("实验未删除")
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		callers []*Provenance
+	)
+	trigger := NewTrigger().WithHooks(Hooks{
+		BeforeDispatch: func(info DispatchInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			callers = append(callers, info.Caller)
+		},
+	})
+	trigger.On("audit", func() {})
+
+	t.Log("测试未开启时不记录来源")
+	trigger.EmitSync("audit")
+	if nil != callers[0] {
+		t.Fatalf("未开启时不应记录来源: %v", callers[0])
+	}
+
+	t.Log("测试开启后记录调用处与协程")
+	trigger.WithProvenance(true).EmitSync("audit")
+	caller := callers[1]
+	if nil == caller || !strings.HasSuffix(caller.Function, "TestProvenance") || !strings.HasSuffix(caller.File, "trigger_test.go") || 0 == caller.Line || 0 == caller.Goroutine {
+		t.Fatalf("来源错误: %+v", caller)
+	}
+	if !strings.Contains(caller.String(), fmt.Sprintf("trigger_test.go:%d goroutine %d", caller.Line, caller.Goroutine)) {
+		t.Fatalf("来源格式错误: %s", caller)
+	}
+
+	t.Log("测试调试记录包含触发协程")
+	trigger.WithDebugTrace(1).EmitSync("audit")
+	var trace strings.Builder
+	if err := trigger.DebugTrace(&trace); nil != err || !strings.Contains(trace.String(), fmt.Sprintf("goroutine=%d", caller.Goroutine)) {
+		t.Fatalf("调试记录缺少协程: %v %s", err, trace.String())
+	}
+}
+
+func TestEventCatalog(t *testing.T) {
+	type order struct{ ID int }
+	var errs []error
+	trigger := NewTrigger().RecoverWith(func(event, listener interface{}, err error) {
+		errs = append(errs, err)
+	})
+	trigger.RegisterEvent("order.created", order{}, "订单创建").RegisterEvent("order.cancelled", nil, "订单取消")
+
+	t.Log("测试事件目录")
+	specs := trigger.RegisteredEvents()
+	if 2 != len(specs) || "order.cancelled" != specs[0].Name || "订单创建" != specs[1].Doc || "order" != specs[1].Payload.Name() {
+		t.Fatalf("事件目录错误: %+v", specs)
+	}
+
+	t.Log("测试非严格模式下未声明的事件照常执行")
+	var calls int
+	trigger.On("order.shipped", func() { calls++ }).EmitSync("order.shipped")
+	if 1 != calls || 0 != len(errs) {
+		t.Fatalf("非严格模式不应拒绝: %d %v", calls, errs)
+	}
+
+	t.Log("测试载荷类型不一致时拒绝触发")
+	var created []order
+	trigger.On("order.created", func(o order) { created = append(created, o) })
+	trigger.EmitSync("order.created", "1").EmitSync("order.created", order{ID: 1})
+	var validationErr *ValidationError
+	if 1 != len(created) || 1 != len(errs) || !errors.As(errs[0], &validationErr) || !errors.Is(errs[0], ErrPayloadMismatch) {
+		t.Fatalf("载荷校验错误: %v %v", created, errs)
+	}
+
+	t.Log("测试严格模式拒绝未声明的事件")
+	errs = nil
+	trigger.WithStrictEvents(true).On("order.refunded", func() {}).EmitSync("order.shipped")
+	var registrationErr *RegistrationError
+	if 0 != trigger.GetListenerCount("order.refunded") || 1 != calls || 2 != len(errs) ||
+		!errors.As(errs[0], &registrationErr) || !errors.Is(errs[1], ErrUnregisteredEvent) {
+		t.Fatalf("严格模式未拒绝: %d %v", calls, errs)
+	}
+
+	t.Log("测试严格模式拒绝无法接收载荷的监听")
+	errs = nil
+	trigger.On("order.created", func(id int) {}).On("order.cancelled", func(id int) {})
+	if 1 != trigger.GetListenerCount("order.created") || 1 != trigger.GetListenerCount("order.cancelled") || 1 != len(errs) || !errors.Is(errs[0], ErrPayloadMismatch) {
+		t.Fatalf("监听载荷校验错误: %v", errs)
+	}
+}
+
+func TestDeprecateEvent(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		warnings []Deprecation
+	)
+	trigger := NewTrigger().WithProvenance(true)
+	trigger.On(DeprecatedEvent, func(d Deprecation) {
+		mu.Lock()
+		defer mu.Unlock()
+		warnings = append(warnings, d)
+	})
+	trigger.RegisterEvent("user.login", nil, "用户登录").DeprecateEvent("user.login", "session.started").RegisterEvent("user.login", nil, "旧的登录事件")
+
+	t.Log("测试已弃用事件照常执行并计数")
+	var calls int
+	trigger.On("user.login", func() { calls++ })
+	trigger.EmitSync("user.login").EmitSync("user.login")
+	if 2 != calls || 3 != trigger.DeprecatedUsage()["user.login"] {
+		t.Fatalf("使用计数错误: %d %v", calls, trigger.DeprecatedUsage())
+	}
+	if spec, _ := trigger.EventSpecOf("user.login"); !spec.Deprecated || "旧的登录事件" != spec.Doc {
+		t.Fatalf("重新声明不应清除弃用标记: %+v", spec)
+	}
+
+	t.Log("测试触发与监听各报告一次")
+	if err := trigger.WaitIdle(context.Background()); nil != err {
+		t.Fatalf("等待失败: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if 2 != len(warnings) || DeprecatedListen != warnings[0].Usage || DeprecatedEmit != warnings[1].Usage || "session.started" != warnings[1].Replacement {
+		t.Fatalf("弃用报告错误: %+v", warnings)
+	}
+	if nil == warnings[1].Caller || !strings.HasSuffix(warnings[1].Caller.Function, "TestDeprecateEvent") {
+		t.Fatalf("弃用报告缺少使用处: %+v", warnings[1].Caller)
+	}
+}
+
+func TestMutationGuard(t *testing.T) {
+	type order struct {
+		ID    int
+		Items []string
+		tags  map[string]int
+		next  *order
+	}
+	var errs []error
+	trigger := NewTrigger().WithMutationGuard(true).RecoverWith(func(event, listener interface{}, err error) {
+		errs = append(errs, err)
+	})
+	trigger.On("order.created", func(o *order, bag *Bag, ctx context.Context) {
+		bag.Set("checked", true)
+		_ = ctx.Done()
+	})
+
+	t.Log("测试只读监听与允许改变的参数不报告")
+	o := &order{ID: 1, Items: []string{"a"}, tags: map[string]int{"x": 1, "y": 2}}
+	o.next = o
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	trigger.EmitSync("order.created", o, NewBag(), ctx)
+	if 0 != len(errs) {
+		t.Fatalf("不应报告: %v", errs)
+	}
+
+	t.Log("测试修改嵌套参数的监听被报告")
+	mutate := func(o *order, bag *Bag, ctx context.Context) {
+		o.tags["x"]++
+	}
+	trigger.On("order.created", mutate).EmitSync("order.created", o, NewBag(), ctx)
+	var dispatchErr *DispatchError
+	if 1 != len(errs) || !errors.Is(errs[0], ErrArgumentMutated) || !errors.As(errs[0], &dispatchErr) || fmt.Sprintf("%p", dispatchErr.Listener) != fmt.Sprintf("%p", mutate) {
+		t.Fatalf("修改参数未报告: %v", errs)
+	}
+
+	t.Log("测试关闭后不再检查")
+	errs = nil
+	trigger.WithMutationGuard(false).EmitSync("order.created", o, NewBag(), ctx)
+	if 0 != len(errs) {
+		t.Fatalf("关闭后不应报告: %v", errs)
+	}
+}
+
+func TestCopyOnDispatch(t *testing.T) {
+	type item struct {
+		Name string
+		Tags map[string]int
+	}
+	type order struct {
+		ID    int
+		Items []*item
+		Self  *order
+		note  string
+	}
+
+	t.Log("测试深度复制")
+	src := &order{ID: 1, Items: []*item{{Name: "a", Tags: map[string]int{"x": 1}}}, note: "备注"}
+	src.Self = src
+	dst := DeepCopy(src).(*order)
+	dst.Items[0].Tags["x"] = 2
+	dst.Items[0].Name = "b"
+	if src == dst || dst.Self != dst || "备注" != dst.note || 1 != src.Items[0].Tags["x"] || "a" != src.Items[0].Name {
+		t.Fatalf("深度复制错误: %+v %+v", src, dst)
+	}
+
+	t.Log("测试每个监听收到各自的副本")
+	var seen []int
+	trigger := NewTrigger().WithCopyOnDispatch(DeepCopy).WithMutationGuard(true).RecoverWith(func(event, listener interface{}, err error) {
+		t.Fatalf("不应报告错误: %v", err)
+	})
+	for i := 0; i < 2; i++ {
+		trigger.On("order.created", func(o *order, bag *Bag) {
+			o.Items[0].Tags["x"]++
+			seen = append(seen, o.Items[0].Tags["x"])
+			bag.Set(fmt.Sprint(len(seen)), true)
+		})
+	}
+	bag := NewBag()
+	trigger.EmitSync("order.created", src, bag)
+	if "[2 2]" != fmt.Sprint(seen) || 1 != src.Items[0].Tags["x"] || 2 != len(bag.Keys()) {
+		t.Fatalf("监听之间不应共享参数: %v %v", seen, src.Items[0].Tags)
+	}
+
+	t.Log("测试自定义复制函数与关闭复制")
+	var copies int
+	trigger.WithCopyOnDispatch(func(argument interface{}) interface{} {
+		copies++
+		return DeepCopy(argument)
+	}).EmitSync("order.created", src, bag)
+	if 2 != copies {
+		t.Fatalf("复制次数错误: %d", copies)
+	}
+	seen = nil
+	trigger.WithCopyOnDispatch(nil).WithMutationGuard(false).EmitSync("order.created", src, bag)
+	if "[2 3]" != fmt.Sprint(seen) {
+		t.Fatalf("关闭后应共享参数: %v", seen)
+	}
+}
+
+func TestHappensBefore(t *testing.T) {
+	// 以下变量都不加锁读写, 由-race检查可见性保证
+	type state struct {
+		before int
+		after  []int
+	}
+	workers := make(chan func(), 4)
+	defer close(workers)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for task := range workers {
+				task()
+			}
+		}()
+	}
+	executor := ExecutorFunc(func(task func()) { workers <- task })
+
+	trigger := NewTrigger()
+	for i := 0; i < 4; i++ {
+		i := i
+		listener := func(s *state) {
+			if 1 != s.before {
+				panic("触发前的写入不可见")
+			}
+			s.after[i] = i + 1
+		}
+		switch i {
+		case 0:
+			trigger.On("visibility", listener)
+		case 1:
+			trigger.AddSerialListener("visibility", listener)
+		case 2:
+			trigger.OnExecutor("visibility", executor, listener)
+		case 3:
+			trigger.AddShadowListener("visibility", listener)
+		}
+	}
+
+	t.Log("测试异步, 串行与执行器监听的写入在Emit返回后可见, 影子监听的写入在WaitIdle返回后可见")
+	for round := 0; round < 20; round++ {
+		s := &state{after: make([]int, 4)}
+		s.before = 1
+		trigger.Emit("visibility", s)
+		if 1 != s.after[0] || 2 != s.after[1] || 3 != s.after[2] {
+			t.Fatalf("监听写入不可见: %v", s.after)
+		}
+		if err := trigger.WaitIdle(context.Background()); nil != err {
+			t.Fatalf("等待失败: %v", err)
+		}
+		if 4 != s.after[3] {
+			t.Fatalf("影子监听写入不可见: %v", s.after)
+		}
+	}
+
+	t.Log("测试EmitSync的写入可见")
+	s := &state{after: make([]int, 4)}
+	s.before = 1
+	trigger.EmitSync("visibility", s)
+	if 1 != s.after[0] || 2 != s.after[1] || 3 != s.after[2] {
+		t.Fatalf("同步监听写入不可见: %v", s.after)
+	}
+	if err := trigger.WaitIdle(context.Background()); nil != err || 4 != s.after[3] {
+		t.Fatalf("同步触发的影子监听写入不可见: %v %v", err, s.after)
+	}
+}
+
+func TestEmitOnCleanup(t *testing.T) {
+	type entry struct {
+		key   string
+		value [64]byte
+	}
+	evicted := make(chan string, 1)
+	var errs []error
+	trigger := NewTrigger().RecoverWith(func(event, listener interface{}, err error) {
+		errs = append(errs, err)
+	}).On("cache.evicted", func(key string) { evicted <- key })
+
+	t.Log("测试非指针对象报告错误")
+	trigger.EmitOnCleanup(entry{}, "cache.evicted", "a").EmitOnCleanup((*entry)(nil), "cache.evicted", "a")
+	if 2 != len(errs) || !errors.Is(errs[0], ErrNotPointer) || !errors.Is(errs[1], ErrNotPointer) {
+		t.Fatalf("非指针对象未报告: %v", errs)
+	}
+
+	t.Log("测试对象被回收后触发同一对象上注册的所有事件")
+	func() {
+		e := &entry{key: "user:1"}
+		trigger.EmitOnCleanup(e, "cache.evicted", e.key).EmitOnCleanup(e, "cache.evicted", "user:2")
+	}()
+	var keys []string
+	deadline := time.After(5 * time.Second)
+	for 2 > len(keys) {
+		runtime.GC()
+		select {
+		case key := <-evicted:
+			keys = append(keys, key)
+		case <-deadline:
+			t.Fatalf("对象回收后未触发: %v", keys)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	sort.Strings(keys)
+	if "user:1,user:2" != strings.Join(keys, ",") {
+		t.Fatalf("参数错误: %v", keys)
+	}
+}
+
+func TestOnceInit(t *testing.T) {
+	type conn struct{ dsn string }
+	var calls atomic.Int32
+	trigger := NewTrigger().OnceInit("db.open", func(dsn string) (*conn, error) {
+		calls.Add(1)
+		time.Sleep(5 * time.Millisecond)
+		return &conn{dsn: dsn}, nil
+	})
+	if _, ok := trigger.InitResult("db.open"); ok {
+		t.Fatalf("触发前不应有初始化结果")
+	}
+
+	t.Log("测试等待初始化")
+	waited := make(chan []interface{}, 1)
+	go func() {
+		result, err := trigger.WaitFor(context.Background(), "db.open")
+		if nil != err {
+			t.Errorf("等待失败: %v", err)
+		}
+		waited <- result
+	}()
+
+	t.Log("测试并发触发只初始化一次")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			trigger.Emit("db.open", fmt.Sprintf("dsn-%d", i))
+		}(i)
+	}
+	wg.Wait()
+	result := <-waited
+	if 1 != calls.Load() || 2 != len(result) || nil != result[1] {
+		t.Fatalf("初始化错误: %d %v", calls.Load(), result)
+	}
+
+	t.Log("测试之后的触发与等待使用缓存的结果")
+	trigger.EmitSync("db.open", "other")
+	cached, ok := trigger.InitResult("db.open")
+	again, err := trigger.WaitFor(context.Background(), "db.open")
+	if !ok || 1 != calls.Load() || nil != err || cached[0] != result[0] || again[0] != result[0] {
+		t.Fatalf("缓存结果错误: %v %v %v", cached, again, err)
+	}
+
+	t.Log("测试初始化panic后不再执行")
+	var panics []error
+	trigger.RecoverWith(func(event, listener interface{}, err error) {
+		panics = append(panics, err)
+	}).OnceInit("cache.warm", func() int {
+		calls.Add(1)
+		panic("预热失败")
+	})
+	trigger.EmitSync("cache.warm").EmitSync("cache.warm")
+	if warm, ok := trigger.InitResult("cache.warm"); !ok || 0 != warm[0] || 2 != calls.Load() || 1 != len(panics) {
+		t.Fatalf("panic后结果错误: %v %d %v", warm, calls.Load(), panics)
+	}
+}
+
+func TestBarrier(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+	block := func(started chan<- struct{}, release <-chan struct{}) func() {
+		return func() {
+			started <- struct{}{}
+			<-release
+		}
+	}
+	dbStarted, dbRelease := make(chan struct{}), make(chan struct{})
+	otherStarted, otherRelease := make(chan struct{}), make(chan struct{})
+	trigger := NewTrigger().
+		On("boot.db", block(dbStarted, dbRelease)).
+		On("metrics.flush", block(otherStarted, otherRelease)).
+		On("boot.http", func(phase string) { record(phase) })
+
+	// 预先创建, 之后此事件的屏障只等待此事件的触发
+	if err := trigger.Barrier("boot.db").Wait(context.Background()); nil != err {
+		t.Fatalf("屏障未通过: %v", err)
+	}
+	go trigger.Emit("boot.db")
+	go trigger.Emit("metrics.flush")
+	<-dbStarted
+	<-otherStarted
+
+	t.Log("测试屏障之前的触发未结束时不分发")
+	barrier := trigger.Barrier("boot.db")
+	all := trigger.Barrier()
+	trigger.EmitAfterBarrier(barrier, "boot.http", "listen").EmitAfterBarrier(barrier, "boot.http", "serve")
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	if 0 != len(order) {
+		t.Fatalf("屏障未通过时不应分发: %v", order)
+	}
+	mu.Unlock()
+
+	t.Log("测试指定事件的屏障不等待其他事件")
+	close(dbRelease)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := barrier.Wait(ctx); nil != err {
+		t.Fatalf("屏障未通过: %v", err)
+	}
+	select {
+	case <-all.Done():
+		t.Fatalf("全局屏障不应在其他事件结束前通过")
+	default:
+	}
+
+	t.Log("测试屏障通过后按调用顺序分发")
+	close(otherRelease)
+	if err := all.Wait(ctx); nil != err {
+		t.Fatalf("全局屏障未通过: %v", err)
+	}
+	if err := trigger.WaitIdle(ctx); nil != err {
+		t.Fatalf("等待失败: %v", err)
+	}
+	trigger.EmitAfterBarrier(barrier, "boot.http", "ready")
+	mu.Lock()
+	defer mu.Unlock()
+	if "listen,serve,ready" != strings.Join(order, ",") {
+		t.Fatalf("分发顺序错误: %v", order)
+	}
+}
+
+func TestAppPhases(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		steps []string
+	)
+	step := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			steps = append(steps, name)
+			return nil
+		}
+	}
+	var errs []error
+	trigger := NewTrigger().RecoverWith(func(event, listener interface{}, err error) {
+		errs = append(errs, err)
+	})
+	trigger.AddComponent("http", step("start http"), step("stop http"), "db", "cache").
+		AddComponent("cache", step("start cache"), step("stop cache"), "db").
+		AddComponent("db", step("start db"), func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+	t.Log("测试按依赖顺序启动")
+	if err := trigger.StartApp(context.Background(), time.Second); nil != err {
+		t.Fatalf("启动失败: %v", err)
+	}
+	if "start db,start cache,start http" != strings.Join(steps, ",") {
+		t.Fatalf("启动顺序错误: %v", steps)
+	}
+
+	t.Log("测试按相反顺序关闭并报告阻塞关闭的组件")
+	steps = nil
+	err := trigger.StopApp(context.Background(), 20*time.Millisecond)
+	var phaseErr *PhaseError
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &phaseErr) || "db" != phaseErr.Component || AppStopEvent != phaseErr.Phase || !errors.As(err, &timeoutErr) || 1 != len(errs) {
+		t.Fatalf("关闭错误: %v %v", err, errs)
+	}
+	if "stop http,stop cache" != strings.Join(steps, ",") {
+		t.Fatalf("关闭顺序错误: %v", steps)
+	}
+
+	t.Log("测试启动失败时停止启动")
+	steps = nil
+	failed := errors.New("连接失败")
+	trigger.AddComponent("cache", func(ctx context.Context) error { return failed }, nil, "db")
+	if err := trigger.StartApp(context.Background(), 0); !errors.Is(err, failed) || !errors.As(err, &phaseErr) || "cache" != phaseErr.Component {
+		t.Fatalf("启动失败未报告: %v", err)
+	}
+	if "start db" != strings.Join(steps, ",") {
+		t.Fatalf("失败后不应继续启动: %v", steps)
+	}
+}
@@ -0,0 +1,260 @@
+// triggertest 提供测试监听代码与触发器本身的工具, 如模糊测试与并发压力测试
+package triggertest
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 默认单步操作超时时间, 超时视为死锁
+const defaultStepTimeout = 5 * time.Second
+
+// 重入触发的最大深度, 每层最多有最大监听数量的回调, 调用次数随深度指数增长
+const maxReentry = 1
+
+// 单步操作超时
+var ErrDeadlock = errors.New("操作超时, 可能发生死锁")
+
+// 操作类型
+const (
+	opOn = iota
+	opOnce
+	opOff
+	opTryOff
+	opEmit
+	opEmitSync
+	opOnNamed
+	opOffNamed
+	opCount
+)
+
+// 操作名称
+var opNames = [opCount]string{"On", "Once", "Off", "TryOff", "Emit", "EmitSync", "OnNamed", "OffNamed"}
+
+// 模糊测试的执行失败
+type Failure struct {
+	// 失败的步骤序号, 从0开始
+	Step int
+	// 已执行的操作记录, 最后一条为失败的操作
+	Trace []string
+	// 逃逸出的panic, 超时时为nil
+	Panic interface{}
+	// 超时等错误
+	Err error
+	// 失败时的协程堆栈, 超时时包含所有协程
+	Stack string
+}
+
+// 失败描述
+func (f *Failure) Error() string {
+	cause := fmt.Sprintf("panic: %v", f.Panic)
+	if nil != f.Err {
+		cause = f.Err.Error()
+	}
+	return fmt.Sprintf("第%d步操作失败: %s\n操作记录:\n  %s\n%s", f.Step, cause, strings.Join(f.Trace, "\n  "), f.Stack)
+}
+
+// 解包错误
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+// 模糊测试驱动器, 将任意字节解码为注册/触发/移除操作序列
+type Harness struct {
+	// 被测试的触发器
+	Trigger *trigger.Trigger
+	// 可选的事件类型
+	Events []interface{}
+	// 可选的回调函数
+	Listeners []interface{}
+	// 单步操作超时时间
+	StepTimeout time.Duration
+}
+
+//***************************************************
+//Description : 创建模糊测试驱动器
+//param :       被测试的触发器
+//param :       可选的回调函数, 为空时使用一组覆盖常见签名的回调函数
+//return :      模糊测试驱动器
+//***************************************************
+func NewHarness(t *trigger.Trigger, listeners ...interface{}) *Harness {
+	h := &Harness{
+		Trigger:     t,
+		Events:      []interface{}{"a", "b", "c"},
+		Listeners:   listeners,
+		StepTimeout: defaultStepTimeout,
+	}
+	if 0 == len(h.Listeners) {
+		h.Listeners = defaultListeners(t, h.Events)
+	}
+	return h
+}
+
+//***************************************************
+//Description : 执行一组操作
+//param :       任意字节输入
+//return :      逃逸出的panic或超时时返回*Failure
+//***************************************************
+func (h *Harness) Run(data []byte) error {
+	in := &input{data: data}
+	var trace []string
+	for step := 0; !in.done(); step++ {
+		op, desc := h.decode(in)
+		trace = append(trace, desc)
+		if failure := h.step(op); nil != failure {
+			failure.Step = step
+			failure.Trace = trace
+			return failure
+		}
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 在超时保护下执行单步操作
+//param :       操作
+//return :      失败时返回*Failure
+//***************************************************
+func (h *Harness) step(op func()) *Failure {
+	done := make(chan *Failure, 1)
+	go func() {
+		defer func() {
+			if r := recover(); nil != r {
+				buf := make([]byte, 64<<10)
+				done <- &Failure{Panic: r, Stack: string(buf[:runtime.Stack(buf, false)])}
+				return
+			}
+			done <- nil
+		}()
+		op()
+	}()
+
+	timer := time.NewTimer(h.StepTimeout)
+	defer timer.Stop()
+	select {
+	case failure := <-done:
+		return failure
+	case <-timer.C:
+		buf := make([]byte, 1<<20)
+		return &Failure{Err: ErrDeadlock, Stack: string(buf[:runtime.Stack(buf, true)])}
+	}
+}
+
+//***************************************************
+//Description : 解码一个操作
+//param :       字节输入
+//return :      操作
+//return :      操作描述
+//***************************************************
+func (h *Harness) decode(in *input) (func(), string) {
+	kind := int(in.next()) % opCount
+	event := h.Events[int(in.next())%len(h.Events)]
+	index := int(in.next()) % len(h.Listeners)
+	listener := h.Listeners[index]
+	desc := fmt.Sprintf("%s(%v, listener#%d)", opNames[kind], event, index)
+	t := h.Trigger
+
+	switch kind {
+	case opOn:
+		return func() { t.On(event, listener) }, desc
+	case opOnce:
+		return func() { t.Once(event, listener) }, desc
+	case opOff:
+		return func() { t.Off(event, listener) }, desc
+	case opTryOff:
+		return func() { t.TryOff(event, listener) }, desc
+	case opOnNamed, opOffNamed:
+		key := fmt.Sprintf("key%d", in.next()%4)
+		if opOnNamed == kind {
+			return func() { t.OnNamed(event, key, listener) }, fmt.Sprintf("OnNamed(%v, %s, listener#%d)", event, key, index)
+		}
+		return func() { t.OffNamed(event, key) }, fmt.Sprintf("OffNamed(%v, %s)", event, key)
+	}
+
+	arguments := in.arguments()
+	desc = fmt.Sprintf("%s(%v, %#v)", opNames[kind], event, arguments)
+	if opEmit == kind {
+		return func() { t.Emit(event, arguments...) }, desc
+	}
+	return func() { t.EmitSync(event, arguments...) }, desc
+}
+
+//***************************************************
+//Description : 覆盖常见签名的回调函数, 包括返回错误, panic与重入触发
+//param :       触发器
+//param :       重入触发的事件类型
+//return :      回调函数数组
+//***************************************************
+func defaultListeners(t *trigger.Trigger, events []interface{}) []interface{} {
+	var depth int32
+	return []interface{}{
+		func() {},
+		func(int) {},
+		func(string, int) {},
+		func(...interface{}) {},
+		func(*trigger.Bag, int) {},
+		func(int) error { return errors.New("listener error") },
+		func(string) { panic("listener panic") },
+		func(n int) {
+			// 重入触发其他事件, 限制深度避免无限递归
+			if atomic.AddInt32(&depth, 1) <= maxReentry {
+				t.EmitSync(events[(n%len(events)+len(events))%len(events)], n+1)
+			}
+			atomic.AddInt32(&depth, -1)
+		},
+	}
+}
+
+// 字节输入, 读完后返回0
+type input struct {
+	data []byte
+	pos  int
+}
+
+// 是否已读完
+func (in *input) done() bool {
+	return in.pos >= len(in.data)
+}
+
+// 读取一个字节
+func (in *input) next() byte {
+	if in.done() {
+		return 0
+	}
+	b := in.data[in.pos]
+	in.pos++
+	return b
+}
+
+//***************************************************
+//Description : 解码触发参数, 参数类型包括int, string, nil, float64与bool
+//return :      参数数组
+//***************************************************
+func (in *input) arguments() []interface{} {
+	arguments := make([]interface{}, int(in.next())%4)
+	for i := range arguments {
+		switch in.next() % 5 {
+		case 0:
+			arguments[i] = int(int8(in.next()))
+		case 1:
+			var sb strings.Builder
+			for n := in.next() % 8; n > 0; n-- {
+				sb.WriteByte('a' + in.next()%26)
+			}
+			arguments[i] = sb.String()
+		case 2:
+			arguments[i] = nil
+		case 3:
+			arguments[i] = float64(int8(in.next())) / 4
+		default:
+			arguments[i] = 0 != in.next()%2
+		}
+	}
+	return arguments
+}
@@ -0,0 +1,65 @@
+package triggertest
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 静默的错误处理
+func quiet(event interface{}, listener interface{}, err error) {}
+
+func FuzzHarness(f *testing.F) {
+	f.Add([]byte{0, 0, 1, 4, 0, 1, 0, 5})
+	f.Add([]byte{1, 1, 7, 5, 1, 1, 0, 3, 2, 1, 7})
+	f.Add([]byte{6, 2, 3, 1, 4, 2, 1, 3, 1, 2, 1, 2, 7, 2, 0, 1})
+	f.Add([]byte{0, 0, 6, 0, 1, 6, 5, 0, 2, 1, 3, 1, 0, 9})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := NewHarness(trigger.NewTrigger().RecoverWith(quiet)).Run(data); nil != err {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestHarnessFailure(t *testing.T) {
+	t.Log("测试panic逃逸")
+	harness := NewHarness(trigger.NewTrigger().WithPanicPolicy(trigger.PanicPropagate), func(string) { panic("boom") })
+	var failure *Failure
+	if err := harness.Run([]byte{0, 0, 0, 5, 0, 1, 1, 1, 0}); !errors.As(err, &failure) || 1 != failure.Step || 2 != len(failure.Trace) {
+		t.Fatalf("未报告panic: %v", err)
+	}
+
+	t.Log("测试死锁")
+	block := make(chan struct{})
+	defer close(block)
+	harness = NewHarness(trigger.NewTrigger(), func() { <-block })
+	harness.StepTimeout = 10 * time.Millisecond
+	if err := harness.Run([]byte{0, 0, 0, 5, 0, 0}); !errors.Is(err, ErrDeadlock) {
+		t.Fatalf("未报告死锁: %v", err)
+	}
+}
+
+func TestStressTest(t *testing.T) {
+	t.Log("测试默认配置的并发压力")
+	StressTest(t, StressOptions{
+		New:        func() *trigger.Trigger { return trigger.NewTrigger().RecoverWith(quiet) },
+		Operations: 200,
+	})
+
+	t.Log("测试自定义监听的并发压力")
+	var calls int64
+	StressTest(t, StressOptions{
+		New:        func() *trigger.Trigger { return trigger.NewTrigger().RecoverWith(quiet) },
+		Setup:      func(tr *trigger.Trigger) { tr.On("a", func() { atomic.AddInt64(&calls, 1) }) },
+		Listeners:  []interface{}{func(...interface{}) {}},
+		Goroutines: 4,
+		Operations: 200,
+		Seed:       1,
+	})
+	if 0 == atomic.LoadInt64(&calls) {
+		t.Fatalf("准备工作注册的监听未执行")
+	}
+}
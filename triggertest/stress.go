@@ -0,0 +1,188 @@
+package triggertest
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 每个协程保留的操作记录数量
+const stressTraceSize = 32
+
+// 并发压力测试配置
+type StressOptions struct {
+	// 创建触发器, 默认为trigger.NewTrigger
+	New func() *trigger.Trigger
+	// 准备工作, 如注册自己的监听
+	Setup func(t *trigger.Trigger)
+	// 可选的事件类型, 默认为"a", "b", "c"
+	Events []interface{}
+	// 并发注册与移除的回调函数, 默认为一组覆盖常见签名的回调函数
+	Listeners []interface{}
+	// 生成触发参数, 默认随机生成int, string, nil, float64与bool参数
+	Arguments func(r *rand.Rand, event interface{}) []interface{}
+	// 并发协程数量, 默认为8
+	Goroutines int
+	// 每个协程的操作次数, 默认为1000
+	Operations int
+	// 随机种子, 为0时使用当前时间, 失败时输出用于复现
+	Seed int64
+	// 超时时间, 超时视为死锁, 默认为30秒
+	Timeout time.Duration
+}
+
+//***************************************************
+//Description : 并发压力测试, 多个协程同时执行On/Off/Once/Emit/EmitSync
+//              配合-race使用, 用于发现监听代码与触发器之间的数据竞争, 死锁与逃逸的panic
+//param :       测试对象
+//param :       压力测试配置
+//***************************************************
+func StressTest(t testing.TB, opts StressOptions) {
+	t.Helper()
+	opts = opts.withDefaults()
+	t.Logf("StressTest seed=%d goroutines=%d operations=%d", opts.Seed, opts.Goroutines, opts.Operations)
+
+	tr := opts.New()
+	if nil != opts.Setup {
+		opts.Setup(tr)
+	}
+	listeners := opts.Listeners
+	if 0 == len(listeners) {
+		listeners = defaultListeners(tr, opts.Events)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures []string
+	)
+	for g := 0; g < opts.Goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			if failure := opts.hammer(tr, listeners, g); nil != failure {
+				mu.Lock()
+				failures = append(failures, fmt.Sprintf("协程%d: %v", g, failure))
+				mu.Unlock()
+			}
+		}(g)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(opts.Timeout):
+		buf := make([]byte, 1<<20)
+		t.Fatalf("%v\n复现: StressOptions.Seed = %d\n%s", ErrDeadlock, opts.Seed, buf[:runtime.Stack(buf, true)])
+	}
+
+	for _, failure := range failures {
+		t.Error(failure)
+	}
+	if 0 != len(failures) {
+		t.Fatalf("复现: StressOptions.Seed = %d", opts.Seed)
+	}
+}
+
+//***************************************************
+//Description : 单个协程执行随机操作
+//param :       触发器
+//param :       回调函数数组
+//param :       协程序号, 与随机种子共同决定操作序列
+//return :      逃逸出panic时返回*Failure
+//***************************************************
+func (opts StressOptions) hammer(tr *trigger.Trigger, listeners []interface{}, g int) (failure *Failure) {
+	r := rand.New(rand.NewSource(opts.Seed + int64(g)))
+	trace := make([]string, 0, stressTraceSize)
+
+	step := 0
+	defer func() {
+		if p := recover(); nil != p {
+			buf := make([]byte, 64<<10)
+			failure = &Failure{Step: step, Trace: trace, Panic: p, Stack: string(buf[:runtime.Stack(buf, false)])}
+		}
+	}()
+
+	for ; step < opts.Operations; step++ {
+		event := opts.Events[r.Intn(len(opts.Events))]
+		index := r.Intn(len(listeners))
+		listener := listeners[index]
+
+		var desc string
+		switch r.Intn(5) {
+		case 0:
+			desc = fmt.Sprintf("On(%v, listener#%d)", event, index)
+			tr.On(event, listener)
+		case 1:
+			desc = fmt.Sprintf("Off(%v, listener#%d)", event, index)
+			tr.Off(event, listener)
+		case 2:
+			desc = fmt.Sprintf("Once(%v, listener#%d)", event, index)
+			tr.Once(event, listener)
+		case 3:
+			arguments := opts.Arguments(r, event)
+			desc = fmt.Sprintf("Emit(%v, %#v)", event, arguments)
+			tr.Emit(event, arguments...)
+		default:
+			arguments := opts.Arguments(r, event)
+			desc = fmt.Sprintf("EmitSync(%v, %#v)", event, arguments)
+			tr.EmitSync(event, arguments...)
+		}
+
+		if len(trace) == stressTraceSize {
+			trace = append(trace[:0], trace[1:]...)
+		}
+		trace = append(trace, desc)
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 填充默认配置
+//return :      填充后的配置
+//***************************************************
+func (opts StressOptions) withDefaults() StressOptions {
+	if nil == opts.New {
+		opts.New = trigger.NewTrigger
+	}
+	if 0 == len(opts.Events) {
+		opts.Events = []interface{}{"a", "b", "c"}
+	}
+	if nil == opts.Arguments {
+		opts.Arguments = randomArguments
+	}
+	if opts.Goroutines < 1 {
+		opts.Goroutines = 8
+	}
+	if opts.Operations < 1 {
+		opts.Operations = 1000
+	}
+	if 0 == opts.Seed {
+		opts.Seed = time.Now().UnixNano()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	return opts
+}
+
+//***************************************************
+//Description : 随机生成触发参数, 与模糊测试的参数解码规则一致
+//param :       随机数生成器
+//param :       事件类型
+//return :      参数数组
+//***************************************************
+func randomArguments(r *rand.Rand, event interface{}) []interface{} {
+	data := make([]byte, 32)
+	r.Read(data)
+	return (&input{data: data}).arguments()
+}
@@ -0,0 +1,64 @@
+package trigger
+
+import (
+	"time"
+)
+
+// 触发或监听调用超过截止时间被丢弃, 参数为Expiry
+const ExpiredEvent = "trigger.expired"
+
+// 被丢弃的过期触发
+type Expiry struct {
+	// 事件类型
+	Event interface{}
+	// 被跳过的监听回调函数, 为nil表示整个触发被丢弃
+	Listener interface{}
+	// 回调函数中的参数
+	Arguments []interface{}
+	// 截止时间
+	Deadline time.Time
+}
+
+//***************************************************
+//Description : 触发带有效期的事件, 开始分发, 协程开始执行监听或降级缓存补发时已过期则丢弃并触发ExpiredEvent
+//              开启继承时监听中发起的触发沿用此截止时间
+//param :       有效期
+//param :       事件类型
+//param :       回调函数中的参数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) EmitTTL(ttl time.Duration, event interface{}, arguments ...interface{}) *Trigger {
+	return trigger.EmitWith(Lineage{Deadline: time.Now().Add(ttl)}, event, arguments...)
+}
+
+//***************************************************
+//Description : 是否已超过截止时间
+//param :       截止时间, 零值表示不限
+//return :      是否过期
+//***************************************************
+func expired(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+//***************************************************
+//Description : 截止时间
+//return :      没有继承属性时为零值
+//***************************************************
+func (lineage *Lineage) deadline() time.Time {
+	if nil == lineage {
+		return time.Time{}
+	}
+	return lineage.Deadline
+}
+
+//***************************************************
+//Description : 报告过期, 元事件不继承当前的继承属性
+//param :       被丢弃的过期触发
+//***************************************************
+func (trigger *Trigger) reportExpired(expiry Expiry) {
+	if isMetaEvent(expiry.Event) {
+		return
+	}
+	defer trigger.enterLineage(nil)()
+	trigger.Emit(ExpiredEvent, expiry)
+}
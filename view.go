@@ -0,0 +1,79 @@
+package trigger
+
+import "context"
+
+// 触发器的只读视图, 只能订阅事件, 不能触发事件或修改配置, 用于不受信任的组件
+type View interface {
+	// 添加监听, 同Trigger.On
+	On(event, listener interface{}) View
+	// 删除监听, 同Trigger.Off
+	Off(event, listener interface{}) View
+	// 等待事件, 同Trigger.WaitFor
+	WaitFor(ctx context.Context, event interface{}) ([]interface{}, error)
+}
+
+// 只读视图的实现, 不暴露触发器本身
+type readOnlyView struct {
+	trigger *Trigger
+}
+
+//***************************************************
+//Description : 获取触发器的只读视图
+//return :      只读视图
+//***************************************************
+func (trigger *Trigger) ReadOnlyView() View {
+	return readOnlyView{trigger: trigger}
+}
+
+// 调用的Trigger.On
+func (view readOnlyView) On(event, listener interface{}) View {
+	view.trigger.On(event, listener)
+	return view
+}
+
+// 调用的Trigger.Off
+func (view readOnlyView) Off(event, listener interface{}) View {
+	view.trigger.Off(event, listener)
+	return view
+}
+
+// 调用的Trigger.WaitFor
+func (view readOnlyView) WaitFor(ctx context.Context, event interface{}) ([]interface{}, error) {
+	return view.trigger.WaitFor(ctx, event)
+}
+
+//***************************************************
+//Description : 阻塞等待事件的下一次触发
+//              添加了OnceInit的事件等待初始化结束, 返回初始化函数的返回值
+//param :       上下文, 取消或超时后停止等待
+//param :       事件类型
+//return :      本次触发的参数
+//return :      上下文结束时返回包装ctx.Err()的TimeoutError
+//***************************************************
+func (trigger *Trigger) WaitFor(ctx context.Context, event interface{}) ([]interface{}, error) {
+	if state := trigger.initOf(event); nil != state {
+		return state.wait(ctx, event)
+	}
+
+	received := make(chan []interface{}, 1)
+	listener := func(arguments ...interface{}) {
+		select {
+		case received <- arguments:
+		default:
+		}
+	}
+
+	// 同一函数字面量的闭包函数指针相同, 因此按监听者移除, 避免误删其他等待者
+	h := &handler{}
+	trigger.register(event, listener, h)
+	defer trigger.removeMatching(event, false, func(other *handler) bool {
+		return other == h
+	})
+
+	select {
+	case arguments := <-received:
+		return arguments, nil
+	case <-ctx.Done():
+		return nil, &TimeoutError{Event: event, Listener: listener, Err: ctx.Err()}
+	}
+}
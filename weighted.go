@@ -0,0 +1,95 @@
+package trigger
+
+import (
+	"math/rand"
+)
+
+//***************************************************
+//Description : 以名称和版本添加带权重的监听, 用于灰度发布
+//              同名的带权重监听每次触发只按权重随机执行其中一个, 如95/5
+//              同名同版本的监听会被替换, 调用统计按版本区分, 见ListenerStats
+//param :       事件名称
+//param :       监听名称
+//param :       版本名称
+//param :       流量权重, 需大于0
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) AddWeightedListener(event interface{}, key, variant string, weight int, listener interface{}) *Trigger {
+	if weight <= 0 {
+		trigger.report(event, listener, &RegistrationError{Event: event, Listener: listener, Err: ErrInvalidWeight})
+		return trigger
+	}
+
+	return trigger.registerAt(event, listener, &handler{key: key, variant: variant, weight: weight}, func(handlers []*handler, h *handler) ([]*handler, []*handler) {
+		placed := make([]*handler, 0, len(handlers)+1)
+		var replaced []*handler
+		for _, old := range handlers {
+			if old.key == h.key && old.variant == h.variant {
+				replaced = append(replaced, old)
+				continue
+			}
+			placed = append(placed, old)
+		}
+		return append(placed, h), replaced
+	})
+}
+
+//***************************************************
+//Description : 调用的AddWeightedListener
+//param :       事件名称
+//param :       监听名称
+//param :       版本名称
+//param :       流量权重
+//param :       回调函数
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) OnWeighted(event interface{}, key, variant string, weight int, listener interface{}) *Trigger {
+	return trigger.AddWeightedListener(event, key, variant, weight, listener)
+}
+
+//***************************************************
+//Description : 同名的带权重监听按权重只保留一个, 没有带权重的监听时返回原数组
+//param :       监听者数组
+//return :      本次需要执行的监听者数组
+//***************************************************
+func selectVariants(handlers []*handler) []*handler {
+	// 各名称的总权重
+	var totals map[string]int
+	for _, h := range handlers {
+		if h.weight > 0 {
+			if nil == totals {
+				totals = make(map[string]int)
+			}
+			totals[h.key] += h.weight
+		}
+	}
+	if nil == totals {
+		return handlers
+	}
+
+	// 为每个名称抽取一个落点
+	points := make(map[string]int, len(totals))
+	for key, total := range totals {
+		points[key] = rand.Intn(total)
+	}
+
+	selected := make([]*handler, 0, len(handlers))
+	for _, h := range handlers {
+		if h.weight <= 0 {
+			selected = append(selected, h)
+			continue
+		}
+		point, ok := points[h.key]
+		if !ok {
+			continue
+		}
+		if point < h.weight {
+			selected = append(selected, h)
+			delete(points, h.key)
+		} else {
+			points[h.key] = point - h.weight
+		}
+	}
+	return selected
+}
@@ -0,0 +1,233 @@
+package trigger
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// 聚合窗口的命名监听前缀
+const windowKeyPrefix = "window:"
+
+// 聚合窗口配置
+type Window struct {
+	// 汇总事件名称, 如"metrics.request.1m", 同时作为窗口名称
+	Name string
+	// 被聚合的事件
+	Event interface{}
+	// 聚合的数值字段, 作用于第一个参数, 规则同Condition.Field, 为空表示只计数
+	Field string
+	// 窗口长度
+	Size time.Duration
+	// 滑动步长, 为0或不小于Size时为滚动窗口
+	Slide time.Duration
+}
+
+// 窗口汇总, 作为汇总事件的参数
+type Summary struct {
+	// 汇总事件名称
+	Name string
+	// 被聚合的事件
+	Event interface{}
+	// 聚合的字段
+	Field string
+	// 窗口开始时间
+	Start time.Time
+	// 窗口结束时间
+	End time.Time
+	// 事件次数
+	Count int
+	// 取到数值字段的次数
+	Values int
+	// 数值之和
+	Sum float64
+	// 数值平均值, 没有数值时为0
+	Avg float64
+	// 数值最小值, 没有数值时为0
+	Min float64
+	// 数值最大值, 没有数值时为0
+	Max float64
+}
+
+// 窗口中的一个步长
+type bucket struct {
+	count  int
+	values int
+	sum    float64
+	min    float64
+	max    float64
+}
+
+// 运行中的聚合窗口
+type aggregator struct {
+	// 配置
+	window Window
+	// 保护buckets与current
+	mu sync.Mutex
+	// 按步长划分的桶, 滚动窗口只有一个桶
+	buckets []bucket
+	// 当前写入的桶
+	current int
+	// 停止通道
+	stop chan struct{}
+}
+
+//***************************************************
+//Description : 添加聚合窗口, 每个步长触发一次汇总事件, 参数为Summary
+//              同名窗口会替换之前的窗口, 触发器关闭时停止
+//param :       窗口配置
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) Aggregate(window Window) *Trigger {
+	if "" == window.Name || nil == window.Event || window.Size <= 0 || window.Slide < 0 {
+		err := fmt.Errorf("%w: 窗口[%s]需要名称, 事件与大于0的长度", ErrInvalidWindow, window.Name)
+		trigger.report(window.Event, nil, &RegistrationError{Event: window.Event, Err: err})
+		return trigger
+	}
+	if 0 == window.Slide || window.Slide > window.Size {
+		window.Slide = window.Size
+	}
+
+	a := &aggregator{
+		window:  window,
+		buckets: make([]bucket, int(math.Ceil(float64(window.Size)/float64(window.Slide)))),
+		stop:    make(chan struct{}),
+	}
+
+	trigger.Lock()
+	if previous := trigger.aggregators[window.Name]; nil != previous {
+		close(previous.stop)
+	}
+	if nil == trigger.aggregators {
+		trigger.aggregators = make(map[string]*aggregator)
+	}
+	trigger.aggregators[window.Name] = a
+	trigger.Unlock()
+
+	trigger.ReplaceListener(window.Event, windowKeyPrefix+window.Name, func(arguments ...interface{}) {
+		a.record(arguments)
+	})
+	go trigger.runAggregator(a)
+	return trigger
+}
+
+//***************************************************
+//Description : 停止聚合窗口, 不再触发汇总事件
+//param :       窗口名称
+//return :      事件触发器
+//***************************************************
+func (trigger *Trigger) StopAggregate(name string) *Trigger {
+	trigger.Lock()
+	a := trigger.aggregators[name]
+	delete(trigger.aggregators, name)
+	trigger.Unlock()
+
+	if nil != a {
+		close(a.stop)
+		trigger.RemoveNamedListener(a.window.Event, windowKeyPrefix+name)
+	}
+	return trigger
+}
+
+//***************************************************
+//Description : 停止所有聚合窗口, 用于关闭触发器
+//***************************************************
+func (trigger *Trigger) stopAggregators() {
+	trigger.Lock()
+	aggregators := trigger.aggregators
+	trigger.aggregators = nil
+	trigger.Unlock()
+
+	for _, a := range aggregators {
+		close(a.stop)
+	}
+}
+
+//***************************************************
+//Description : 按步长触发汇总事件
+//param :       聚合窗口
+//***************************************************
+func (trigger *Trigger) runAggregator(a *aggregator) {
+	ticker := time.NewTicker(a.window.Slide)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case now := <-ticker.C:
+			trigger.Emit(a.window.Name, a.flush(now))
+		}
+	}
+}
+
+//***************************************************
+//Description : 记录一次事件
+//param :       回调函数中的参数
+//***************************************************
+func (a *aggregator) record(arguments []interface{}) {
+	var value float64
+	var numeric bool
+	if "" != a.window.Field && 0 != len(arguments) {
+		if field, ok := fieldValue(arguments[0], a.window.Field); ok {
+			value, numeric = toFloat(field)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	b := &a.buckets[a.current]
+	b.count++
+	if !numeric {
+		return
+	}
+	if 0 == b.values || value < b.min {
+		b.min = value
+	}
+	if 0 == b.values || value > b.max {
+		b.max = value
+	}
+	b.values++
+	b.sum += value
+}
+
+//***************************************************
+//Description : 汇总当前窗口并前进一个步长
+//param :       当前时间
+//return :      窗口汇总
+//***************************************************
+func (a *aggregator) flush(now time.Time) Summary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	summary := Summary{
+		Name:  a.window.Name,
+		Event: a.window.Event,
+		Field: a.window.Field,
+		Start: now.Add(-a.window.Size),
+		End:   now,
+	}
+	for _, b := range a.buckets {
+		summary.Count += b.count
+		if 0 == b.values {
+			continue
+		}
+		if 0 == summary.Values || b.min < summary.Min {
+			summary.Min = b.min
+		}
+		if 0 == summary.Values || b.max > summary.Max {
+			summary.Max = b.max
+		}
+		summary.Values += b.values
+		summary.Sum += b.sum
+	}
+	if 0 != summary.Values {
+		summary.Avg = summary.Sum / float64(summary.Values)
+	}
+
+	a.current = (a.current + 1) % len(a.buckets)
+	a.buckets[a.current] = bucket{}
+	return summary
+}
@@ -0,0 +1,161 @@
+package wsbridge
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// 批量信封的压缩方式
+const encodingGzip = "gzip"
+
+// 批量信封格式错误
+var ErrBadBatch = errors.New("批量信封格式错误")
+
+//***************************************************
+//Description : 发送一条消息, 开启批量发送时先收集队列中的后续消息一起发送
+//param :       已编码的信封
+//return :      写入失败的错误
+//***************************************************
+func (p *peer) write(data []byte) error {
+	if p.options.BatchSize <= 1 {
+		return p.conn.writeFrame(opText, data)
+	}
+	messages := p.collect(data)
+	if 1 == len(messages) && !p.options.Compress {
+		return p.conn.writeFrame(opText, data)
+	}
+	batch, err := encodeBatch(messages, p.options.Compress)
+	if nil != err {
+		return err
+	}
+	return p.conn.writeFrame(opText, batch)
+}
+
+//***************************************************
+//Description : 收集发送队列中的消息, 达到批量大小或等待超过BatchDelay时结束
+//param :       第一条消息
+//return :      按入队顺序排列的消息
+//***************************************************
+func (p *peer) collect(first []byte) [][]byte {
+	messages := [][]byte{first}
+	size := len(first)
+	var timeout <-chan time.Time
+	if p.options.BatchDelay > 0 {
+		timer := time.NewTimer(p.options.BatchDelay)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for len(messages) < p.options.BatchSize && size < maxMessageSize/2 {
+		if nil == timeout {
+			// 不等待, 只取队列中已有的消息
+			select {
+			case data := <-p.send:
+				messages = append(messages, data)
+				size += len(data)
+				continue
+			default:
+			}
+			return messages
+		}
+		select {
+		case data := <-p.send:
+			messages = append(messages, data)
+			size += len(data)
+		case <-timeout:
+			return messages
+		case <-p.done:
+			return messages
+		}
+	}
+	return messages
+}
+
+//***************************************************
+//Description : 编码批量信封
+//param :       已编码的信封
+//param :       是否压缩
+//return :      批量信封
+//return :      错误
+//***************************************************
+func encodeBatch(messages [][]byte, compress bool) ([]byte, error) {
+	raws := make([]json.RawMessage, len(messages))
+	for i, message := range messages {
+		raws[i] = message
+	}
+	if !compress {
+		return json.Marshal(Envelope{Type: TypeBatch, Batch: raws})
+	}
+
+	data, err := json.Marshal(raws)
+	if nil != err {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); nil != err {
+		return nil, err
+	}
+	if err := writer.Close(); nil != err {
+		return nil, err
+	}
+	return json.Marshal(Envelope{Type: TypeBatch, Encoding: encodingGzip, Data: buffer.Bytes()})
+}
+
+//***************************************************
+//Description : 解码批量信封
+//param :       批量信封
+//return :      按发送顺序排列的信封
+//return :      格式错误或解压后超出大小上限时的错误
+//***************************************************
+func decodeBatch(envelope Envelope) ([]Envelope, error) {
+	raws := envelope.Batch
+	switch envelope.Encoding {
+	case "":
+	case encodingGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(envelope.Data))
+		if nil != err {
+			return nil, ErrBadBatch
+		}
+		data, err := io.ReadAll(io.LimitReader(reader, maxMessageSize+1))
+		if nil != err {
+			return nil, ErrBadBatch
+		}
+		if len(data) > maxMessageSize {
+			return nil, ErrMessageTooLarge
+		}
+		if err := json.Unmarshal(data, &raws); nil != err {
+			return nil, ErrBadBatch
+		}
+	default:
+		return nil, ErrBadBatch
+	}
+
+	envelopes := make([]Envelope, len(raws))
+	for i, raw := range raws {
+		// 不允许嵌套
+		if err := json.Unmarshal(raw, &envelopes[i]); nil != err || TypeBatch == envelopes[i].Type {
+			return nil, ErrBadBatch
+		}
+	}
+	return envelopes, nil
+}
+
+//***************************************************
+//Description : 按顺序处理批量信封中的信封
+//param :       批量信封
+//***************************************************
+func (p *peer) unbatch(envelope Envelope) {
+	envelopes, err := decodeBatch(envelope)
+	if nil != err {
+		p.enqueue(Envelope{Type: TypeError, Error: err.Error()})
+		return
+	}
+	for _, e := range envelopes {
+		p.handle(e)
+	}
+}
@@ -0,0 +1,758 @@
+// wsbridge 通过websocket双向交换事件信封, 让浏览器与其他服务实时加入总线
+// 连接双方都可以订阅对方的事件与向对方触发事件, 收到的参数为json.RawMessage, 监听使用具体类型时需开启WithCoercion
+//
+// 线上协议: 每条websocket文本消息为一个JSON信封, 其他语言的客户端按此实现即可加入总线
+//
+//	{"type":"subscribe","event":"order.paid"}               订阅, 对应EventEmitter的on
+//	{"type":"unsubscribe","event":"order.paid"}             取消订阅, 对应removeListener
+//	{"type":"emit","event":"order.paid","args":[1,"a"]}     触发, 对应emit(event, ...args)
+//	{"type":"error","event":"order.paid","error":"没有权限"} 对方拒绝或无法处理请求
+//
+// 请求与回复: 触发的第一个参数为*trigger.ReplyAddress时提升为信封的reply_to与correlation_id, 不作为参数传输
+// 收到带这两个字段的触发时, 本地监听收到绑定本地触发器的*trigger.ReplyAddress作为第一个参数, 调用Reply即可回复
+//
+//	{"type":"emit","event":"price.query","args":["A1"],"reply_to":"trigger.reply.1","correlation_id":"1"}
+//	{"type":"emit","event":"trigger.reply.1","args":[100],"correlation_id":"1"}
+//
+// 截止时间: 触发带截止时间时写入信封的deadline字段, 为Unix毫秒, 接收方过期时丢弃并在本地触发trigger.ExpiredEvent
+// 转发订阅的事件时取自触发的继承属性, 需开启WithInheritance, Client.EmitWithAck与Client.Request取自上下文的截止时间
+//
+//	{"type":"emit","event":"price.query","args":["A1"],"deadline":1700000000000}
+//
+// 大参数: 配置Options.Blobs后编码超过阈值的参数存入大参数存储, 信封中替换为null, claims记录参数下标与引用
+// 接收方在本地触发前按引用取回, 监听收到的仍是原参数, 双方需共用同一存储
+//
+//	{"type":"emit","event":"report.ready","args":["r1",null],"claims":{"1":"9f86d0..."}}
+//
+// 批量发送: 配置Options.BatchSize后发送方把队列中的多个信封合并为一个批量信封, 接收方按原顺序逐个处理
+// 开启Options.Compress时batch数组编码后以gzip压缩, 存入data字段(base64), 接收方总能处理批量信封
+//
+//	{"type":"batch","batch":[{"type":"emit","event":"tick","args":[1]},{"type":"emit","event":"tick","args":[2]}]}
+//	{"type":"batch","encoding":"gzip","data":"H4sIAAAA..."}
+//
+// 路由: 配置Options.Router后转发订阅的事件与接收对方的触发都按路由表放行与改写, 规则按Options.Name匹配桥接
+// 配置Options.Node后每个信封的origin为发送方节点, 触发携带经过的节点, 防止网状拓扑中循环转发:
+// 接收方发现自己已在其中或超出Options.MaxHops时丢弃, 发送方不把已经过对方节点的触发再转发给对方
+// 转发时经过的节点取自触发的继承属性, 需开启WithInheritance
+//
+//	{"type":"emit","event":"order.paid","args":[1],"hops":["node-a","node-b"],"origin":"node-b"}
+//
+// 送达保证: 本地以WithDelivery声明至少一次的事件, 转发时带id与delivery, 未在等待时间内收到确认则重发
+// 接收方按id去重, 处理完毕后回复ack, 重发用尽仍未确认时在本地触发ErrorEvent
+//
+//	{"type":"emit","event":"order.paid","args":[1],"id":"9","delivery":"at-least-once"}
+//	{"type":"ack","id":"9","handled":true}
+//
+// 开启Options.NodeCompat后额外支持EventEmitter的语义:
+//
+//	{"type":"once","event":"order.paid"}                    只转发一次, 对应once
+//	{"type":"emit","event":"x","args":[],"id":"7"}          带id的触发会收到确认
+//	{"type":"ack","id":"7","handled":true}                  handled为是否有监听, 对应emit的返回值
+//
+// 以及error事件规则: 触发error事件而本地没有监听时, 回复error信封, 对应EventEmitter没有error监听时抛出异常
+package wsbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 信封类型
+const (
+	// 订阅对方的事件
+	TypeSubscribe = "subscribe"
+	// 取消订阅
+	TypeUnsubscribe = "unsubscribe"
+	// 触发事件, 或转发订阅的事件
+	TypeEmit = "emit"
+	// 对方的请求被拒绝或格式错误
+	TypeError = "error"
+	// 只转发一次的订阅, 仅NodeCompat模式
+	TypeOnce = "once"
+	// 带id的触发的确认, 仅NodeCompat模式
+	TypeAck = "ack"
+	// 批量信封, 按顺序包含多个信封
+	TypeBatch = "batch"
+)
+
+// EventEmitter中有特殊语义的error事件
+const nodeErrorEvent = "error"
+
+// 关闭状态码
+const (
+	closeNormal    = 1000
+	closeProtocol  = 1002
+	closePolicy    = 1008
+	closeTryLater  = 1013
+	defaultPing    = 30 * time.Second
+	defaultQueue   = 256
+	defaultBlob    = 64 << 10
+	namedKeyPrefix = "wsbridge:"
+	defaultName    = "wsbridge"
+	maxSeen        = 1024
+)
+
+// 对方返回错误信封时在本地触发的事件, 参数为连接ID, 事件名称与错误描述
+const ErrorEvent = "wsbridge.error"
+
+// 至少一次送达的信封标记
+const DeliveryAtLeastOnce = "at-least-once"
+
+// 发送队列已满
+var ErrQueueFull = errors.New("发送队列已满")
+
+// 线上传输的事件信封
+type Envelope struct {
+	// 信封类型
+	Type string `json:"type"`
+	// 事件名称
+	Event string `json:"event,omitempty"`
+	// 参数, 每个参数单独编码为JSON
+	Args []json.RawMessage `json:"args,omitempty"`
+	// 错误描述, 仅error类型
+	Error string `json:"error,omitempty"`
+	// 请求ID, 带id的触发会收到相同id的确认, 仅NodeCompat模式
+	ID string `json:"id,omitempty"`
+	// 触发时是否有监听, 仅ack类型
+	Handled bool `json:"handled,omitempty"`
+	// 回复事件, 仅请求触发
+	ReplyTo string `json:"reply_to,omitempty"`
+	// 关联ID, 请求与回复触发
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// 截止时间, Unix毫秒, 0表示没有
+	Deadline int64 `json:"deadline,omitempty"`
+	// 转存到大参数存储的参数下标 -> 引用
+	Claims map[int]string `json:"claims,omitempty"`
+	// 批量信封中的信封, 仅batch类型
+	Batch []json.RawMessage `json:"batch,omitempty"`
+	// 批量信封的压缩方式, 为空表示不压缩
+	Encoding string `json:"encoding,omitempty"`
+	// 压缩后的batch数组, 仅压缩的batch类型
+	Data []byte `json:"data,omitempty"`
+	// 经过的节点, 用于环路检测
+	Hops []string `json:"hops,omitempty"`
+	// 发送方节点
+	Origin string `json:"origin,omitempty"`
+	// 送达保证, 为DeliveryAtLeastOnce时接收方去重并确认
+	Delivery string `json:"delivery,omitempty"`
+}
+
+// 连接配置
+type Options struct {
+	// 心跳间隔, 超过两个间隔没有收到任何帧视为断开, 默认30秒
+	PingInterval time.Duration
+	// 每个连接的发送队列长度, 默认256
+	SendQueue int
+	// 发送队列满时丢弃消息, 默认为关闭连接
+	DropWhenFull bool
+	// 校验对方的订阅与触发请求, nil表示全部允许
+	Allow func(peer string, envelope Envelope) bool
+	// 服务端识别连接所属的用户, 返回错误时拒绝连接, nil表示不识别
+	Identify func(req *http.Request) (string, error)
+	// 服务端校验握手请求的Origin, 返回false时以403拒绝, nil表示只允许同源或没有Origin的请求
+	CheckOrigin func(req *http.Request) bool
+	// 兼容Node EventEmitter的语义, 见包文档
+	NodeCompat bool
+	// 大参数存储, nil表示不转存
+	Blobs trigger.BlobStore
+	// 编码后超过此字节数的参数转存, 默认64KB
+	BlobThreshold int
+	// 每个批量信封最多包含的信封数量, 小于等于1表示不合并
+	BatchSize int
+	// 收集批量信封的最长等待时间, 0表示只合并队列中已有的信封
+	BatchDelay time.Duration
+	// 以gzip压缩批量信封, 仅BatchSize大于1时有效
+	Compress bool
+	// 桥接名称, 路由规则按此名称匹配, 默认wsbridge
+	Name string
+	// 本节点名称, 为空表示不做环路检测
+	Node string
+	// 触发最多经过的节点数, 超出时丢弃, 0表示不限
+	MaxHops int
+	// 路由表, nil表示转发所有订阅的事件并接收所有触发
+	Router *trigger.Router
+}
+
+//***************************************************
+//Description : 填充默认配置
+//return :      填充后的配置
+//***************************************************
+func (options Options) withDefaults() Options {
+	if options.PingInterval <= 0 {
+		options.PingInterval = defaultPing
+	}
+	if options.SendQueue <= 0 {
+		options.SendQueue = defaultQueue
+	}
+	if options.BlobThreshold <= 0 {
+		options.BlobThreshold = defaultBlob
+	}
+	if "" == options.Name {
+		options.Name = defaultName
+	}
+	return options
+}
+
+//***************************************************
+//Description : 按路由表放行与改写事件
+//param :       方向
+//param :       事件名称
+//param :       参数
+//return :      改写后的事件名称
+//return :      改写后的参数
+//return :      是否放行
+//***************************************************
+func (options Options) route(direction, event string, arguments []interface{}) (string, []interface{}, bool) {
+	if nil == options.Router {
+		return event, arguments, true
+	}
+	return options.Router.Route(options.Name, direction, event, arguments)
+}
+
+//***************************************************
+//Description : 是否已经过本节点或超出最大跳数
+//param :       经过的节点
+//return :      是否形成环路
+//***************************************************
+func (options Options) looped(hops []string) bool {
+	if options.MaxHops > 0 && len(hops) > options.MaxHops {
+		return true
+	}
+	return "" != options.Node && contains(hops, options.Node)
+}
+
+//***************************************************
+//Description : 节点是否在经过的节点中
+//param :       经过的节点
+//param :       节点名称
+//return :      是否经过
+//***************************************************
+func contains(hops []string, node string) bool {
+	for _, hop := range hops {
+		if node == hop {
+			return true
+		}
+	}
+	return false
+}
+
+//***************************************************
+//Description : 追加本节点
+//param :       经过的节点
+//return :      追加后的节点
+//***************************************************
+func (options Options) via(hops []string) []string {
+	if "" == options.Node || options.looped(hops) {
+		return hops
+	}
+	return append(hops[:len(hops):len(hops)], options.Node)
+}
+
+//***************************************************
+//Description : 构造触发信封, 配置了大参数存储时转存超过阈值的参数
+//param :       本地触发器, 按其脱敏配置脱敏参数
+//param :       事件名称
+//param :       参数
+//return :      信封
+//return :      参数编码或转存失败的错误
+//***************************************************
+func (options Options) encode(t *trigger.Trigger, event string, arguments []interface{}) (Envelope, error) {
+	envelope, err := emitEnvelope(t, event, arguments)
+	if nil != err || nil == options.Blobs {
+		return envelope, err
+	}
+	if envelope.Claims, err = trigger.CheckIn(context.Background(), options.Blobs, options.BlobThreshold, envelope.Args); nil != err {
+		return Envelope{}, err
+	}
+	return envelope, nil
+}
+
+// 连接的一端, 服务端与客户端共用
+type peer struct {
+	// 连接ID, 同时用于命名监听与权限策略中的触发方
+	id string
+	// 连接所属的用户, 未识别用户时为空
+	user string
+	// websocket连接
+	conn *conn
+	// 本地触发器
+	trigger *trigger.Trigger
+	// 连接配置
+	options Options
+	// 待发送的消息
+	send chan []byte
+	// 保护subscriptions与seen
+	mu sync.Mutex
+	// 对方订阅的本地事件
+	subscriptions map[string]bool
+	// 对方只转发一次的订阅, 关闭时移除
+	onces []string
+	// 关闭时关闭
+	done chan struct{}
+	// 保证只关闭一次
+	closeOnce sync.Once
+	// 队列满时丢弃的消息数量
+	dropped atomic.Uint64
+	// 关闭时的回调
+	onClose func(*peer)
+	// 等待确认的触发, 请求ID -> 结果通道
+	pending sync.Map
+	// 请求序号
+	seq atomic.Uint64
+	// 对方节点, 从对方信封的origin得知
+	remote atomic.Pointer[string]
+	// 最近收到的至少一次送达的信封ID, 用于去重
+	seen map[string]bool
+	// seen中的ID, 按收到的顺序淘汰
+	seenOrder []string
+}
+
+//***************************************************
+//Description : 创建连接的一端, 调用start后开始收发
+//param :       连接ID
+//param :       websocket连接
+//param :       本地触发器
+//param :       连接配置
+//param :       关闭时的回调, 可以为nil
+//return :      连接的一端
+//***************************************************
+func newPeer(id string, c *conn, t *trigger.Trigger, options Options, onClose func(*peer)) *peer {
+	return &peer{
+		id:            id,
+		conn:          c,
+		trigger:       t,
+		options:       options,
+		send:          make(chan []byte, options.SendQueue),
+		subscriptions: make(map[string]bool),
+		done:          make(chan struct{}),
+		onClose:       onClose,
+	}
+}
+
+// 开始收发
+func (p *peer) start() {
+	go p.readLoop()
+	go p.writeLoop()
+}
+
+// 本地命名监听的名称
+func (p *peer) key() string {
+	return namedKeyPrefix + p.id
+}
+
+//***************************************************
+//Description : 关闭连接并移除对方订阅的所有监听
+//param :       状态码
+//***************************************************
+func (p *peer) close(code uint16) {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.conn.close(code)
+
+		p.mu.Lock()
+		events, onces := p.subscriptions, p.onces
+		p.subscriptions, p.onces = nil, nil
+		p.mu.Unlock()
+		for event := range events {
+			p.trigger.OffNamed(event, p.key())
+		}
+		for _, event := range onces {
+			p.trigger.OffNamed(event, p.key()+":once")
+		}
+		if nil != p.onClose {
+			p.onClose(p)
+		}
+	})
+}
+
+//***************************************************
+//Description : 发送信封, 队列满时按配置丢弃或关闭连接
+//param :       信封
+//return :      连接已关闭或队列已满时的错误
+//***************************************************
+func (p *peer) enqueue(envelope Envelope) error {
+	if "" != p.options.Node {
+		envelope.Origin = p.options.Node
+	}
+	data, err := json.Marshal(envelope)
+	if nil != err {
+		return err
+	}
+
+	select {
+	case <-p.done:
+		return ErrClosed
+	default:
+	}
+	select {
+	case p.send <- data:
+		return nil
+	default:
+	}
+
+	// 对方消费过慢
+	p.dropped.Add(1)
+	if !p.options.DropWhenFull {
+		go p.close(closeTryLater)
+	}
+	return ErrQueueFull
+}
+
+//***************************************************
+//Description : 将本地事件转发给对方
+//param :       事件名称
+//param :       回调函数中的参数
+//***************************************************
+func (p *peer) forward(event string, arguments []interface{}) {
+	// 已经过对方节点的触发不再转发给对方, 未开启继承时获取不到经过的节点
+	lineage, _ := p.trigger.Lineage()
+	if remote := p.remote.Load(); nil != remote && contains(lineage.Hops, *remote) {
+		return
+	}
+	policy := p.trigger.DeliveryOf(event)
+	event, arguments, ok := p.options.route(trigger.RouteOut, event, arguments)
+	if !ok {
+		return
+	}
+	envelope, err := p.options.encode(p.trigger, event, arguments)
+	if nil != err {
+		p.enqueue(Envelope{Type: TypeError, Event: event, Error: err.Error()})
+		return
+	}
+	// 传递触发的截止时间与经过的节点
+	envelope.withDeadline(lineage.Deadline)
+	envelope.Hops = p.options.via(lineage.Hops)
+	if trigger.AtLeastOnce != policy.Guarantee {
+		p.enqueue(envelope)
+		return
+	}
+
+	// 第一次发送在转发方协程中完成, 保持转发顺序, 之后在后台等待确认并重发
+	envelope.ID = strconv.FormatUint(p.seq.Add(1), 10)
+	envelope.Delivery = DeliveryAtLeastOnce
+	acked := make(chan bool, 1)
+	p.pending.Store(envelope.ID, acked)
+	if err := p.enqueue(envelope); errors.Is(err, ErrClosed) {
+		p.pending.Delete(envelope.ID)
+		return
+	}
+	go p.redeliver(envelope, acked, policy)
+}
+
+//***************************************************
+//Description : 等待确认, 超时未确认时重发, 用尽次数后在本地触发ErrorEvent
+//param :       已发送一次的信封
+//param :       确认通道
+//param :       送达策略
+//***************************************************
+func (p *peer) redeliver(envelope Envelope, acked chan bool, policy trigger.DeliveryPolicy) {
+	defer p.pending.Delete(envelope.ID)
+
+	timeout := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(timeout)
+		select {
+		case <-acked:
+			timer.Stop()
+			return
+		case <-p.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if attempt >= policy.MaxAttempts {
+			p.trigger.Emit(ErrorEvent, p.id, envelope.Event, "未收到确认")
+			return
+		}
+		if err := p.enqueue(envelope); errors.Is(err, ErrClosed) {
+			return
+		}
+		timeout *= 2
+	}
+}
+
+//***************************************************
+//Description : 记录至少一次送达的信封ID
+//param :       信封ID
+//return :      是否已经收到过
+//***************************************************
+func (p *peer) duplicate(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.seen[id] {
+		return true
+	}
+	if nil == p.seen {
+		p.seen = make(map[string]bool)
+	}
+	if len(p.seenOrder) >= maxSeen {
+		delete(p.seen, p.seenOrder[0])
+		p.seenOrder = p.seenOrder[1:]
+	}
+	p.seen[id] = true
+	p.seenOrder = append(p.seenOrder, id)
+	return false
+}
+
+//***************************************************
+//Description : 构造触发信封, 参数按触发器的结构体标签与脱敏函数脱敏
+//param :       本地触发器, nil时只按结构体标签脱敏
+//param :       事件名称
+//param :       参数
+//return :      信封
+//return :      参数编码失败的错误
+//***************************************************
+func emitEnvelope(t *trigger.Trigger, event string, arguments []interface{}) (Envelope, error) {
+	envelope := Envelope{Type: TypeEmit, Event: event}
+	// 回复地址提升为信封字段
+	if 0 != len(arguments) {
+		if address, ok := arguments[0].(*trigger.ReplyAddress); ok && nil != address {
+			envelope.ReplyTo, envelope.CorrelationID = address.ReplyTo, address.CorrelationID
+			arguments = arguments[1:]
+		}
+	}
+	arguments = t.RedactArguments(event, arguments)
+	envelope.Args = make([]json.RawMessage, len(arguments))
+	for i, argument := range arguments {
+		raw, err := json.Marshal(argument)
+		if nil != err {
+			return Envelope{}, fmt.Errorf("第%d个参数编码失败: %w", i+1, err)
+		}
+		envelope.Args[i] = raw
+	}
+	return envelope, nil
+}
+
+//***************************************************
+//Description : 设置信封的截止时间
+//param :       截止时间, 零值表示没有
+//***************************************************
+func (envelope *Envelope) withDeadline(deadline time.Time) {
+	if !deadline.IsZero() {
+		envelope.Deadline = deadline.UnixMilli()
+	}
+}
+
+//***************************************************
+//Description : 对方订阅本地事件
+//param :       事件名称
+//***************************************************
+func (p *peer) subscribe(event string) {
+	p.mu.Lock()
+	if nil == p.subscriptions || p.subscriptions[event] {
+		p.mu.Unlock()
+		return
+	}
+	p.subscriptions[event] = true
+	p.mu.Unlock()
+
+	// 命名监听按连接区分, 移除时不影响其他连接
+	p.trigger.OnNamed(event, p.key(), func(arguments ...interface{}) {
+		p.forward(event, arguments)
+	})
+}
+
+//***************************************************
+//Description : 对方取消订阅本地事件
+//param :       事件名称
+//***************************************************
+func (p *peer) unsubscribe(event string) {
+	p.mu.Lock()
+	subscribed := p.subscriptions[event]
+	delete(p.subscriptions, event)
+	p.mu.Unlock()
+
+	if subscribed {
+		p.trigger.OffNamed(event, p.key())
+	}
+}
+
+//***************************************************
+//Description : 处理对方发来的信封
+//param :       信封
+//***************************************************
+func (p *peer) handle(envelope Envelope) {
+	if "" != envelope.Origin {
+		if remote := p.remote.Load(); nil == remote || envelope.Origin != *remote {
+			origin := envelope.Origin
+			p.remote.Store(&origin)
+		}
+	}
+	if TypeBatch == envelope.Type {
+		p.unbatch(envelope)
+		return
+	}
+	if "" == envelope.Event && TypeError != envelope.Type && TypeAck != envelope.Type {
+		p.enqueue(Envelope{Type: TypeError, Error: "缺少事件名称"})
+		return
+	}
+	if TypeError != envelope.Type && TypeAck != envelope.Type && nil != p.options.Allow && !p.options.Allow(p.id, envelope) {
+		p.enqueue(Envelope{Type: TypeError, Event: envelope.Event, Error: "没有权限"})
+		return
+	}
+
+	switch envelope.Type {
+	case TypeSubscribe:
+		p.subscribe(envelope.Event)
+	case TypeUnsubscribe:
+		p.unsubscribe(envelope.Event)
+	case TypeEmit:
+		p.emit(envelope)
+	case TypeError:
+		p.trigger.Emit(ErrorEvent, p.id, envelope.Event, envelope.Error)
+	case TypeOnce:
+		if p.options.NodeCompat {
+			p.subscribeOnce(envelope.Event)
+			return
+		}
+		p.enqueue(Envelope{Type: TypeError, Event: envelope.Event, Error: "未开启NodeCompat"})
+	case TypeAck:
+		if result, ok := p.pending.LoadAndDelete(envelope.ID); ok {
+			result.(chan bool) <- envelope.Handled
+		}
+	default:
+		p.enqueue(Envelope{Type: TypeError, Event: envelope.Event, Error: "未知的信封类型" + strconv.Quote(envelope.Type)})
+	}
+}
+
+//***************************************************
+//Description : 在本地触发对方发来的事件
+//param :       触发信封
+//***************************************************
+func (p *peer) emit(envelope Envelope) {
+	// 已经过本节点的触发直接丢弃
+	if p.options.looped(envelope.Hops) {
+		return
+	}
+	// 重发的信封只回复确认, 不再触发
+	reliable := DeliveryAtLeastOnce == envelope.Delivery && "" != envelope.ID
+	if reliable && p.duplicate(envelope.ID) {
+		p.enqueue(Envelope{Type: TypeAck, ID: envelope.ID, Handled: true})
+		return
+	}
+	// 执行监听前取回转存的参数
+	if err := trigger.CheckOut(context.Background(), p.options.Blobs, envelope.Args, envelope.Claims); nil != err {
+		p.enqueue(Envelope{Type: TypeError, Event: envelope.Event, ID: envelope.ID, Error: err.Error()})
+		return
+	}
+
+	arguments := make([]interface{}, 0, len(envelope.Args)+1)
+	if "" != envelope.ReplyTo || "" != envelope.CorrelationID {
+		arguments = append(arguments, p.trigger.NewReplyAddress(envelope.ReplyTo, envelope.CorrelationID))
+	}
+	for _, arg := range envelope.Args {
+		arguments = append(arguments, arg)
+	}
+	event, arguments, ok := p.options.route(trigger.RouteIn, envelope.Event, arguments)
+	if !ok {
+		p.enqueue(Envelope{Type: TypeError, Event: envelope.Event, ID: envelope.ID, Error: "没有匹配的路由"})
+		return
+	}
+
+	handled := 0 != p.trigger.GetListenerCount(event)
+	if p.options.NodeCompat && nodeErrorEvent == event && !handled {
+		p.enqueue(Envelope{Type: TypeError, Event: envelope.Event, ID: envelope.ID, Error: "没有error事件的监听"})
+		return
+	}
+	hops := p.options.via(envelope.Hops)
+	if 0 != envelope.Deadline || 0 != len(hops) {
+		// 已过期的触发由触发器丢弃并报告
+		lineage := trigger.Lineage{Hops: hops}
+		if 0 != envelope.Deadline {
+			lineage.Deadline = time.UnixMilli(envelope.Deadline)
+		}
+		p.trigger.EmitSyncAsWith(p.key(), lineage, event, arguments...)
+	} else {
+		p.trigger.EmitSyncAs(p.key(), event, arguments...)
+	}
+
+	if (p.options.NodeCompat && "" != envelope.ID) || reliable {
+		p.enqueue(Envelope{Type: TypeAck, ID: envelope.ID, Handled: handled})
+	}
+}
+
+//***************************************************
+//Description : 对方订阅本地事件, 只转发一次
+//param :       事件名称
+//***************************************************
+func (p *peer) subscribeOnce(event string) {
+	key := p.key() + ":once"
+	var fired atomic.Bool
+	p.trigger.OnNamed(event, key, func(arguments ...interface{}) {
+		if fired.CompareAndSwap(false, true) {
+			p.forward(event, arguments)
+			// 移除发生在监听自身的回调中, 不能等待调用结束
+			go p.trigger.OffNamed(event, key)
+		}
+	})
+
+	p.mu.Lock()
+	if nil != p.subscriptions {
+		p.onces = append(p.onces, event)
+	}
+	p.mu.Unlock()
+}
+
+//***************************************************
+//Description : 读取循环, 收到任何帧都会延长读取期限
+//***************************************************
+func (p *peer) readLoop() {
+	code := uint16(closeNormal)
+	defer func() { p.close(code) }()
+
+	for {
+		p.conn.netConn.SetReadDeadline(time.Now().Add(2 * p.options.PingInterval))
+		message, err := p.conn.readMessage()
+		if nil != err {
+			if errors.Is(err, ErrMessageTooLarge) {
+				code = closePolicy
+			}
+			if errors.Is(err, ErrProtocol) {
+				code = closeProtocol
+			}
+			return
+		}
+
+		var envelope Envelope
+		if err := json.Unmarshal(message, &envelope); nil != err {
+			code = closeProtocol
+			return
+		}
+		p.handle(envelope)
+	}
+}
+
+//***************************************************
+//Description : 写入循环, 按心跳间隔发送ping
+//***************************************************
+func (p *peer) writeLoop() {
+	ticker := time.NewTicker(p.options.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case data := <-p.send:
+			if err := p.write(data); nil != err {
+				p.close(closeNormal)
+				return
+			}
+		case <-ticker.C:
+			if err := p.conn.writeFrame(opPing, nil); nil != err {
+				p.close(closeNormal)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,521 @@
+package wsbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+type news struct {
+	Title string `json:"title"`
+}
+
+// 等待条件成立
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("等待超时: %s", what)
+}
+
+func TestBridge(t *testing.T) {
+	orders := make(chan int, 1)
+	local := trigger.NewTrigger().WithCoercion(true).On("order", func(id int) { orders <- id })
+	server := NewServer(local, Options{
+		Allow: func(peer string, envelope Envelope) bool { return "secret" != envelope.Event },
+	})
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	received := make(chan news, 1)
+	errs := make(chan string, 1)
+	remote := trigger.NewTrigger().WithCoercion(true).
+		On("news", func(n news) { received <- n }).
+		On(ErrorEvent, func(peer, event, reason string) { errs <- event })
+	client, err := Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), remote, Options{}, nil)
+	if nil != err {
+		t.Fatalf("连接失败: %v", err)
+	}
+
+	t.Log("测试订阅服务端事件")
+	client.Subscribe("news")
+	eventually(t, "订阅生效", func() bool { return 1 == local.GetListenerCount("news") })
+	local.EmitSync("news", news{Title: "hello"})
+	if n := <-received; "hello" != n.Title {
+		t.Fatalf("收到的事件错误: %+v", n)
+	}
+
+	t.Log("测试向服务端触发事件")
+	client.Emit("order", 42)
+	if id := <-orders; 42 != id {
+		t.Fatalf("服务端收到的参数错误: %d", id)
+	}
+
+	t.Log("测试权限校验")
+	client.Subscribe("secret")
+	if event := <-errs; "secret" != event {
+		t.Fatalf("拒绝的事件错误: %s", event)
+	}
+
+	t.Log("测试关闭连接")
+	remote.RegisterHealthCheck("wsbridge", client.Check)
+	if report := remote.Health(); trigger.StatusUp != report.Status {
+		t.Fatalf("连接正常时健康报告错误: %+v", report)
+	}
+	client.Close()
+	eventually(t, "服务端移除连接", func() bool { return 0 == server.Connections() && 0 == local.GetListenerCount("news") })
+	if err := client.Emit("order", 1); !errors.Is(err, ErrClosed) {
+		t.Fatalf("关闭后发送应返回ErrClosed: %v", err)
+	}
+	if report := remote.Health(); trigger.StatusDown != report.Status || trigger.StatusDown != report.Checks["wsbridge"].Status {
+		t.Fatalf("连接关闭后健康报告错误: %+v", report)
+	}
+	server.Close()
+	if err := server.Check(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("服务端关闭后检查应失败: %v", err)
+	}
+	if _, err := Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), remote, Options{}, nil); nil == err {
+		t.Fatalf("服务端关闭后不应接受连接")
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	server := NewServer(trigger.NewTrigger(), Options{})
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http")
+
+	t.Log("测试默认只允许同源连接")
+	if _, err := Dial(url, trigger.NewTrigger(), Options{}, http.Header{"Origin": {"http://evil.example"}}); !errors.Is(err, ErrHandshake) {
+		t.Fatalf("跨域连接应被拒绝: %v", err)
+	}
+	client, err := Dial(url, trigger.NewTrigger(), Options{}, http.Header{"Origin": {httpServer.URL}})
+	if nil != err {
+		t.Fatalf("同源连接失败: %v", err)
+	}
+	client.Close()
+	allowed := NewServer(trigger.NewTrigger(), Options{CheckOrigin: func(req *http.Request) bool { return true }})
+	allowedServer := httptest.NewServer(allowed)
+	defer allowedServer.Close()
+	client, err = Dial("ws"+strings.TrimPrefix(allowedServer.URL, "http"), trigger.NewTrigger(), Options{}, http.Header{"Origin": {"http://evil.example"}})
+	if nil != err {
+		t.Fatalf("CheckOrigin放行的连接失败: %v", err)
+	}
+	client.Close()
+
+	frames := map[string][]byte{
+		"没有掩码的客户端帧":   {0x81, 0x02, '{', '}'},
+		"没有FIN的控制帧":   {0x09, 0x80, 0, 0, 0, 0},
+		"超过125字节的控制帧": append([]byte{0x89, 0x80 | 126, 0, 126, 0, 0, 0, 0}, make([]byte, 126)...),
+	}
+	for name, frame := range frames {
+		t.Log("测试" + name + "以1002关闭")
+		c, err := dial(url, time.Second, nil)
+		if nil != err {
+			t.Fatalf("连接失败: %v", err)
+		}
+		c.netConn.Write(frame)
+		c.netConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		final, opcode, payload, err := c.readFrame()
+		if nil != err || !final || opClose != opcode || 2 > len(payload) || closeProtocol != int(payload[0])<<8|int(payload[1]) {
+			t.Fatalf("%s的关闭帧错误: %v %x %v", name, opcode, payload, err)
+		}
+		c.netConn.Close()
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	p := &peer{send: make(chan []byte, 1), done: make(chan struct{}), options: Options{DropWhenFull: true}}
+	if err := p.enqueue(Envelope{Type: TypeEmit, Event: "a"}); nil != err {
+		t.Fatalf("入队失败: %v", err)
+	}
+	if err := p.enqueue(Envelope{Type: TypeEmit, Event: "a"}); !errors.Is(err, ErrQueueFull) || 1 != p.dropped.Load() {
+		t.Fatalf("队列满时应丢弃: %v", err)
+	}
+}
+
+func TestEmitToUser(t *testing.T) {
+	server := NewServer(trigger.NewTrigger(), Options{
+		Identify: func(req *http.Request) (string, error) {
+			if user := req.Header.Get("X-User"); "" != user {
+				return user, nil
+			}
+			return "", errors.New("未登录")
+		},
+	})
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http")
+
+	connect := func(user string, notes chan string) *Client {
+		local := trigger.NewTrigger().WithCoercion(true).On("notify", func(text string) { notes <- user + ":" + text })
+		client, err := Dial(url, local, Options{}, http.Header{"X-User": {user}})
+		if nil != err {
+			t.Fatalf("连接失败: %v", err)
+		}
+		return client
+	}
+
+	notes := make(chan string, 4)
+	alice1, alice2, bob := connect("alice", notes), connect("alice", notes), connect("bob", notes)
+	defer bob.Close()
+	eventually(t, "识别用户", func() bool { return 2 == server.UserConnections("alice") && 2 == len(server.Users()) })
+
+	t.Log("测试按用户推送")
+	if n, err := server.EmitToUser("alice", "notify", "hi"); nil != err || 2 != n {
+		t.Fatalf("推送数量错误: %d %v", n, err)
+	}
+	for i := 0; i < 2; i++ {
+		if note := <-notes; "alice:hi" != note {
+			t.Fatalf("推送给了错误的用户: %s", note)
+		}
+	}
+
+	t.Log("测试用户下线")
+	alice1.Close()
+	alice2.Close()
+	eventually(t, "用户下线", func() bool { return 0 == server.UserConnections("alice") })
+	if n, _ := server.EmitToUser("alice", "notify", "hi"); 0 != n {
+		t.Fatalf("下线用户不应收到推送")
+	}
+
+	if _, err := Dial(url, trigger.NewTrigger(), Options{}, nil); !errors.Is(err, ErrHandshake) {
+		t.Fatalf("未识别的用户应被拒绝: %v", err)
+	}
+}
+
+func TestNodeCompat(t *testing.T) {
+	local := trigger.NewTrigger().On("ping", func() {})
+	httpServer := httptest.NewServer(NewServer(local, Options{NodeCompat: true}))
+	defer httpServer.Close()
+
+	received := make(chan string, 2)
+	errs := make(chan string, 1)
+	remote := trigger.NewTrigger().WithCoercion(true).
+		On("tick", func(n int) { received <- "tick" }).
+		On(ErrorEvent, func(peer, event, reason string) { errs <- event })
+	client, err := Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), remote, Options{}, nil)
+	if nil != err {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer client.Close()
+
+	t.Log("测试emit返回值")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if handled, err := client.EmitWithAck(ctx, "ping"); nil != err || !handled {
+		t.Fatalf("有监听的事件确认错误: %v %v", handled, err)
+	}
+	if handled, err := client.EmitWithAck(ctx, "nobody"); nil != err || handled {
+		t.Fatalf("没有监听的事件确认错误: %v %v", handled, err)
+	}
+
+	t.Log("测试once")
+	client.Once("tick")
+	eventually(t, "once订阅生效", func() bool { return 1 == local.GetListenerCount("tick") })
+	local.EmitSync("tick", 1).EmitSync("tick", 2)
+	<-received
+	eventually(t, "once订阅移除", func() bool { return 0 == local.GetListenerCount("tick") })
+	if 0 != len(received) {
+		t.Fatalf("once订阅转发了多次")
+	}
+
+	t.Log("测试error事件规则")
+	client.Emit("error", "boom")
+	if event := <-errs; "error" != event {
+		t.Fatalf("没有监听的error事件应回复错误: %s", event)
+	}
+}
+
+func TestRequestReply(t *testing.T) {
+	local := trigger.NewTrigger().WithCoercion(true).On("price.query", func(reply *trigger.ReplyAddress, sku string) {
+		reply.Reply(context.Background(), sku, 100)
+	})
+	httpServer := httptest.NewServer(NewServer(local, Options{}))
+	defer httpServer.Close()
+
+	client, err := Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), trigger.NewTrigger(), Options{}, nil)
+	if nil != err {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer client.Close()
+
+	t.Log("测试跨桥接的请求与回复")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := client.Request(ctx, "price.query", "A1")
+	if nil != err || 2 != len(reply) || `"A1"` != string(reply[0]) || "100" != string(reply[1]) {
+		t.Fatalf("回复错误: %s %v", reply, err)
+	}
+
+	t.Log("测试回复地址提升为信封字段")
+	envelope, _ := emitEnvelope(nil, "x", []interface{}{local.NewReplyAddress("r", "7"), 1})
+	if "r" != envelope.ReplyTo || "7" != envelope.CorrelationID || 1 != len(envelope.Args) {
+		t.Fatalf("信封错误: %+v", envelope)
+	}
+
+	t.Log("测试信封中的参数脱敏")
+	type secret struct {
+		Token string `trigger:"redact"`
+	}
+	envelope, _ = emitEnvelope(nil, "x", []interface{}{map[string]secret{"a": {Token: "abc"}}})
+	if strings.Contains(string(envelope.Args[0]), "abc") {
+		t.Fatalf("信封参数未脱敏: %s", envelope.Args[0])
+	}
+
+	t.Log("测试信封参数经过触发器的脱敏函数")
+	masking := trigger.NewTrigger().WithRedactor(func(event interface{}, arguments []interface{}) []interface{} {
+		masked := append([]interface{}(nil), arguments...)
+		masked[0] = "138****0000"
+		return masked
+	})
+	envelope, _ = emitEnvelope(masking, "x", []interface{}{masking.NewReplyAddress("r", "8"), "13800000000", secret{Token: "abc"}})
+	if `"138****0000"` != string(envelope.Args[0]) || strings.Contains(string(envelope.Args[1]), "abc") {
+		t.Fatalf("信封参数未经过脱敏函数: %s %s", envelope.Args[0], envelope.Args[1])
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	orders := make(chan int, 1)
+	expired := make(chan string, 1)
+	local := trigger.NewTrigger().WithCoercion(true).WithInheritance(true).
+		On("order", func(id int) { orders <- id }).
+		On(trigger.ExpiredEvent, func(expiry trigger.Expiry) { expired <- expiry.Event.(string) })
+	httpServer := httptest.NewServer(NewServer(local, Options{}))
+	defer httpServer.Close()
+
+	deadlines := make(chan time.Time, 1)
+	remote := trigger.NewTrigger().WithInheritance(true)
+	remote.On("quote", func(price json.RawMessage) {
+		lineage, _ := remote.Lineage()
+		deadlines <- lineage.Deadline
+	})
+	client, err := Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), remote, Options{}, nil)
+	if nil != err {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer client.Close()
+
+	t.Log("测试转发的事件携带截止时间")
+	client.Subscribe("quote")
+	eventually(t, "订阅生效", func() bool { return 1 == local.GetListenerCount("quote") })
+	deadline := time.Now().Add(time.Hour)
+	local.EmitSyncWith(trigger.Lineage{Deadline: deadline}, "quote", 100)
+	if got := <-deadlines; !got.Equal(time.UnixMilli(deadline.UnixMilli())) {
+		t.Fatalf("截止时间错误: %v", got)
+	}
+
+	t.Log("测试接收方丢弃过期的触发")
+	client.peer.enqueue(Envelope{Type: TypeEmit, Event: "order", Args: []json.RawMessage{json.RawMessage("1")}, Deadline: time.Now().Add(-time.Second).UnixMilli()})
+	if event := <-expired; "order" != event {
+		t.Fatalf("过期的事件错误: %s", event)
+	}
+	client.peer.enqueue(Envelope{Type: TypeEmit, Event: "order", Args: []json.RawMessage{json.RawMessage("2")}, Deadline: time.Now().Add(time.Minute).UnixMilli()})
+	if id := <-orders; 2 != id {
+		t.Fatalf("未过期的触发错误: %d", id)
+	}
+}
+
+func TestBlobOffload(t *testing.T) {
+	store, err := trigger.NewFileBlobStore(t.TempDir())
+	if nil != err {
+		t.Fatalf("创建大参数存储失败: %v", err)
+	}
+	reports := make(chan string, 1)
+	local := trigger.NewTrigger().WithCoercion(true).On("report.ready", func(id, body string) { reports <- body })
+	options := Options{Blobs: store, BlobThreshold: 32}
+	httpServer := httptest.NewServer(NewServer(local, options))
+	defer httpServer.Close()
+
+	client, err := Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), trigger.NewTrigger(), options, nil)
+	if nil != err {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer client.Close()
+
+	t.Log("测试大参数转存后在执行监听前取回")
+	body := strings.Repeat("b", 100)
+	envelope, _ := options.withDefaults().encode(nil, "report.ready", []interface{}{"r1", body})
+	if 1 != len(envelope.Claims) || "null" != string(envelope.Args[1]) {
+		t.Fatalf("信封未转存: %+v", envelope)
+	}
+	client.Emit("report.ready", "r1", body)
+	if got := <-reports; body != got {
+		t.Fatalf("取回的参数错误: %s", got)
+	}
+}
+
+func TestBatching(t *testing.T) {
+	t.Log("测试批量信封编码与解码")
+	messages := [][]byte{[]byte(`{"type":"emit","event":"tick","args":[1]}`), []byte(`{"type":"emit","event":"tick","args":[2]}`)}
+	for _, compress := range []bool{false, true} {
+		data, err := encodeBatch(messages, compress)
+		if nil != err {
+			t.Fatalf("编码失败: %v", err)
+		}
+		var envelope Envelope
+		json.Unmarshal(data, &envelope)
+		envelopes, err := decodeBatch(envelope)
+		if nil != err || 2 != len(envelopes) || "2" != string(envelopes[1].Args[0]) {
+			t.Fatalf("解码错误: %v %+v", err, envelopes)
+		}
+	}
+	if _, err := decodeBatch(Envelope{Type: TypeBatch, Batch: []json.RawMessage{json.RawMessage(`{"type":"batch"}`)}}); !errors.Is(err, ErrBadBatch) {
+		t.Fatalf("嵌套批量信封未拒绝: %v", err)
+	}
+
+	t.Log("测试批量发送保持顺序")
+	local := trigger.NewTrigger()
+	httpServer := httptest.NewServer(NewServer(local, Options{BatchSize: 32, BatchDelay: 5 * time.Millisecond, Compress: true}))
+	defer httpServer.Close()
+
+	const total = 200
+	ticks := make(chan int, total)
+	remote := trigger.NewTrigger().WithCoercion(true).On("tick", func(n int) { ticks <- n })
+	client, err := Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), remote, Options{}, nil)
+	if nil != err {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer client.Close()
+	client.Subscribe("tick")
+	eventually(t, "订阅生效", func() bool { return 1 == local.GetListenerCount("tick") })
+	for i := 0; i < total; i++ {
+		local.EmitSync("tick", i)
+	}
+	for i := 0; i < total; i++ {
+		select {
+		case n := <-ticks:
+			if i != n {
+				t.Fatalf("第%d条顺序错误: %d", i, n)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("只收到%d条", i)
+		}
+	}
+}
+
+func TestRouting(t *testing.T) {
+	router, _ := trigger.NewRouter([]trigger.Route{
+		{Name: "in", Direction: trigger.RouteIn, Events: []string{"order.*"}, Rename: "remote.order"},
+	})
+	var hops []string
+	orders := make(chan int, 1)
+	local := trigger.NewTrigger().WithCoercion(true).WithInheritance(true)
+	local.On("remote.order", func(id int) {
+		lineage, _ := local.Lineage()
+		hops = lineage.Hops
+		orders <- id
+	})
+	p := &peer{id: "1", trigger: local, send: make(chan []byte, 4), done: make(chan struct{}), subscriptions: make(map[string]bool), options: Options{Node: "node-b", Router: router}.withDefaults()}
+
+	t.Log("测试接收时按路由改写并记录经过的节点")
+	p.emit(Envelope{Type: TypeEmit, Event: "order.paid", Args: []json.RawMessage{json.RawMessage("7")}, Hops: []string{"node-a"}})
+	if id := <-orders; 7 != id || 2 != len(hops) || "node-b" != hops[1] {
+		t.Fatalf("路由错误: %d %v", id, hops)
+	}
+
+	t.Log("测试拒绝没有路由的触发")
+	p.emit(Envelope{Type: TypeEmit, Event: "user.created"})
+	var envelope Envelope
+	json.Unmarshal(<-p.send, &envelope)
+	if TypeError != envelope.Type {
+		t.Fatalf("未回复错误: %+v", envelope)
+	}
+
+	t.Log("测试丢弃已经过本节点的触发")
+	p.emit(Envelope{Type: TypeEmit, Event: "order.paid", Args: []json.RawMessage{json.RawMessage("8")}, Hops: []string{"node-a", "node-b"}})
+	select {
+	case id := <-orders:
+		t.Fatalf("环路触发未丢弃: %d", id)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	t.Log("测试转发时追加本节点")
+	p.options.Router = nil
+	p.subscribe("loop")
+	local.EmitSyncWith(trigger.Lineage{Hops: []string{"node-a"}}, "loop", 1)
+	json.Unmarshal(<-p.send, &envelope)
+	if 2 != len(envelope.Hops) || "node-b" != envelope.Hops[1] {
+		t.Fatalf("转发的节点错误: %+v", envelope)
+	}
+}
+
+func TestLoopPrevention(t *testing.T) {
+	local := trigger.NewTrigger().WithInheritance(true)
+	p := &peer{id: "1", trigger: local, send: make(chan []byte, 4), done: make(chan struct{}), subscriptions: make(map[string]bool), options: Options{Node: "node-a", MaxHops: 3}.withDefaults()}
+
+	t.Log("测试从对方信封得知对方节点")
+	p.handle(Envelope{Type: TypeSubscribe, Event: "stock", Origin: "node-b"})
+	if remote := p.remote.Load(); nil == remote || "node-b" != *remote {
+		t.Fatalf("未记录对方节点")
+	}
+
+	t.Log("测试不把经过对方节点的触发转发回对方")
+	local.EmitSyncWith(trigger.Lineage{Hops: []string{"node-b", "node-a"}}, "stock", 1)
+	local.EmitSyncWith(trigger.Lineage{Hops: []string{"node-c", "node-a"}}, "stock", 2)
+	var envelope Envelope
+	json.Unmarshal(<-p.send, &envelope)
+	if "2" != string(envelope.Args[0]) || "node-a" != envelope.Origin || 0 != len(p.send) {
+		t.Fatalf("转发错误: %+v", envelope)
+	}
+
+	t.Log("测试丢弃超出最大跳数的触发")
+	received := 0
+	local.On("price", func(arguments ...interface{}) { received++ })
+	p.emit(Envelope{Type: TypeEmit, Event: "price", Hops: []string{"n1", "n2", "n3", "n4"}})
+	p.emit(Envelope{Type: TypeEmit, Event: "price", Hops: []string{"n1", "n2", "n3"}})
+	if 1 != received {
+		t.Fatalf("超出最大跳数未丢弃: %d", received)
+	}
+}
+
+func TestAtLeastOnce(t *testing.T) {
+	local := trigger.NewTrigger().WithDelivery("payment", trigger.DeliveryPolicy{Guarantee: trigger.AtLeastOnce, MaxAttempts: 2, Backoff: 10 * time.Millisecond})
+	failures := make(chan string, 1)
+	local.On(ErrorEvent, func(peer, event, reason string) { failures <- reason })
+	p := &peer{id: "1", trigger: local, send: make(chan []byte, 8), done: make(chan struct{}), subscriptions: make(map[string]bool), options: Options{}.withDefaults()}
+	p.subscribe("payment")
+
+	t.Log("测试未确认时重发")
+	local.EmitSync("payment", 1)
+	var first, second Envelope
+	json.Unmarshal(<-p.send, &first)
+	json.Unmarshal(<-p.send, &second)
+	if DeliveryAtLeastOnce != first.Delivery || "" == first.ID || first.ID != second.ID {
+		t.Fatalf("重发的信封错误: %+v %+v", first, second)
+	}
+	if reason := <-failures; "" == reason {
+		t.Fatalf("重发用尽后未报告")
+	}
+
+	t.Log("测试确认后不再重发")
+	local.EmitSync("payment", 2)
+	json.Unmarshal(<-p.send, &first)
+	p.handle(Envelope{Type: TypeAck, ID: first.ID, Handled: true})
+	select {
+	case data := <-p.send:
+		t.Fatalf("确认后仍在重发: %s", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	t.Log("测试接收方去重并确认")
+	received := 0
+	remote := trigger.NewTrigger().On("payment", func(arguments ...interface{}) { received++ })
+	r := &peer{id: "2", trigger: remote, send: make(chan []byte, 8), done: make(chan struct{}), options: Options{}.withDefaults()}
+	r.emit(first)
+	r.emit(first)
+	if 1 != received || 2 != len(r.send) {
+		t.Fatalf("去重错误: 触发%d次, 确认%d次", received, len(r.send))
+	}
+}
@@ -0,0 +1,196 @@
+package wsbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yann1989/trigger"
+)
+
+// 握手超时时间
+const dialTimeout = 10 * time.Second
+
+// websocket客户端, 订阅的远程事件在本地触发器上同步触发
+type Client struct {
+	peer *peer
+}
+
+//***************************************************
+//Description : 连接websocket服务端
+//param :       服务端地址, ws://或wss://
+//param :       本地触发器
+//param :       连接配置
+//param :       附加的请求头, 可以为nil
+//return :      客户端
+//return :      连接或握手失败的错误
+//***************************************************
+func Dial(url string, t *trigger.Trigger, options Options, header http.Header) (*Client, error) {
+	c, err := dial(url, dialTimeout, header)
+	if nil != err {
+		return nil, err
+	}
+	p := newPeer("client", c, t, options.withDefaults(), nil)
+	p.start()
+	return &Client{peer: p}, nil
+}
+
+//***************************************************
+//Description : 订阅远程事件, 收到后在本地触发
+//param :       事件名称
+//return :      连接已关闭或队列已满时的错误
+//***************************************************
+func (client *Client) Subscribe(event string) error {
+	return client.peer.enqueue(Envelope{Type: TypeSubscribe, Event: event})
+}
+
+//***************************************************
+//Description : 取消订阅远程事件
+//param :       事件名称
+//return :      连接已关闭或队列已满时的错误
+//***************************************************
+func (client *Client) Unsubscribe(event string) error {
+	return client.peer.enqueue(Envelope{Type: TypeUnsubscribe, Event: event})
+}
+
+//***************************************************
+//Description : 在远程触发事件
+//param :       事件名称
+//param :       参数, 编码为JSON
+//return :      参数编码或转存失败, 连接已关闭或队列已满时的错误
+//***************************************************
+func (client *Client) Emit(event string, arguments ...interface{}) error {
+	envelope, err := client.peer.options.encode(client.peer.trigger, event, arguments)
+	if nil != err {
+		return err
+	}
+	return client.peer.enqueue(envelope)
+}
+
+//***************************************************
+//Description : 只订阅一次远程事件, 需要服务端开启NodeCompat
+//param :       事件名称
+//return :      连接已关闭或队列已满时的错误
+//***************************************************
+func (client *Client) Once(event string) error {
+	return client.peer.enqueue(Envelope{Type: TypeOnce, Event: event})
+}
+
+//***************************************************
+//Description : 在远程触发事件并等待确认, 需要服务端开启NodeCompat
+//param :       上下文
+//param :       事件名称
+//param :       参数, 编码为JSON
+//return :      远程是否有此事件的监听, 同EventEmitter.emit的返回值
+//return :      发送失败或上下文结束时的错误
+//***************************************************
+func (client *Client) EmitWithAck(ctx context.Context, event string, arguments ...interface{}) (bool, error) {
+	envelope, err := client.peer.options.encode(client.peer.trigger, event, arguments)
+	if nil != err {
+		return false, err
+	}
+	envelope.ID = strconv.FormatUint(client.peer.seq.Add(1), 10)
+	if deadline, ok := ctx.Deadline(); ok {
+		envelope.withDeadline(deadline)
+	}
+
+	result := make(chan bool, 1)
+	client.peer.pending.Store(envelope.ID, result)
+	defer client.peer.pending.Delete(envelope.ID)
+	if err := client.peer.enqueue(envelope); nil != err {
+		return false, err
+	}
+
+	select {
+	case handled := <-result:
+		return handled, nil
+	case <-client.peer.done:
+		return false, ErrClosed
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+//***************************************************
+//Description : 在远程发起请求并等待第一个回复, 远程监听通过第一个参数*trigger.ReplyAddress的Reply回复
+//param :       上下文
+//param :       事件名称
+//param :       参数, 编码为JSON
+//return :      回复的参数
+//return :      发送失败或上下文结束时的错误
+//***************************************************
+func (client *Client) Request(ctx context.Context, event string, arguments ...interface{}) ([]json.RawMessage, error) {
+	id := fmt.Sprintf("%d-%d", time.Now().UnixNano(), client.peer.seq.Add(1))
+	replyTo := trigger.ReplyEventPrefix + id
+	envelope, err := client.peer.options.encode(client.peer.trigger, event, arguments)
+	if nil != err {
+		return nil, err
+	}
+	envelope.ReplyTo, envelope.CorrelationID = replyTo, id
+	if deadline, ok := ctx.Deadline(); ok {
+		envelope.withDeadline(deadline)
+	}
+
+	// 先订阅远程的回复事件, 同一连接上的信封按顺序处理, 因此回复不会早于订阅
+	replies := make(chan []json.RawMessage, 1)
+	client.peer.trigger.OnNamed(replyTo, namedKeyPrefix+"request", func(reply *trigger.ReplyAddress, arguments ...interface{}) {
+		raws := make([]json.RawMessage, 0, len(arguments))
+		for _, argument := range arguments {
+			if raw, ok := argument.(json.RawMessage); ok {
+				raws = append(raws, raw)
+			}
+		}
+		select {
+		case replies <- raws:
+		default:
+		}
+	})
+	defer client.peer.trigger.OffNamed(replyTo, namedKeyPrefix+"request")
+	if err := client.Subscribe(replyTo); nil != err {
+		return nil, err
+	}
+	defer client.Unsubscribe(replyTo)
+	if err := client.peer.enqueue(envelope); nil != err {
+		return nil, err
+	}
+
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-client.peer.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+//***************************************************
+//Description : 连接关闭时关闭的通道
+//return :      通道
+//***************************************************
+func (client *Client) Done() <-chan struct{} {
+	return client.peer.done
+}
+
+//***************************************************
+//Description : 健康检查, 用于trigger.RegisterHealthCheck
+//return :      连接已关闭时为ErrClosed
+//***************************************************
+func (client *Client) Check() error {
+	select {
+	case <-client.peer.done:
+		return ErrClosed
+	default:
+		return nil
+	}
+}
+
+//***************************************************
+//Description : 关闭连接
+//***************************************************
+func (client *Client) Close() {
+	client.peer.close(closeNormal)
+}
@@ -0,0 +1,368 @@
+package wsbridge
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 握手校验值使用的GUID, 见RFC 6455
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// 单条消息的大小上限
+const maxMessageSize = 1 << 20
+
+// 帧类型
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+var (
+	// 连接已关闭
+	ErrClosed = errors.New("连接已关闭")
+	// 握手失败
+	ErrHandshake = errors.New("websocket握手失败")
+	// 消息超出大小上限
+	ErrMessageTooLarge = errors.New("消息超出大小上限")
+	// 对方违反websocket协议, 如客户端帧没有掩码或控制帧过长
+	ErrProtocol = errors.New("websocket协议错误")
+)
+
+// 控制帧内容的长度上限, 见RFC 6455 5.5
+const maxControlSize = 125
+
+// 最小化的websocket连接, 只支持本包需要的文本消息与控制帧
+type conn struct {
+	// 底层连接
+	netConn net.Conn
+	// 带缓冲的读取
+	reader *bufio.Reader
+	// 客户端发送的帧需要掩码
+	client bool
+	// 保护写入
+	wmu sync.Mutex
+	// 是否已发送关闭帧
+	closeSent bool
+}
+
+//***************************************************
+//Description : 计算握手校验值
+//param :       客户端的Sec-WebSocket-Key
+//return :      Sec-WebSocket-Accept
+//***************************************************
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+//***************************************************
+//Description : 服务端升级HTTP连接
+//param :       响应
+//param :       请求
+//return :      websocket连接
+//return :      不是有效的websocket请求时返回包装ErrHandshake的错误, 并已写入400响应
+//***************************************************
+func upgrade(w http.ResponseWriter, req *http.Request) (*conn, error) {
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if http.MethodGet != req.Method ||
+		!headerContains(req.Header, "Connection", "upgrade") ||
+		!headerContains(req.Header, "Upgrade", "websocket") ||
+		"13" != req.Header.Get("Sec-WebSocket-Version") || "" == key {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, fmt.Errorf("%w: 不是websocket请求", ErrHandshake)
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("%w: 响应不支持Hijack", ErrHandshake)
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if nil != err {
+		return nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := netConn.Write([]byte(response)); nil != err {
+		netConn.Close()
+		return nil, err
+	}
+	return &conn{netConn: netConn, reader: rw.Reader}, nil
+}
+
+//***************************************************
+//Description : 客户端建立连接, 支持ws与wss
+//param :       websocket地址
+//param :       握手超时时间
+//param :       附加的请求头, 如认证信息
+//return :      websocket连接
+//return :      连接或握手失败的错误
+//***************************************************
+func dial(rawURL string, timeout time.Duration, header http.Header) (*conn, error) {
+	u, err := url.Parse(rawURL)
+	if nil != err {
+		return nil, err
+	}
+	host := u.Host
+	if "" == u.Port() {
+		if "wss" == u.Scheme {
+			host += ":443"
+		} else {
+			host += ":80"
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var netConn net.Conn
+	switch u.Scheme {
+	case "ws":
+		netConn, err = dialer.Dial("tcp", host)
+	case "wss":
+		netConn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("%w: 不支持的协议%q", ErrHandshake, u.Scheme)
+	}
+	if nil != err {
+		return nil, err
+	}
+	netConn.SetDeadline(time.Now().Add(timeout))
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); nil != err {
+		netConn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{Method: http.MethodGet, URL: u, Host: u.Host, Header: http.Header{}}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(netConn); nil != err {
+		netConn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(reader, req)
+	if nil != err {
+		netConn.Close()
+		return nil, err
+	}
+	if http.StatusSwitchingProtocols != resp.StatusCode || acceptKey(key) != resp.Header.Get("Sec-WebSocket-Accept") {
+		netConn.Close()
+		return nil, fmt.Errorf("%w: %s", ErrHandshake, resp.Status)
+	}
+
+	netConn.SetDeadline(time.Time{})
+	return &conn{netConn: netConn, reader: reader, client: true}, nil
+}
+
+//***************************************************
+//Description : 读取一条数据消息, 自动回复ping与close
+//return :      消息内容
+//return :      连接关闭时返回io.EOF
+//***************************************************
+func (c *conn) readMessage() ([]byte, error) {
+	var message []byte
+	for {
+		final, opcode, payload, err := c.readFrame()
+		if nil != err {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); nil != err {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.writeClose(payload)
+			return nil, io.EOF
+		}
+
+		message = append(message, payload...)
+		if len(message) > maxMessageSize {
+			return nil, ErrMessageTooLarge
+		}
+		if final {
+			return message, nil
+		}
+	}
+}
+
+//***************************************************
+//Description : 读取一帧
+//return :      是否为最后一帧
+//return :      帧类型
+//return :      帧内容
+//return :      读取失败的错误
+//***************************************************
+func (c *conn) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); nil != err {
+		return false, 0, nil, err
+	}
+	final := 0 != header[0]&0x80
+	opcode := header[0] & 0x0F
+	masked := 0 != header[1]&0x80
+	// 客户端发送的帧必须掩码, 服务端发送的帧不能掩码
+	if masked == c.client {
+		return false, 0, nil, fmt.Errorf("%w: 帧掩码错误", ErrProtocol)
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); nil != err {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); nil != err {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, ErrMessageTooLarge
+	}
+	// 控制帧不能分片且不超过125字节
+	if 0 != opcode&0x8 && (!final || length > maxControlSize) {
+		return false, 0, nil, fmt.Errorf("%w: 控制帧分片或过长", ErrProtocol)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); nil != err {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); nil != err {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return final, opcode, payload, nil
+}
+
+//***************************************************
+//Description : 写入一帧, 客户端帧使用掩码
+//param :       帧类型
+//param :       帧内容
+//return :      写入失败的错误
+//***************************************************
+func (c *conn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	if opClose == opcode {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|opcode)
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, maskBit|126, byte(length>>8), byte(length))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); nil != err {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	_, err := c.netConn.Write(frame)
+	return err
+}
+
+//***************************************************
+//Description : 发送关闭帧, 已发送过时忽略
+//param :       关闭帧内容, 为状态码与原因
+//***************************************************
+func (c *conn) writeClose(payload []byte) {
+	if len(payload) > 2 {
+		payload = payload[:2]
+	}
+	c.writeFrame(opClose, payload)
+}
+
+//***************************************************
+//Description : 发送关闭帧并关闭底层连接
+//param :       状态码, 见RFC 6455
+//return :      关闭失败的错误
+//***************************************************
+func (c *conn) close(code uint16) error {
+	c.writeClose([]byte{byte(code >> 8), byte(code)})
+	return c.netConn.Close()
+}
+
+//***************************************************
+//Description : 请求头中是否包含某个值, 忽略大小写
+//param :       请求头
+//param :       名称
+//param :       值
+//return :      是否包含
+//***************************************************
+func headerContains(header http.Header, name, value string) bool {
+	for _, field := range header.Values(name) {
+		for _, token := range strings.Split(field, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package wsbridge
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yann1989/trigger"
+)
+
+// websocket服务端, 每个连接可以订阅本地事件与向本地触发事件
+type Server struct {
+	// 本地触发器
+	trigger *trigger.Trigger
+	// 连接配置
+	options Options
+	// 连接序号
+	seq atomic.Uint64
+	// 保护peers
+	mu sync.Mutex
+	// 连接ID -> 连接
+	peers map[string]*peer
+	// 用户 -> 连接ID -> 连接
+	sessions map[string]map[string]*peer
+	// 是否已关闭
+	closed bool
+}
+
+//***************************************************
+//Description : 创建websocket服务端
+//param :       本地触发器
+//param :       连接配置
+//return :      服务端, 作为http.Handler挂载
+//***************************************************
+func NewServer(t *trigger.Trigger, options Options) *Server {
+	return &Server{
+		trigger:  t,
+		options:  options.withDefaults(),
+		peers:    make(map[string]*peer),
+		sessions: make(map[string]map[string]*peer),
+	}
+}
+
+//***************************************************
+//Description : 升级为websocket连接
+//param :       响应
+//param :       请求
+//***************************************************
+func (server *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var user string
+	if nil != server.options.Identify {
+		var err error
+		if user, err = server.options.Identify(req); nil != err {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	checkOrigin := server.options.CheckOrigin
+	if nil == checkOrigin {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(req) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if err := server.Check(); nil != err {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	c, err := upgrade(w, req)
+	if nil != err {
+		return
+	}
+
+	id := strconv.FormatUint(server.seq.Add(1), 10)
+	p := newPeer(id, c, server.trigger, server.options, server.remove)
+	p.user = user
+	server.mu.Lock()
+	server.peers[id] = p
+	if "" != user {
+		if nil == server.sessions[user] {
+			server.sessions[user] = make(map[string]*peer)
+		}
+		server.sessions[user][id] = p
+	}
+	server.mu.Unlock()
+	p.start()
+}
+
+//***************************************************
+//Description : 只向指定用户的所有连接推送事件, 不需要对方订阅
+//param :       用户, 由Options.Identify识别
+//param :       事件名称
+//param :       参数, 编码为JSON
+//return :      成功放入发送队列的连接数量
+//return :      参数编码或转存失败的错误
+//***************************************************
+func (server *Server) EmitToUser(user, event string, arguments ...interface{}) (int, error) {
+	envelope, err := server.options.encode(server.trigger, event, arguments)
+	if nil != err {
+		return 0, err
+	}
+
+	server.mu.Lock()
+	peers := make([]*peer, 0, len(server.sessions[user]))
+	for _, p := range server.sessions[user] {
+		peers = append(peers, p)
+	}
+	server.mu.Unlock()
+
+	delivered := 0
+	for _, p := range peers {
+		if nil == p.enqueue(envelope) {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+//***************************************************
+//Description : 获取在线的用户
+//return :      用户数组
+//***************************************************
+func (server *Server) Users() []string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	users := make([]string, 0, len(server.sessions))
+	for user := range server.sessions {
+		users = append(users, user)
+	}
+	return users
+}
+
+//***************************************************
+//Description : 获取用户的连接数量
+//param :       用户
+//return :      连接数量
+//***************************************************
+func (server *Server) UserConnections(user string) int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return len(server.sessions[user])
+}
+
+//***************************************************
+//Description : 当前连接数量
+//return :      连接数量
+//***************************************************
+func (server *Server) Connections() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return len(server.peers)
+}
+
+//***************************************************
+//Description : 健康检查, 用于trigger.RegisterHealthCheck
+//return :      已关闭时为ErrClosed
+//***************************************************
+func (server *Server) Check() error {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+//***************************************************
+//Description : 关闭所有连接
+//***************************************************
+func (server *Server) Close() {
+	server.mu.Lock()
+	server.closed = true
+	peers := make([]*peer, 0, len(server.peers))
+	for _, p := range server.peers {
+		peers = append(peers, p)
+	}
+	server.mu.Unlock()
+
+	for _, p := range peers {
+		p.close(closeNormal)
+	}
+}
+
+//***************************************************
+//Description : 连接关闭后移除
+//param :       连接
+//***************************************************
+func (server *Server) remove(p *peer) {
+	server.mu.Lock()
+	delete(server.peers, p.id)
+	if sessions := server.sessions[p.user]; nil != sessions {
+		delete(sessions, p.id)
+		if 0 == len(sessions) {
+			delete(server.sessions, p.user)
+		}
+	}
+	server.mu.Unlock()
+}
+
+//***************************************************
+//Description : 默认的Origin校验, 浏览器跨域发起的连接需显式配置CheckOrigin
+//param :       握手请求
+//return :      没有Origin或Origin的主机与请求的Host相同时为true
+//***************************************************
+func sameOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if "" == origin {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if nil != err {
+		return false
+	}
+	return strings.EqualFold(u.Host, req.Host)
+}